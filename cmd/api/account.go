@@ -0,0 +1,334 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/damarteplok/social/internal/mailer"
+	"github.com/damarteplok/social/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidPassword = errors.New("the password is not correct")
+
+// forgotPasswordHandler godoc
+//
+//	@Summary		Forgot password
+//	@Description	Email a password reset link, the response is the same whether the email is known or not
+//	@Tags			authentication
+//	@Accept			json
+//	@produce		json
+//	@Param			payload	body		ForgotPasswordPayload	true	"User email"
+//	@Success		202		{string}	string					"Reset link sent"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password/forgot [post]
+func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ForgotPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.store.Users.GetByEmail(ctx, payload.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			// do not tell which emails have an account
+			app.writeAccepted(w, r)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	plainToken, hashToken := newUserToken()
+	if err := app.store.Users.CreatePasswordReset(ctx, user.ID, hashToken, app.config.mail.resetExp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	vars := struct {
+		Username string
+		ResetURL string
+		ValidFor string
+	}{
+		Username: user.Username,
+		ResetURL: fmt.Sprintf("%s/reset-password/%s", app.config.frontendURL, plainToken),
+		ValidFor: app.config.mail.resetExp.String(),
+	}
+
+	isProdEnv := app.config.env == "production"
+	if _, err := app.mailer.Send(mailer.PasswordResetTemplate, user.Username, user.Email, vars, !isProdEnv); err != nil {
+		// an error would tell the email has an account
+		app.requestLogger(r).Errorw("error sending password reset email", "error", err)
+	}
+
+	app.writeAccepted(w, r)
+}
+
+// resetPasswordHandler godoc
+//
+//	@Summary		Reset password
+//	@Description	Set a new password with the token of a reset link, every session of the user is revoked
+//	@Tags			authentication
+//	@Accept			json
+//	@produce		json
+//	@Param			payload	body		ResetPasswordPayload	true	"Reset token and new password"
+//	@Success		204		{string}	string					"Password reset"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password/reset [post]
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResetPasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := &store.User{}
+	if err := user.Password.Set(payload.Password); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Users.ResetPassword(ctx, payload.Token, user); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.revokeAllSessions(ctx, user.ID, ""); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// changePasswordHandler godoc
+//
+//	@Summary		Change password
+//	@Description	Change the password of the current user, every other session of the user is revoked and a new one is started
+//	@Tags			authentication
+//	@Accept			json
+//	@produce		json
+//	@Param			payload	body		ChangePasswordPayload	true	"Current and new password"
+//	@Success		200		{object}	UserToken				"Password changed"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password [put]
+//	@Security		ApiKeyAuth
+func (app *application) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ChangePasswordPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.checkCurrentPassword(r, payload.Password)
+	if err != nil {
+		app.handlePasswordError(w, r, err)
+		return
+	}
+
+	if err := user.Password.Set(payload.NewPassword); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.store.Users.UpdatePassword(ctx, user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// the caller stays logged in with a new session, the one of the old
+	// password is revoked along with the others
+	sessionID := uuid.New().String()
+	if err := app.revokeAllSessions(ctx, user.ID, sessionID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	session, err := app.startSession(ctx, user, sessionID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, session); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// changeEmailHandler godoc
+//
+//	@Summary		Change email
+//	@Description	Email a confirmation link to the new address, the email changes once it is confirmed
+//	@Tags			authentication
+//	@Accept			json
+//	@produce		json
+//	@Param			payload	body		ChangeEmailPayload	true	"New email and current password"
+//	@Success		202		{string}	string				"Confirmation sent"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/email [post]
+//	@Security		ApiKeyAuth
+func (app *application) changeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ChangeEmailPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.checkCurrentPassword(r, payload.Password)
+	if err != nil {
+		app.handlePasswordError(w, r, err)
+		return
+	}
+
+	if _, err := app.store.Users.GetByEmail(ctx, payload.Email); err == nil {
+		app.badRequestResponse(w, r, store.ErrDuplicateEmail)
+		return
+	} else if !errors.Is(err, store.ErrNotFound) {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	plainToken, hashToken := newUserToken()
+	if err := app.store.Users.CreateEmailChange(ctx, user.ID, payload.Email, hashToken, app.config.mail.exp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	vars := struct {
+		Username        string
+		Email           string
+		ConfirmationURL string
+	}{
+		Username:        user.Username,
+		Email:           payload.Email,
+		ConfirmationURL: fmt.Sprintf("%s/confirm-email/%s", app.config.frontendURL, plainToken),
+	}
+
+	// the link goes to the new address, it proves the user owns it
+	isProdEnv := app.config.env == "production"
+	if _, err := app.mailer.Send(mailer.EmailChangeTemplate, user.Username, payload.Email, vars, !isProdEnv); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.writeAccepted(w, r)
+}
+
+// confirmEmailHandler godoc
+//
+//	@Summary		Confirm an email change
+//	@Description	Confirm an email change with the token of the confirmation link
+//	@Tags			authentication
+//	@produce		json
+//	@Param			token	path		string	true	"Confirmation token"
+//	@Success		204		{string}	string	"Email changed"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/email/confirm/{token} [put]
+func (app *application) confirmEmailHandler(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	ctx := r.Context()
+
+	user, err := app.store.Users.ChangeEmail(ctx, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrDuplicateEmail):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if app.config.redisCfg.enabled {
+		app.cacheStorage.Users.Delete(ctx, user.ID)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkCurrentPassword loads the current user from the database, the cached
+// user has no password hash.
+func (app *application) checkCurrentPassword(r *http.Request, password string) (*store.User, error) {
+	user, err := app.store.Users.GetByID(r.Context(), GetUserFromContext(r).ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := user.Password.Check(password); err != nil {
+		return nil, ErrInvalidPassword
+	}
+	return user, nil
+}
+
+func (app *application) handlePasswordError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidPassword):
+		app.badRequestResponse(w, r, err)
+	case errors.Is(err, store.ErrNotFound):
+		app.unauthorizedErrorResponse(w, r, err)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) writeAccepted(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusAccepted, nil); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// newUserToken returns a token for a link and its hash for user_invitations.
+func newUserToken() (string, string) {
+	plainToken := uuid.New().String()
+	hash := sha256.Sum256([]byte(plainToken))
+	return plainToken, hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/damarteplok/social/internal/auth"
+	"github.com/damarteplok/social/internal/mailer"
+	"github.com/damarteplok/social/internal/store"
+	"github.com/damarteplok/social/internal/store/cache"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAccount(t *testing.T) {
+	app := newTestApplication(t, config{
+		mail:        mailConfig{exp: time.Hour, resetExp: time.Hour},
+		frontendURL: "http://localhost:5173",
+		auth:        authConfig{token: tokenConfig{exp: time.Hour, refreshExp: time.Hour}},
+	})
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, body, token string) int {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should email a reset link", func(t *testing.T) {
+		code := request(t, http.MethodPost, "/v1/authentication/password/forgot", `{"email": "damar@test.com"}`, "")
+		checkResponseCode(t, http.StatusAccepted, code)
+
+		sent, ok := app.mailer.(*mailer.MockMailer).Last(mailer.PasswordResetTemplate)
+		if !ok {
+			t.Fatal("expected a password reset email")
+		}
+		if !strings.Contains(fmt.Sprint(sent.Data), "http://localhost:5173/reset-password/") {
+			t.Errorf("expected the reset url in the email got %+v", sent.Data)
+		}
+	})
+
+	t.Run("should revoke the sessions on reset", func(t *testing.T) {
+		refreshToken := "reset-refresh-token"
+		rt := &store.RefreshToken{SessionID: "reset-session", Expiry: time.Now().Add(time.Hour)}
+		if err := app.store.RefreshTokens.Create(context.Background(), refreshToken, rt); err != nil {
+			t.Fatal(err)
+		}
+
+		code := request(t, http.MethodPost, "/v1/authentication/password/reset", `{"token": "reset", "password": "new-secret"}`, "")
+		checkResponseCode(t, http.StatusNoContent, code)
+
+		_, err := app.store.RefreshTokens.Rotate(context.Background(), refreshToken, "next", time.Hour)
+		if err == nil {
+			t.Error("expected the refresh token to be revoked")
+		}
+	})
+
+	t.Run("should not change the password without the current one", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/authentication/password", strings.NewReader(`{"password": "wrong", "new_password": "new-secret"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+
+		var problem Problem
+		if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+			t.Fatal(err)
+		}
+		if problem.Code != codeInvalidPassword {
+			t.Errorf("expected the code %q got %q", codeInvalidPassword, problem.Code)
+		}
+	})
+}
+
+// passwordUserStore logs in a user with the password secret.
+type passwordUserStore struct {
+	*store.MockUserStore
+}
+
+func (s *passwordUserStore) GetByID(ctx context.Context, userID int64) (*store.User, error) {
+	user := &store.User{ID: 1, Email: "damar@test.com"}
+	if err := user.Password.Set("secret"); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *passwordUserStore) GetByEmailAndPassword(ctx context.Context, email, password string) (*store.User, error) {
+	return s.GetByID(ctx, 1)
+}
+
+func TestChangePassword(t *testing.T) {
+	app := newTestApplication(t, config{
+		redisCfg: redisConfig{enabled: true},
+		auth: authConfig{token: tokenConfig{
+			exp:        time.Hour,
+			iss:        "test-iss",
+			aud:        "test-aud",
+			refreshExp: time.Hour,
+		}},
+	})
+	app.authenticator = auth.NewJWTAuthenticator("test", "test-aud", "test-iss")
+	app.store.Users = &passwordUserStore{&store.MockUserStore{}}
+	mux := app.mount()
+
+	mockCacheStore := app.cacheStorage.Users.(*cache.MockUserStore)
+	mockCacheStore.On("Get", mock.Anything).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything).Return(nil)
+
+	getUser := func(t *testing.T, token string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, "/v1/authentication/user", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return executeRequest(req, mux).Code
+	}
+
+	var login UserToken
+	checkResponseCode(t, http.StatusCreated, postJSON(t, mux, "/v1/authentication/token", `{"email": "damar@test.com", "password": "secret"}`, "", &login))
+
+	t.Run("should keep the caller logged in with a new session", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/authentication/password", strings.NewReader(`{"password": "secret", "new_password": "new-secret"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+login.Token)
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var envelope struct {
+			Data UserToken `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&envelope); err != nil {
+			t.Fatal(err)
+		}
+		session := envelope.Data
+		if session.Token == "" || session.RefreshToken == "" {
+			t.Fatalf("expected an access and a refresh token got %+v", session)
+		}
+
+		checkResponseCode(t, http.StatusOK, getUser(t, session.Token))
+		checkResponseCode(t, http.StatusUnauthorized, getUser(t, login.Token))
+
+		if _, err := app.store.RefreshTokens.Rotate(context.Background(), login.RefreshToken, "next", time.Hour); err == nil {
+			t.Error("expected the refresh token of the old session to be revoked")
+		}
+		if _, err := app.store.RefreshTokens.Rotate(context.Background(), session.RefreshToken, "next", time.Hour); err != nil {
+			t.Errorf("expected the refresh token of the new session to rotate got %v", err)
+		}
+	})
+}
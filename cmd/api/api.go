@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"errors"
-	"expvar"
 	"fmt"
 	"net/http"
 	"os"
@@ -13,6 +12,7 @@ import (
 
 	"github.com/damarteplok/social/docs"
 	"github.com/damarteplok/social/internal/env"
+	"github.com/damarteplok/social/internal/metrics"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
@@ -24,30 +24,32 @@ func (app *application) mount() http.Handler {
 
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Logger)
+	r.Use(app.TracingMiddleware)
+	r.Use(app.MetricsMiddleware)
+	r.Use(app.LoggerMiddleware)
 	r.Use(middleware.Recoverer)
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   env.Envs.AllowedOrigin,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", idempotencyKeyHeader},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: false,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
-	r.Use(app.RateLimiterMiddleware)
 
-	r.Use(middleware.Timeout(60 * time.Second))
+	// the probes and the prometheus scrape skip the rate limiter
+	r.Get("/livez", app.livenessHandler)
+	r.Get("/readyz", app.readinessHandler)
+	r.Get("/.well-known/jwks.json", app.jwksHandler)
+	r.With(app.BasicAuthMiddleware()).Get("/metrics", metrics.Handler().ServeHTTP)
 
-	r.Route("/v1", func(r chi.Router) {
+	r.With(app.RateLimiterMiddleware, middleware.Timeout(60*time.Second)).Route("/v1", func(r chi.Router) {
 		r.Group(func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware)
 			r.Get("/health", app.healthCheckHandler)
 		})
 
-		r.With(app.BasicAuthMiddleware()).
-			Get("/debug/vars", expvar.Handler().ServeHTTP)
-
 		docsURL := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
 		r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(docsURL)))
 
@@ -55,9 +57,31 @@ func (app *application) mount() http.Handler {
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/user", app.registerUserHandler)
 			r.Post("/token", app.createTokenHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.Post("/activation/resend", app.resendActivationHandler)
+			r.Post("/password/forgot", app.forgotPasswordHandler)
+			r.Post("/password/reset", app.resetPasswordHandler)
+			r.Put("/email/confirm/{token}", app.confirmEmailHandler)
+			r.Post("/2fa/verify", app.verifyTwoFactorHandler)
+			r.Post("/2fa/enroll", app.enrollTwoFactorHandler)
+			r.Put("/2fa/enroll/confirm/{token}", app.confirmTwoFactorEnrollmentHandler)
+			if app.oidcProvider != nil {
+				r.Get("/oidc/authorize", app.oidcAuthorizeHandler)
+				r.Post("/oidc/token", app.oidcTokenHandler)
+			}
 			r.Group(func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
 				r.Get("/user", app.getTokenUserHandler)
+				r.Post("/logout", app.logoutHandler)
+				r.Post("/logout-all", app.logoutAllHandler)
+				r.Put("/password", app.changePasswordHandler)
+				r.Post("/email", app.changeEmailHandler)
+				r.Route("/2fa", func(r chi.Router) {
+					r.Post("/setup", app.setupTwoFactorHandler)
+					r.Post("/enable", app.enableTwoFactorHandler)
+					r.Post("/disable", app.disableTwoFactorHandler)
+					r.Post("/recovery-codes", app.regenerateRecoveryCodesHandler)
+				})
 			})
 		})
 
@@ -83,6 +107,8 @@ func (app *application) mount() http.Handler {
 				r.Get("/", app.getUserHandler)
 				r.Put("/follow", app.followUserHandler)
 				r.Put("/unfollow", app.unfollowUserHandler)
+				r.Delete("/sessions", app.revokeUserSessionsHandler)
+				r.Put("/activate", app.activateUserByAdminHandler)
 			})
 
 			r.Group(func(r chi.Router) {
@@ -91,12 +117,15 @@ func (app *application) mount() http.Handler {
 			})
 		})
 
+		r.Route("/roles/{roleName}", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Put("/two-factor", app.updateRoleTwoFactorHandler)
+		})
+
 		r.Route("/camunda", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware)
 			r.Route("/resource", func(r chi.Router) {
 				r.Post("/deploy", app.deployOnlyCamundaHandler)
-				r.Post("/crud", app.crudCamundaHandler)
-				r.Post("/deploy-crud", app.deployCamundaHandler)
 				r.Post("/{processDefinitionKey}/delete", app.deleteCamundaHandler)
 				r.Get("/{processDefinitionKey}/xml", app.xmlCamundaHandler)
 				r.Get("/operate/statistics", app.operateStatisticsHandler)
@@ -104,7 +133,7 @@ func (app *application) mount() http.Handler {
 			r.Route("/minio", func(r chi.Router) {
 				r.Post("/upload", app.uploadCamundaHandler)
 				r.Post("/upload-multiple", app.uploadMultipleCamundaHandler)
-				r.Post("/deploy-crud", app.getObjectFromMinioThanUseItHandler)
+				r.Post("/deploy", app.deployFromMinioCamundaHandler)
 			})
 			r.Route("/incident", func(r chi.Router) {
 				r.Route("/{incidentKey}", func(r chi.Router) {
@@ -142,14 +171,34 @@ func (app *application) mount() http.Handler {
 
 			// GENERATE USER TASK ROUTES API
 
-			r.Route("/approvingartikel", func(r chi.Router) {
+			r.Route("/pembuatan_media_berita_technology/tasks/approvingartikel", func(r chi.Router) {
+				r.Get("/", app.getUserTaskActiveApprovingArtikelHandler)
+				r.Get("/submissions", app.searchApprovingArtikelHandler)
+			})
+			r.Route("/approvingartikel/{taskKey}", func(r chi.Router) {
+				r.Post("/claim", app.claimApprovingArtikelHandler)
+				r.Post("/unclaim", app.unclaimApprovingArtikelHandler)
+				r.Post("/complete", app.completeApprovingArtikelHandler)
 			})
 
-			r.Route("/reviewingartikel", func(r chi.Router) {
+			r.Route("/pembuatan_media_berita_technology/tasks/reviewingartikel", func(r chi.Router) {
+				r.Get("/", app.getUserTaskActiveReviewingArtikelHandler)
+				r.Get("/submissions", app.searchReviewingArtikelHandler)
+			})
+			r.Route("/reviewingartikel/{taskKey}", func(r chi.Router) {
+				r.Post("/claim", app.claimReviewingArtikelHandler)
+				r.Post("/unclaim", app.unclaimReviewingArtikelHandler)
+				r.Post("/complete", app.completeReviewingArtikelHandler)
 			})
 
-			r.Route("/pembuatanartikel", func(r chi.Router) {
+			r.Route("/pembuatan_media_berita_technology/tasks/pembuatanartikel", func(r chi.Router) {
 				r.Get("/", app.getUserTaskActivePembuatanArtikelHandler)
+				r.Get("/submissions", app.searchPembuatanArtikelHandler)
+			})
+			r.Route("/pembuatanartikel/{taskKey}", func(r chi.Router) {
+				r.Post("/claim", app.claimPembuatanArtikelHandler)
+				r.Post("/unclaim", app.unclaimPembuatanArtikelHandler)
+				r.Post("/complete", app.completePembuatanArtikelHandler)
 			})
 		})
 	})
@@ -182,7 +231,23 @@ func (app *application) run(mux http.Handler) error {
 
 		app.logger.Infow("signal caught", "signal", s.String())
 
-		shutdown <- srv.Shutdown(ctx)
+		err := srv.Shutdown(ctx)
+
+		// stop polling jobs once no more requests are in flight
+		if app.service != nil {
+			app.service.Close()
+		}
+		if app.reconciler != nil {
+			app.reconciler.Close()
+		}
+		if app.outbox != nil {
+			app.outbox.Close()
+		}
+		if app.cleaner != nil {
+			app.cleaner.Close()
+		}
+
+		shutdown <- err
 	}()
 
 	app.logger.Infow("Server has started", "addr", app.config.addr, "env", app.config.env)
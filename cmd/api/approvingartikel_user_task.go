@@ -1,6 +1,231 @@
 package main
 
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/damarteplok/social/internal/store"
+)
+
 type FormDataApprovingArtikel struct {
+	Decision *string `json:"decision" validate:"required,oneof=publish archive"`
 }
 
-	
+// GetUserTaskActive ApprovingArtikel godoc
+//
+//	@Summary		GetUserTaskActive ApprovingArtikel
+//	@Description	GetUserTaskActive ApprovingArtikel
+//	@Tags			bpmn/ApprovingArtikel
+//	@Accept			json
+//	@produce		json
+//	@Param			size			query		string	false	"Size 50"
+//	@Param			order			query		string	false	"Order DESC ASC"
+//	@Param			sort			query		string	false	"Sort creationTime"
+//
+// @Param			state			query		string	false	"State CREATED"
+//
+//	@Param			searchAfter		query		string	false	"SearchAfter 1731486859777,2251799814109407"
+//	@Param			searchBefore	query		string	false	"SearchBefore 1731486859777,2251799814109407"
+//	@Success		200				{string}	string	"ApprovingArtikel GetUserTaskActive"
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bpmn/pembuatan_media_berita_technology/tasks/approvingartikel  [get]
+func (app *application) getUserTaskActiveApprovingArtikelHandler(w http.ResponseWriter, r *http.Request) {
+	taskListQueryParams, err := getTaskListQueryParams(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if taskListQueryParams.Sort == "" {
+		taskListQueryParams.Sort = "creationTime"
+	}
+
+	if taskListQueryParams.State == "" {
+		taskListQueryParams.State = "CREATED"
+	}
+
+	ctx := r.Context()
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	tasks, err := app.searchUserTasks(ctx, store.ApprovingArtikelID, taskListQueryParams, GetUserFromContext(r))
+	if err != nil {
+		app.handleRequestError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tasks); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// Search ApprovingArtikel godoc
+//
+//	@Summary		Search ApprovingArtikel
+//	@Description	Search the submitted ApprovingArtikel forms
+//	@Tags			bpmn/ApprovingArtikel
+//	@Accept			json
+//	@produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			page	query		int		false	"Page"
+//	@Param			sort	query		string	false	"Sort asc desc"
+//	@Param			search	query		string	false	"Search"
+//	@Param			since	query		string	false	"Since"
+//	@Param			until	query		string	false	"Until"
+//	@Success		200		{string}	string	"ApprovingArtikel Search"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bpmn/pembuatan_media_berita_technology/tasks/approvingartikel/submissions  [get]
+func (app *application) searchApprovingArtikelHandler(w http.ResponseWriter, r *http.Request) {
+	pq := store.PaginatedQuery{
+		Limit: 20,
+		Page:  1,
+		Sort:  "desc",
+	}
+	if err := pq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	models, err := app.store.ApprovingArtikel.Search(ctx, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, models); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Claim ApprovingArtikel godoc
+//
+//	@Summary		Claim ApprovingArtikel
+//	@Description	Assign the ApprovingArtikel user task to the current user
+//	@Tags			bpmn/ApprovingArtikel
+//	@Accept			json
+//	@produce		json
+//	@Param			taskKey	path		int	true	"Task Key"
+//	@Success		200		{object}	camunda.Task
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bpmn/approvingartikel/{taskKey}/claim  [post]
+func (app *application) claimApprovingArtikelHandler(w http.ResponseWriter, r *http.Request) {
+	app.claimUserTask(w, r, store.ApprovingArtikelID)
+}
+
+// Unclaim ApprovingArtikel godoc
+//
+//	@Summary		Unclaim ApprovingArtikel
+//	@Description	Unassign the ApprovingArtikel user task from the current user
+//	@Tags			bpmn/ApprovingArtikel
+//	@Accept			json
+//	@produce		json
+//	@Param			taskKey	path		int	true	"Task Key"
+//	@Success		200		{object}	camunda.Task
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bpmn/approvingartikel/{taskKey}/unclaim  [post]
+func (app *application) unclaimApprovingArtikelHandler(w http.ResponseWriter, r *http.Request) {
+	app.unclaimUserTask(w, r, store.ApprovingArtikelID)
+}
+
+// Complete ApprovingArtikel godoc
+//
+//	@Summary		Complete ApprovingArtikel
+//	@Description	Complete the ApprovingArtikel user task claimed by the current user
+//	@Tags			bpmn/ApprovingArtikel
+//	@Accept			json
+//	@produce		json
+//	@Param			taskKey	path		int				true	"Task Key"
+//	@Param			payload	body		FormDataApprovingArtikel	true	"Form Data ApprovingArtikel"
+//	@Success		201		{object}	store.ApprovingArtikel
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bpmn/approvingartikel/{taskKey}/complete  [post]
+func (app *application) completeApprovingArtikelHandler(w http.ResponseWriter, r *http.Request) {
+	taskKey, err := getTaskKeyParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload FormDataApprovingArtikel
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	user := GetUserFromContext(r)
+
+	task, err := app.getAssignedUserTask(ctx, taskKey, store.ApprovingArtikelID, user)
+	if err != nil {
+		app.userTaskErrorResponse(w, r, err)
+		return
+	}
+
+	variables, err := formVariables(payload)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	model := &store.ApprovingArtikel{
+		Name:       store.ApprovingArtikelName,
+		TaskId:     task.ID,
+		FormId:     store.ApprovingArtikelFormID,
+		Properties: variables,
+		CreatedBy:  user.ID,
+		Decision:   payload.Decision,
+	}
+
+	if err := app.store.ApprovingArtikel.Create(ctx, model); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.completeUserTask(ctx, task, variables); err != nil {
+		// the task is still open, drop the submission so it can be sent again
+		if errDelete := app.store.ApprovingArtikel.Delete(ctx, model.ID); errDelete != nil {
+			app.logger.Errorw("failed to delete user task submission", "id", model.ID, "error", errDelete)
+		}
+		app.handleRequestError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, model); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/damarteplok/social/internal/mailer"
@@ -53,11 +56,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 
 	ctx := r.Context()
 
-	plainToken := uuid.New().String()
-
-	// store
-	hash := sha256.Sum256([]byte(plainToken))
-	hashToken := hex.EncodeToString(hash[:])
+	plainToken, hashToken := newUserToken()
 
 	// store the user
 	err := app.store.Users.CreateAndInvite(ctx, user, hashToken, time.Duration(app.config.mail.exp))
@@ -78,44 +77,107 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		Token: plainToken,
 	}
 
-	activationURL := fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken)
-
-	isProdEnv := app.config.env == "production"
-	vars := struct {
-		Username      string
-		ActivationURL string
-	}{
-		Username:      user.Username,
-		ActivationURL: activationURL,
-	}
-
 	// TODO: make asychronus
-	status, err := app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
+	status, err := app.sendActivationEmail(user, plainToken)
 	if err != nil {
-		app.logger.Errorw("error sending welcome email", "error", err)
+		app.requestLogger(r).Errorw("error sending welcome email", "error", err)
 		// rollback user creation if email fails
 		if err := app.store.Users.Delete(ctx, user.ID); err != nil {
-			app.logger.Errorw("error deleting user", "error", err)
+			app.requestLogger(r).Errorw("error deleting user", "error", err)
 		}
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	app.logger.Infow("Email sent", "status code", status)
+	app.requestLogger(r).Infow("Email sent", "status code", status)
 	if err := app.jsonResponse(w, http.StatusCreated, userWithToken); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
+// resendActivationHandler godoc
+//
+//	@Summary		Resend the activation email
+//	@Description	Send a new activation link to an account not activated yet, the response is the same whether the email has one or not
+//	@Tags			authentication
+//	@Accept			json
+//	@produce		json
+//	@Param			payload	body		ResendActivationPayload	true	"User email"
+//	@Success		202		{string}	string					"Activation link sent"
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/activation/resend [post]
+func (app *application) resendActivationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ResendActivationPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	allow, retryAfter, err := app.resendLimiter.Allow("activation:" + strings.ToLower(payload.Email))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !allow {
+		app.rateLimitExceededResponse(w, r, retryAfter.String())
+		return
+	}
+
+	ctx := r.Context()
+
+	plainToken, hashToken := newUserToken()
+	user, err := app.store.Users.RenewInvitation(ctx, payload.Email, hashToken, app.config.mail.exp)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			// do not tell which emails have an account
+			app.writeAccepted(w, r)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if _, err := app.sendActivationEmail(user, plainToken); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.writeAccepted(w, r)
+}
+
+func (app *application) sendActivationEmail(user *store.User, plainToken string) (int, error) {
+	activationURL := fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken)
+
+	isProdEnv := app.config.env == "production"
+	vars := struct {
+		Username      string
+		ActivationURL string
+	}{
+		Username:      user.Username,
+		ActivationURL: activationURL,
+	}
+
+	return app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
+}
+
 // createTokenHandler godoc
 //
 //	@Summary		Create a token
-//	@Description	Create a token for user
+//	@Description	Create a token for user, a user with two-factor authentication gets a challenge for /authentication/2fa/verify instead
 //	@Tags			authentication
 //	@Accept			json
 //	@produce		json
 //	@Param			payload	body		CreateUserTokenPayload	true	"User credentials"
-//	@Success		201		{object}	string					"Token"
+//	@Success		201		{object}	UserToken				"Token"
+//	@Success		202		{object}	TwoFactorChallenge		"Two-factor challenge"
 //	@Failure		400		{object}	error
 //	@Failure		404		{object}	error
 //	@Failure		500		{object}	error
@@ -141,27 +203,234 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		}
 		return
 	}
-	claims := jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(app.config.auth.token.exp).Unix(),
-		"iat": time.Now().Unix(),
-		"nbf": time.Now().Unix(),
-		"iss": app.config.auth.token.iss,
-		"aud": app.config.auth.token.iss,
+
+	challenge, err := app.twoFactorChallenge(r.Context(), user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if challenge != nil {
+		if err := app.jsonResponse(w, http.StatusAccepted, challenge); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
 	}
-	token, err := app.authenticator.GenerateToken(claims)
+
+	userToken, err := app.createSession(r.Context(), user)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
-	if err := app.jsonResponse(w, http.StatusCreated, map[string]interface{}{
-		"token": token,
-		"user":  user,
+	if err := app.jsonResponse(w, http.StatusCreated, userToken); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// createSession logs the user in, every login starts a session and the
+// refresh tokens rotated from it share its id.
+func (app *application) createSession(ctx context.Context, user *store.User) (*UserToken, error) {
+	return app.startSession(ctx, user, uuid.New().String())
+}
+
+// startSession logs the user in with the session id, createSession is the
+// one of a new login.
+func (app *application) startSession(ctx context.Context, user *store.User, sessionID string) (*UserToken, error) {
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	err = app.store.RefreshTokens.Create(ctx, refreshToken, &store.RefreshToken{
+		UserID:    user.ID,
+		SessionID: sessionID,
+		Expiry:    time.Now().Add(app.config.auth.token.refreshExp),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := app.generateAccessToken(user.ID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserToken{
+		Token:        token,
+		RefreshToken: refreshToken,
+		User:         user,
+	}, nil
+}
+
+// refreshTokenHandler godoc
+//
+//	@Summary		Refresh a token
+//	@Description	Exchange a refresh token for a new access token and refresh token, a refresh token is valid once
+//	@Tags			authentication
+//	@Accept			json
+//	@produce		json
+//	@Param			payload	body		RefreshTokenPayload	true	"Refresh token"
+//	@Success		200		{object}	UserToken			"Token"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/refresh [post]
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	rotated, err := app.store.RefreshTokens.Rotate(ctx, payload.RefreshToken, refreshToken, app.config.auth.token.refreshExp)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound), errors.Is(err, store.ErrTokenReused):
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// the user may have been deactivated since the login
+	if _, err := app.store.Users.GetByID(ctx, rotated.UserID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	token, err := app.generateAccessToken(rotated.UserID, rotated.SessionID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, &UserToken{
+		Token:        token,
+		RefreshToken: refreshToken,
 	}); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
+// logoutHandler godoc
+//
+//	@Summary		Logout
+//	@Description	Revoke the access token and the refresh tokens of the current session
+//	@Tags			authentication
+//	@Success		204	{string}	string	"Logged out"
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/authentication/logout [post]
+//	@Security		ApiKeyAuth
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r)
+	claims := GetClaimsFromContext(r)
+	ctx := r.Context()
+
+	if sessionID, _ := claims["sid"].(string); sessionID != "" {
+		if err := app.store.RefreshTokens.RevokeSession(ctx, user.ID, sessionID); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.revokeAccessToken(ctx, claims); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// logoutAllHandler godoc
+//
+//	@Summary		Logout everywhere
+//	@Description	Revoke every session of the current user
+//	@Tags			authentication
+//	@Success		204	{string}	string	"Logged out"
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/authentication/logout-all [post]
+//	@Security		ApiKeyAuth
+func (app *application) logoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r)
+
+	if err := app.revokeAllSessions(r.Context(), user.ID, ""); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) generateAccessToken(userID int64, sessionID string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub": userID,
+		"exp": now.Add(app.config.auth.token.exp).Unix(),
+		// to the millisecond, a revocation later in the same second
+		// revokes the token
+		"iat": float64(now.UnixMilli()) / 1000,
+		"nbf": now.Unix(),
+		"iss": app.config.auth.token.iss,
+		"aud": app.config.auth.token.aud,
+		"jti": uuid.New().String(),
+		"sid": sessionID,
+	}
+	return app.authenticator.GenerateToken(claims)
+}
+
+// revokeAccessToken puts the token on the denylist until it expires.
+func (app *application) revokeAccessToken(ctx context.Context, claims jwt.MapClaims) error {
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil
+	}
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return err
+	}
+	return app.cacheStorage.Tokens.Revoke(ctx, jti, time.Until(exp.Time))
+}
+
+// revokeAllSessions revokes the refresh tokens of the user and the access
+// tokens issued so far. The access tokens of keepSessionID stay valid, the
+// session is started right after with startSession.
+func (app *application) revokeAllSessions(ctx context.Context, userID int64, keepSessionID string) error {
+	if err := app.store.RefreshTokens.RevokeAllForUser(ctx, userID); err != nil {
+		return err
+	}
+
+	return app.cacheStorage.Tokens.RevokeUser(ctx, userID, store.TokenRevocation{
+		At:        time.Now(),
+		SessionID: keepSessionID,
+	}, app.config.auth.token.exp)
+}
+
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
 // getTokenUserHandler godoc
 //
 //	@Summary		Get user from token
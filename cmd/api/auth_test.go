@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/damarteplok/social/internal/auth"
+	"github.com/damarteplok/social/internal/mailer"
+	"github.com/damarteplok/social/internal/store"
+	"github.com/damarteplok/social/internal/store/cache"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestTokens(t *testing.T) {
+	app := newTestApplication(t, config{
+		redisCfg: redisConfig{enabled: true},
+		auth: authConfig{token: tokenConfig{
+			exp:        time.Hour,
+			iss:        "test-iss",
+			aud:        "test-aud",
+			refreshExp: time.Hour,
+		}},
+	})
+	app.authenticator = auth.NewJWTAuthenticator("test", "test-aud", "test-iss")
+	mux := app.mount()
+
+	mockCacheStore := app.cacheStorage.Users.(*cache.MockUserStore)
+	mockCacheStore.On("Get", mock.Anything).Return(nil, nil)
+	mockCacheStore.On("Set", mock.Anything).Return(nil)
+
+	post := func(t *testing.T, path, body, token string) (int, UserToken) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rr := executeRequest(req, mux)
+
+		var envelope struct {
+			Data UserToken `json:"data"`
+		}
+		if rr.Code < http.StatusMultipleChoices {
+			_ = json.NewDecoder(rr.Body).Decode(&envelope)
+		}
+		return rr.Code, envelope.Data
+	}
+	refresh := func(t *testing.T, refreshToken string) (int, UserToken) {
+		t.Helper()
+		return post(t, "/v1/authentication/refresh", `{"refresh_token": "`+refreshToken+`"}`, "")
+	}
+	getUser := func(t *testing.T, token string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, "/v1/authentication/user", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return executeRequest(req, mux).Code
+	}
+
+	code, login := post(t, "/v1/authentication/token", `{"email": "damar@test.com", "password": "secret"}`, "")
+	checkResponseCode(t, http.StatusCreated, code)
+	if login.Token == "" || login.RefreshToken == "" {
+		t.Fatalf("expected an access and a refresh token got %+v", login)
+	}
+
+	t.Run("should rotate the refresh token once", func(t *testing.T) {
+		code, rotated := refresh(t, login.RefreshToken)
+		checkResponseCode(t, http.StatusOK, code)
+		checkResponseCode(t, http.StatusOK, getUser(t, rotated.Token))
+
+		code, _ = refresh(t, login.RefreshToken)
+		checkResponseCode(t, http.StatusUnauthorized, code)
+
+		// the reuse revoked the session, the rotated token too
+		code, _ = refresh(t, rotated.RefreshToken)
+		checkResponseCode(t, http.StatusUnauthorized, code)
+	})
+
+	t.Run("should revoke the access token on logout", func(t *testing.T) {
+		_, session := post(t, "/v1/authentication/token", `{"email": "damar@test.com", "password": "secret"}`, "")
+		checkResponseCode(t, http.StatusOK, getUser(t, session.Token))
+
+		code, _ := post(t, "/v1/authentication/logout", "", session.Token)
+		checkResponseCode(t, http.StatusNoContent, code)
+
+		checkResponseCode(t, http.StatusUnauthorized, getUser(t, session.Token))
+		code, _ = refresh(t, session.RefreshToken)
+		checkResponseCode(t, http.StatusUnauthorized, code)
+	})
+
+	t.Run("should revoke the tokens issued before a revoke-all to the millisecond", func(t *testing.T) {
+		code, session := post(t, "/v1/authentication/token", `{"email": "damar@test.com", "password": "secret"}`, "")
+		checkResponseCode(t, http.StatusCreated, code)
+
+		claims := jwt.MapClaims{}
+		if _, _, err := jwt.NewParser().ParseUnverified(session.Token, claims); err != nil {
+			t.Fatal(err)
+		}
+		sub, _ := claims["sub"].(float64)
+		issuedAt, ok := tokenIssuedAt(claims)
+		if !ok {
+			t.Fatal("expected an iat")
+		}
+
+		// a revocation before the token must not revoke it
+		if err := app.cacheStorage.Tokens.RevokeUser(context.Background(), int64(sub), store.TokenRevocation{At: issuedAt.Add(-time.Millisecond)}, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		checkResponseCode(t, http.StatusOK, getUser(t, session.Token))
+
+		// a revocation later in the same second does, unless it keeps the
+		// session of the token
+		sessionID, _ := claims["sid"].(string)
+		if err := app.cacheStorage.Tokens.RevokeUser(context.Background(), int64(sub), store.TokenRevocation{At: issuedAt.Add(time.Millisecond), SessionID: sessionID}, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		checkResponseCode(t, http.StatusOK, getUser(t, session.Token))
+
+		if err := app.cacheStorage.Tokens.RevokeUser(context.Background(), int64(sub), store.TokenRevocation{At: issuedAt.Add(time.Millisecond)}, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		checkResponseCode(t, http.StatusUnauthorized, getUser(t, session.Token))
+	})
+
+	t.Run("should verify the issuer and the audience apart", func(t *testing.T) {
+		claims := jwt.MapClaims{
+			"sub": 1,
+			"exp": time.Now().Add(time.Hour).Unix(),
+			"iss": "test-aud",
+			"aud": "test-aud",
+		}
+		token, err := app.authenticator.GenerateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkResponseCode(t, http.StatusUnauthorized, getUser(t, token))
+	})
+}
+
+func TestResendActivation(t *testing.T) {
+	app := newTestApplication(t, config{
+		frontendURL: "http://localhost:5173",
+		mail:        mailConfig{exp: time.Hour, resendLimit: 1, resendWindow: time.Hour},
+	})
+	mux := app.mount()
+
+	resend := func(t *testing.T, email string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/activation/resend", strings.NewReader(`{"email": "`+email+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return executeRequest(req, mux).Code
+	}
+
+	checkResponseCode(t, http.StatusAccepted, resend(t, "damar@test.com"))
+	if _, ok := app.mailer.(*mailer.MockMailer).Last(mailer.UserWelcomeTemplate); !ok {
+		t.Fatal("expected an activation email")
+	}
+
+	t.Run("should throttle an address", func(t *testing.T) {
+		checkResponseCode(t, http.StatusTooManyRequests, resend(t, "DAMAR@test.com"))
+		checkResponseCode(t, http.StatusAccepted, resend(t, "other@test.com"))
+	})
+}
@@ -1,98 +1,25 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"time"
 
+	"github.com/damarteplok/social/internal/camunda"
 	"github.com/damarteplok/social/internal/store"
 	"github.com/go-chi/chi/v5"
 )
 
 const (
-	BucketBPMN             = "bpmn"
-	BucketForm             = "form"
-	StateCreated           = "CREATED"
-	StateCompleted         = "COMPLETED"
-	StateCanceled          = "CANCELED"
-	StateFailed            = "FAILED"
-	ResourceUrl            = "/v2/resources"
-	ProcessInstanceUrl     = "/v2/process-instances"
-	V1TasklistUrl          = "/v1/tasks"
-	V1FlowNodeUrl          = "/v1/flownode-instances"
-	V1ProcessInstanceUrl   = "/v1/process-instances"
-	V1ProcessDefinitionUrl = "/v1/process-definitions"
-	V1IncidentUrl          = "/v1/incidents"
+	BucketBPMN = "bpmn"
+	BucketForm = "form"
 )
 
-// Deploy godoc
-//
-//	@Summary		Deploy Bpmn Camunda and Create CRUD in Store And Handler File
-//	@Description	Deploy Bpmn Camunda by Name In Folder Resources And Create CRUD in Store And Handler File
-//	@Tags			camunda/resource
-//	@Accept			json
-//	@produce		json
-//	@Param			payload	body		DeployBpmnPayload	true	"Deploy Bpmn Payload"
-//	@Success		201		{string}	string
-//	@Failure		400		{object}	error
-//	@Failure		500		{object}	error
-//	@Security		ApiKeyAuth
-//	@Router			/camunda/resource/deploy-crud  [post]
-func (app *application) deployCamundaHandler(w http.ResponseWriter, r *http.Request) {
-	var payload DeployBpmnPayload
-	if err := readJSON(w, r, &payload); err != nil {
-		app.badRequestResponse(w, r, err)
-		return
-	}
-
-	if err := Validate.Struct(payload); err != nil {
-		app.badRequestResponse(w, r, err)
-		return
-	}
-
-	response, bpmnProcess, err := app.zeebeClient.DeployProcessDefinition(payload.ResourceName, payload.FormResources)
-	if err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-
-	if err := app.zeebeClient.GenerateCRUDUserTaskServiceTaskHandler(&bpmnProcess); err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-
-	processes := make([]map[string]interface{}, len(response))
-	for i, process := range response {
-		if err := app.zeebeClient.GenerateCRUDHandlers(process); err != nil {
-			app.internalServerError(w, r, err)
-			return
-		}
-		processes[i] = map[string]interface{}{
-			"processDefinitionKey": process.ProcessDefinitionKey,
-			"bpmnProcessId":        process.BpmnProcessId,
-			"version":              process.Version,
-		}
-	}
-
-	jsonResponse := map[string]interface{}{
-		"processes": processes,
-	}
-
-	if err := app.jsonResponse(w, http.StatusCreated, jsonResponse); err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-}
-
 // upload upload godoc
 //
 //	@Summary		Upload Bpmn Camunda
@@ -236,8 +163,8 @@ func (app *application) uploadMultipleCamundaHandler(w http.ResponseWriter, r *h
 
 // Deploy godoc
 //
-//	@Summary		Deploy Bpmn Camunda and Create CRUD in Store And Handler File
-//	@Description	Deploy Bpmn Camunda by Name From MINIO And Create CRUD in Store And Handler File
+//	@Summary		Deploy Bpmn Camunda From MINIO
+//	@Description	Deploy Bpmn Camunda by Name From MINIO, generate the code with cmd/bpmngen
 //	@Tags			camunda/minio
 //	@Accept			json
 //	@produce		json
@@ -246,8 +173,8 @@ func (app *application) uploadMultipleCamundaHandler(w http.ResponseWriter, r *h
 //	@Failure		400		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
-//	@Router			/camunda/minio/deploy-crud  [post]
-func (app *application) getObjectFromMinioThanUseItHandler(w http.ResponseWriter, r *http.Request) {
+//	@Router			/camunda/minio/deploy  [post]
+func (app *application) deployFromMinioCamundaHandler(w http.ResponseWriter, r *http.Request) {
 	var payload DeployBpmnPayload
 	if err := readJSON(w, r, &payload); err != nil {
 		app.badRequestResponse(w, r, err)
@@ -298,7 +225,7 @@ func (app *application) getObjectFromMinioThanUseItHandler(w http.ResponseWriter
 		}
 	}
 
-	response, bpmnProcess, err := app.zeebeClient.DeployProcessDefinitionFromFiles(fileResource, formFiles)
+	response, _, err := app.zeebeClient.DeployProcessDefinitionFromFiles(fileResource, formFiles)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -308,17 +235,8 @@ func (app *application) getObjectFromMinioThanUseItHandler(w http.ResponseWriter
 		os.Remove(tmpFile.Name())
 	}
 
-	if err := app.zeebeClient.GenerateCRUDUserTaskServiceTaskHandler(&bpmnProcess); err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-
 	processes := make([]map[string]interface{}, len(response))
 	for i, process := range response {
-		if err := app.zeebeClient.GenerateCRUDHandlers(process); err != nil {
-			app.internalServerError(w, r, err)
-			return
-		}
 		processes[i] = map[string]interface{}{
 			"processDefinitionKey": process.ProcessDefinitionKey,
 			"bpmnProcessId":        process.BpmnProcessId,
@@ -386,47 +304,6 @@ func (app *application) deployOnlyCamundaHandler(w http.ResponseWriter, r *http.
 	}
 }
 
-// CRUD godoc
-//
-//	@Summary		CRUD Store And Handler
-//	@Description	CRUD Store And Handler from Payload
-//	@Tags			camunda/resource
-//	@Accept			json
-//	@produce		json
-//	@Param			payload	body		CrudPayload	true	"Crud Payload"
-//	@Success		201		{string}	string
-//	@Failure		400		{object}	error
-//	@Failure		500		{object}	error
-//	@Security		ApiKeyAuth
-//	@Router			/camunda/resource/crud  [post]
-func (app *application) crudCamundaHandler(w http.ResponseWriter, r *http.Request) {
-	var payload CrudPayload
-	if err := readJSON(w, r, &payload); err != nil {
-		app.badRequestResponse(w, r, err)
-		return
-	}
-
-	if err := Validate.Struct(payload); err != nil {
-		app.badRequestResponse(w, r, err)
-		return
-	}
-
-	if err := app.zeebeClient.GenerateCRUDFromPayloadHandlers(
-		payload.ProcessName,
-		payload.ResourceName,
-		payload.Version,
-		payload.ProcessDefinitionKey,
-	); err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-
-	if err := app.jsonResponse(w, http.StatusCreated, "ok"); err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-}
-
 // Create Proses Instance godoc
 //
 //	@Summary		Create Proses Instance from rest api
@@ -434,14 +311,14 @@ func (app *application) crudCamundaHandler(w http.ResponseWriter, r *http.Reques
 //	@Tags			camunda/process-instance
 //	@Accept			json
 //	@produce		json
-//	@Param			payload	body		CreateProcessInstancePayload	true	"Create Proses Instance Payload"
-//	@Success		200		{object}	CreateProcessInstancesResponse
+//	@Param			payload	body		camunda.CreateProcessInstanceRequest	true	"Create Proses Instance Payload"
+//	@Success		200		{object}	camunda.CreateProcessInstanceResponse
 //	@Failure		400		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/camunda/process-instance  [post]
 func (app *application) createProsesInstance(w http.ResponseWriter, r *http.Request) {
-	var payload CreateProcessInstancePayload
+	var payload camunda.CreateProcessInstanceRequest
 	if err := readJSON(w, r, &payload); err != nil {
 		app.badRequestResponse(w, r, err)
 		return
@@ -452,39 +329,17 @@ func (app *application) createProsesInstance(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	body, err := json.Marshal(payload)
-	if err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-
 	ctx := r.Context()
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
 
-	resp, err := app.zeebeClientRest.SendRequest(
-		ctx,
-		http.MethodPost,
-		app.config.camundaRest.zeebeRestAddress+ProcessInstanceUrl,
-		bytes.NewBuffer(body),
-	)
+	created, err := app.camundaClient.ProcessInstances.Create(ctx, payload)
 	if err != nil {
-		switch err {
-		case store.ErrNotFound:
-			app.notFoundResponse(w, r, err)
-		default:
-			app.internalServerError(w, r, err)
-		}
-		return
-	}
-
-	var processInstanceResp CreateProcessInstancesResponse
-	if err := json.Unmarshal(resp, &processInstanceResp); err != nil {
-		app.internalServerError(w, r, fmt.Errorf("failed to unmarshal response: %w", err))
+		app.handleRequestError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, processInstanceResp); err != nil {
+	if err := app.jsonResponse(w, http.StatusOK, created); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -514,20 +369,8 @@ func (app *application) cancelProcessInstance(w http.ResponseWriter, r *http.Req
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
 
-	url := fmt.Sprintf("%s/%d/cancellation", ProcessInstanceUrl, processInstanceKey)
-	_, err = app.zeebeClientRest.SendRequest(
-		ctx,
-		http.MethodPost,
-		app.config.camundaRest.zeebeRestAddress+url,
-		bytes.NewBufferString("{}"),
-	)
-	if err != nil {
-		switch err {
-		case store.ErrNotFound:
-			app.notFoundResponse(w, r, err)
-		default:
-			app.internalServerError(w, r, err)
-		}
+	if err := app.camundaClient.ProcessInstances.Cancel(ctx, processInstanceKey); err != nil {
+		app.handleRequestError(w, r, err)
 		return
 	}
 
@@ -544,14 +387,14 @@ func (app *application) cancelProcessInstance(w http.ResponseWriter, r *http.Req
 //	@Tags			camunda/user-task
 //	@Accept			json
 //	@produce		json
-//	@Param			payload	body		SearchTaskListPayload	true	"Search TaskList Payload"
-//	@Success		200		{object}	SearchTaskListPayload
+//	@Param			payload	body		camunda.TaskSearch	true	"Search TaskList Payload"
+//	@Success		200		{array}		camunda.Task
 //	@Failure		400		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/camunda/user-task  [post]
 func (app *application) searchTaskListHandler(w http.ResponseWriter, r *http.Request) {
-	var payload SearchTaskListPayload
+	var payload camunda.TaskSearch
 	if err := readJSON(w, r, &payload); err != nil {
 		app.badRequestResponse(w, r, err)
 		return
@@ -565,35 +408,23 @@ func (app *application) searchTaskListHandler(w http.ResponseWriter, r *http.Req
 	app.setDefaultSort(&payload)
 	app.setDefaultState(&payload)
 
-	body, err := json.Marshal(payload)
-	if err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-
 	ctx := r.Context()
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
 
-	url := fmt.Sprintf("%s%s/search", app.config.camundaRest.camundaTasklistBaseUrl, V1TasklistUrl)
-	resp, err := app.zeebeClientRest.SendRequest(
-		ctx,
-		http.MethodPost,
-		url,
-		bytes.NewBuffer(body),
-	)
+	results, err := app.camundaClient.Tasks.Search(ctx, payload)
 	if err != nil {
 		app.handleRequestError(w, r, err)
 		return
 	}
 
-	var jsonData interface{}
-	if err := json.Unmarshal(resp, &jsonData); err != nil {
+	tasks, err := filterUserTasks(ctx, app, GetUserFromContext(r), results.Items)
+	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, jsonData); err != nil {
+	if err := app.jsonResponse(w, http.StatusOK, tasks); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -628,13 +459,7 @@ func (app *application) deleteCamundaHandler(w http.ResponseWriter, r *http.Requ
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
 
-	_, err = app.zeebeClientRest.SendRequest(
-		ctx,
-		http.MethodDelete,
-		fmt.Sprintf("%s/%d/deletion", app.config.camundaRest.zeebeRestAddress+ResourceUrl, processDefinitionKey),
-		nil,
-	)
-	if err != nil {
+	if err := app.camundaClient.ProcessDefinitions.Delete(ctx, processDefinitionKey); err != nil {
 		app.handleRequestError(w, r, err)
 		return
 	}
@@ -674,13 +499,7 @@ func (app *application) resolveIncidentHandler(w http.ResponseWriter, r *http.Re
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
 
-	_, err = app.zeebeClientRest.SendRequest(
-		ctx,
-		http.MethodPost,
-		fmt.Sprintf("%s/%d/resolution", app.config.camundaRest.zeebeRestAddress+"/v2/incidents", incidentKey),
-		nil,
-	)
-	if err != nil {
+	if err := app.camundaClient.Incidents.Resolve(ctx, incidentKey); err != nil {
 		app.handleRequestError(w, r, err)
 		return
 	}
@@ -698,14 +517,14 @@ func (app *application) resolveIncidentHandler(w http.ResponseWriter, r *http.Re
 //	@Tags			camunda/user-task
 //	@Accept			json
 //	@produce		json
-//	@Param			payload	body		QueryUserTaskPayload	true	"Query User Task Payload"
-//	@Success		200		{object}	QueryUserTaskPayload
+//	@Param			payload	body		camunda.UserTaskQuery	true	"Query User Task Payload"
+//	@Success		200		{object}	camunda.UserTaskResults
 //	@Failure		400		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/camunda/user-task/search  [post]
 func (app *application) searchUserTaskHandler(w http.ResponseWriter, r *http.Request) {
-	var payload QueryUserTaskPayload
+	var payload camunda.UserTaskQuery
 	if err := readJSON(w, r, &payload); err != nil {
 		app.badRequestResponse(w, r, err)
 		return
@@ -716,35 +535,23 @@ func (app *application) searchUserTaskHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	body, err := json.Marshal(payload)
-	if err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-
 	ctx := r.Context()
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
 
-	url := fmt.Sprintf("%s/v2/user-tasks/search", app.config.camundaRest.zeebeRestAddress)
-	resp, err := app.zeebeClientRest.SendRequest(
-		ctx,
-		http.MethodPost,
-		url,
-		bytes.NewBuffer(body),
-	)
+	results, err := app.camundaClient.UserTasks.Search(ctx, payload)
 	if err != nil {
 		app.handleRequestError(w, r, err)
 		return
 	}
 
-	var jsonData interface{}
-	if err := json.Unmarshal(resp, &jsonData); err != nil {
+	results.Items, err = filterUserTasks(ctx, app, GetUserFromContext(r), results.Items)
+	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, jsonData); err != nil {
+	if err := app.jsonResponse(w, http.StatusOK, results); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -768,7 +575,7 @@ func (app *application) searchUserTaskHandler(w http.ResponseWriter, r *http.Req
 //	@Param			size						query		string	false	"Size 50"
 //	@Param			searchAfter					query		string	false	"SearchAfter 1731486859777,2251799814109407"
 //	@Param			searchBefore				query		string	false	"SearchBefore 1731486859777,2251799814109407"
-//	@Success		200							{string}	string	"search process instance"
+//	@Success		200							{object}	camunda.Results[camunda.ProcessInstance]	"search process instance"
 //	@Failure		400							{object}	error
 //	@Failure		500							{object}	error
 //	@Security		ApiKeyAuth
@@ -785,79 +592,26 @@ func (app *application) searchProcessInstance(w http.ResponseWriter, r *http.Req
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
 
-	jsonTemplate := `
-{
-	"filter": {
-		%s
-    }, 
-    "size": %s
-    %s
-}`
-	var bpmnProcessIdStr, processDefinitionKeyStr, parentProcessInstanceKeyStr, startDateStr, endDateStr, stateStr string
-	if flowNodeQueryParams.BpmnProcessId != "" {
-		bpmnProcessIdStr = fmt.Sprintf(`, "bpmnProcessId": "%s"`, flowNodeQueryParams.BpmnProcessId)
-	}
-	if flowNodeQueryParams.ProcessDefinitionKey != "" {
-		processDefinitionKeyStr = fmt.Sprintf(`, "processDefinitionKey": %s`, flowNodeQueryParams.ProcessDefinitionKey)
-	}
-	if flowNodeQueryParams.ParentProcessInstanceKey != "" {
-		parentProcessInstanceKeyStr = fmt.Sprintf(`, "parentProcessInstanceKey": %s`, flowNodeQueryParams.ParentProcessInstanceKey)
-	}
-	if flowNodeQueryParams.StartDate != "" {
-		startDateStr = fmt.Sprintf(`, "startDate": "%s"`, flowNodeQueryParams.StartDate)
-	}
-	if flowNodeQueryParams.EndDate != "" {
-		endDateStr = fmt.Sprintf(`, "endDate": "%s"`, flowNodeQueryParams.EndDate)
-	}
-	if flowNodeQueryParams.State != "" {
-		stateStr = fmt.Sprintf(`, "state": "%s"`, flowNodeQueryParams.State)
-	}
-
-	filterForm := bpmnProcessIdStr + processDefinitionKeyStr + parentProcessInstanceKeyStr + startDateStr + endDateStr + stateStr
-
-	if filterForm != "" {
-		filterForm = filterForm[2:]
-	}
-	var searchAfterStr, searchBeforeStr string
-
-	if flowNodeQueryParams.SearchAfter != "" {
-		searchAfterStr = fmt.Sprintf(`, "searchAfter": %s`, flowNodeQueryParams.SearchAfter)
-	}
-
-	if flowNodeQueryParams.SearchBefore != "" {
-		searchBeforeStr = fmt.Sprintf(`, "searchBefore": %s`, flowNodeQueryParams.SearchBefore)
-	}
-
-	searchParams := searchAfterStr + searchBeforeStr
-
-	body := []byte(fmt.Sprintf(
-		jsonTemplate,
-		filterForm,
-		flowNodeQueryParams.Size,
-		searchParams,
-	))
-
-	log.Println(string(body))
-
-	url := fmt.Sprintf("%s%s/search", app.config.camundaRest.camundaOperateBaseUrl, V1ProcessInstanceUrl)
-	resp, err := app.zeebeClientRest.SendRequest(
-		ctx,
-		http.MethodPost,
-		url,
-		bytes.NewBuffer(body),
-	)
+	results, err := app.camundaClient.ProcessInstances.Search(ctx, camunda.Query[camunda.ProcessInstanceFilter]{
+		Filter: camunda.ProcessInstanceFilter{
+			BpmnProcessId:        flowNodeQueryParams.BpmnProcessId,
+			ProcessDefinitionKey: flowNodeQueryParams.ProcessDefinitionKey,
+			ParentKey:            flowNodeQueryParams.ParentProcessInstanceKey,
+			StartDate:            flowNodeQueryParams.StartDate,
+			EndDate:              flowNodeQueryParams.EndDate,
+			State:                flowNodeQueryParams.State,
+		},
+		Size:         flowNodeQueryParams.Size,
+		Sort:         flowNodeQueryParams.SortBy(),
+		SearchAfter:  flowNodeQueryParams.SearchAfter,
+		SearchBefore: flowNodeQueryParams.SearchBefore,
+	})
 	if err != nil {
 		app.handleRequestError(w, r, err)
 		return
 	}
 
-	var jsonData interface{}
-	if err := json.Unmarshal(resp, &jsonData); err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-
-	if err := app.jsonResponse(w, http.StatusOK, jsonData); err != nil {
+	if err := app.jsonResponse(w, http.StatusOK, results); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -881,44 +635,18 @@ func (app *application) operateStatisticsHandler(w http.ResponseWriter, r *http.
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
 
-	// get core statistics
-	url := fmt.Sprintf("%s/api/process-instances/core-statistics", app.config.camundaRest.camundaOperateBaseUrl)
-	resp, err := app.zeebeClientRest.SendRequest(
-		ctx,
-		http.MethodGet,
-		url,
-		nil,
-	)
+	coreStats, err := app.camundaClient.Statistics.Core(ctx)
 	if err != nil {
 		app.handleRequestError(w, r, err)
 		return
 	}
 
-	var coreStats OperateCoreStats
-	if err := json.Unmarshal(resp, &coreStats); err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-
-	// get process
-	urlProcess := fmt.Sprintf("%s/api/incidents/byProcess", app.config.camundaRest.camundaOperateBaseUrl)
-	respProcess, err := app.zeebeClientRest.SendRequest(
-		ctx,
-		http.MethodGet,
-		urlProcess,
-		nil,
-	)
+	processStats, err := app.camundaClient.Statistics.IncidentsByProcess(ctx)
 	if err != nil {
 		app.handleRequestError(w, r, err)
 		return
 	}
 
-	var processStats []OperateProcessStats
-	if err := json.Unmarshal(respProcess, &processStats); err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-
 	if err := app.jsonResponse(w, http.StatusOK, map[string]interface{}{
 		"stats":   coreStats,
 		"process": processStats,
@@ -954,18 +682,7 @@ func (app *application) xmlCamundaHandler(w http.ResponseWriter, r *http.Request
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
 
-	url := fmt.Sprintf("%s%s/%d/xml",
-		app.config.camundaRest.camundaOperateBaseUrl,
-		V1ProcessDefinitionUrl,
-		processDefinitionKey,
-	)
-	log.Println(url)
-	resp, err := app.zeebeClientRest.SendRequest(
-		ctx,
-		http.MethodGet,
-		url,
-		nil,
-	)
+	xml, err := app.camundaClient.ProcessDefinitions.XML(ctx, processDefinitionKey)
 	if err != nil {
 		app.handleRequestError(w, r, err)
 		return
@@ -973,12 +690,12 @@ func (app *application) xmlCamundaHandler(w http.ResponseWriter, r *http.Request
 
 	w.Header().Set("Content-Type", "text/xml")
 	w.WriteHeader(http.StatusOK)
-	if _, err := w.Write(resp); err != nil {
+	if _, err := io.WriteString(w, xml); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
-func (app *application) setDefaultSort(payload *SearchTaskListPayload) {
+func (app *application) setDefaultSort(payload *camunda.TaskSearch) {
 	if len(payload.Sort) > 1 {
 		for i := range payload.Sort {
 			if payload.Sort[i].Field == "" {
@@ -989,14 +706,14 @@ func (app *application) setDefaultSort(payload *SearchTaskListPayload) {
 			}
 		}
 	} else {
-		payload.Sort = append(payload.Sort, SortSearchTasklist{
+		payload.Sort = append(payload.Sort, camunda.Sort{
 			Field: "creationTime",
 			Order: "DESC",
 		})
 	}
 }
 
-func (app *application) setDefaultState(payload *SearchTaskListPayload) {
+func (app *application) setDefaultState(payload *camunda.TaskSearch) {
 	if payload.State == "" {
 		payload.State = "CREATED"
 	}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/damarteplok/social/internal/camunda"
+	"github.com/damarteplok/social/internal/zeebe"
+)
+
+func TestCamundaHandlers(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+	engine := app.zeebeClient.(*zeebe.FakeEngine)
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, url string, body any, data any) int {
+		t.Helper()
+
+		var payload bytes.Buffer
+		if body != nil {
+			if err := json.NewEncoder(&payload).Encode(body); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		req, err := http.NewRequest(method, url, &payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+		if data != nil && rr.Code < 300 {
+			envelope := struct {
+				Data any `json:"data"`
+			}{Data: data}
+			if err := json.Unmarshal(rr.Body.Bytes(), &envelope); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rr.Code
+	}
+
+	var deployed struct {
+		Processes []struct {
+			ProcessDefinitionKey int64 `json:"processDefinitionKey"`
+		} `json:"processes"`
+	}
+	code := request(t, http.MethodPost, "/v1/camunda/resource/deploy", DeployBpmnPayload{
+		ResourceName: "pembuatan_media_berita_technology.bpmn",
+	}, &deployed)
+	checkResponseCode(t, http.StatusCreated, code)
+	if len(deployed.Processes) != 1 {
+		t.Fatalf("expected 1 deployed process got %d", len(deployed.Processes))
+	}
+
+	var created camunda.CreateProcessInstanceResponse
+	code = request(t, http.MethodPost, "/v1/camunda/process-instance", camunda.CreateProcessInstanceRequest{
+		ProcessDefinitionKey: deployed.Processes[0].ProcessDefinitionKey,
+	}, &created)
+	checkResponseCode(t, http.StatusOK, code)
+	processInstanceKey := created.ProcessInstanceKey
+
+	t.Run("should search the user tasks of the instance", func(t *testing.T) {
+		var tasks []camunda.Task
+		code := request(t, http.MethodPost, "/v1/camunda/user-task", camunda.TaskSearch{
+			State:              "CREATED",
+			ProcessInstanceKey: fmt.Sprint(processInstanceKey),
+		}, &tasks)
+
+		checkResponseCode(t, http.StatusOK, code)
+		if len(tasks) != 1 || tasks[0].TaskDefinitionId != "creating_artikel" {
+			t.Errorf("unexpected tasks %+v", tasks)
+		}
+	})
+
+	t.Run("should count the running instances", func(t *testing.T) {
+		var stats struct {
+			Stats camunda.CoreStatistics `json:"stats"`
+		}
+		code := request(t, http.MethodGet, "/v1/camunda/resource/operate/statistics", nil, &stats)
+
+		checkResponseCode(t, http.StatusOK, code)
+		if stats.Stats.Running != 1 {
+			t.Errorf("expected 1 running instance got %d", stats.Stats.Running)
+		}
+	})
+
+	t.Run("should cancel the instance once", func(t *testing.T) {
+		url := fmt.Sprintf("/v1/camunda/process-instance/%d/cancel", processInstanceKey)
+
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodPost, url, nil, nil))
+		if instance, _ := engine.Instance(processInstanceKey); instance.State != zeebe.FakeStateCanceled {
+			t.Errorf("expected state %s got %s", zeebe.FakeStateCanceled, instance.State)
+		}
+
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodPost, url, nil, nil))
+	})
+
+	t.Run("should not resolve an unknown incident", func(t *testing.T) {
+		code := request(t, http.MethodPost, "/v1/camunda/incident/1/resolve", nil, nil)
+
+		checkResponseCode(t, http.StatusNotFound, code)
+	})
+}
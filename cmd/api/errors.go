@@ -1,61 +1,257 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/damarteplok/social/internal/store"
+	"github.com/damarteplok/social/internal/zeebe"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-playground/validator/v10"
+)
+
+// the codes of the problems are part of the api, never rename one
+const (
+	codeInternal             = "internal_error"
+	codeBadRequest           = "bad_request"
+	codeMalformedBody        = "malformed_body"
+	codeValidation           = "validation_failed"
+	codeDuplicateEmail       = "duplicate_email"
+	codeDuplicateUsername    = "duplicate_username"
+	codeTypeNotAllowed       = "file_type_not_allowed"
+	codeUnauthorized         = "unauthorized"
+	codeForbidden            = "forbidden"
+	codeNotFound             = "not_found"
+	codeMethodNotAllowed     = "method_not_allowed"
+	codeConflict             = "conflict"
+	codeIdempotencyKeyReused = "idempotency_key_reused"
+	codeProcessNotStarted    = "process_not_started"
+	codeInvalidPassword      = "invalid_password"
+	codeInvalidTwoFactorCode = "invalid_two_factor_code"
+	codeTwoFactorRequired    = "two_factor_required"
+	codeRateLimited          = "rate_limited"
+	codeServiceUnavailable   = "service_unavailable"
 )
 
+// publicErrors are written for the clients, they keep their own code and
+// their text in every env.
+var publicErrors = []struct {
+	err   error
+	code  string
+	field string
+}{
+	{store.ErrDuplicateEmail, codeDuplicateEmail, "email"},
+	{store.ErrDuplicateUsername, codeDuplicateUsername, "username"},
+	{store.ErrTypeNotAllowed, codeTypeNotAllowed, ""},
+	{ErrIdempotencyKeyReused, codeIdempotencyKeyReused, ""},
+	{ErrProcessNotStarted, codeProcessNotStarted, ""},
+	{ErrInvalidPassword, codeInvalidPassword, "password"},
+	{ErrInvalidTwoFactorCode, codeInvalidTwoFactorCode, "code"},
+	{ErrTwoFactorRequired, codeTwoFactorRequired, ""},
+}
+
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Errorw("internal error", "method", r.Method, "path", r.URL.Path, "error", err)
+	app.requestLogger(r).Errorw("internal error", "error", err)
 
-	writeJSONError(w, http.StatusInternalServerError, "the server encountered a problem")
+	app.writeProblem(w, r, http.StatusInternalServerError, codeInternal, "the server encountered a problem", nil)
 }
 
 func (app *application) forbiddenResponse(w http.ResponseWriter, r *http.Request) {
-	app.logger.Warnw("forbidden", "method", r.Method, "path", r.URL.Path)
+	app.requestLogger(r).Warnw("forbidden")
 
-	writeJSONError(w, http.StatusForbidden, "forbidden")
+	app.writeProblem(w, r, http.StatusForbidden, codeForbidden, "", nil)
 }
 
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Warnf("bad request error error: %s path: %s error: %s", r.Method, r.URL.Path, err)
+	app.requestLogger(r).Warnw("bad request", "error", err)
+
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		app.writeProblem(w, r, http.StatusBadRequest, codeValidation, "the request has invalid fields", fieldErrors(validationErrors))
+		return
+	}
+
+	if fieldErr, ok := decodeError(err); ok {
+		detail := app.errorDetail(err, "the request body is not valid json")
+		var errs []FieldError
+		if fieldErr != nil {
+			errs = []FieldError{*fieldErr}
+		}
+		app.writeProblem(w, r, http.StatusBadRequest, codeMalformedBody, detail, errs)
+		return
+	}
 
-	writeJSONError(w, http.StatusBadRequest, err.Error())
+	app.writeErrorProblem(w, r, http.StatusBadRequest, codeBadRequest, err, "the request is invalid")
 }
 
 func (app *application) conflictResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Errorw("conflict response", "method", r.Method, "path", r.URL.Path, "error", err)
+	app.requestLogger(r).Errorw("conflict response", "error", err)
 
-	writeJSONError(w, http.StatusConflict, err.Error())
+	app.writeErrorProblem(w, r, http.StatusConflict, codeConflict, err, "the resource is in conflict with the request")
 }
 
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Warnf("not found error: %s path: %s error: %s", r.Method, r.URL.Path, err)
+	app.requestLogger(r).Warnw("not found", "error", err)
 
-	writeJSONError(w, http.StatusNotFound, "not found")
+	app.writeProblem(w, r, http.StatusNotFound, codeNotFound, "", nil)
 }
 
 func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Warnf("not found error: %s path: %s error: %s", r.Method, r.URL.Path, err)
+	app.requestLogger(r).Warnw("method not allowed", "error", err)
 
-	writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+	app.writeProblem(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "", nil)
 }
 
 func (app *application) unauthorizedErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Warnf("unauthorized: %s path: %s error: %s", r.Method, r.URL.Path, err)
+	app.requestLogger(r).Warnw("unauthorized", "error", err)
 
-	writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+	app.writeProblem(w, r, http.StatusUnauthorized, codeUnauthorized, "", nil)
 }
 
 func (app *application) unauthorizedBasicErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.Warnf("unauthorized basic error: %s path: %s error: %s", r.Method, r.URL.Path, err)
+	app.requestLogger(r).Warnw("unauthorized basic", "error", err)
 
 	w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charshet="UTF-8"`)
-	writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+	app.writeProblem(w, r, http.StatusUnauthorized, codeUnauthorized, "", nil)
 }
 
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter string) {
-	app.logger.Warnw("rate limit exceeded", "method", r.Method, "path", r.URL.Path)
+	app.requestLogger(r).Warnw("rate limit exceeded")
 
 	w.Header().Set("Retry-After", retryAfter)
-	writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded, retry after: "+retryAfter)
+	app.writeProblem(w, r, http.StatusTooManyRequests, codeRateLimited, "rate limit exceeded, retry after: "+retryAfter, nil)
+}
+
+func (app *application) serviceUnavailableResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.requestLogger(r).Warnw("service unavailable", "error", err)
+
+	var circuitOpen *zeebe.CircuitOpenError
+	if errors.As(err, &circuitOpen) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(circuitOpen.RetryAfter.Seconds()))))
+	}
+	app.writeProblem(w, r, http.StatusServiceUnavailable, codeServiceUnavailable, "service unavailable, try again later", nil)
+}
+
+// writeErrorProblem writes the problem of an error, a public error brings its
+// own code and field.
+func (app *application) writeErrorProblem(w http.ResponseWriter, r *http.Request, status int, code string, err error, fallback string) {
+	for _, public := range publicErrors {
+		if !errors.Is(err, public.err) {
+			continue
+		}
+		var errs []FieldError
+		if public.field != "" {
+			errs = []FieldError{{Field: public.field, Code: public.code, Message: public.err.Error()}}
+		}
+		app.writeProblem(w, r, status, public.code, public.err.Error(), errs)
+		return
+	}
+
+	app.writeProblem(w, r, status, code, app.errorDetail(err, fallback), nil)
+}
+
+func (app *application) writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string, errs []FieldError) {
+	problem := &Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Code:      code,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: middleware.GetReqID(r.Context()),
+		Errors:    errs,
+	}
+
+	if err := writeProblem(w, problem); err != nil {
+		app.requestLogger(r).Errorw("failed to write the problem", "error", err)
+	}
+}
+
+// errorDetail hides the text of the errors in production, it may come from
+// the database or a dependency.
+func (app *application) errorDetail(err error, fallback string) string {
+	if app.config.env == "production" {
+		return fallback
+	}
+	return err.Error()
+}
+
+// fieldErrors translates the errors of the validator, the fields are named
+// after their json tag.
+func fieldErrors(validationErrors validator.ValidationErrors) []FieldError {
+	errs := make([]FieldError, 0, len(validationErrors))
+	for _, fe := range validationErrors {
+		field := fe.Namespace()
+		if _, path, ok := strings.Cut(field, "."); ok {
+			field = path
+		}
+
+		errs = append(errs, FieldError{
+			Field:   field,
+			Code:    fe.Tag(),
+			Message: validationMessage(fe),
+		})
+	}
+	return errs
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "required_if", "required_with", "required_without":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "url", "http_url":
+		return "must be a valid url"
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "min", "gte":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", fe.Param())
+		}
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters long", fe.Param())
+		}
+		return "must be at most " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	case "len":
+		return "must have a length of " + fe.Param()
+	default:
+		return fmt.Sprintf("failed the %s validation", fe.Tag())
+	}
+}
+
+// decodeError tells whether err comes from readJSON, with the field at fault
+// when the decoder knows it.
+func decodeError(err error) (*FieldError, bool) {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return nil, true
+		}
+		return &FieldError{Field: typeErr.Field, Code: "type", Message: "must be of type " + typeErr.Type.String()}, true
+	case errors.As(err, &syntaxErr), errors.As(err, &maxBytesErr),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return nil, true
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &FieldError{Field: field, Code: "unknown", Message: "is not a known field"}, true
+	}
+	return nil, false
 }
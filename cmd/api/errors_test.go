@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProblemResponses(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	decodeProblem := func(t *testing.T, rr *httptest.ResponseRecorder) Problem {
+		t.Helper()
+		if got := rr.Header().Get("Content-Type"); got != "application/problem+json" {
+			t.Errorf("expected a problem+json content type got %s", got)
+		}
+		var problem Problem
+		if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+			t.Fatal(err)
+		}
+		return problem
+	}
+
+	t.Run("should translate the validation errors to fields", func(t *testing.T) {
+		body := `{"username": "damar", "email": "not an email", "password": ""}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/user", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		problem := decodeProblem(t, rr)
+		if problem.Code != codeValidation || problem.RequestID == "" {
+			t.Errorf("expected a validation problem with a request id got %+v", problem)
+		}
+		fields := map[string]string{}
+		for _, fieldErr := range problem.Errors {
+			fields[fieldErr.Field] = fieldErr.Code
+		}
+		if fields["email"] != "email" || fields["password"] != "required" || len(fields) != 2 {
+			t.Errorf("expected the email and the password to be invalid got %+v", problem.Errors)
+		}
+	})
+
+	t.Run("should point to an unknown field", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/user", strings.NewReader(`{"name": "damar"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		problem := decodeProblem(t, rr)
+		if problem.Code != codeMalformedBody || len(problem.Errors) != 1 || problem.Errors[0].Field != "name" {
+			t.Errorf("expected the unknown field got %+v", problem)
+		}
+	})
+
+	t.Run("should hide the internal error text in production", func(t *testing.T) {
+		app.config.env = "production"
+		t.Cleanup(func() { app.config.env = "" })
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/users/1", nil)
+		rr := httptest.NewRecorder()
+		app.conflictResponse(rr, req, errors.New("pq: relation users does not exist"))
+
+		problem := decodeProblem(t, rr)
+		if strings.Contains(problem.Detail, "pq:") || problem.Code != codeConflict {
+			t.Errorf("expected the detail to be hidden got %+v", problem)
+		}
+
+		rr = httptest.NewRecorder()
+		app.badRequestResponse(rr, req, errors.Join(errors.New("insert failed"), ErrIdempotencyKeyReused))
+
+		problem = decodeProblem(t, rr)
+		if problem.Code != codeIdempotencyKeyReused || problem.Detail != ErrIdempotencyKeyReused.Error() {
+			t.Errorf("expected a public error to keep its text got %+v", problem)
+		}
+	})
+}
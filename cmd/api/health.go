@@ -1,9 +1,34 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
+	"sync"
+	"time"
+
+	"github.com/damarteplok/social/internal/camunda"
+	"github.com/damarteplok/social/internal/zeebe"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	checkUp       = "up"
+	checkDown     = "down"
+	checkDisabled = "disabled"
 )
 
+// healthCheck is a dependency checked by /readyz. A check that is not
+// required is reported but does not fail readiness.
+type healthCheck struct {
+	name     string
+	required bool
+	disabled bool
+	check    func(ctx context.Context) error
+}
+
 // HealthMonitoring godoc
 //
 //	@Summary		Fetches health status api
@@ -24,7 +49,161 @@ func (app *application) healthCheckHandler(w http.ResponseWriter, r *http.Reques
 		Version: version,
 	}
 
+	// the api keeps serving while a camunda component is down, the open
+	// breakers show which one
+	if app.zeebeClientRest != nil {
+		data.Breakers = app.zeebeClientRest.Breakers()
+		for _, breaker := range data.Breakers {
+			if breaker.State != zeebe.BreakerClosed {
+				data.Status = "degraded"
+			}
+		}
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, data); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
+
+// livenessHandler only tells the process serves requests, a dependency
+// being down must not get the pod restarted.
+func (app *application) livenessHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, HealthResponse{
+		Status:  "ok",
+		Env:     app.config.env,
+		Version: version,
+	}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// readinessHandler runs the dependency checks concurrently, each within its
+// own timeout, and answers 503 when a required one is down.
+func (app *application) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	results := make([]HealthCheckResult, len(app.healthChecks))
+
+	var wg sync.WaitGroup
+	for i, check := range app.healthChecks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = app.runHealthCheck(r.Context(), check)
+		}()
+	}
+	wg.Wait()
+
+	data := ReadinessResponse{Status: "ok", Checks: results}
+	status := http.StatusOK
+	for _, result := range results {
+		if result.Status != checkDown {
+			continue
+		}
+		if result.Required {
+			data.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		} else if data.Status == "ok" {
+			data.Status = "degraded"
+		}
+	}
+
+	if status != http.StatusOK {
+		app.requestLogger(r).Warnw("not ready", "checks", results)
+	}
+
+	if err := app.jsonResponse(w, status, data); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) runHealthCheck(ctx context.Context, check healthCheck) HealthCheckResult {
+	result := HealthCheckResult{Name: check.name, Required: check.required, Status: checkDisabled}
+	if check.disabled {
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, app.config.healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.check(ctx)
+	result.Duration = time.Since(start).String()
+
+	result.Status = checkUp
+	if err != nil {
+		result.Status = checkDown
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// dependencyChecks returns the checks of every dependency of the api, redis
+// and minio are only required when they are enabled.
+func (app *application) dependencyChecks(db *sql.DB, rdb *redis.Client) []healthCheck {
+	return []healthCheck{
+		{
+			name:     "postgres",
+			required: true,
+			check:    db.PingContext,
+		},
+		{
+			name:     "redis",
+			required: app.config.redisCfg.enabled,
+			disabled: rdb == nil,
+			check: func(ctx context.Context) error {
+				return rdb.Ping(ctx).Err()
+			},
+		},
+		{
+			name:     "minio",
+			required: app.config.minio.enabled,
+			check: func(ctx context.Context) error {
+				bucket := app.config.minio.bucket
+				if bucket == "" {
+					// no default bucket, only check minio answers
+					_, err := app.minioClient.ExistBucket(ctx, BucketBPMN)
+					return err
+				}
+
+				exists, err := app.minioClient.ExistBucket(ctx, bucket)
+				if err != nil {
+					return err
+				}
+				if !exists {
+					return fmt.Errorf("bucket %s does not exist", bucket)
+				}
+				return nil
+			},
+		},
+		{
+			name:     "zeebe",
+			required: true,
+			check: func(ctx context.Context) error {
+				topology, err := app.zeebeClient.Topology(ctx)
+				if err != nil {
+					return err
+				}
+				if len(topology.Brokers) == 0 {
+					return errors.New("no brokers in the zeebe topology")
+				}
+				return nil
+			},
+		},
+		{
+			name:     "operate",
+			required: true,
+			check: func(ctx context.Context) error {
+				_, err := app.camundaClient.ProcessDefinitions.Search(ctx, camunda.Query[camunda.ProcessDefinitionFilter]{Size: 1})
+				return err
+			},
+		},
+		{
+			name:     "tasklist",
+			required: true,
+			check: func(ctx context.Context) error {
+				_, err := app.camundaClient.Tasks.Search(ctx, camunda.TaskSearch{PageSize: 1})
+				return err
+			},
+		},
+	}
+}
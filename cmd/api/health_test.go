@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestReadiness(t *testing.T) {
+	app := newTestApplication(t, config{healthCheckTimeout: time.Second})
+	mux := app.mount()
+
+	// postgres and minio have no fake, the camunda checks run against the fake engine
+	camundaChecks := []healthCheck{}
+	for _, check := range app.dependencyChecks(nil, nil) {
+		switch check.name {
+		case "zeebe", "operate", "tasklist", "redis":
+			camundaChecks = append(camundaChecks, check)
+		}
+	}
+	down := func(ctx context.Context) error { return errors.New("down") }
+
+	readiness := func(t *testing.T) (int, ReadinessResponse) {
+		req, err := http.NewRequest(http.MethodGet, "/readyz", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		var body struct {
+			Data ReadinessResponse `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return rr.Code, body.Data
+	}
+
+	t.Run("should be ready while an optional dependency is down", func(t *testing.T) {
+		app.healthChecks = append(camundaChecks, healthCheck{name: "minio", check: down})
+
+		code, data := readiness(t)
+
+		checkResponseCode(t, http.StatusOK, code)
+		if data.Status != "degraded" || len(data.Checks) != 5 {
+			t.Errorf("expected a degraded report of 5 checks got %+v", data)
+		}
+		for _, check := range data.Checks {
+			want := checkUp
+			switch check.Name {
+			case "redis":
+				want = checkDisabled
+			case "minio":
+				want = checkDown
+			}
+			if check.Status != want {
+				t.Errorf("expected %s to be %s got %s (%s)", check.Name, want, check.Status, check.Error)
+			}
+		}
+	})
+
+	t.Run("should not be ready when a required dependency is down", func(t *testing.T) {
+		app.healthChecks = append(camundaChecks, healthCheck{name: "postgres", required: true, check: down})
+
+		code, data := readiness(t)
+
+		checkResponseCode(t, http.StatusServiceUnavailable, code)
+		if data.Status != "unavailable" {
+			t.Errorf("expected unavailable got %s", data.Status)
+		}
+	})
+
+	t.Run("should stay live and skip authentication", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/livez", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusOK, rr.Code)
+	})
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"encoding/json"
 	"net/http"
+	"reflect"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -11,6 +13,14 @@ var Validate *validator.Validate
 
 func init() {
 	Validate = validator.New(validator.WithRequiredStructEnabled())
+	// the validation errors name the fields as the clients send them
+	Validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
 }
 
 func writeJSON(w http.ResponseWriter, status int, data any) error {
@@ -29,16 +39,10 @@ func readJSON(w http.ResponseWriter, r *http.Request, data any) error {
 	return decoder.Decode(data)
 }
 
-func writeJSONError(w http.ResponseWriter, status int, message string) error {
-	type envelope struct {
-		Message string `json:"error"`
-		Status  int    `json:"status"`
-	}
-
-	return writeJSON(w, status, &envelope{
-		Message: message,
-		Status:  status,
-	})
+func writeProblem(w http.ResponseWriter, problem *Problem) error {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	return json.NewEncoder(w).Encode(problem)
 }
 
 func (app *application) jsonResponse(w http.ResponseWriter, status int, data any) error {
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/damarteplok/social/internal/auth"
+)
+
+// jwksHandler godoc
+//
+//	@Summary		JSON web key set
+//	@Description	The public keys that verify the access tokens, empty while the tokens are signed with a shared secret
+//	@Tags			authentication
+//	@produce		json
+//	@Success		200	{object}	auth.JSONWebKeySet
+//	@Router			/.well-known/jwks.json [get]
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	set := auth.JSONWebKeySet{Keys: []auth.JSONWebKey{}}
+	if keySet, ok := app.authenticator.(auth.KeySet); ok {
+		set = keySet.JWKS()
+	}
+
+	// a new key is listed as a verification key before it signs, so the
+	// cached sets know it by then
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := writeJSON(w, http.StatusOK, set); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// newJWTAuthenticator signs with the key file of cfg, without one with the
+// shared secret.
+func newJWTAuthenticator(cfg tokenConfig) (*auth.JWTAuthenticator, error) {
+	if cfg.signingKeyFile == "" {
+		return auth.NewJWTAuthenticator(cfg.secret, cfg.aud, cfg.iss), nil
+	}
+
+	signing, err := auth.LoadKey(cfg.signingKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var verification []*auth.Key
+	for _, path := range cfg.verifyKeyFiles {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		key, err := auth.LoadKey(path)
+		if err != nil {
+			return nil, err
+		}
+		verification = append(verification, key)
+	}
+
+	return auth.NewKeyAuthenticator(signing, verification, cfg.aud, cfg.iss)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"testing"
+
+	"github.com/damarteplok/social/internal/auth"
+)
+
+func TestJWKS(t *testing.T) {
+	app := newTestApplication(t, config{})
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := auth.ParseKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.authenticator, err = auth.NewKeyAuthenticator(key, nil, "test-aud", "test-iss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := app.mount()
+
+	req, err := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := executeRequest(req, mux)
+	checkResponseCode(t, http.StatusOK, rr.Code)
+
+	var set auth.JSONWebKeySet
+	if err := json.NewDecoder(rr.Body).Decode(&set); err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 1 || set.Keys[0].Kid != key.ID || set.Keys[0].Alg != "EdDSA" {
+		t.Errorf("unexpected key set %+v", set)
+	}
+}
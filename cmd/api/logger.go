@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type loggerKey string
+
+const loggerCtx loggerKey = "logger"
+
+// requestLog is the logger of a single request. The middlewares deeper in
+// the chain add their fields to it, so the access log written once the
+// request is served carries them too.
+type requestLog struct {
+	mu     sync.Mutex
+	logger *zap.SugaredLogger
+}
+
+func (l *requestLog) with(args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logger = l.logger.With(args...)
+}
+
+func (l *requestLog) get() *zap.SugaredLogger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.logger
+}
+
+// newLogger builds the logger of the api, format is either json or console.
+func newLogger(cfg logConfig) (*zap.Logger, error) {
+	level, err := zap.ParseAtomicLevel(cfg.level)
+	if err != nil {
+		return nil, err
+	}
+
+	zapCfg := zap.NewProductionConfig()
+	zapCfg.Level = level
+	switch cfg.format {
+	case "json":
+	case "console":
+		zapCfg.Encoding = "console"
+		zapCfg.EncoderConfig = zap.NewDevelopmentEncoderConfig()
+	default:
+		return nil, fmt.Errorf("unknown log format %q", cfg.format)
+	}
+
+	return zapCfg.Build()
+}
+
+// LoggerMiddleware puts the request logger in the context and writes the
+// access log of the request.
+func (app *application) LoggerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		fields := []interface{}{
+			"request_id", middleware.GetReqID(r.Context()),
+			"method", r.Method,
+			"path", r.URL.Path,
+			"remote_addr", r.RemoteAddr,
+		}
+		if span := trace.SpanContextFromContext(r.Context()); span.IsValid() {
+			fields = append(fields, "trace_id", span.TraceID().String())
+		}
+		reqLog := &requestLog{logger: app.logger.With(fields...)}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), loggerCtx, reqLog)))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		logger := reqLog.get()
+		log := logger.Infow
+		switch {
+		case status >= http.StatusInternalServerError:
+			log = logger.Errorw
+		case status >= http.StatusBadRequest:
+			log = logger.Warnw
+		}
+		log("request served",
+			"route", chi.RouteContext(r.Context()).RoutePattern(),
+			"status", status,
+			"latency", time.Since(start),
+			"bytes", ww.BytesWritten(),
+		)
+	})
+}
+
+// requestLogger returns the logger of the request, or the logger of the api
+// outside of LoggerMiddleware.
+func (app *application) requestLogger(r *http.Request) *zap.SugaredLogger {
+	if reqLog, ok := r.Context().Value(loggerCtx).(*requestLog); ok {
+		return reqLog.get()
+	}
+	return app.logger
+}
+
+// addLogFields adds fields to the logger of the request and its access log.
+func addLogFields(r *http.Request, args ...interface{}) {
+	if reqLog, ok := r.Context().Value(loggerCtx).(*requestLog); ok {
+		reqLog.with(args...)
+	}
+}
+
+// redactToken keeps bearer tokens out of the logs while still telling the
+// requests of one token apart.
+func redactToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestLogging(t *testing.T) {
+	app := newTestApplication(t, config{})
+	core, logs := observer.New(zapcore.InfoLevel)
+	app.logger = zap.New(core).Sugar()
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should write the access log with the authenticated user", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		logs.TakeAll()
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		entries := logs.FilterMessage("request served").All()
+		if len(entries) != 1 {
+			t.Fatalf("expected one access log got %d", len(entries))
+		}
+		fields := entries[0].ContextMap()
+		for _, key := range []string{"request_id", "user_id", "latency", "bytes"} {
+			if _, ok := fields[key]; !ok {
+				t.Errorf("expected %s in the access log got %v", key, fields)
+			}
+		}
+		if fields["route"] != "/v1/users/{userID}" || fields["status"] != int64(http.StatusOK) {
+			t.Errorf("expected the route and the status got %v", fields)
+		}
+	})
+
+	t.Run("should log the errors with the fields of the request", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		logs.TakeAll()
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+
+		unauthorized := logs.FilterMessage("unauthorized").All()
+		served := logs.FilterMessage("request served").All()
+		if len(unauthorized) != 1 || len(served) != 1 {
+			t.Fatalf("expected an error and an access log got %d and %d", len(unauthorized), len(served))
+		}
+		if unauthorized[0].ContextMap()["request_id"] != served[0].ContextMap()["request_id"] {
+			t.Error("expected the error to carry the request id of the access log")
+		}
+		if served[0].Level != zapcore.WarnLevel {
+			t.Errorf("expected a client error to log at warn got %s", served[0].Level)
+		}
+	})
+}
@@ -1,18 +1,25 @@
 package main
 
 import (
-	"expvar"
-	"runtime"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"strconv"
+	"time"
 
 	"github.com/damarteplok/social/internal/auth"
+	"github.com/damarteplok/social/internal/camunda"
 	"github.com/damarteplok/social/internal/db"
 	"github.com/damarteplok/social/internal/env"
 	"github.com/damarteplok/social/internal/mailer"
+	"github.com/damarteplok/social/internal/metrics"
 	"github.com/damarteplok/social/internal/minioupload"
+	"github.com/damarteplok/social/internal/oidc"
 	"github.com/damarteplok/social/internal/ratelimiter"
+	"github.com/damarteplok/social/internal/service"
 	"github.com/damarteplok/social/internal/store"
 	"github.com/damarteplok/social/internal/store/cache"
+	"github.com/damarteplok/social/internal/tracing"
 	"github.com/damarteplok/social/internal/zeebe"
 	"github.com/go-redis/redis/v8"
 	"go.uber.org/zap"
@@ -56,8 +63,11 @@ func main() {
 		env:    env.Envs.ENV,
 		apiURL: env.Envs.ApiUrl,
 		mail: mailConfig{
-			exp:       env.Envs.MailerExp,
-			fromEmail: env.Envs.MailerFromEmail,
+			exp:          env.Envs.MailerExp,
+			resetExp:     env.Envs.PasswordResetExp,
+			resendLimit:  env.Envs.ActivationResendLimit,
+			resendWindow: env.Envs.ActivationResendWindow,
+			fromEmail:    env.Envs.MailerFromEmail,
 			sendgrid: sendGridConfig{
 				apiKey: env.Envs.MailerApiKey,
 			},
@@ -78,9 +88,19 @@ func main() {
 				pass: env.Envs.AdminPass,
 			},
 			token: tokenConfig{
-				secret: env.Envs.JwtSecret,
-				exp:    env.Envs.JwtExp,
-				iss:    env.Envs.JwtIss,
+				secret:         env.Envs.JwtSecret,
+				signingKeyFile: env.Envs.JwtSigningKeyFile,
+				verifyKeyFiles: env.Envs.JwtVerifyKeyFiles,
+				exp:            env.Envs.JwtExp,
+				iss:            env.Envs.JwtIss,
+				aud:            env.Envs.JwtAud,
+				refreshExp:     env.Envs.JwtRefreshExp,
+			},
+			twoFactor: twoFactorConfig{
+				key:           env.Envs.TwoFactorKey,
+				challengeExp:  env.Envs.TwoFactorChallengeExp,
+				maxAttempts:   env.Envs.TwoFactorMaxAttempts,
+				attemptWindow: env.Envs.TwoFactorAttemptWindow,
 			},
 		},
 		camunda: camundaConfig{
@@ -88,25 +108,116 @@ func main() {
 			zeebeClientId:      env.Envs.ZeebeClientID,
 			zeebeClientSecret:  env.Envs.ZeebeClientSecret,
 			zeebeAuthServerUrl: env.Envs.ZeebeAuthServerUrl,
+			audiences: map[zeebe.Component]string{
+				zeebe.ComponentZeebe:    env.Envs.ZeebeAudience,
+				zeebe.ComponentOperate:  env.Envs.OperateAudience,
+				zeebe.ComponentTasklist: env.Envs.TasklistAudience,
+				zeebe.ComponentOptimize: env.Envs.OptimizeAudience,
+			},
+			tokenTimeout:      env.Envs.ZeebeTokenTimeout,
+			tokenRefreshAhead: env.Envs.ZeebeTokenRefresh,
+			retry: zeebe.RetryConfig{
+				MaxAttempts: env.Envs.CamundaRetryAttempts,
+				Backoff:     env.Envs.CamundaRetryBackoff,
+				MaxBackoff:  env.Envs.CamundaRetryMaxBackoff,
+			},
+			breaker: zeebe.BreakerConfig{
+				FailureThreshold: env.Envs.CamundaBreakerFailures,
+				OpenTimeout:      env.Envs.CamundaBreakerTimeout,
+			},
+			timeouts: map[zeebe.Component]time.Duration{
+				zeebe.ComponentZeebe:    env.Envs.ZeebeTimeout,
+				zeebe.ComponentOperate:  env.Envs.OperateTimeout,
+				zeebe.ComponentTasklist: env.Envs.TasklistTimeout,
+				zeebe.ComponentOptimize: env.Envs.OptimizeTimeout,
+			},
 		},
 		camundaRest: camundaRestConfig{
 			zeebeRestAddress:       env.Envs.ZeebeRestAddress,
 			zeebeGrpcAddress:       env.Envs.ZeebeGrpcAddress,
 			camundaTasklistBaseUrl: env.Envs.CamundaTasklistBaseUrl,
 			camundaOperateBaseUrl:  env.Envs.CamundaOperateBaseUrl,
-			camundaOptimizeBaseUrl: env.Envs.CamundaOperateBaseUrl,
+			camundaOptimizeBaseUrl: env.Envs.CamundaOptimizeBaseUrl,
 		},
 		rateLimiter: ratelimiter.Config{
 			RequestPerTimeFrame: env.Envs.RequestPerTimeFrame,
 			TimeFrame:           env.Envs.RateLimiterTimeFrame,
 			Enabled:             env.Envs.RateLimiterEnabled,
 		},
+		worker: service.Config{
+			Enabled:       env.Envs.ZeebeWorkerEnabled,
+			Concurrency:   env.Envs.ZeebeWorkerConcurrency,
+			MaxJobsActive: env.Envs.ZeebeWorkerMaxActive,
+			Timeout:       env.Envs.ZeebeWorkerTimeout,
+			PollInterval:  env.Envs.ZeebeWorkerInterval,
+			RetryBackoff:  env.Envs.ZeebeWorkerBackoff,
+		},
+		reconciler: service.ReconcilerConfig{
+			Enabled:   env.Envs.ReconcilerEnabled,
+			Interval:  env.Envs.ReconcilerInterval,
+			BatchSize: env.Envs.ReconcilerBatchSize,
+		},
+		outbox: service.DispatcherConfig{
+			Enabled:      env.Envs.OutboxEnabled,
+			Interval:     env.Envs.OutboxInterval,
+			BatchSize:    env.Envs.OutboxBatchSize,
+			RetryBackoff: env.Envs.OutboxRetryBackoff,
+			MaxAttempts:  env.Envs.OutboxMaxAttempts,
+			ExportLag:    env.Envs.OutboxExportLag,
+		},
+		cleanup: service.CleanupConfig{
+			Enabled:           env.Envs.CleanupEnabled,
+			Interval:          env.Envs.CleanupInterval,
+			InactiveUserGrace: env.Envs.InactiveUserGrace,
+			// a failed attempt counts for the attempt window
+			ChallengeRetention: env.Envs.TwoFactorAttemptWindow,
+		},
+		tracing: tracing.Config{
+			Enabled:     env.Envs.TracingEnabled,
+			Endpoint:    env.Envs.TracingEndpoint,
+			Insecure:    env.Envs.TracingInsecure,
+			ServiceName: env.Envs.TracingServiceName,
+			SampleRatio: env.Envs.TracingSampleRatio,
+		},
+		oidc: oidc.Config{
+			Enabled:      env.Envs.OidcEnabled,
+			IssuerURL:    env.Envs.OidcIssuerURL,
+			ClientID:     env.Envs.OidcClientID,
+			ClientSecret: env.Envs.OidcClientSecret,
+			RedirectURL:  env.Envs.OidcRedirectURL,
+			Audiences:    env.Envs.OidcAudiences,
+			GroupsClaim:  env.Envs.OidcGroupsClaim,
+			RoleMapping:  env.Envs.OidcRoleMapping,
+			MFAAcrValues: env.Envs.OidcMFAAcrValues,
+			MFAAmrValues: env.Envs.OidcMFAAmrValues,
+		},
+		log: logConfig{
+			level:  env.Envs.LogLevel,
+			format: env.Envs.LogFormat,
+		},
+		healthCheckTimeout: env.Envs.HealthCheckTimeout,
 	}
 
 	// Logger
-	logger := zap.Must(zap.NewProduction()).Sugar()
+	logger := zap.Must(newLogger(cfg.log)).Sugar()
 	defer logger.Sync()
 
+	// Tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.tracing, version)
+	if err != nil {
+		logger.Fatalw("tracing failed", "error", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Errorw("tracing shutdown failed", "error", err)
+		}
+	}()
+	if cfg.tracing.Enabled {
+		logger.Infow("tracing enabled", "endpoint", cfg.tracing.Endpoint)
+	}
+
 	// Database
 	db, err := db.New(
 		cfg.db.addr,
@@ -141,14 +252,47 @@ func main() {
 			cfg.rateLimiter.TimeFrame,
 		)
 	}
+	// the activation emails are throttled by address
+	var resendLimiter ratelimiter.Limiter
+	if cfg.redisCfg.enabled {
+		resendLimiter = ratelimiter.NewFixedWindowLimiterJWT(rdb, cfg.mail.resendLimit, cfg.mail.resendWindow)
+	} else {
+		resendLimiter = ratelimiter.NewFixedWindowLimiter(cfg.mail.resendLimit, cfg.mail.resendWindow)
+	}
+
 	// Mailer
 	mailer := mailer.NewSendgrid(cfg.mail.sendgrid.apiKey, cfg.mail.fromEmail)
 
-	jwtAuthenticator := auth.NewJWTAuthenticator(
-		cfg.auth.token.secret,
-		cfg.auth.token.iss,
-		cfg.auth.token.iss,
-	)
+	jwtAuthenticator, err := newJWTAuthenticator(cfg.auth.token)
+	if err != nil {
+		logger.Fatalw("jwt keys failed", "error", err)
+	}
+
+	// the totp secrets are only as safe as this key
+	twoFactorKey := cfg.auth.twoFactor.key
+	if err := auth.CheckPassphrase(twoFactorKey); err != nil {
+		if cfg.env != "development" || twoFactorKey != "" {
+			logger.Fatalw("TWO_FACTOR_KEY is not set or too weak", "error", err)
+		}
+		// a key of the process, the enrollments are lost on a restart
+		random := make([]byte, auth.MinPassphraseLength)
+		if _, err := rand.Read(random); err != nil {
+			logger.Fatalw("two factor key failed", "error", err)
+		}
+		twoFactorKey = hex.EncodeToString(random)
+		logger.Warnw("TWO_FACTOR_KEY is not set, the two-factor enrollments do not survive a restart")
+	}
+	secretBox, err := auth.NewSecretBox(twoFactorKey)
+	if err != nil {
+		logger.Fatalw("two factor secret box failed", "error", err)
+	}
+
+	// oidc login with the keycloak of camunda identity
+	var oidcProvider *oidc.Provider
+	if cfg.oidc.Enabled {
+		oidcProvider = oidc.NewProvider(cfg.oidc)
+		logger.Infow("oidc enabled", "issuer", cfg.oidc.IssuerURL)
+	}
 
 	// zeebe
 	zeebeClient, err := zeebe.NewZeebeClient(
@@ -164,18 +308,35 @@ func main() {
 	logger.Info("zeebe client established")
 
 	// zeebe rest client
-	zeebeClientRest, err := zeebe.NewZeebeClientRest(
-		cfg.camunda.zeebeClientId,
-		cfg.camunda.zeebeClientSecret,
-		cfg.camunda.zeebeAuthServerUrl,
-		cfg.camunda.zeebeAddr,
-	)
+	zeebeClientRest, err := zeebe.NewZeebeClientRest(zeebe.RestConfig{
+		Token: zeebe.TokenConfig{
+			ClientID:     cfg.camunda.zeebeClientId,
+			ClientSecret: cfg.camunda.zeebeClientSecret,
+			AuthURL:      cfg.camunda.zeebeAuthServerUrl,
+			Audiences:    cfg.camunda.audiences,
+			Timeout:      cfg.camunda.tokenTimeout,
+			RefreshAhead: cfg.camunda.tokenRefreshAhead,
+		},
+		ZeebeURL:    cfg.camundaRest.zeebeRestAddress,
+		OperateURL:  cfg.camundaRest.camundaOperateBaseUrl,
+		TasklistURL: cfg.camundaRest.camundaTasklistBaseUrl,
+		OptimizeURL: cfg.camundaRest.camundaOptimizeBaseUrl,
+		Retry:       cfg.camunda.retry,
+		Breaker:     cfg.camunda.breaker,
+		Timeouts:    cfg.camunda.timeouts,
+	})
 	if err != nil {
 		logger.Fatalw("zeebe rest api client failed", err)
 	}
 	defer zeebeClientRest.Close()
 	logger.Info("zeebe client rest api established")
 
+	camundaClient := camunda.NewClient(zeebeClientRest, camunda.Config{
+		ZeebeURL:    cfg.camundaRest.zeebeRestAddress,
+		OperateURL:  cfg.camundaRest.camundaOperateBaseUrl,
+		TasklistURL: cfg.camundaRest.camundaTasklistBaseUrl,
+	})
+
 	// minio
 	endpointMinio := cfg.minio.addr + ":" + strconv.Itoa(cfg.minio.port)
 	minioClient, err := minioupload.NewMinioClient(
@@ -191,6 +352,49 @@ func main() {
 
 	store := store.NewStorage(db)
 	cacheStorage := cache.NewRedisStorage(rdb)
+	if !cfg.redisCfg.enabled {
+		// the revoked tokens are kept in postgres instead, logout and the
+		// revocations of a user must hold without redis too
+		cacheStorage.Tokens = store.RevokedTokens
+	}
+
+	// service task workers
+	serviceTask := service.NewService(store, zeebeClient, logger, cfg.worker)
+	if err := serviceTask.Start(); err != nil {
+		logger.Fatalw("zeebe job workers failed", "error", err)
+	}
+	logger.Info("zeebe job workers started")
+
+	// process instance reconciler
+	var reconcilerCache cache.Storage
+	if cfg.redisCfg.enabled {
+		reconcilerCache = cacheStorage
+	}
+	reconciler := service.NewReconciler(
+		store,
+		reconcilerCache,
+		&camundaClient,
+		logger,
+		cfg.reconciler,
+	)
+	reconciler.Start()
+	logger.Info("process instance reconciler started")
+
+	// outbox dispatcher for start and cancel commands
+	outbox := service.NewDispatcher(
+		store,
+		zeebeClient,
+		&camundaClient,
+		logger,
+		cfg.outbox,
+	)
+	outbox.Start()
+	logger.Info("outbox dispatcher started")
+
+	// expired invitations and never activated users
+	cleaner := service.NewCleaner(store, logger, cfg.cleanup)
+	cleaner.Start()
+	logger.Info("invitation cleaner started")
 
 	app := &application{
 		config:          cfg,
@@ -201,18 +405,23 @@ func main() {
 		authenticator:   jwtAuthenticator,
 		rateLimiter:     rateLimiter,
 		zeebeClient:     zeebeClient,
-		zeebeClientRest: *zeebeClientRest,
+		camundaClient:   camundaClient,
+		zeebeClientRest: zeebeClientRest,
 		minioClient:     minioClient,
+		service:         serviceTask,
+		reconciler:      reconciler,
+		outbox:          outbox,
+		cleaner:         cleaner,
+		resendLimiter:   resendLimiter,
+		secretBox:       secretBox,
+		oidcProvider:    oidcProvider,
 	}
+	app.healthChecks = app.dependencyChecks(db, rdb)
 
-	// Metrics Collected
-	expvar.NewString("version").Set(version)
-	expvar.Publish("database", expvar.Func(func() any {
-		return db.Stats()
-	}))
-	expvar.Publish("goroutine", expvar.Func(func() any {
-		return runtime.NumGoroutine()
-	}))
+	// Metrics Collected, the go collector of the default registry covers the goroutines
+	if err := metrics.Register(version, db); err != nil {
+		logger.Fatalw("metrics failed", "error", err)
+	}
 
 	mux := app.mount()
 
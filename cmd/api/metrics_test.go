@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	app := newTestApplication(t, config{
+		auth: authConfig{basic: basicConfig{user: "admin", pass: "admin"}},
+	})
+	mux := app.mount()
+
+	req, err := http.NewRequest(http.MethodGet, "/v1/users/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executeRequest(req, mux)
+
+	t.Run("should require basic auth", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("should count requests by route pattern", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("admin", "admin")
+
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		body, _ := io.ReadAll(rr.Body)
+		want := `social_http_requests_total{method="GET",route="/v1/users/{userID}",status="401"}`
+		if !strings.Contains(string(body), want) {
+			t.Errorf("expected %s in the metrics", want)
+		}
+	})
+}
@@ -4,13 +4,20 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/damarteplok/social/internal/metrics"
 	"github.com/damarteplok/social/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
@@ -26,32 +33,75 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		token := parts[1]
-		jwtToken, err := app.authenticator.ValidateToken(token)
+		ctx := r.Context()
+
+		user, claims, err := app.authenticateToken(ctx, parts[1])
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
 			return
 		}
 
-		claims, _ := jwtToken.Claims.(jwt.MapClaims)
+		addLogFields(r, "user_id", user.ID)
+
+		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, claimsCtx, claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+// authenticateToken returns the user of a local access token or of a token
+// of the oidc provider.
+func (app *application) authenticateToken(ctx context.Context, token string) (*store.User, jwt.MapClaims, error) {
+	if app.isOIDCToken(token) {
+		oidcClaims, err := app.oidcProvider.Verify(ctx, token)
 		if err != nil {
-			app.unauthorizedErrorResponse(w, r, err)
-			return
+			return nil, nil, err
 		}
-
-		ctx := r.Context()
-
-		user, err := app.getUser(ctx, userID)
+		user, err := app.oidcUser(ctx, oidcClaims)
 		if err != nil {
-			app.unauthorizedErrorResponse(w, r, err)
-			return
+			return nil, nil, err
+		}
+		// a bearer token cannot answer a challenge, the roles that require a
+		// second factor must have logged in with one at keycloak
+		required, err := app.twoFactorRequired(ctx, user)
+		if err != nil {
+			return nil, nil, err
 		}
+		if required && !app.oidcProvider.MultiFactor(oidcClaims) {
+			return nil, nil, ErrTwoFactorRequired
+		}
+		if err := app.checkTokenRevoked(ctx, oidcClaims.MapClaims, user.ID); err != nil {
+			return nil, nil, err
+		}
+		return user, oidcClaims.MapClaims, nil
+	}
 
-		ctx = context.WithValue(ctx, userCtx, user)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+	jwtToken, err := app.authenticator.ValidateToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	claims, _ := jwtToken.Claims.(jwt.MapClaims)
+	if _, ok := claims["purpose"]; ok {
+		// a two-factor challenge is not an access token
+		return nil, nil, ErrInvalidChallenge
+	}
+
+	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := app.checkTokenRevoked(ctx, claims, userID); err != nil {
+		return nil, nil, err
+	}
+
+	user, err := app.getUser(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, claims, nil
 }
 
 func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
@@ -125,6 +175,48 @@ func (app *application) checkRolePrecedence(ctx context.Context, user *store.Use
 	return user.Role.Level >= role.Level, nil
 }
 
+// checkTokenRevoked looks the token up in the denylist, kept in redis or in
+// postgres when redis is disabled.
+func (app *application) checkTokenRevoked(ctx context.Context, claims jwt.MapClaims, userID int64) error {
+	if jti, _ := claims["jti"].(string); jti != "" {
+		revoked, err := app.cacheStorage.Tokens.IsRevoked(ctx, jti)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+
+	revocation, err := app.cacheStorage.Tokens.UserRevocation(ctx, userID)
+	if err != nil || revocation == nil {
+		return err
+	}
+	// the session started with the revocation is kept whenever in the
+	// millisecond its tokens were issued
+	if sessionID, _ := claims["sid"].(string); sessionID != "" && sessionID == revocation.SessionID {
+		return nil
+	}
+	// the local tokens are issued to the millisecond, a token of the
+	// provider to the second is revoked for the whole second
+	issuedAt, ok := tokenIssuedAt(claims)
+	if !ok || !issuedAt.After(revocation.At) {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// tokenIssuedAt returns the iat of a token with its fraction of a second,
+// jwt.MapClaims.GetIssuedAt keeps it to jwt.TimePrecision only.
+func tokenIssuedAt(claims jwt.MapClaims) (time.Time, bool) {
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(int64(math.Round(iat * 1000))), true
+}
+
 func (app *application) getUser(ctx context.Context, userID int64) (*store.User, error) {
 	if !app.config.redisCfg.enabled {
 		return app.store.Users.GetByID(ctx, userID)
@@ -156,15 +248,19 @@ func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 			var err error
 			var key interface{}
 
+			logKey := r.RemoteAddr
 			if app.config.redisCfg.enabled {
 				token := r.Header.Get("Authorization")
 				key = token
+				logKey = redactToken(token)
 				if token == "" {
 					key = r.RemoteAddr
+					logKey = r.RemoteAddr
 				}
 			} else {
 				key = r.RemoteAddr
 			}
+			addLogFields(r, "rate_limit_key", logKey)
 
 			allow, retryAfter, err = app.rateLimiter.Allow(key)
 			if err != nil {
@@ -173,6 +269,7 @@ func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 			}
 
 			if !allow {
+				metrics.RateLimitRejections.Inc()
 				app.rateLimitExceededResponse(w, r, retryAfter.String())
 				return
 			}
@@ -181,3 +278,37 @@ func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// TracingMiddleware starts the server span of a request, continuing the
+// trace of an incoming traceparent. The span is named after the route
+// pattern once chi routed the request.
+func (app *application) TracingMiddleware(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		if route == "" {
+			return
+		}
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route))
+	}), "http.request")
+}
+
+// MetricsMiddleware counts the requests by the route pattern chi matched,
+// which is only known once the request was routed.
+func (app *application) MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		metrics.ObserveHTTP(r.Method, chi.RouteContext(r.Context()).RoutePattern(), status, start)
+	})
+}
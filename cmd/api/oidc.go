@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/damarteplok/social/internal/oidc"
+	"github.com/damarteplok/social/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// oidcStatePurpose marks the state tokens of the oidc login, the state
+// carries the nonce so the api keeps no login in flight.
+const oidcStatePurpose = "oidc_state"
+
+const oidcStateExp = 10 * time.Minute
+
+var (
+	ErrInvalidOIDCState = errors.New("the oidc login state is not valid")
+	ErrOIDCEmailMissing = errors.New("the oidc token has no email")
+)
+
+// oidcAuthorizeHandler godoc
+//
+//	@Summary		Start an oidc login
+//	@Description	Get the url of the keycloak login page, the client sends the code and the state of the redirect to /authentication/oidc/token
+//	@Tags			authentication
+//	@produce		json
+//	@Success		200	{object}	OIDCAuthorization	"Authorization url"
+//	@Failure		500	{object}	error
+//	@Failure		503	{object}	error
+//	@Router			/authentication/oidc/authorize [get]
+func (app *application) oidcAuthorizeHandler(w http.ResponseWriter, r *http.Request) {
+	nonce := uuid.New().String()
+
+	now := time.Now()
+	state, err := app.authenticator.GenerateToken(jwt.MapClaims{
+		"exp":     now.Add(oidcStateExp).Unix(),
+		"iat":     now.Unix(),
+		"nbf":     now.Unix(),
+		"iss":     app.config.auth.token.iss,
+		"aud":     app.config.auth.token.aud,
+		"nonce":   nonce,
+		"purpose": oidcStatePurpose,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	authURL, err := app.oidcProvider.AuthCodeURL(r.Context(), state, nonce)
+	if err != nil {
+		app.serviceUnavailableResponse(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, &OIDCAuthorization{
+		AuthorizationURL: authURL,
+		State:            state,
+	}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// oidcTokenHandler godoc
+//
+//	@Summary		Finish an oidc login
+//	@Description	Exchange the code of the keycloak redirect for a token, the user is provisioned on the first login. A login without a second factor at keycloak (acr or amr of the id token) gets the local two-factor challenge when the user needs one
+//	@Tags			authentication
+//	@Accept			json
+//	@produce		json
+//	@Param			payload	body		OIDCTokenPayload	true	"Code and state"
+//	@Success		201		{object}	UserToken			"Token"
+//	@Success		202		{object}	TwoFactorChallenge	"Second factor required"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/oidc/token [post]
+func (app *application) oidcTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload OIDCTokenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	nonce, err := app.getOIDCNonce(payload.State)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	tokens, err := app.oidcProvider.Exchange(ctx, payload.Code)
+	if err != nil {
+		app.handleOIDCError(w, r, err)
+		return
+	}
+
+	claims, err := app.oidcProvider.Verify(ctx, tokens.IDToken)
+	if err != nil {
+		app.handleOIDCError(w, r, err)
+		return
+	}
+	if claimNonce, _ := claims.MapClaims["nonce"].(string); claimNonce != nonce {
+		app.unauthorizedErrorResponse(w, r, ErrInvalidOIDCState)
+		return
+	}
+
+	user, err := app.oidcUser(ctx, claims)
+	if err != nil {
+		app.handleOIDCError(w, r, err)
+		return
+	}
+
+	// the local second factor is asked unless keycloak asked for one
+	if !app.oidcProvider.MultiFactor(claims) {
+		challenge, err := app.twoFactorChallenge(ctx, user)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if challenge != nil {
+			if err := app.jsonResponse(w, http.StatusAccepted, challenge); err != nil {
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+	}
+
+	userToken, err := app.createSession(ctx, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, userToken); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) getOIDCNonce(state string) (string, error) {
+	jwtToken, err := app.authenticator.ValidateToken(state)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidOIDCState, err)
+	}
+
+	claims, _ := jwtToken.Claims.(jwt.MapClaims)
+	if purpose, _ := claims["purpose"].(string); purpose != oidcStatePurpose {
+		return "", ErrInvalidOIDCState
+	}
+	nonce, _ := claims["nonce"].(string)
+	if nonce == "" {
+		return "", ErrInvalidOIDCState
+	}
+
+	return nonce, nil
+}
+
+// oidcUser returns the user of the subject of a token, the user is created
+// on the first login and its role follows the keycloak groups.
+func (app *application) oidcUser(ctx context.Context, claims *oidc.Claims) (*store.User, error) {
+	roleName, err := app.oidcRoleName(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := app.oidcProvider.Issuer()
+	user, err := app.store.Users.GetByIdentity(ctx, issuer, claims.Subject)
+	switch {
+	case err == nil && user.Role.Name == roleName:
+		return user, nil
+	case err != nil && !errors.Is(err, store.ErrNotFound):
+		return nil, err
+	}
+
+	if claims.Email == "" {
+		return nil, ErrOIDCEmailMissing
+	}
+	username := claims.Username
+	if username == "" {
+		username, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	// only a verified email may take over the local user of the email
+	user, err = app.store.Users.ProvisionIdentity(ctx, issuer, claims.Subject, &store.User{
+		Username: username,
+		Email:    claims.Email,
+		Role:     store.Role{Name: roleName},
+	}, claims.EmailVerified)
+	if err != nil {
+		return nil, err
+	}
+
+	if app.config.redisCfg.enabled {
+		app.cacheStorage.Users.Delete(ctx, user.ID)
+	}
+
+	return user, nil
+}
+
+// oidcRoleName returns the highest role the groups of the user map to, a user
+// without a mapped group gets the user role.
+func (app *application) oidcRoleName(ctx context.Context, claims *oidc.Claims) (string, error) {
+	var highest *store.Role
+	for _, name := range app.oidcProvider.RoleNames(claims) {
+		role, err := app.store.Roles.GetByName(ctx, name)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				app.logger.Warnw("oidc role mapping names an unknown role", "role", name)
+				continue
+			}
+			return "", err
+		}
+		if highest == nil || role.Level > highest.Level {
+			highest = role
+		}
+	}
+
+	if highest == nil {
+		return "user", nil
+	}
+	return highest.Name, nil
+}
+
+// isOIDCToken tells a token of the oidc provider from a local one by its
+// issuer, the signature is checked by the provider.
+func (app *application) isOIDCToken(token string) bool {
+	if app.oidcProvider == nil {
+		return false
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return false
+	}
+	issuer, _ := claims.GetIssuer()
+	return issuer == app.oidcProvider.Issuer()
+}
+
+func (app *application) handleOIDCError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, oidc.ErrInvalidToken), errors.Is(err, oidc.ErrUnknownKey):
+		app.unauthorizedErrorResponse(w, r, err)
+	case errors.Is(err, ErrOIDCEmailMissing):
+		app.badRequestResponse(w, r, err)
+	case errors.Is(err, store.ErrDuplicateEmail):
+		// a local user has the email the provider did not verify
+		app.conflictResponse(w, r, err)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
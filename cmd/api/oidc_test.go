@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/damarteplok/social/internal/auth"
+	"github.com/damarteplok/social/internal/oidc"
+	"github.com/damarteplok/social/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestOIDCLogin(t *testing.T) {
+	fake := oidc.NewFakeProvider()
+	keycloak := httptest.NewServer(fake)
+	t.Cleanup(keycloak.Close)
+	fake.Issuer = keycloak.URL
+
+	app := newTestApplication(t, config{
+		auth: authConfig{
+			token: tokenConfig{
+				exp:        time.Hour,
+				iss:        "test-iss",
+				aud:        "test-aud",
+				refreshExp: time.Hour,
+			},
+			twoFactor: twoFactorConfig{
+				challengeExp: time.Minute,
+			},
+		},
+	})
+	app.authenticator = auth.NewJWTAuthenticator("test", "test-aud", "test-iss")
+	app.oidcProvider = oidc.NewProvider(oidc.Config{
+		Enabled:      true,
+		IssuerURL:    keycloak.URL,
+		ClientID:     "social",
+		RedirectURL:  "http://localhost:5173/oidc/callback",
+		RoleMapping:  map[string]string{"moderators": "moderator"},
+		MFAAcrValues: []string{"2"},
+		MFAAmrValues: []string{"otp"},
+	})
+	mux := app.mount()
+
+	decode := func(t *testing.T, rr *httptest.ResponseRecorder, data any) {
+		t.Helper()
+		envelope := struct {
+			Data any `json:"data"`
+		}{Data: data}
+		if err := json.NewDecoder(rr.Body).Decode(&envelope); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// login follows the redirect of the provider with the claims of the id
+	// token
+	login := func(t *testing.T, code string, claims jwt.MapClaims) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, "/v1/authentication/oidc/authorize", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var authorization OIDCAuthorization
+		decode(t, rr, &authorization)
+		authURL, err := url.Parse(authorization.AuthorizationURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		claims["nonce"] = authURL.Query().Get("nonce")
+		fake.AddCode(code, claims)
+		body := `{"code": "` + code + `", "state": "` + authorization.State + `"}`
+		req, err = http.NewRequest(http.MethodPost, "/v1/authentication/oidc/token", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return executeRequest(req, mux)
+	}
+
+	t.Run("should log in with the code of the provider", func(t *testing.T) {
+		rr := login(t, "code", jwt.MapClaims{
+			"sub":            "f3a1",
+			"aud":            "social",
+			"email":          "damar@test.com",
+			"email_verified": true,
+			"acr":            "2",
+			"groups":         []string{"/moderators"},
+		})
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		var session UserToken
+		decode(t, rr, &session)
+		if session.User.Username != "damar" || session.User.Role.Name != "moderator" {
+			t.Errorf("unexpected user %+v", session.User)
+		}
+	})
+
+	t.Run("should ask the local second factor without one at the provider", func(t *testing.T) {
+		rr := login(t, "code-pwd", jwt.MapClaims{
+			"sub":            "f3a1",
+			"aud":            "social",
+			"email":          "damar@test.com",
+			"email_verified": true,
+			"acr":            "1",
+			"amr":            []string{"pwd"},
+			"groups":         []string{"/moderators"},
+		})
+		checkResponseCode(t, http.StatusAccepted, rr.Code)
+
+		var challenge TwoFactorChallenge
+		decode(t, rr, &challenge)
+		if challenge.ChallengeToken == "" || !challenge.EnrollmentRequired {
+			t.Errorf("unexpected challenge %+v", challenge)
+		}
+	})
+
+	t.Run("should reject a code without the nonce of the state", func(t *testing.T) {
+		state, err := app.authenticator.GenerateToken(jwt.MapClaims{
+			"exp":     time.Now().Add(time.Minute).Unix(),
+			"iss":     "test-iss",
+			"aud":     "test-aud",
+			"nonce":   "expected",
+			"purpose": oidcStatePurpose,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fake.AddCode("other", jwt.MapClaims{"sub": "f3a1", "aud": "social", "nonce": "other"})
+
+		body := `{"code": "other", "state": "` + state + `"}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/oidc/token", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("should accept an access token of the provider", func(t *testing.T) {
+		token := fake.Token(jwt.MapClaims{
+			"sub":   "f3a1",
+			"azp":   "social",
+			"email": "damar@test.com",
+		})
+		req, err := http.NewRequest(http.MethodGet, "/v1/authentication/user", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+
+		var user store.User
+		decode(t, rr, &user)
+		if user.Email != "damar@test.com" || user.Role.Name != "user" {
+			t.Errorf("unexpected user %+v", user)
+		}
+	})
+
+	t.Run("should require a second factor in the token of the provider", func(t *testing.T) {
+		cases := map[string]struct {
+			amr      []string
+			expected int
+		}{
+			"password": {[]string{"pwd"}, http.StatusUnauthorized},
+			"otp":      {[]string{"pwd", "otp"}, http.StatusOK},
+		}
+		for name, tt := range cases {
+			token := fake.Token(jwt.MapClaims{
+				"sub":    "f3a1",
+				"azp":    "social",
+				"email":  "damar@test.com",
+				"amr":    tt.amr,
+				"groups": []string{"/moderators"},
+			})
+			req, err := http.NewRequest(http.MethodGet, "/v1/authentication/user", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			rr := executeRequest(req, mux)
+			if rr.Code != tt.expected {
+				t.Errorf("%s: expected response code %d, got %d", name, tt.expected, rr.Code)
+			}
+		}
+	})
+
+	t.Run("should reject a token of the provider for another client", func(t *testing.T) {
+		token := fake.Token(jwt.MapClaims{"sub": "f3a1", "azp": "operate", "email": "damar@test.com"})
+		req, err := http.NewRequest(http.MethodGet, "/v1/authentication/user", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const (
+	idempotencyKeyHeader    = "Idempotency-Key"
+	maxIdempotencyKeyLength = 256
+)
+
+var (
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for another request")
+	ErrProcessNotStarted    = errors.New("process instance is not started yet, try again later")
+	ErrOutboxDisabled       = errors.New("outbox dispatcher is disabled, process commands are never sent")
+)
+
+// getIdempotencyKey returns the Idempotency-Key header, requests without it get
+// a new key and are never deduplicated.
+func getIdempotencyKey(r *http.Request) (string, error) {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if key == "" {
+		return uuid.New().String(), nil
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		return "", fmt.Errorf("%s must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength)
+	}
+
+	return key, nil
+}
+
+// cancelIdempotencyKey allows a single cancel command per row.
+func cancelIdempotencyKey(tableName string, id int64) string {
+	return fmt.Sprintf("cancel-%s-%d", tableName, id)
+}
+
+// requireOutbox answers 503 when the outbox dispatcher is disabled, the start
+// and cancel commands written by the handler would never reach zeebe.
+func (app *application) requireOutbox(w http.ResponseWriter, r *http.Request) bool {
+	if !app.config.outbox.Enabled {
+		app.serviceUnavailableResponse(w, r, ErrOutboxDisabled)
+		return false
+	}
+
+	return true
+}
+
+func (app *application) triggerOutbox() {
+	if app.outbox != nil {
+		app.outbox.Trigger()
+	}
+}
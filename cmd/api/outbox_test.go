@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProcessCommandsWithoutOutbox(t *testing.T) {
+	app := newTestApplication(t, config{})
+
+	handlers := map[string]http.HandlerFunc{
+		"create": app.createPembuatanMediaBeritaTechnologyHandler,
+		"cancel": app.cancelPembuatanMediaBeritaTechnologyHandler,
+	}
+	for name, handler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+			rr := httptest.NewRecorder()
+			handler(rr, req)
+
+			checkResponseCode(t, http.StatusServiceUnavailable, rr.Code)
+		})
+	}
+}
@@ -1,14 +1,14 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/damarteplok/social/internal/camunda"
 	"github.com/damarteplok/social/internal/store"
 	"github.com/go-chi/chi/v5"
 )
@@ -30,17 +30,25 @@ type DataStorePembuatanMediaBeritaTechnologyWrapper struct {
 // Create PembuatanMediaBeritaTechnology godoc
 //
 //	@Summary		Create PembuatanMediaBeritaTechnology
-//	@Description	Create PembuatanMediaBeritaTechnology
+//	@Description	Create PembuatanMediaBeritaTechnology, the process instance is started in the background: the response is 202 Accepted instead of 201 Created and process_instance_key is set once zeebe started the instance. A retry with the same Idempotency-Key returns the first row with 200.
 //	@Tags			bpmn/PembuatanMediaBeritaTechnology
 //	@Accept			json
 //	@produce		json
-//	@Param			payload	body		CreatePembuatanMediaBeritaTechnologyPayload		true	"PembuatanMediaBeritaTechnology Payload"
-//	@Success		201		{object}	DataStorePembuatanMediaBeritaTechnologyWrapper	"PembuatanMediaBeritaTechnology Created"
-//	@Failure		400		{object}	error
-//	@Failure		500		{object}	error
+//	@Param			Idempotency-Key	header		string											false	"Retries with the same key return the first row"
+//	@Param			payload			body		CreatePembuatanMediaBeritaTechnologyPayload		true	"PembuatanMediaBeritaTechnology Payload"
+//	@Success		200				{object}	DataStorePembuatanMediaBeritaTechnologyWrapper	"PembuatanMediaBeritaTechnology Already Created"
+//	@Success		202				{object}	DataStorePembuatanMediaBeritaTechnologyWrapper	"PembuatanMediaBeritaTechnology Created"
+//	@Failure		400				{object}	error
+//	@Failure		409				{object}	error
+//	@Failure		500				{object}	error
+//	@Failure		503				{object}	error	"Outbox dispatcher is disabled"
 //	@Security		ApiKeyAuth
 //	@Router			/bpmn/pembuatan_media_berita_technology  [post]
 func (app *application) createPembuatanMediaBeritaTechnologyHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.requireOutbox(w, r) {
+		return
+	}
+
 	user := GetUserFromContext(r)
 	var payload CreatePembuatanMediaBeritaTechnologyPayload
 	if err := readJSON(w, r, &payload); err != nil {
@@ -53,6 +61,12 @@ func (app *application) createPembuatanMediaBeritaTechnologyHandler(w http.Respo
 		return
 	}
 
+	idempotencyKey, err := getIdempotencyKey(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	// TODO: Change in this code
 	// TODO: ADD to storage interface for use create store
 
@@ -73,31 +87,68 @@ func (app *application) createPembuatanMediaBeritaTechnologyHandler(w http.Respo
 		}
 	}
 
-	resp, err := app.zeebeClient.StartWorkflow(ctx, store.PembuatanMediaBeritaTechnologyProcessDefinitionKey, variables)
+	// a retried request returns the row of the first one, the keys are
+	// scoped to the user
+	msg, err := app.store.Outbox.GetByIdempotencyKey(ctx, user.ID, idempotencyKey)
+	if err == nil {
+		if msg.Command != store.OutboxStartProcess || msg.TableName != store.PembuatanMediaBeritaTechnologyTableName {
+			app.conflictResponse(w, r, ErrIdempotencyKeyReused)
+			return
+		}
+
+		model, err := app.getPembuatanMediaBeritaTechnology(ctx, msg.RowID)
+		if err != nil {
+			app.handleRequestError(w, r, err)
+			return
+		}
+
+		if err := app.jsonResponse(w, http.StatusOK, model); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	startPayload, err := json.Marshal(store.StartProcessPayload{
+		ProcessDefinitionKey: store.PembuatanMediaBeritaTechnologyProcessDefinitionKey,
+		Variables:            variables,
+	})
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
 	model := &store.PembuatanMediaBeritaTechnology{
-		ProcessDefinitionKey: resp.GetProcessDefinitionKey(),
-		Version:              resp.GetVersion(),
-		ProcessInstanceKey:   resp.GetProcessInstanceKey(),
+		ProcessDefinitionKey: store.PembuatanMediaBeritaTechnologyProcessDefinitionKey,
+		Version:              store.PembuatanMediaBeritaTechnologyVersion,
 		ResourceName:         store.PembuatanMediaBeritaTechnologyResourceName,
 		CreatedBy:            user.ID,
-		TaskState:            StringPtr("CREATED"),
+		TaskState:            StringPtr(store.ProcessStateCreated),
 	}
 
-	if err := app.store.PembuatanMediaBeritaTechnology.Create(ctx, model); err != nil {
-		if err := app.zeebeClient.CancelWorkflow(ctx, model.ProcessInstanceKey); err != nil {
+	// the row and the start command are committed together, the outbox
+	// dispatcher sets process_instance_key once zeebe started the instance
+	if err := app.store.PembuatanMediaBeritaTechnology.CreateWithOutbox(ctx, model, &store.OutboxMessage{
+		IdempotencyKey: idempotencyKey,
+		CreatedBy:      user.ID,
+		Command:        store.OutboxStartProcess,
+		Payload:        startPayload,
+	}); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, err)
+		default:
 			app.internalServerError(w, r, err)
-			return
 		}
-		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusCreated, model); err != nil {
+	app.triggerOutbox()
+
+	if err := app.jsonResponse(w, http.StatusAccepted, model); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -106,7 +157,7 @@ func (app *application) createPembuatanMediaBeritaTechnologyHandler(w http.Respo
 // Cancel PembuatanMediaBeritaTechnology godoc
 //
 //	@Summary		Cancel PembuatanMediaBeritaTechnology
-//	@Description	Cancel PembuatanMediaBeritaTechnology
+//	@Description	Cancel PembuatanMediaBeritaTechnology, the process instance is canceled in the background
 //	@Tags			bpmn/PembuatanMediaBeritaTechnology
 //	@Accept			json
 //	@produce		json
@@ -114,9 +165,14 @@ func (app *application) createPembuatanMediaBeritaTechnologyHandler(w http.Respo
 //	@Success		200	{string}	string	"PembuatanMediaBeritaTechnology Canceled"
 //	@Failure		400	{object}	error
 //	@Failure		500	{object}	error
+//	@Failure		503	{object}	error	"Outbox dispatcher is disabled"
 //	@Security		ApiKeyAuth
 //	@Router			/bpmn/pembuatan_media_berita_technology/{id}  [delete]
 func (app *application) cancelPembuatanMediaBeritaTechnologyHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.requireOutbox(w, r) {
+		return
+	}
+
 	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil || id < 1 {
 		app.badRequestResponse(w, r, err)
@@ -134,17 +190,16 @@ func (app *application) cancelPembuatanMediaBeritaTechnologyHandler(w http.Respo
 		return
 	}
 
-	// delete model
-	if err := app.store.PembuatanMediaBeritaTechnology.Delete(ctx, model.ID); err != nil {
-		app.internalServerError(w, r, err)
+	// delete model and cancel the process instance through the outbox
+	if err := app.store.PembuatanMediaBeritaTechnology.DeleteWithOutbox(ctx, model.ID, &store.OutboxMessage{
+		IdempotencyKey: cancelIdempotencyKey(store.PembuatanMediaBeritaTechnologyTableName, model.ID),
+		Command:        store.OutboxCancelProcess,
+	}); err != nil {
+		app.handleRequestError(w, r, err)
 		return
 	}
 
-	// TODO: add rollback if failed to cancel in zeebe
-	if err := app.zeebeClient.CancelWorkflow(ctx, model.ProcessInstanceKey); err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
+	app.triggerOutbox()
 
 	// delete cache
 	app.cacheStorage.PembuatanMediaBeritaTechnology.Delete(ctx, model.ID)
@@ -187,28 +242,27 @@ func (app *application) getByIdPembuatanMediaBeritaTechnologyHandler(w http.Resp
 		return
 	}
 
-	// get zeebe client untuk mendapatkan detail task
-	url := fmt.Sprintf("%s%s/%d", app.config.camundaRest.camundaOperateBaseUrl, V1ProcessInstanceUrl, model.ProcessInstanceKey)
-	resp, err := app.zeebeClientRest.SendRequest(
-		ctx,
-		http.MethodGet,
-		url,
-		bytes.NewBuffer([]byte("{}")),
-	)
-	if err != nil {
-		app.handleRequestError(w, r, err)
+	// the process instance is not started by the outbox dispatcher yet
+	if model.ProcessInstanceKey == 0 {
+		if err := app.jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"model":   model,
+			"camunda": nil,
+		}); err != nil {
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
-	var jsonData interface{}
-	if err := json.Unmarshal(resp, &jsonData); err != nil {
-		app.internalServerError(w, r, err)
+	// get zeebe client untuk mendapatkan detail task
+	instance, err := app.camundaClient.ProcessInstances.Get(ctx, model.ProcessInstanceKey)
+	if err != nil {
+		app.handleRequestError(w, r, err)
 		return
 	}
 
 	if err := app.jsonResponse(w, http.StatusOK, map[string]interface{}{
 		"model":   model,
-		"camunda": jsonData,
+		"camunda": instance,
 	}); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -258,6 +312,7 @@ func (app *application) getPembuatanMediaBeritaTechnology(ctx context.Context, m
 //	@Success		200				{string}	string	"PembuatanMediaBeritaTechnology GetHistoryById"
 //	@Failure		400				{object}	error
 //	@Failure		404				{object}	error
+//	@Failure		409				{object}	error	"Process instance is not started yet"
 //	@Failure		500				{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/bpmn/pembuatan_media_berita_technology/{id}/history  [get]
@@ -289,57 +344,28 @@ func (app *application) getHistoryByIdPembuatanMediaBeritaTechnologyHandler(w ht
 		return
 	}
 
-	jsonTemplate := `
-{
-    "filter": {
-        "processInstanceKey": %d
-    }, 
-    "size": %s, 
-    "sort": [{"field": "%s", "order": "%s"}]
-    %s
-}`
-
-	var searchAfterStr, searchBeforeStr string
-
-	if flowNodeQueryParams.SearchAfter != "" {
-		searchAfterStr = fmt.Sprintf(`, "searchAfter": %s`, flowNodeQueryParams.SearchAfter)
-	}
-
-	if flowNodeQueryParams.SearchBefore != "" {
-		searchBeforeStr = fmt.Sprintf(`, "searchBefore": %s`, flowNodeQueryParams.SearchBefore)
+	if model.ProcessInstanceKey == 0 {
+		app.conflictResponse(w, r, ErrProcessNotStarted)
+		return
 	}
 
-	searchParams := searchAfterStr + searchBeforeStr
-
-	body := []byte(fmt.Sprintf(
-		jsonTemplate,
-		model.ProcessInstanceKey,
-		flowNodeQueryParams.Size,
-		flowNodeQueryParams.Sort,
-		flowNodeQueryParams.Order,
-		searchParams,
-	))
-
-	// get history from operate api rest api
-	url := fmt.Sprintf("%s%s/search", app.config.camundaRest.camundaOperateBaseUrl, V1FlowNodeUrl)
-	resp, err := app.zeebeClientRest.SendRequest(
-		ctx,
-		http.MethodPost,
-		url,
-		bytes.NewBuffer(body),
-	)
+	results, err := app.camundaClient.FlowNodeInstances.Search(ctx, camunda.Query[camunda.FlowNodeInstanceFilter]{
+		Filter: camunda.FlowNodeInstanceFilter{
+			ProcessInstanceKey: &model.ProcessInstanceKey,
+			Type:               flowNodeQueryParams.Type,
+			State:              flowNodeQueryParams.State,
+		},
+		Size:         flowNodeQueryParams.Size,
+		Sort:         flowNodeQueryParams.SortBy(),
+		SearchAfter:  flowNodeQueryParams.SearchAfter,
+		SearchBefore: flowNodeQueryParams.SearchBefore,
+	})
 	if err != nil {
 		app.handleRequestError(w, r, err)
 		return
 	}
 
-	var jsonData interface{}
-	if err := json.Unmarshal(resp, &jsonData); err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-
-	if err := app.jsonResponse(w, http.StatusOK, jsonData); err != nil {
+	if err := app.jsonResponse(w, http.StatusOK, results); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -356,6 +382,7 @@ func (app *application) getHistoryByIdPembuatanMediaBeritaTechnologyHandler(w ht
 //	@Param			page	query		string	true	"Page 1"
 //	@Param			search	query		string	false	"Search string"
 //	@Param			sort	query		string	false	"Sort desc"
+//	@Param			state	query		string	false	"State CREATED COMPLETED CANCELED FAILED"
 //	@Param			since	query		string	false	"Since desc"
 //	@Param			until	query		string	false	"Until desc"
 //	@Success		200		{string}	string	"PembuatanMediaBeritaTechnology Search"
@@ -438,6 +465,11 @@ func (app *application) updatePembuatanMediaBeritaTechnologyHandler(w http.Respo
 		return
 	}
 
+	if model.ProcessInstanceKey == 0 {
+		app.conflictResponse(w, r, ErrProcessNotStarted)
+		return
+	}
+
 	variables := make(map[string]interface{})
 	variables["updated_by"] = map[string]interface{}{
 		"id":         user.ID,
@@ -490,6 +522,7 @@ func (app *application) updatePembuatanMediaBeritaTechnologyHandler(w http.Respo
 //	@Success		200				{string}	string	"PembuatanMediaBeritaTechnology GetProcessIncidents"
 //	@Failure		400				{object}	error
 //	@Failure		404				{object}	error
+//	@Failure		409				{object}	error	"Process instance is not started yet"
 //	@Failure		500				{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/bpmn/pembuatan_media_berita_technology/{id}/incidents  [get]
@@ -521,57 +554,28 @@ func (app *application) getIncidentsByIdPembuatanMediaBeritaTechnologyHandler(w
 		return
 	}
 
-	jsonTemplate := `
-{
-    "filter": {
-        "processInstanceKey": %d
-    }, 
-    "size": %s, 
-    "sort": [{"field": "%s", "order": "%s"}]
-    %s
-}`
-
-	var searchAfterStr, searchBeforeStr string
-
-	if flowNodeQueryParams.SearchAfter != "" {
-		searchAfterStr = fmt.Sprintf(`, "searchAfter": %s`, flowNodeQueryParams.SearchAfter)
-	}
-
-	if flowNodeQueryParams.SearchBefore != "" {
-		searchBeforeStr = fmt.Sprintf(`, "searchBefore": %s`, flowNodeQueryParams.SearchBefore)
+	if model.ProcessInstanceKey == 0 {
+		app.conflictResponse(w, r, ErrProcessNotStarted)
+		return
 	}
 
-	searchParams := searchAfterStr + searchBeforeStr
-
-	body := []byte(fmt.Sprintf(
-		jsonTemplate,
-		model.ProcessInstanceKey,
-		flowNodeQueryParams.Size,
-		flowNodeQueryParams.Sort,
-		flowNodeQueryParams.Order,
-		searchParams,
-	))
-
-	// get history from operate api rest api
-	url := fmt.Sprintf("%s%s/search", app.config.camundaRest.camundaOperateBaseUrl, V1IncidentUrl)
-	resp, err := app.zeebeClientRest.SendRequest(
-		ctx,
-		http.MethodPost,
-		url,
-		bytes.NewBuffer(body),
-	)
+	results, err := app.camundaClient.Incidents.Search(ctx, camunda.Query[camunda.IncidentFilter]{
+		Filter: camunda.IncidentFilter{
+			ProcessInstanceKey: &model.ProcessInstanceKey,
+			Type:               flowNodeQueryParams.Type,
+			State:              flowNodeQueryParams.State,
+		},
+		Size:         flowNodeQueryParams.Size,
+		Sort:         flowNodeQueryParams.SortBy(),
+		SearchAfter:  flowNodeQueryParams.SearchAfter,
+		SearchBefore: flowNodeQueryParams.SearchBefore,
+	})
 	if err != nil {
 		app.handleRequestError(w, r, err)
 		return
 	}
 
-	var jsonData interface{}
-	if err := json.Unmarshal(resp, &jsonData); err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-
-	if err := app.jsonResponse(w, http.StatusOK, jsonData); err != nil {
+	if err := app.jsonResponse(w, http.StatusOK, results); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -1,10 +1,7 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
 	"net/http"
 	"time"
 
@@ -12,10 +9,11 @@ import (
 )
 
 type FormDataPembuatanArtikel struct {
-	Title   string  `json:"title" validate:"required"`
-	Content string  `json:"content,omitempty"`
-	Version float64 `json:"version" validate:"required"`
-	Tags    string  `json:"tags,omitempty"`
+	Title    *string  `json:"title" validate:"required"`
+	Content  *string  `json:"content,omitempty"`
+	Version  *float64 `json:"version" validate:"required"`
+	Tags     *string  `json:"tags,omitempty" validate:"omitempty,max=256"`
+	Decision *string  `json:"decision" validate:"required,oneof=submit draft"`
 }
 
 // GetUserTaskActive PembuatanArtikel godoc
@@ -38,7 +36,7 @@ type FormDataPembuatanArtikel struct {
 //	@Failure		404				{object}	error
 //	@Failure		500				{object}	error
 //	@Security		ApiKeyAuth
-//	@Router			/bpmn/pembuatanartikel/search  [get]
+//	@Router			/bpmn/pembuatan_media_berita_technology/tasks/pembuatanartikel  [get]
 func (app *application) getUserTaskActivePembuatanArtikelHandler(w http.ResponseWriter, r *http.Request) {
 	taskListQueryParams, err := getTaskListQueryParams(r)
 	if err != nil {
@@ -59,56 +57,182 @@ func (app *application) getUserTaskActivePembuatanArtikelHandler(w http.Response
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
 
-	jsonTemplate := `
-{
-	"taskDefinitionId": "%s",
-	"state:": "%s",
-    "pageSize": %d, 
-    "sort": [{"field": "%s", "order": "%s"}]
-    %s
-}`
+	tasks, err := app.searchUserTasks(ctx, store.PembuatanArtikelID, taskListQueryParams, GetUserFromContext(r))
+	if err != nil {
+		app.handleRequestError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tasks); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// Search PembuatanArtikel godoc
+//
+//	@Summary		Search PembuatanArtikel
+//	@Description	Search the submitted PembuatanArtikel forms
+//	@Tags			bpmn/PembuatanArtikel
+//	@Accept			json
+//	@produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			page	query		int		false	"Page"
+//	@Param			sort	query		string	false	"Sort asc desc"
+//	@Param			search	query		string	false	"Search"
+//	@Param			since	query		string	false	"Since"
+//	@Param			until	query		string	false	"Until"
+//	@Success		200		{string}	string	"PembuatanArtikel Search"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bpmn/pembuatan_media_berita_technology/tasks/pembuatanartikel/submissions  [get]
+func (app *application) searchPembuatanArtikelHandler(w http.ResponseWriter, r *http.Request) {
+	pq := store.PaginatedQuery{
+		Limit: 20,
+		Page:  1,
+		Sort:  "desc",
+	}
+	if err := pq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	models, err := app.store.PembuatanArtikel.Search(ctx, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, models); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Claim PembuatanArtikel godoc
+//
+//	@Summary		Claim PembuatanArtikel
+//	@Description	Assign the PembuatanArtikel user task to the current user
+//	@Tags			bpmn/PembuatanArtikel
+//	@Accept			json
+//	@produce		json
+//	@Param			taskKey	path		int	true	"Task Key"
+//	@Success		200		{object}	camunda.Task
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bpmn/pembuatanartikel/{taskKey}/claim  [post]
+func (app *application) claimPembuatanArtikelHandler(w http.ResponseWriter, r *http.Request) {
+	app.claimUserTask(w, r, store.PembuatanArtikelID)
+}
+
+// Unclaim PembuatanArtikel godoc
+//
+//	@Summary		Unclaim PembuatanArtikel
+//	@Description	Unassign the PembuatanArtikel user task from the current user
+//	@Tags			bpmn/PembuatanArtikel
+//	@Accept			json
+//	@produce		json
+//	@Param			taskKey	path		int	true	"Task Key"
+//	@Success		200		{object}	camunda.Task
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bpmn/pembuatanartikel/{taskKey}/unclaim  [post]
+func (app *application) unclaimPembuatanArtikelHandler(w http.ResponseWriter, r *http.Request) {
+	app.unclaimUserTask(w, r, store.PembuatanArtikelID)
+}
 
-	var searchAfterStr, searchBeforeStr string
+// Complete PembuatanArtikel godoc
+//
+//	@Summary		Complete PembuatanArtikel
+//	@Description	Complete the PembuatanArtikel user task claimed by the current user
+//	@Tags			bpmn/PembuatanArtikel
+//	@Accept			json
+//	@produce		json
+//	@Param			taskKey	path		int				true	"Task Key"
+//	@Param			payload	body		FormDataPembuatanArtikel	true	"Form Data PembuatanArtikel"
+//	@Success		201		{object}	store.PembuatanArtikel
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bpmn/pembuatanartikel/{taskKey}/complete  [post]
+func (app *application) completePembuatanArtikelHandler(w http.ResponseWriter, r *http.Request) {
+	taskKey, err := getTaskKeyParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
 
-	if taskListQueryParams.SearchAfter != "" {
-		searchAfterStr = fmt.Sprintf(`, "searchAfter": %s`, taskListQueryParams.SearchAfter)
+	var payload FormDataPembuatanArtikel
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
 	}
 
-	if taskListQueryParams.SearchBefore != "" {
-		searchBeforeStr = fmt.Sprintf(`, "searchBefore": %s`, taskListQueryParams.SearchBefore)
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
 	}
 
-	searchParams := searchAfterStr + searchBeforeStr
+	ctx := r.Context()
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
 
-	body := []byte(fmt.Sprintf(
-		jsonTemplate,
-		store.PembuatanArtikelID,
-		taskListQueryParams.State,
-		taskListQueryParams.Size,
-		taskListQueryParams.Sort,
-		taskListQueryParams.Order,
-		searchParams))
+	user := GetUserFromContext(r)
 
-	// get history from operate api rest api
-	url := fmt.Sprintf("%s%s/search", app.config.camundaRest.camundaTasklistBaseUrl, V1TasklistUrl)
-	resp, err := app.zeebeClientRest.SendRequest(
-		ctx,
-		http.MethodPost,
-		url,
-		bytes.NewBuffer(body),
-	)
+	task, err := app.getAssignedUserTask(ctx, taskKey, store.PembuatanArtikelID, user)
 	if err != nil {
-		app.handleRequestError(w, r, err)
+		app.userTaskErrorResponse(w, r, err)
 		return
 	}
 
-	var jsonData interface{}
-	if err := json.Unmarshal(resp, &jsonData); err != nil {
+	variables, err := formVariables(payload)
+	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusOK, jsonData); err != nil {
+	model := &store.PembuatanArtikel{
+		Name:       store.PembuatanArtikelName,
+		TaskId:     task.ID,
+		FormId:     store.PembuatanArtikelFormID,
+		Properties: variables,
+		CreatedBy:  user.ID,
+		Title:      payload.Title,
+		Content:    payload.Content,
+		Version:    payload.Version,
+		Tags:       payload.Tags,
+		Decision:   payload.Decision,
+	}
+
+	if err := app.store.PembuatanArtikel.Create(ctx, model); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.completeUserTask(ctx, task, variables); err != nil {
+		// the task is still open, drop the submission so it can be sent again
+		if errDelete := app.store.PembuatanArtikel.Delete(ctx, model.ID); errDelete != nil {
+			app.logger.Errorw("failed to delete user task submission", "id", model.ID, "error", errDelete)
+		}
+		app.handleRequestError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, model); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -1,7 +1,233 @@
 package main
 
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/damarteplok/social/internal/store"
+)
+
 type FormDataReviewingArtikel struct {
-	Note string `json:"note,omitempty"`
+	Note     *string `json:"note,omitempty"`
+	Decision *string `json:"decision" validate:"required,oneof=lolos perbaikan"`
 }
 
-	
+// GetUserTaskActive ReviewingArtikel godoc
+//
+//	@Summary		GetUserTaskActive ReviewingArtikel
+//	@Description	GetUserTaskActive ReviewingArtikel
+//	@Tags			bpmn/ReviewingArtikel
+//	@Accept			json
+//	@produce		json
+//	@Param			size			query		string	false	"Size 50"
+//	@Param			order			query		string	false	"Order DESC ASC"
+//	@Param			sort			query		string	false	"Sort creationTime"
+//
+// @Param			state			query		string	false	"State CREATED"
+//
+//	@Param			searchAfter		query		string	false	"SearchAfter 1731486859777,2251799814109407"
+//	@Param			searchBefore	query		string	false	"SearchBefore 1731486859777,2251799814109407"
+//	@Success		200				{string}	string	"ReviewingArtikel GetUserTaskActive"
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bpmn/pembuatan_media_berita_technology/tasks/reviewingartikel  [get]
+func (app *application) getUserTaskActiveReviewingArtikelHandler(w http.ResponseWriter, r *http.Request) {
+	taskListQueryParams, err := getTaskListQueryParams(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if taskListQueryParams.Sort == "" {
+		taskListQueryParams.Sort = "creationTime"
+	}
+
+	if taskListQueryParams.State == "" {
+		taskListQueryParams.State = "CREATED"
+	}
+
+	ctx := r.Context()
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	tasks, err := app.searchUserTasks(ctx, store.ReviewingArtikelID, taskListQueryParams, GetUserFromContext(r))
+	if err != nil {
+		app.handleRequestError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tasks); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// Search ReviewingArtikel godoc
+//
+//	@Summary		Search ReviewingArtikel
+//	@Description	Search the submitted ReviewingArtikel forms
+//	@Tags			bpmn/ReviewingArtikel
+//	@Accept			json
+//	@produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			page	query		int		false	"Page"
+//	@Param			sort	query		string	false	"Sort asc desc"
+//	@Param			search	query		string	false	"Search"
+//	@Param			since	query		string	false	"Since"
+//	@Param			until	query		string	false	"Until"
+//	@Success		200		{string}	string	"ReviewingArtikel Search"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bpmn/pembuatan_media_berita_technology/tasks/reviewingartikel/submissions  [get]
+func (app *application) searchReviewingArtikelHandler(w http.ResponseWriter, r *http.Request) {
+	pq := store.PaginatedQuery{
+		Limit: 20,
+		Page:  1,
+		Sort:  "desc",
+	}
+	if err := pq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	models, err := app.store.ReviewingArtikel.Search(ctx, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, models); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// Claim ReviewingArtikel godoc
+//
+//	@Summary		Claim ReviewingArtikel
+//	@Description	Assign the ReviewingArtikel user task to the current user
+//	@Tags			bpmn/ReviewingArtikel
+//	@Accept			json
+//	@produce		json
+//	@Param			taskKey	path		int	true	"Task Key"
+//	@Success		200		{object}	camunda.Task
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bpmn/reviewingartikel/{taskKey}/claim  [post]
+func (app *application) claimReviewingArtikelHandler(w http.ResponseWriter, r *http.Request) {
+	app.claimUserTask(w, r, store.ReviewingArtikelID)
+}
+
+// Unclaim ReviewingArtikel godoc
+//
+//	@Summary		Unclaim ReviewingArtikel
+//	@Description	Unassign the ReviewingArtikel user task from the current user
+//	@Tags			bpmn/ReviewingArtikel
+//	@Accept			json
+//	@produce		json
+//	@Param			taskKey	path		int	true	"Task Key"
+//	@Success		200		{object}	camunda.Task
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bpmn/reviewingartikel/{taskKey}/unclaim  [post]
+func (app *application) unclaimReviewingArtikelHandler(w http.ResponseWriter, r *http.Request) {
+	app.unclaimUserTask(w, r, store.ReviewingArtikelID)
+}
+
+// Complete ReviewingArtikel godoc
+//
+//	@Summary		Complete ReviewingArtikel
+//	@Description	Complete the ReviewingArtikel user task claimed by the current user
+//	@Tags			bpmn/ReviewingArtikel
+//	@Accept			json
+//	@produce		json
+//	@Param			taskKey	path		int				true	"Task Key"
+//	@Param			payload	body		FormDataReviewingArtikel	true	"Form Data ReviewingArtikel"
+//	@Success		201		{object}	store.ReviewingArtikel
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bpmn/reviewingartikel/{taskKey}/complete  [post]
+func (app *application) completeReviewingArtikelHandler(w http.ResponseWriter, r *http.Request) {
+	taskKey, err := getTaskKeyParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload FormDataReviewingArtikel
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	user := GetUserFromContext(r)
+
+	task, err := app.getAssignedUserTask(ctx, taskKey, store.ReviewingArtikelID, user)
+	if err != nil {
+		app.userTaskErrorResponse(w, r, err)
+		return
+	}
+
+	variables, err := formVariables(payload)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	model := &store.ReviewingArtikel{
+		Name:       store.ReviewingArtikelName,
+		TaskId:     task.ID,
+		FormId:     store.ReviewingArtikelFormID,
+		Properties: variables,
+		CreatedBy:  user.ID,
+		Note:       payload.Note,
+		Decision:   payload.Decision,
+	}
+
+	if err := app.store.ReviewingArtikel.Create(ctx, model); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.completeUserTask(ctx, task, variables); err != nil {
+		// the task is still open, drop the submission so it can be sent again
+		if errDelete := app.store.ReviewingArtikel.Delete(ctx, model.ID); errDelete != nil {
+			app.logger.Errorw("failed to delete user task submission", "id", model.ID, "error", errDelete)
+		}
+		app.handleRequestError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, model); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/damarteplok/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// UpdateRoleTwoFactor godoc
+//
+//	@Summary		Require a second factor for a role
+//	@Description	Set whether the users of the role must log in with a second factor, only for admins. The change applies to the next login and to the next request with a token of the oidc provider.
+//	@Tags			roles
+//	@Accept			json
+//	@produce		json
+//	@Param			roleName	path		string					true	"Role name"
+//	@Param			payload		body		RoleTwoFactorPayload	true	"Policy"
+//	@Success		200			{object}	store.Role				"Role"
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/roles/{roleName}/two-factor  [put]
+func (app *application) updateRoleTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RoleTwoFactorPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	allowed, err := app.checkRolePrecedence(ctx, GetUserFromContext(r), "admin")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !allowed {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	role, err := app.store.Roles.SetTwoFactorRequired(ctx, chi.URLParam(r, "roleName"), *payload.Required)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, role); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
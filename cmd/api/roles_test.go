@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/damarteplok/social/internal/auth"
+	"github.com/damarteplok/social/internal/store"
+)
+
+func TestRoleTwoFactorPolicy(t *testing.T) {
+	app := newTestApplication(t, config{
+		auth: authConfig{
+			token: tokenConfig{
+				exp:        time.Hour,
+				iss:        "test-iss",
+				aud:        "test-aud",
+				refreshExp: time.Hour,
+			},
+			twoFactor: twoFactorConfig{challengeExp: time.Minute},
+		},
+	})
+	app.authenticator = auth.NewJWTAuthenticator("test", "test-aud", "test-iss")
+	app.store.Users = &adminUserStore{&store.MockUserStore{}}
+	mux := app.mount()
+	login := `{"email": "damar@test.com", "password": "secret"}`
+
+	token, err := app.generateAccessToken(1, "admin-session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(t *testing.T, roleName, body string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPut, "/v1/roles/"+roleName+"/two-factor", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should require a second factor of the seeded roles", func(t *testing.T) {
+		checkResponseCode(t, http.StatusAccepted, postJSON(t, mux, "/v1/authentication/token", login, "", nil))
+	})
+
+	t.Run("should apply the policy of an admin at the next login", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, put(t, "admin", `{"required": false}`))
+		checkResponseCode(t, http.StatusCreated, postJSON(t, mux, "/v1/authentication/token", login, "", nil))
+
+		checkResponseCode(t, http.StatusOK, put(t, "admin", `{"required": true}`))
+		checkResponseCode(t, http.StatusAccepted, postJSON(t, mux, "/v1/authentication/token", login, "", nil))
+	})
+
+	t.Run("should reject a missing policy or role", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, put(t, "admin", `{}`))
+		checkResponseCode(t, http.StatusNotFound, put(t, "unknown", `{"required": true}`))
+	})
+}
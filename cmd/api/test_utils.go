@@ -7,8 +7,12 @@ import (
 	"testing"
 
 	"github.com/damarteplok/social/internal/auth"
+	"github.com/damarteplok/social/internal/camunda"
+	"github.com/damarteplok/social/internal/mailer"
+	"github.com/damarteplok/social/internal/ratelimiter"
 	"github.com/damarteplok/social/internal/store"
 	"github.com/damarteplok/social/internal/store/cache"
+	"github.com/damarteplok/social/internal/zeebe"
 	"go.uber.org/zap"
 )
 
@@ -26,12 +30,46 @@ func newTestApplication(t *testing.T, cfg config) *application {
 	mockCacheStore := cache.NewMockStore()
 	testAuth := &auth.TestAuthenticator{}
 
+	// the fake engine serves operate, tasklist and the zeebe rest api too
+	engine := zeebe.NewFakeEngine()
+	camundaServer := httptest.NewServer(engine)
+	t.Cleanup(camundaServer.Close)
+
+	zeebeClientRest, err := zeebe.NewZeebeClientRest(zeebe.RestConfig{
+		Token:    zeebe.TokenConfig{ClientID: "test", ClientSecret: "test", AuthURL: camundaServer.URL + "/token"},
+		ZeebeURL: camundaServer.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.camundaRest.zeebeRestAddress = camundaServer.URL
+	cfg.camundaRest.camundaTasklistBaseUrl = camundaServer.URL
+	cfg.camundaRest.camundaOperateBaseUrl = camundaServer.URL
+
+	camundaClient := camunda.NewClient(zeebeClientRest, camunda.Config{
+		ZeebeURL:    cfg.camundaRest.zeebeRestAddress,
+		OperateURL:  cfg.camundaRest.camundaOperateBaseUrl,
+		TasklistURL: cfg.camundaRest.camundaTasklistBaseUrl,
+	})
+
+	secretBox, err := auth.NewSecretBox("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	return &application{
-		logger:        logger,
-		store:         mockStore,
-		cacheStorage:  mockCacheStore,
-		authenticator: testAuth,
-		config:        cfg,
+		logger:          logger,
+		store:           mockStore,
+		cacheStorage:    mockCacheStore,
+		authenticator:   testAuth,
+		mailer:          &mailer.MockMailer{},
+		secretBox:       secretBox,
+		resendLimiter:   ratelimiter.NewFixedWindowLimiter(cfg.mail.resendLimit, cfg.mail.resendWindow),
+		config:          cfg,
+		zeebeClient:     engine,
+		camundaClient:   camundaClient,
+		zeebeClientRest: zeebeClientRest,
 	}
 }
 
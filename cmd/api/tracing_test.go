@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/damarteplok/social/internal/tracing"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	// installs the propagator, the recorder stands in for the exporter
+	if _, err := tracing.Setup(context.Background(), tracing.Config{}, version); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+
+	t.Run("should name the server span after the route pattern", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+		executeRequest(req, mux)
+
+		spans := recorder.Ended()
+		if len(spans) == 0 {
+			t.Fatal("expected a server span")
+		}
+		span := spans[len(spans)-1]
+		if span.Name() != "GET /v1/users/{userID}" {
+			t.Errorf("expected the route as span name got %s", span.Name())
+		}
+		if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("expected the trace of the traceparent got %s", got)
+		}
+	})
+}
@@ -0,0 +1,639 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/damarteplok/social/internal/auth"
+	"github.com/damarteplok/social/internal/mailer"
+	"github.com/damarteplok/social/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// challengePurpose marks the challenge tokens, AuthTokenMiddleware rejects
+// them so they only work on the two-factor endpoints.
+const challengePurpose = "2fa"
+
+const recoveryCodeCount = 10
+
+var (
+	ErrInvalidTwoFactorCode = errors.New("the two-factor code is not correct")
+	ErrTwoFactorRequired    = errors.New("two-factor authentication is required for the role of the user")
+	ErrInvalidChallenge     = errors.New("the two-factor challenge is not valid")
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// verifyTwoFactorHandler godoc
+//
+//	@Summary		Verify a two-factor challenge
+//	@Description	Exchange a challenge of /authentication/token and a TOTP or recovery code for a token. The first code of an enrollment enables it, the recovery codes are only returned then.
+//	@Tags			authentication
+//	@Accept			json
+//	@produce		json
+//	@Param			payload	body		VerifyTwoFactorPayload	true	"Challenge token and code"
+//	@Success		201		{object}	TwoFactorToken			"Token"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/2fa/verify [post]
+func (app *application) verifyTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload VerifyTwoFactorPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, jti, err := app.getChallengeUser(ctx, payload.ChallengeToken)
+	if err != nil {
+		app.handleChallengeError(w, r, err)
+		return
+	}
+
+	twoFactorCfg := app.config.auth.twoFactor
+	if err := app.store.TwoFactor.AttemptChallenge(ctx, user.ID, jti, twoFactorCfg.maxAttempts, twoFactorCfg.attemptWindow); err != nil {
+		app.handleChallengeError(w, r, err)
+		return
+	}
+
+	tf, err := app.store.TwoFactor.Get(ctx, user.ID)
+	if err != nil {
+		app.handleTwoFactorError(w, r, err)
+		return
+	}
+
+	var recoveryCodes []string
+	if tf.Enabled {
+		err = app.checkTwoFactorCode(ctx, tf, payload.Code)
+	} else {
+		recoveryCodes, err = app.enableTwoFactor(ctx, tf, payload.Code)
+	}
+	if err != nil {
+		app.handleTwoFactorError(w, r, err)
+		return
+	}
+
+	// a challenge logs in once
+	if err := app.store.TwoFactor.UseChallenge(ctx, jti); err != nil {
+		app.handleChallengeError(w, r, err)
+		return
+	}
+
+	userToken, err := app.createSession(ctx, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, &TwoFactorToken{
+		UserToken:     *userToken,
+		RecoveryCodes: recoveryCodes,
+	}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// enrollTwoFactorHandler godoc
+//
+//	@Summary		Enroll in two-factor authentication at login
+//	@Description	Start the enrollment a role requires with a challenge of /authentication/token, a link to /authentication/2fa/enroll/confirm/{token} is emailed to the user
+//	@Tags			authentication
+//	@Accept			json
+//	@produce		json
+//	@Param			payload	body		TwoFactorChallengePayload	true	"Challenge token"
+//	@Success		202		{string}	string						"Enrollment link sent"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/2fa/enroll [post]
+func (app *application) enrollTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload TwoFactorChallengePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, _, err := app.getChallengeUser(ctx, payload.ChallengeToken)
+	if err != nil {
+		app.handleChallengeError(w, r, err)
+		return
+	}
+
+	tf, err := app.store.TwoFactor.Get(ctx, user.ID)
+	switch {
+	case err == nil && tf.Enabled:
+		app.conflictResponse(w, r, store.ErrConflict)
+		return
+	case err != nil && !errors.Is(err, store.ErrNotFound):
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	plainToken, hashToken := newUserToken()
+	if err := app.store.Users.CreateTwoFactorEnrollment(ctx, user.ID, hashToken, app.config.mail.resetExp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	vars := struct {
+		Username  string
+		EnrollURL string
+		ValidFor  string
+	}{
+		Username:  user.Username,
+		EnrollURL: fmt.Sprintf("%s/2fa/enroll/%s", app.config.frontendURL, plainToken),
+		ValidFor:  app.config.mail.resetExp.String(),
+	}
+
+	// the password alone does not set up the second factor, the link proves
+	// the user owns the email too
+	isProdEnv := app.config.env == "production"
+	if _, err := app.mailer.Send(mailer.TwoFactorTemplate, user.Username, user.Email, vars, !isProdEnv); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.writeAccepted(w, r)
+}
+
+// confirmTwoFactorEnrollmentHandler godoc
+//
+//	@Summary		Confirm a two-factor enrollment
+//	@Description	Get the TOTP secret with the token of an enrollment link, the first code is verified by /authentication/2fa/verify with the returned challenge
+//	@Tags			authentication
+//	@produce		json
+//	@Param			token	path		string				true	"Enrollment token"
+//	@Success		201		{object}	TwoFactorEnrollment	"TOTP secret and challenge"
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/2fa/enroll/confirm/{token} [put]
+func (app *application) confirmTwoFactorEnrollmentHandler(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	ctx := r.Context()
+
+	user, err := app.store.Users.ConfirmTwoFactorEnrollment(ctx, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	setup, err := app.newTwoFactorSetup(ctx, user)
+	if err != nil {
+		app.handleSetupError(w, r, err)
+		return
+	}
+	// the challenge of the login may have expired while the email was on
+	// its way
+	challengeToken, err := app.newChallengeToken(ctx, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, &TwoFactorEnrollment{
+		TwoFactorSetup: *setup,
+		ChallengeToken: challengeToken,
+	}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// setupTwoFactorHandler godoc
+//
+//	@Summary		Set up two-factor authentication
+//	@Description	Start an enrollment for the current user, it is enabled by /authentication/2fa/enable
+//	@Tags			authentication
+//	@produce		json
+//	@Success		201	{object}	TwoFactorSetup	"TOTP secret"
+//	@Failure		401	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/authentication/2fa/setup [post]
+//	@Security		ApiKeyAuth
+func (app *application) setupTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	setup, err := app.newTwoFactorSetup(r.Context(), GetUserFromContext(r))
+	if err != nil {
+		app.handleSetupError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, setup); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// enableTwoFactorHandler godoc
+//
+//	@Summary		Enable two-factor authentication
+//	@Description	Confirm the enrollment of the current user with a first code, the recovery codes are only returned now
+//	@Tags			authentication
+//	@Accept			json
+//	@produce		json
+//	@Param			payload	body		TwoFactorCodePayload	true	"TOTP code"
+//	@Success		200		{object}	RecoveryCodes			"Recovery codes"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/2fa/enable [post]
+//	@Security		ApiKeyAuth
+func (app *application) enableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload TwoFactorCodePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	tf, err := app.store.TwoFactor.Get(ctx, GetUserFromContext(r).ID)
+	if err != nil {
+		app.handleTwoFactorError(w, r, err)
+		return
+	}
+	if tf.Enabled {
+		app.conflictResponse(w, r, store.ErrConflict)
+		return
+	}
+
+	recoveryCodes, err := app.enableTwoFactor(ctx, tf, payload.Code)
+	if err != nil {
+		app.handleTwoFactorError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, &RecoveryCodes{RecoveryCodes: recoveryCodes}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// disableTwoFactorHandler godoc
+//
+//	@Summary		Disable two-factor authentication
+//	@Description	Disable two-factor authentication for the current user, not allowed for the roles that require it
+//	@Tags			authentication
+//	@Accept			json
+//	@produce		json
+//	@Param			payload	body		DisableTwoFactorPayload	true	"Password and code"
+//	@Success		204		{string}	string					"Two-factor authentication disabled"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/2fa/disable [post]
+//	@Security		ApiKeyAuth
+func (app *application) disableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload DisableTwoFactorPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.checkCurrentPassword(r, payload.Password)
+	if err != nil {
+		app.handlePasswordError(w, r, err)
+		return
+	}
+	required, err := app.twoFactorRequired(ctx, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if required {
+		app.badRequestResponse(w, r, ErrTwoFactorRequired)
+		return
+	}
+
+	tf, err := app.store.TwoFactor.Get(ctx, user.ID)
+	if err != nil {
+		app.handleTwoFactorError(w, r, err)
+		return
+	}
+	if tf.Enabled {
+		if err := app.checkTwoFactorCode(ctx, tf, payload.Code); err != nil {
+			app.handleTwoFactorError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.store.TwoFactor.Disable(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// regenerateRecoveryCodesHandler godoc
+//
+//	@Summary		Regenerate the recovery codes
+//	@Description	Replace the recovery codes of the current user, the previous ones stop working
+//	@Tags			authentication
+//	@Accept			json
+//	@produce		json
+//	@Param			payload	body		TwoFactorCodePayload	true	"TOTP code"
+//	@Success		200		{object}	RecoveryCodes			"Recovery codes"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/2fa/recovery-codes [post]
+//	@Security		ApiKeyAuth
+func (app *application) regenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	var payload TwoFactorCodePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := GetUserFromContext(r)
+
+	tf, err := app.store.TwoFactor.Get(ctx, user.ID)
+	if err == nil && !tf.Enabled {
+		err = store.ErrNotFound
+	}
+	if err != nil {
+		app.handleTwoFactorError(w, r, err)
+		return
+	}
+	if err := app.checkTwoFactorCode(ctx, tf, payload.Code); err != nil {
+		app.handleTwoFactorError(w, r, err)
+		return
+	}
+
+	recoveryCodes, err := newRecoveryCodes()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.store.TwoFactor.ReplaceRecoveryCodes(ctx, user.ID, recoveryCodes); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, &RecoveryCodes{RecoveryCodes: recoveryCodes}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// twoFactorChallenge returns the challenge of a user that needs a second
+// factor to log in, or nil.
+func (app *application) twoFactorChallenge(ctx context.Context, user *store.User) (*TwoFactorChallenge, error) {
+	enabled := false
+	tf, err := app.store.TwoFactor.Get(ctx, user.ID)
+	switch {
+	case err == nil:
+		enabled = tf.Enabled
+	case !errors.Is(err, store.ErrNotFound):
+		return nil, err
+	}
+
+	if !enabled {
+		required, err := app.twoFactorRequired(ctx, user)
+		if err != nil || !required {
+			return nil, err
+		}
+	}
+
+	token, err := app.newChallengeToken(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorChallenge{
+		ChallengeToken:     token,
+		EnrollmentRequired: !enabled,
+	}, nil
+}
+
+// newChallengeToken returns a challenge token of the user, it is recorded
+// so it is used once.
+func (app *application) newChallengeToken(ctx context.Context, user *store.User) (string, error) {
+	jti := uuid.New().String()
+	exp := app.config.auth.twoFactor.challengeExp
+	if err := app.store.TwoFactor.CreateChallenge(ctx, user.ID, jti, exp); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	return app.authenticator.GenerateToken(jwt.MapClaims{
+		"sub":     user.ID,
+		"exp":     now.Add(exp).Unix(),
+		"iat":     now.Unix(),
+		"nbf":     now.Unix(),
+		"iss":     app.config.auth.token.iss,
+		"aud":     app.config.auth.token.aud,
+		"jti":     jti,
+		"purpose": challengePurpose,
+	})
+}
+
+// twoFactorRequired tells whether the role of the user requires a second
+// factor. The role is read from the store and not from the cached user, a
+// change of the policy applies at once.
+func (app *application) twoFactorRequired(ctx context.Context, user *store.User) (bool, error) {
+	role, err := app.store.Roles.GetByName(ctx, user.Role.Name)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return role.TwoFactorRequired, nil
+}
+
+// getChallengeUser returns the user and the jti of a challenge token.
+func (app *application) getChallengeUser(ctx context.Context, challengeToken string) (*store.User, string, error) {
+	jwtToken, err := app.authenticator.ValidateToken(challengeToken)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %w", ErrInvalidChallenge, err)
+	}
+
+	claims, _ := jwtToken.Claims.(jwt.MapClaims)
+	if purpose, _ := claims["purpose"].(string); purpose != challengePurpose {
+		return nil, "", ErrInvalidChallenge
+	}
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil, "", ErrInvalidChallenge
+	}
+
+	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %w", ErrInvalidChallenge, err)
+	}
+
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	return user, jti, nil
+}
+
+// newTwoFactorSetup starts the enrollment of the user with a new secret, an
+// enabled enrollment gives store.ErrConflict.
+func (app *application) newTwoFactorSetup(ctx context.Context, user *store.User) (*TwoFactorSetup, error) {
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := app.secretBox.Seal([]byte(secret))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.store.TwoFactor.SetSecret(ctx, user.ID, sealed); err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorSetup{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(app.config.auth.token.iss, user.Email, secret),
+	}, nil
+}
+
+// enableTwoFactor confirms a pending enrollment with its first code and
+// returns the recovery codes.
+func (app *application) enableTwoFactor(ctx context.Context, tf *store.TwoFactor, code string) ([]string, error) {
+	step, err := app.validateTOTP(tf, code)
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := app.store.TwoFactor.Enable(ctx, tf.UserID, step, recoveryCodes); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrInvalidTwoFactorCode
+		}
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+// checkTwoFactorCode accepts a TOTP code or a recovery code, both are valid
+// once.
+func (app *application) checkTwoFactorCode(ctx context.Context, tf *store.TwoFactor, code string) error {
+	code = strings.TrimSpace(code)
+
+	step, err := app.validateTOTP(tf, code)
+	if err == nil {
+		err = app.store.TwoFactor.UseStep(ctx, tf.UserID, step)
+	} else if errors.Is(err, ErrInvalidTwoFactorCode) {
+		err = app.store.TwoFactor.UseRecoveryCode(ctx, tf.UserID, strings.ToLower(code))
+	}
+
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrInvalidTwoFactorCode
+	}
+	return err
+}
+
+func (app *application) validateTOTP(tf *store.TwoFactor, code string) (int64, error) {
+	secret, err := app.secretBox.Open(tf.Secret)
+	if err != nil {
+		return 0, err
+	}
+
+	step, ok := auth.ValidateTOTP(string(secret), code, time.Now())
+	if !ok {
+		return 0, ErrInvalidTwoFactorCode
+	}
+	return step, nil
+}
+
+func (app *application) handleChallengeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound), errors.Is(err, ErrInvalidChallenge):
+		// a used or expired challenge, the user logs in again
+		app.unauthorizedErrorResponse(w, r, err)
+	case errors.Is(err, store.ErrTooManyAttempts):
+		retryAfter := app.config.auth.twoFactor.attemptWindow
+		app.rateLimitExceededResponse(w, r, strconv.Itoa(int(retryAfter.Seconds())))
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) handleSetupError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, store.ErrConflict):
+		app.conflictResponse(w, r, err)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) handleTwoFactorError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidTwoFactorCode):
+		app.badRequestResponse(w, r, err)
+	case errors.Is(err, store.ErrNotFound):
+		// no enrollment, no code can be right
+		app.badRequestResponse(w, r, ErrInvalidTwoFactorCode)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
+
+// newRecoveryCodes returns codes like 7kq2m-xp4ha, they are stored hashed.
+func newRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/damarteplok/social/internal/auth"
+	"github.com/damarteplok/social/internal/mailer"
+	"github.com/damarteplok/social/internal/store"
+)
+
+func TestTwoFactor(t *testing.T) {
+	app := newTestApplication(t, config{
+		auth: authConfig{
+			token: tokenConfig{
+				exp:        time.Hour,
+				iss:        "test-iss",
+				aud:        "test-aud",
+				refreshExp: time.Hour,
+			},
+			twoFactor: twoFactorConfig{
+				challengeExp:  time.Minute,
+				maxAttempts:   3,
+				attemptWindow: time.Minute,
+			},
+		},
+	})
+	app.authenticator = auth.NewJWTAuthenticator("test", "test-aud", "test-iss")
+	mux := app.mount()
+
+	post := func(t *testing.T, path, body, token string, data any) int {
+		t.Helper()
+		return postJSON(t, mux, path, body, token, data)
+	}
+	login := `{"email": "damar@test.com", "password": "secret"}`
+
+	var session UserToken
+	checkResponseCode(t, http.StatusCreated, post(t, "/v1/authentication/token", login, "", &session))
+
+	var setup TwoFactorSetup
+	checkResponseCode(t, http.StatusCreated, post(t, "/v1/authentication/2fa/setup", "", session.Token, &setup))
+	if !strings.HasPrefix(setup.ProvisioningURI, "otpauth://totp/") {
+		t.Errorf("unexpected provisioning uri %s", setup.ProvisioningURI)
+	}
+
+	code, err := auth.TOTPCode(setup.Secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recovery RecoveryCodes
+	checkResponseCode(t, http.StatusOK, post(t, "/v1/authentication/2fa/enable", `{"code": "`+code+`"}`, session.Token, &recovery))
+	if len(recovery.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("expected %d recovery codes got %v", recoveryCodeCount, recovery.RecoveryCodes)
+	}
+
+	newChallenge := func(t *testing.T) string {
+		t.Helper()
+		var challenge TwoFactorChallenge
+		checkResponseCode(t, http.StatusAccepted, post(t, "/v1/authentication/token", login, "", &challenge))
+		if challenge.ChallengeToken == "" || challenge.EnrollmentRequired {
+			t.Fatalf("unexpected challenge %+v", challenge)
+		}
+		return challenge.ChallengeToken
+	}
+
+	t.Run("should not take a challenge as an access token", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnauthorized, post(t, "/v1/authentication/logout", "", newChallenge(t), nil))
+	})
+
+	verify := func(t *testing.T, challenge, code string) int {
+		t.Helper()
+		var token TwoFactorToken
+		status := post(t, "/v1/authentication/2fa/verify", `{"challenge_token": "`+challenge+`", "code": "`+code+`"}`, "", &token)
+		if status == http.StatusCreated && token.Token == "" {
+			t.Error("expected an access token")
+		}
+		return status
+	}
+
+	t.Run("should not accept a code twice", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, verify(t, newChallenge(t), code))
+	})
+
+	used := newChallenge(t)
+	t.Run("should accept a recovery code once", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, verify(t, used, recovery.RecoveryCodes[0]))
+		checkResponseCode(t, http.StatusBadRequest, verify(t, newChallenge(t), recovery.RecoveryCodes[0]))
+	})
+
+	t.Run("should accept a challenge once", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnauthorized, verify(t, used, recovery.RecoveryCodes[1]))
+	})
+
+	t.Run("should limit the failed attempts of a user", func(t *testing.T) {
+		challenge := newChallenge(t)
+		checkResponseCode(t, http.StatusBadRequest, verify(t, challenge, "00000-00000"))
+		checkResponseCode(t, http.StatusTooManyRequests, verify(t, challenge, recovery.RecoveryCodes[1]))
+		checkResponseCode(t, http.StatusTooManyRequests, verify(t, newChallenge(t), recovery.RecoveryCodes[1]))
+	})
+}
+
+// adminUserStore logs in an admin, the role that must enroll.
+type adminUserStore struct {
+	*store.MockUserStore
+}
+
+func (s *adminUserStore) GetByID(ctx context.Context, userID int64) (*store.User, error) {
+	return &store.User{ID: 1, Email: "damar@test.com", Role: store.Role{Name: "admin", Level: 3}}, nil
+}
+
+func (s *adminUserStore) GetByEmailAndPassword(ctx context.Context, email, password string) (*store.User, error) {
+	return s.GetByID(ctx, 1)
+}
+
+func (s *adminUserStore) ConfirmTwoFactorEnrollment(ctx context.Context, token string) (*store.User, error) {
+	return s.GetByID(ctx, 1)
+}
+
+func TestTwoFactorEnrollment(t *testing.T) {
+	app := newTestApplication(t, config{
+		auth: authConfig{
+			token: tokenConfig{
+				exp:        time.Hour,
+				iss:        "test-iss",
+				aud:        "test-aud",
+				refreshExp: time.Hour,
+			},
+			twoFactor: twoFactorConfig{
+				challengeExp:  time.Minute,
+				maxAttempts:   3,
+				attemptWindow: time.Minute,
+			},
+		},
+		mail: mailConfig{resetExp: time.Hour},
+	})
+	app.authenticator = auth.NewJWTAuthenticator("test", "test-aud", "test-iss")
+	app.store.Users = &adminUserStore{&store.MockUserStore{}}
+	mux := app.mount()
+	login := `{"email": "damar@test.com", "password": "secret"}`
+
+	var challenge TwoFactorChallenge
+	checkResponseCode(t, http.StatusAccepted, postJSON(t, mux, "/v1/authentication/token", login, "", &challenge))
+	if !challenge.EnrollmentRequired {
+		t.Fatalf("unexpected challenge %+v", challenge)
+	}
+
+	t.Run("should email the enrollment link instead of the secret", func(t *testing.T) {
+		body := `{"challenge_token": "` + challenge.ChallengeToken + `"}`
+		checkResponseCode(t, http.StatusAccepted, postJSON(t, mux, "/v1/authentication/2fa/enroll", body, "", nil))
+
+		sent, ok := app.mailer.(*mailer.MockMailer).Last(mailer.TwoFactorTemplate)
+		if !ok || sent.Email != "damar@test.com" {
+			t.Fatalf("expected the enrollment link to be emailed, got %+v", sent)
+		}
+		if _, err := app.store.TwoFactor.Get(context.Background(), 1); err != store.ErrNotFound {
+			t.Errorf("expected no enrollment before the link is confirmed, got %v", err)
+		}
+	})
+
+	var enrollment TwoFactorEnrollment
+	t.Run("should enroll with the link", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/authentication/2fa/enroll/confirm/token", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		envelope := struct {
+			Data any `json:"data"`
+		}{Data: &enrollment}
+		if err := json.NewDecoder(rr.Body).Decode(&envelope); err != nil {
+			t.Fatal(err)
+		}
+		if enrollment.Secret == "" || enrollment.ChallengeToken == "" {
+			t.Fatalf("unexpected enrollment %+v", enrollment)
+		}
+
+		code, err := auth.TOTPCode(enrollment.Secret, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var token TwoFactorToken
+		body := `{"challenge_token": "` + enrollment.ChallengeToken + `", "code": "` + code + `"}`
+		checkResponseCode(t, http.StatusCreated, postJSON(t, mux, "/v1/authentication/2fa/verify", body, "", &token))
+		if token.Token == "" || len(token.RecoveryCodes) != recoveryCodeCount {
+			t.Errorf("unexpected token %+v", token)
+		}
+	})
+
+	t.Run("should not enroll twice", func(t *testing.T) {
+		var challenge TwoFactorChallenge
+		checkResponseCode(t, http.StatusAccepted, postJSON(t, mux, "/v1/authentication/token", login, "", &challenge))
+		body := `{"challenge_token": "` + challenge.ChallengeToken + `"}`
+		checkResponseCode(t, http.StatusConflict, postJSON(t, mux, "/v1/authentication/2fa/enroll", body, "", nil))
+	})
+}
+
+func postJSON(t *testing.T, mux http.Handler, path, body, token string, data any) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rr := executeRequest(req, mux)
+
+	if data != nil && rr.Code < http.StatusMultipleChoices {
+		envelope := struct {
+			Data any `json:"data"`
+		}{Data: data}
+		if err := json.NewDecoder(rr.Body).Decode(&envelope); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rr.Code
+}
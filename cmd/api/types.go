@@ -1,31 +1,47 @@
 package main
 
 import (
-	"errors"
 	"time"
 
 	"github.com/damarteplok/social/internal/auth"
+	"github.com/damarteplok/social/internal/camunda"
 	"github.com/damarteplok/social/internal/mailer"
 	"github.com/damarteplok/social/internal/minioupload"
+	"github.com/damarteplok/social/internal/oidc"
 	"github.com/damarteplok/social/internal/ratelimiter"
+	"github.com/damarteplok/social/internal/service"
 	"github.com/damarteplok/social/internal/store"
 	"github.com/damarteplok/social/internal/store/cache"
+	"github.com/damarteplok/social/internal/tracing"
 	"github.com/damarteplok/social/internal/zeebe"
 	"go.uber.org/zap"
 )
 
 // api types
 type application struct {
-	config          config
-	store           store.Storage
-	cacheStorage    cache.Storage
-	logger          *zap.SugaredLogger
-	mailer          mailer.Client
-	authenticator   auth.Authenticator
-	rateLimiter     ratelimiter.Limiter
-	zeebeClient     zeebe.ZeebeCamunda
-	zeebeClientRest zeebe.ZeebeClientRest
+	config        config
+	store         store.Storage
+	cacheStorage  cache.Storage
+	logger        *zap.SugaredLogger
+	mailer        mailer.Client
+	authenticator auth.Authenticator
+	rateLimiter   ratelimiter.Limiter
+	zeebeClient   zeebe.ZeebeCamunda
+	camundaClient camunda.Client
+	// zeebeClientRest reports the breakers of the camunda components
+	zeebeClientRest *zeebe.ZeebeClientRest
 	minioClient     minioupload.MinioApi
+	service         *service.Service
+	reconciler      *service.Reconciler
+	outbox          *service.Dispatcher
+	cleaner         *service.Cleaner
+	// resendLimiter throttles the activation emails sent to an address
+	resendLimiter ratelimiter.Limiter
+	// oidcProvider is nil unless oidc is enabled
+	oidcProvider *oidc.Provider
+	// secretBox encrypts the totp secrets
+	secretBox    *auth.SecretBox
+	healthChecks []healthCheck
 }
 
 type config struct {
@@ -41,6 +57,22 @@ type config struct {
 	redisCfg    redisConfig
 	rateLimiter ratelimiter.Config
 	camundaRest camundaRestConfig
+	worker      service.Config
+	reconciler  service.ReconcilerConfig
+	outbox      service.DispatcherConfig
+	cleanup     service.CleanupConfig
+	tracing     tracing.Config
+	oidc        oidc.Config
+	log         logConfig
+	// healthCheckTimeout bounds every dependency check of /readyz
+	healthCheckTimeout time.Duration
+}
+
+type logConfig struct {
+	// level is a zap level, debug, info, warn or error
+	level string
+	// format is json or console
+	format string
 }
 
 type redisConfig struct {
@@ -58,9 +90,17 @@ type dbConfig struct {
 }
 
 type mailConfig struct {
-	sendgrid  sendGridConfig
-	exp       time.Duration
-	fromEmail string
+	sendgrid sendGridConfig
+	// exp is the lifetime of the activation and email change links
+	exp time.Duration
+	// resetExp is the lifetime of a password reset link and of a two-factor
+	// enrollment link
+	resetExp time.Duration
+	// resendLimit activation emails may be resent to an address every
+	// resendWindow
+	resendLimit  int
+	resendWindow time.Duration
+	fromEmail    string
 }
 
 type minioConfig struct {
@@ -79,6 +119,14 @@ type camundaConfig struct {
 	zeebeClientId      string
 	zeebeClientSecret  string
 	zeebeAuthServerUrl string
+	// token audiences of the zeebe, operate, tasklist and optimize apis
+	audiences         map[zeebe.Component]string
+	tokenTimeout      time.Duration
+	tokenRefreshAhead time.Duration
+	retry             zeebe.RetryConfig
+	breaker           zeebe.BreakerConfig
+	// timeouts of a request to the zeebe, operate, tasklist and optimize apis
+	timeouts map[zeebe.Component]time.Duration
 }
 
 type camundaRestConfig struct {
@@ -94,8 +142,21 @@ type sendGridConfig struct {
 }
 
 type authConfig struct {
-	basic basicConfig
-	token tokenConfig
+	basic     basicConfig
+	token     tokenConfig
+	twoFactor twoFactorConfig
+}
+
+type twoFactorConfig struct {
+	// key encrypts the totp secrets in the database
+	key string
+	// challengeExp is the lifetime of the challenge token between the
+	// password and the code
+	challengeExp time.Duration
+	// maxAttempts is how many wrong codes the challenges of a user may get
+	// in attemptWindow
+	maxAttempts   int
+	attemptWindow time.Duration
 }
 
 type basicConfig struct {
@@ -105,15 +166,62 @@ type basicConfig struct {
 
 type tokenConfig struct {
 	secret string
-	exp    time.Duration
-	iss    string
+	// signingKeyFile is a PEM RSA or Ed25519 private key, it replaces the
+	// secret when set
+	signingKeyFile string
+	// verifyKeyFiles are the keys of the tokens signed before a rotation
+	verifyKeyFiles []string
+	// exp is the lifetime of the access tokens
+	exp time.Duration
+	iss string
+	aud string
+	// refreshExp is the lifetime of a refresh token, every refresh starts a
+	// new one
+	refreshExp time.Duration
 }
 
 // health types
 type HealthResponse struct {
-	Status  string `json:"status"`
-	Env     string `json:"env"`
-	Version string `json:"version"`
+	Status   string                                  `json:"status"`
+	Env      string                                  `json:"env"`
+	Version  string                                  `json:"version"`
+	Breakers map[zeebe.Component]zeebe.BreakerStatus `json:"breakers,omitempty"`
+}
+
+type HealthCheckResult struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Required bool   `json:"required"`
+	Duration string `json:"duration,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status string              `json:"status"`
+	Checks []HealthCheckResult `json:"checks"`
+}
+
+// error types
+
+// Problem is the rfc 7807 body of every error response. Code is stable,
+// clients switch on it rather than on the title or the detail.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError points a problem to a field of the request body, Field is the
+// json path of the field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 // posts types
@@ -142,122 +250,114 @@ type DeployBpmnPayload struct {
 	ResourceName  string   `json:"resource_name" validate:"required"`
 	FormResources []string `json:"form_resources" validate:"omitempty,min=0,dive"`
 }
-type CrudPayload struct {
-	ProcessName          string `json:"process_name" validate:"required,max=255"`
-	ResourceName         string `json:"resource_name" validate:"required,max=255"`
-	Version              int32  `json:"version" validate:"required"`
-	ProcessDefinitionKey int64  `json:"process_definition_key" validate:"required"`
+
+// authenticated types
+type RegisterUserPayload struct {
+	Username string `json:"username" validate:"required,max=100"`
+	Email    string `json:"email" validate:"required,max=255,email"`
+	Password string `json:"password" validate:"required,min=3,max=72"`
 }
 
-type StartInstruction struct {
-	ElementID *string `json:"elementId,omitempty"`
+type CreateUserTokenPayload struct {
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,min=3,max=72"`
 }
 
-type CreateProcessInstancePayload struct {
-	ProcessDefinitionKey int64               `json:"processDefinitionKey" validate:"required"`
-	Variables            *map[string]string  `json:"variables,omitempty"`
-	TenantID             *string             `json:"tenantId,omitempty"`
-	OperationReference   *int64              `json:"operationReference,omitempty"`
-	StartInstructions    *[]StartInstruction `json:"startInstructions,omitempty"`
-	AwaitCompletion      *bool               `json:"awaitCompletion,omitempty"`
-	FetchVariables       *[]string           `json:"fetchVariables,omitempty"`
-	RequestTimeout       *int64              `json:"requestTimeout,omitempty"`
+type TwoFactorChallengePayload struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
 }
 
-type CreateProcessInstancesResponse struct {
-	ProcessDefinitionKey     int64             `json:"processDefinitionKey"`
-	ProcessDefinitionId      string            `json:"processDefinitionId"`
-	ProcessDefinitionVersion int32             `json:"processDefinitionVersion"`
-	ProcessInstanceKey       int64             `json:"processInstanceKey"`
-	TenantId                 string            `json:"tenantId"`
-	Variables                map[string]string `json:"variables"`
+type OIDCTokenPayload struct {
+	Code  string `json:"code" validate:"required"`
+	State string `json:"state" validate:"required"`
 }
 
-type SortSearchTasklist struct {
-	Field string `json:"field,omitempty"`
-	Order string `json:"order,omitempty"`
+type VerifyTwoFactorPayload struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	// Code is a TOTP code or a recovery code
+	Code string `json:"code" validate:"required,max=32"`
 }
 
-type SearchTaskListPayload struct {
-	State                string               `json:"state,omitempty"`
-	Assigned             bool                 `json:"assgined,omitempty"`
-	Assignee             string               `json:"assignee,omitempty"`
-	Assignees            []string             `json:"assignees,omitempty"`
-	TaskDefinitionId     string               `json:"taskDefinitionId,omitempty"`
-	CandidateGroup       string               `json:"candidateGroup,omitempty"`
-	CandidateGroups      []string             `json:"candidateGroups,omitempty"`
-	CandidateUser        string               `json:"candidateUser,omitempty"`
-	CandidateUsers       []string             `json:"candidateUsers,omitempty"`
-	ProcessDefinitionKey string               `json:"processDefinitionKey,omitempty"`
-	ProcessInstanceKey   string               `json:"processInstanceKey,omitempty"`
-	PageSize             int32                `json:"pageSize,omitempty"`
-	Sort                 []SortSearchTasklist `json:"sort,omitempty"`
-	SearchAfter          []string             `json:"searchAfter,omitempty"`
-	SearchAfterOrEqual   []string             `json:"searchAfterOrEqual,omitempty"`
-	SearchBefore         []string             `json:"searchBefore,omitempty"`
-	SearchBeforeOrEqual  []string             `json:"searchBeforeOrEqual,omitempty"`
+type TwoFactorCodePayload struct {
+	Code string `json:"code" validate:"required,max=32"`
 }
 
-type QueryUserTaskPayload struct {
-	Sort   []Sort `json:"sort"`
-	Filter Filter `json:"filter,omitempty"`
-	Page   Page   `json:"page,omitempty"`
+type DisableTwoFactorPayload struct {
+	Password string `json:"password" validate:"required,max=72"`
+	Code     string `json:"code" validate:"required,max=32"`
 }
 
-type Page struct {
-	From         int64                `json:"from"`
-	Limit        int64                `json:"limit"`
-	SearchAfter  []SearchAfterPayload `json:"searchAfter"`
-	SearchBefore []SearchAfterPayload `json:"searchBefore"`
+type RoleTwoFactorPayload struct {
+	// Required is a pointer so that false is told from a missing field
+	Required *bool `json:"required" validate:"required"`
 }
 
-type SearchAfterPayload struct {
-	Object []interface{} `json:"object,omitempty"`
+type ResendActivationPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
 }
 
-type Variable struct {
-	Name  string `json:"name,omitempty"`
-	Value string `json:"value,omitempty"`
+type ForgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
 }
 
-type Filter struct {
-	Key                  int64      `json:"key,omitempty"`
-	State                string     `json:"state,omitempty"`
-	Assignee             string     `json:"assignee,omitempty"`
-	ElementId            string     `json:"elementId,omitempty"`
-	CandidateGroup       string     `json:"candidateGroup,omitempty"`
-	CandidateUser        string     `json:"candidateUser,omitempty"`
-	ProcessDefinitionKey int64      `json:"processDefinitionKey,omitempty"`
-	ProcessInstanceKey   int64      `json:"processInstanceKey,omitempty"`
-	TenantIds            string     `json:"tenantIds,omitempty"`
-	ProcessDefinitionId  string     `json:"processDefinitionId,omitempty"`
-	Variables            []Variable `json:"variables,omitempty"`
+type ResetPasswordPayload struct {
+	Token    string `json:"token" validate:"required,max=64"`
+	Password string `json:"password" validate:"required,min=3,max=72"`
 }
 
-type Sort struct {
-	Field string `json:"field" validate:"required"`
-	Order string `json:"order,omitempty"`
+type ChangePasswordPayload struct {
+	Password    string `json:"password" validate:"required,max=72"`
+	NewPassword string `json:"new_password" validate:"required,min=3,max=72"`
 }
 
-func (p *SearchTaskListPayload) IsValidState() error {
-	switch p.State {
-	case StateCreated, StateCompleted, StateCanceled, StateFailed:
-		return nil
-	default:
-		return errors.New("invalid state: must be one of CREATED, COMPLETED, CANCELED, FAILED")
-	}
+type ChangeEmailPayload struct {
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,max=72"`
 }
 
-// authenticated types
-type RegisterUserPayload struct {
-	Username string `json:"username" validate:"required,max=100"`
-	Email    string `json:"email" validate:"required,max=255,email"`
-	Password string `json:"password" validate:"required,min=3,max=72"`
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=128"`
 }
 
-type CreateUserTokenPayload struct {
-	Email    string `json:"email" validate:"required,email,max=255"`
-	Password string `json:"password" validate:"required,min=3,max=72"`
+type UserToken struct {
+	Token        string      `json:"token"`
+	RefreshToken string      `json:"refresh_token,omitempty"`
+	User         *store.User `json:"user,omitempty"`
+}
+
+// TwoFactorToken is the token of a login with a second factor, the
+// recovery codes are only set when the login enabled it.
+type TwoFactorToken struct {
+	UserToken
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type OIDCAuthorization struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+}
+
+type TwoFactorChallenge struct {
+	ChallengeToken string `json:"challenge_token"`
+	// EnrollmentRequired tells the role requires a second factor the user
+	// has not set up yet, see /authentication/2fa/enroll
+	EnrollmentRequired bool `json:"enrollment_required"`
+}
+
+type TwoFactorSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// TwoFactorEnrollment is the secret of an enrollment confirmed by email, the
+// challenge verifies its first code.
+type TwoFactorEnrollment struct {
+	TwoFactorSetup
+	ChallengeToken string `json:"challenge_token"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
 }
 
 type UserWithToken struct {
@@ -266,20 +366,28 @@ type UserWithToken struct {
 }
 
 type FlowNodeQueryParams struct {
-	Size                     string
+	Size                     int32
 	Order                    string
 	Sort                     string
-	SearchAfter              string
-	SearchBefore             string
+	SearchAfter              []interface{}
+	SearchBefore             []interface{}
 	Type                     string
 	State                    string
 	BpmnProcessId            string
-	ProcessDefinitionKey     string
-	ParentProcessInstanceKey string
+	ProcessDefinitionKey     *int64
+	ParentProcessInstanceKey *int64
 	StartDate                string
 	EndDate                  string
 }
 
+// SortBy returns the sort of an operate search, none when no field is given.
+func (p *FlowNodeQueryParams) SortBy() []camunda.Sort {
+	if p.Sort == "" {
+		return nil
+	}
+	return []camunda.Sort{{Field: p.Sort, Order: p.Order}}
+}
+
 type TaskListQueryParams struct {
 	Size               int32
 	State              string
@@ -287,32 +395,6 @@ type TaskListQueryParams struct {
 	ProcessInstanceKey string
 	Sort               string
 	Order              string
-	SearchAfter        string
-	SearchBefore       string
-}
-
-type OperateCoreStats struct {
-	Running       int `json:"running"`
-	Active        int `json:"active"`
-	WithIncidents int `json:"withIncidents"`
-}
-
-type Process struct {
-	ProcessID                         string  `json:"processId"`
-	Version                           int     `json:"version"`
-	Name                              string  `json:"name"`
-	BpmnProcessID                     string  `json:"bpmnProcessId"`
-	TenantID                          string  `json:"tenantId"`
-	ErrorMessage                      *string `json:"errorMessage"`
-	InstancesWithActiveIncidentsCount int     `json:"instancesWithActiveIncidentsCount"`
-	ActiveInstancesCount              int     `json:"activeInstancesCount"`
-}
-
-type OperateProcessStats struct {
-	BpmnProcessID                     string    `json:"bpmnProcessId"`
-	TenantID                          string    `json:"tenantId"`
-	ProcessName                       *string   `json:"processName"`
-	InstancesWithActiveIncidentsCount int       `json:"instancesWithActiveIncidentsCount"`
-	ActiveInstancesCount              int       `json:"activeInstancesCount"`
-	Processes                         []Process `json:"processes"`
+	SearchAfter        []string
+	SearchBefore       []string
 }
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/damarteplok/social/internal/camunda"
+	"github.com/damarteplok/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	ErrUserTaskNotAssigned = errors.New("user task is not assigned to the current user")
+	ErrUserTaskNotAllowed  = errors.New("user task is not available to the current user")
+)
+
+// assignedTask is a task of tasklist v1 or of the zeebe v2 user task api,
+// both carry a camunda.Assignment used for authorization.
+type assignedTask interface {
+	TaskAssignment() camunda.Assignment
+}
+
+func getTaskKeyParam(r *http.Request) (int64, error) {
+	taskKey, err := strconv.ParseInt(chi.URLParam(r, "taskKey"), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if taskKey < 1 {
+		return 0, fmt.Errorf("invalid task key %d", taskKey)
+	}
+
+	return taskKey, nil
+}
+
+func (app *application) userTaskErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrUserTaskNotAssigned) || errors.Is(err, ErrUserTaskNotAllowed) {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	app.handleRequestError(w, r, err)
+}
+
+// canAccessUserTask applies the bpmn assignment of a task: a task without
+// assignment is open to everyone, otherwise the user must be the assignee, one
+// of the candidate users or have a role at least as high as a candidate group.
+func (app *application) canAccessUserTask(ctx context.Context, user *store.User, assignment camunda.Assignment, groups map[string]bool) (bool, error) {
+	if assignment.Assignee == nil && len(assignment.CandidateGroups) == 0 && len(assignment.CandidateUsers) == 0 {
+		return true, nil
+	}
+
+	if assignment.Assignee != nil && *assignment.Assignee == user.Username {
+		return true, nil
+	}
+
+	for _, candidateUser := range assignment.CandidateUsers {
+		if candidateUser == user.Username {
+			return true, nil
+		}
+	}
+
+	for _, group := range assignment.CandidateGroups {
+		allowed, ok := groups[group]
+		if !ok {
+			var err error
+			allowed, err = app.checkRolePrecedence(ctx, user, group)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				return false, err
+			}
+			// groups[group] stays false for groups that are not a role
+			groups[group] = allowed
+		}
+
+		if allowed {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// filterUserTasks drops the tasks of a tasklist search the user may not see.
+func filterUserTasks[T assignedTask](ctx context.Context, app *application, user *store.User, tasks []T) ([]T, error) {
+	groups := map[string]bool{}
+	filtered := []T{}
+
+	for _, task := range tasks {
+		allowed, err := app.canAccessUserTask(ctx, user, task.TaskAssignment(), groups)
+		if err != nil {
+			return nil, err
+		}
+
+		if allowed {
+			filtered = append(filtered, task)
+		}
+	}
+
+	return filtered, nil
+}
+
+// getUserTask returns the task only when it was created for the given task
+// definition, so a task key of another user task cannot be used on this route.
+func (app *application) getUserTask(ctx context.Context, taskKey int64, taskDefinitionId string, user *store.User) (*camunda.Task, error) {
+	task, err := app.camundaClient.Tasks.Get(ctx, strconv.FormatInt(taskKey, 10))
+	if err != nil {
+		return nil, err
+	}
+
+	if task.TaskDefinitionId != taskDefinitionId {
+		return nil, store.ErrNotFound
+	}
+
+	allowed, err := app.canAccessUserTask(ctx, user, task.Assignment, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	if !allowed {
+		return nil, ErrUserTaskNotAllowed
+	}
+
+	return task, nil
+}
+
+func (app *application) getAssignedUserTask(ctx context.Context, taskKey int64, taskDefinitionId string, user *store.User) (*camunda.Task, error) {
+	task, err := app.getUserTask(ctx, taskKey, taskDefinitionId, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if task.Assignee == nil || *task.Assignee != user.Username {
+		return nil, ErrUserTaskNotAssigned
+	}
+
+	return task, nil
+}
+
+// searchUserTasks returns a page of the tasks of a task definition the user
+// may see. Tasklist pages before the tasks are filtered, so the following
+// pages are fetched until the page is full or tasklist has no more tasks.
+func (app *application) searchUserTasks(ctx context.Context, taskDefinitionId string, params *TaskListQueryParams, user *store.User) ([]camunda.Task, error) {
+	search := camunda.TaskSearch{
+		TaskDefinitionId: taskDefinitionId,
+		State:            params.State,
+		PageSize:         params.Size,
+		Sort:             []camunda.Sort{{Field: params.Sort, Order: params.Order}},
+		SearchAfter:      params.SearchAfter,
+		SearchBefore:     params.SearchBefore,
+	}
+
+	backward := params.SearchBefore != nil
+	pager := camunda.PagesAfter(app.camundaClient.Tasks.Search, search)
+	if backward {
+		pager = camunda.PagesBefore(app.camundaClient.Tasks.Search, search)
+	}
+
+	tasks := []camunda.Task{}
+	groups := map[string]bool{}
+	for len(tasks) < int(params.Size) && pager.Next(ctx) {
+		page := pager.Items()
+		for i := range page {
+			// a page before the cursor is walked from its end, the task
+			// closest to the cursor first
+			task := page[i]
+			if backward {
+				task = page[len(page)-1-i]
+			}
+
+			allowed, err := app.canAccessUserTask(ctx, user, task.TaskAssignment(), groups)
+			if err != nil {
+				return nil, err
+			}
+			if allowed {
+				tasks = append(tasks, task)
+			}
+			if len(tasks) == int(params.Size) {
+				break
+			}
+		}
+
+		if len(page) < int(params.Size) {
+			break
+		}
+	}
+	if err := pager.Err(); err != nil {
+		return nil, err
+	}
+
+	if backward {
+		slices.Reverse(tasks)
+	}
+	return tasks, nil
+}
+
+func (app *application) claimUserTask(w http.ResponseWriter, r *http.Request, taskDefinitionId string) {
+	taskKey, err := getTaskKeyParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	user := GetUserFromContext(r)
+
+	task, err := app.getUserTask(ctx, taskKey, taskDefinitionId, user)
+	if err != nil {
+		app.userTaskErrorResponse(w, r, err)
+		return
+	}
+
+	// tasklist answers 400 when the task is already assigned to someone else
+	task, err = app.camundaClient.Tasks.Assign(ctx, task.ID, camunda.AssignTaskRequest{
+		Assignee:                user.Username,
+		AllowOverrideAssignment: false,
+	})
+	if err != nil {
+		app.handleRequestError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, task); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) unclaimUserTask(w http.ResponseWriter, r *http.Request, taskDefinitionId string) {
+	taskKey, err := getTaskKeyParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	user := GetUserFromContext(r)
+
+	task, err := app.getAssignedUserTask(ctx, taskKey, taskDefinitionId, user)
+	if err != nil {
+		app.userTaskErrorResponse(w, r, err)
+		return
+	}
+
+	task, err = app.camundaClient.Tasks.Unassign(ctx, task.ID)
+	if err != nil {
+		app.handleRequestError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, task); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) completeUserTask(ctx context.Context, task *camunda.Task, variables map[string]interface{}) error {
+	taskVariables := []camunda.TaskVariable{}
+
+	// tasklist expects every variable value as a json encoded string
+	for name, value := range variables {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		taskVariables = append(taskVariables, camunda.TaskVariable{
+			Name:  name,
+			Value: string(encoded),
+		})
+	}
+
+	if _, err := app.camundaClient.Tasks.Complete(ctx, task.ID, taskVariables); err != nil {
+		return err
+	}
+
+	// the process moved on, sync the process tables without waiting for the next tick
+	if app.reconciler != nil {
+		app.reconciler.Trigger()
+	}
+
+	return nil
+}
+
+// formVariables turns a validated form payload into process variables keyed
+// by the form field keys.
+func formVariables(payload any) (map[string]interface{}, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	variables := map[string]interface{}{}
+	if err := json.Unmarshal(data, &variables); err != nil {
+		return nil, err
+	}
+
+	return variables, nil
+}
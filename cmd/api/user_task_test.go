@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/damarteplok/social/internal/camunda"
+	"github.com/damarteplok/social/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestFilterUserTasks(t *testing.T) {
+	app := newTestApplication(t, config{})
+	ctx := context.Background()
+
+	var tasks []camunda.Task
+	if err := json.Unmarshal([]byte(`[
+		{"id":"1"},
+		{"id":"2","assignee":"damar"},
+		{"id":"3","candidateUsers":["damar","budi"]},
+		{"id":"4","candidateGroups":["moderator"]},
+		{"id":"5","candidateGroups":["editor"]},
+		{"id":"6","assignee":"budi"}
+	]`), &tasks); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(t *testing.T, user *store.User) []string {
+		t.Helper()
+
+		filtered, err := filterUserTasks(ctx, app, user, tasks)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result := []string{}
+		for _, task := range filtered {
+			result = append(result, task.ID)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		user     *store.User
+		expected []string
+	}{
+		{
+			name:     "should show assigned and candidate tasks to the user",
+			user:     &store.User{Username: "damar", Role: store.Role{Name: "user", Level: 1}},
+			expected: []string{"1", "2", "3"},
+		},
+		{
+			name:     "should show candidate group tasks to higher roles",
+			user:     &store.User{Username: "admin", Role: store.Role{Name: "admin", Level: 3}},
+			expected: []string{"1", "4"},
+		},
+		{
+			name:     "should only show unassigned tasks to other users",
+			user:     &store.User{Username: "other", Role: store.Role{Name: "user", Level: 1}},
+			expected: []string{"1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ids(t, tt.user)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected tasks %v got %v", tt.expected, got)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("expected tasks %v got %v", tt.expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchUserTasks(t *testing.T) {
+	app := newTestApplication(t, config{})
+	ctx := context.Background()
+
+	// tasklist pages through tasks 1 to 10 in ascending order, the even ones
+	// are assigned to another user
+	requests := 0
+	tasklist := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		var search camunda.TaskSearch
+		if err := json.NewDecoder(r.Body).Decode(&search); err != nil {
+			t.Error(err)
+		}
+
+		from, to := 1, 10
+		if len(search.SearchAfter) > 0 {
+			after, _ := strconv.Atoi(search.SearchAfter[0])
+			from = after + 1
+		}
+		if len(search.SearchBefore) > 0 {
+			before, _ := strconv.Atoi(search.SearchBefore[0])
+			to = before - 1
+		}
+
+		tasks := []map[string]interface{}{}
+		for id := from; id <= to; id++ {
+			task := map[string]interface{}{"id": strconv.Itoa(id), "sortValues": []string{strconv.Itoa(id)}}
+			if id%2 == 0 {
+				task["assignee"] = "budi"
+			}
+			tasks = append(tasks, task)
+		}
+		if len(tasks) > int(search.PageSize) {
+			if len(search.SearchBefore) > 0 {
+				tasks = tasks[len(tasks)-int(search.PageSize):]
+			} else {
+				tasks = tasks[:search.PageSize]
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tasks)
+	}))
+	defer tasklist.Close()
+
+	app.camundaClient = camunda.NewClient(http.DefaultClient, camunda.Config{TasklistURL: tasklist.URL})
+	user := &store.User{Username: "damar", Role: store.Role{Name: "user", Level: 1}}
+
+	tests := []struct {
+		name     string
+		params   TaskListQueryParams
+		expected []string
+	}{
+		{
+			name:     "should fill the first page",
+			params:   TaskListQueryParams{Size: 3},
+			expected: []string{"1", "3", "5"},
+		},
+		{
+			name:     "should fill the page after a cursor",
+			params:   TaskListQueryParams{Size: 3, SearchAfter: []string{"5"}},
+			expected: []string{"7", "9"},
+		},
+		{
+			name:     "should fill the page before a cursor",
+			params:   TaskListQueryParams{Size: 2, SearchBefore: []string{"9"}},
+			expected: []string{"5", "7"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, err := app.searchUserTasks(ctx, store.PembuatanArtikelID, &tt.params, user)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			for _, task := range tasks {
+				got = append(got, task.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+				t.Errorf("expected tasks %v got %v", tt.expected, got)
+			}
+		})
+	}
+
+	if requests < 6 {
+		t.Errorf("expected the short pages to be fetched again, got %d requests", requests)
+	}
+}
+
+func TestUserTaskRoutes(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount().(chi.Routes)
+
+	routes := map[string]string{
+		"/v1/bpmn/pembuatan_media_berita_technology/":                                   http.MethodGet,
+		"/v1/bpmn/pembuatan_media_berita_technology/1/history":                          http.MethodGet,
+		"/v1/bpmn/pembuatan_media_berita_technology/tasks/pembuatanartikel/":            http.MethodGet,
+		"/v1/bpmn/pembuatanartikel/1/claim":                                             http.MethodPost,
+		"/v1/bpmn/pembuatan_media_berita_technology/tasks/reviewingartikel/submissions": http.MethodGet,
+	}
+	for path, method := range routes {
+		if !mux.Match(chi.NewRouteContext(), method, path) {
+			t.Errorf("expected a route for %s %s", method, path)
+		}
+	}
+}
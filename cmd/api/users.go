@@ -11,7 +11,12 @@ import (
 
 type userKey string
 
-const userCtx userKey = "user"
+const (
+	userCtx   userKey = "user"
+	claimsCtx userKey = "claims"
+)
+
+var ErrTokenRevoked = errors.New("token was revoked")
 
 // ActivateUser godoc
 //
@@ -43,7 +48,7 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 }
 
 func (app *application) getUserAllHandler(w http.ResponseWriter, r *http.Request) {
-	writeJSONError(w, http.StatusNotFound, "not found")
+	app.notFoundResponse(w, r, store.ErrNotFound)
 }
 
 // GetUser godoc
@@ -159,3 +164,94 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 }
+
+// RevokeSessions godoc
+//
+//	@Summary		Revoke the sessions of a user
+//	@Description	Revoke every refresh and access token of a user, admins may revoke the sessions of any user
+//	@Tags			users
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"Sessions revoked"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/sessions  [delete]
+func (app *application) revokeUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r)
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil || userID < 1 {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if userID != user.ID {
+		allowed, err := app.checkRolePrecedence(ctx, user, "admin")
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !allowed {
+			app.forbiddenResponse(w, r)
+			return
+		}
+	}
+
+	if err := app.revokeAllSessions(ctx, userID, ""); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ActivateUserByAdmin godoc
+//
+//	@Summary		Activate a user as an admin
+//	@Description	Activate a user without the invitation token, only for admins
+//	@Tags			users
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"User activated"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/activate  [put]
+func (app *application) activateUserByAdminHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil || userID < 1 {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	allowed, err := app.checkRolePrecedence(ctx, GetUserFromContext(r), "admin")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !allowed {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	if err := app.store.Users.ActivateByID(ctx, userID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if app.config.redisCfg.enabled {
+		app.cacheStorage.Users.Delete(ctx, userID)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
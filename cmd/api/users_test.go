@@ -78,3 +78,24 @@ func TestGetUser(t *testing.T) {
 		mockCacheStore.Calls = nil
 	})
 }
+
+func TestActivateUserByAdmin(t *testing.T) {
+	app := newTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should forbid the users below admin", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/users/2/activate", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+
+		rr := executeRequest(req, mux)
+
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+	})
+}
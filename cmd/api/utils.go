@@ -1,22 +1,31 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"github.com/damarteplok/social/internal/camunda"
 	"github.com/damarteplok/social/internal/store"
+	"github.com/golang-jwt/jwt/v5"
 )
 
+// handleRequestError matches wrapped errors too, a camunda.Error unwraps to
+// the store error of its status.
 func (app *application) handleRequestError(w http.ResponseWriter, r *http.Request, err error) {
-	switch err {
-	case store.ErrNotFound:
+	switch {
+	case errors.Is(err, store.ErrNotFound):
 		app.notFoundResponse(w, r, err)
-	case store.ErrBadRequest:
+	case errors.Is(err, store.ErrBadRequest):
 		app.badRequestResponse(w, r, err)
-	case store.ErrMethodNotAllowed:
+	case errors.Is(err, store.ErrMethodNotAllowed):
 		app.methodNotAllowedResponse(w, r, err)
+	case errors.Is(err, store.ErrConflict):
+		app.conflictResponse(w, r, err)
+	case errors.Is(err, store.ErrServiceUnavailable):
+		app.serviceUnavailableResponse(w, r, err)
 	default:
 		app.internalServerError(w, r, err)
 	}
@@ -36,106 +45,124 @@ func GetUserFromContext(r *http.Request) *store.User {
 	return user
 }
 
+func GetClaimsFromContext(r *http.Request) jwt.MapClaims {
+	claims, _ := r.Context().Value(claimsCtx).(jwt.MapClaims)
+	return claims
+}
+
 func getFlowNodeQueryParams(r *http.Request) (*FlowNodeQueryParams, error) {
-	size := r.URL.Query().Get("size")
-	if size == "" {
-		size = "50"
+	size, err := getSizeQueryParam(r)
+	if err != nil {
+		return nil, err
 	}
 
-	order := r.URL.Query().Get("order")
-	if order == "" {
-		order = "DESC"
-	} else {
-		order = strings.ToUpper(order)
-		if order != "DESC" && order != "ASC" {
-			return nil, fmt.Errorf("invalid order value")
-		}
+	order, err := getOrderQueryParam(r)
+	if err != nil {
+		return nil, err
 	}
 
-	sort := r.URL.Query().Get("sort")
-	typeStr := r.URL.Query().Get("type")
-	state := r.URL.Query().Get("state")
-	bpmnProcessId := r.URL.Query().Get("bpmnProcessId")
-	processDefinitionKey := r.URL.Query().Get("processDefinitionKey")
-	parentProcessInstanceKey := r.URL.Query().Get("parentProcessInstanceKey")
-	startDate := r.URL.Query().Get("startDate")
-	endDate := r.URL.Query().Get("endDate")
-
-	searchAfter := r.URL.Query().Get("searchAfter")
-	if searchAfter != "" {
-		searchAfter = fmt.Sprintf("[%s]", searchAfter)
+	processDefinitionKey, err := getKeyQueryParam(r, "processDefinitionKey")
+	if err != nil {
+		return nil, err
 	}
 
-	searchBefore := r.URL.Query().Get("searchBefore")
-	if searchBefore != "" {
-		searchBefore = fmt.Sprintf("[%s]", searchBefore)
+	parentProcessInstanceKey, err := getKeyQueryParam(r, "parentProcessInstanceKey")
+	if err != nil {
+		return nil, err
 	}
 
 	return &FlowNodeQueryParams{
 		Size:                     size,
 		Order:                    order,
-		Sort:                     sort,
-		SearchAfter:              searchAfter,
-		SearchBefore:             searchBefore,
-		Type:                     typeStr,
-		State:                    state,
-		BpmnProcessId:            bpmnProcessId,
+		Sort:                     r.URL.Query().Get("sort"),
+		SearchAfter:              camunda.ParseSortValues(r.URL.Query().Get("searchAfter")),
+		SearchBefore:             camunda.ParseSortValues(r.URL.Query().Get("searchBefore")),
+		Type:                     r.URL.Query().Get("type"),
+		State:                    r.URL.Query().Get("state"),
+		BpmnProcessId:            r.URL.Query().Get("bpmnProcessId"),
 		ProcessDefinitionKey:     processDefinitionKey,
 		ParentProcessInstanceKey: parentProcessInstanceKey,
-		StartDate:                startDate,
-		EndDate:                  endDate,
+		StartDate:                r.URL.Query().Get("startDate"),
+		EndDate:                  r.URL.Query().Get("endDate"),
 	}, nil
 }
 
 func getTaskListQueryParams(r *http.Request) (*TaskListQueryParams, error) {
+	size, err := getSizeQueryParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := getOrderQueryParam(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TaskListQueryParams{
+		Size:               size,
+		Order:              order,
+		Sort:               r.URL.Query().Get("sort"),
+		SearchAfter:        getStringsQueryParam(r, "searchAfter"),
+		SearchBefore:       getStringsQueryParam(r, "searchBefore"),
+		State:              r.URL.Query().Get("state"),
+		TaskDefinitionId:   r.URL.Query().Get("taskDefinitionId"),
+		ProcessInstanceKey: r.URL.Query().Get("processInstanceKey"),
+	}, nil
+}
+
+func getSizeQueryParam(r *http.Request) (int32, error) {
 	sizeStr := r.URL.Query().Get("size")
-	var size int32
 	if sizeStr == "" {
-		size = 50
-	} else {
-		size64, err := strconv.ParseInt(sizeStr, 10, 32)
-		if err != nil {
-			return nil, fmt.Errorf("invalid size value")
-		}
-		size = int32(size64)
+		return 50, nil
 	}
 
+	size, err := strconv.ParseInt(sizeStr, 10, 32)
+	if err != nil || size < 1 {
+		return 0, fmt.Errorf("invalid size value")
+	}
+
+	return int32(size), nil
+}
+
+func getOrderQueryParam(r *http.Request) (string, error) {
 	order := r.URL.Query().Get("order")
 	if order == "" {
-		order = "DESC"
-	} else {
-		order = strings.ToUpper(order)
-		if order != "DESC" && order != "ASC" {
-			return nil, fmt.Errorf("invalid order value")
-		}
+		return "DESC", nil
 	}
 
-	sort := r.URL.Query().Get("sort")
-
-	state := r.URL.Query().Get("state")
+	order = strings.ToUpper(order)
+	if order != "DESC" && order != "ASC" {
+		return "", fmt.Errorf("invalid order value")
+	}
 
-	taskDefinitionId := r.URL.Query().Get("taskDefinitionId")
+	return order, nil
+}
 
-	processInstanceKey := r.URL.Query().Get("processInstanceKey")
+func getKeyQueryParam(r *http.Request, name string) (*int64, error) {
+	keyStr := r.URL.Query().Get(name)
+	if keyStr == "" {
+		return nil, nil
+	}
 
-	searchAfter := r.URL.Query().Get("searchAfter")
-	if searchAfter != "" {
-		searchAfter = fmt.Sprintf("[%s]", searchAfter)
+	key, err := strconv.ParseInt(keyStr, 10, 64)
+	if err != nil || key < 1 {
+		return nil, fmt.Errorf("invalid %s value", name)
 	}
 
-	searchBefore := r.URL.Query().Get("searchBefore")
-	if searchBefore != "" {
-		searchBefore = fmt.Sprintf("[%s]", searchBefore)
+	return &key, nil
+}
+
+// getStringsQueryParam reads the comma separated sort values of tasklist,
+// which are strings even for numbers.
+func getStringsQueryParam(r *http.Request, name string) []string {
+	values := camunda.ParseSortValues(r.URL.Query().Get(name))
+	if values == nil {
+		return nil
 	}
 
-	return &TaskListQueryParams{
-		Size:               size,
-		Order:              order,
-		Sort:               sort,
-		SearchAfter:        searchAfter,
-		SearchBefore:       searchBefore,
-		State:              state,
-		TaskDefinitionId:   taskDefinitionId,
-		ProcessInstanceKey: processInstanceKey,
-	}, nil
+	result := make([]string, len(values))
+	for i, v := range values {
+		result[i] = fmt.Sprint(v)
+	}
+	return result
 }
@@ -0,0 +1,179 @@
+// Command bpmngen renders the store, cache, handler, route code and the
+// migrations of a bpmn model and its forms into the module:
+//
+//	go run ./cmd/bpmngen -key 2251799814076217 model.bpmn form.form
+//
+// Run it with -dry-run to see the diff first, running it again with the same
+// model changes nothing. A model with several processes takes the key of each
+// one with -process-key id=key, and the files of the processes started by its
+// call activities can be passed along.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/damarteplok/social/internal/env"
+	"github.com/damarteplok/social/internal/zeebe"
+)
+
+type options struct {
+	outDir       string
+	migrationDir string
+	bpmnFiles    []string
+	formFiles    []string
+	version      int32
+	key          int64
+	processKeys  map[string]int64
+	dryRun       bool
+	force        bool
+	dropColumns  bool
+}
+
+func main() {
+	opts := options{processKeys: map[string]int64{}}
+
+	flag.StringVar(&opts.outDir, "out", ".", "root of the module to generate into")
+	flag.StringVar(&opts.migrationDir, "migrations", env.GetString("MIGRATION_PATH", "./cmd/migrate/migrations"), "golang-migrate directory, relative to -out")
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "print the diff without writing files")
+	flag.BoolVar(&opts.force, "force", false, "overwrite generated files that were edited")
+	flag.BoolVar(&opts.dropColumns, "drop-columns", false, "drop the columns of removed form fields, their data is lost")
+	version := flag.Int("version", 1, "deployed version of the processes")
+	flag.Int64Var(&opts.key, "key", 0, "process definition key of the deployed process")
+	flag.Func("process-key", "process definition key of one process of a multi process model, as `id=key`, repeatable", func(value string) error {
+		id, key, ok := strings.Cut(value, "=")
+		if !ok {
+			return fmt.Errorf("expected id=key, got %q", value)
+		}
+		processKey, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return err
+		}
+		opts.processKeys[id] = processKey
+		return nil
+	})
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: bpmngen [flags] <file.bpmn> [file.bpmn ...] [file.form ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	opts.version = int32(*version)
+
+	for _, file := range flag.Args() {
+		if filepath.Ext(file) == ".bpmn" {
+			opts.bpmnFiles = append(opts.bpmnFiles, file)
+		} else {
+			opts.formFiles = append(opts.formFiles, file)
+		}
+	}
+
+	if len(opts.bpmnFiles) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(opts); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(opts options) error {
+	generator := zeebe.NewGenerator(opts.outDir, opts.force)
+	generator.SetMigrationDir(opts.migrationDir)
+	generator.SetDropColumns(opts.dropColumns)
+	for _, formFile := range opts.formFiles {
+		if err := generator.AddForm(formFile); err != nil {
+			return err
+		}
+	}
+
+	// call activities may start a process of another file
+	var bpmnProcess []zeebe.BPMNProcess
+	resourceNames := map[string]string{}
+	for _, bpmnFile := range opts.bpmnFiles {
+		content, err := os.ReadFile(bpmnFile)
+		if err != nil {
+			return err
+		}
+
+		processes, err := zeebe.ParseBpmn(content)
+		if err != nil {
+			return err
+		}
+
+		for _, process := range processes {
+			resourceNames[process.ID] = filepath.Base(bpmnFile)
+		}
+		bpmnProcess = append(bpmnProcess, processes...)
+	}
+
+	if err := generator.GenerateTasks(bpmnProcess); err != nil {
+		return err
+	}
+
+	for _, process := range bpmnProcess {
+		if !process.IsExecutable {
+			continue
+		}
+
+		key, ok := opts.processKeys[process.ID]
+		if !ok {
+			key = opts.key
+		}
+		if key == 0 {
+			log.Printf("warning: the key of process %s is not set, update its ProcessDefinitionKey constant after deploying", process.ID)
+		}
+
+		if err := generator.GenerateProcess(process.ID, resourceNames[process.ID], opts.version, key); err != nil {
+			return err
+		}
+	}
+
+	for _, processID := range generator.Missing() {
+		log.Printf("warning: process %s is started by a call activity, pass its bpmn file to generate it", processID)
+	}
+
+	for _, column := range generator.KeptColumns() {
+		log.Printf("warning: kept column %s of a removed form field (use -drop-columns to drop it)", column)
+	}
+
+	for _, path := range generator.Skipped() {
+		log.Printf("kept %s, it was edited after generation (use -force to overwrite)", path)
+	}
+
+	if opts.dryRun {
+		diff, err := generator.Diff()
+		if err != nil {
+			return err
+		}
+		if diff == "" {
+			log.Printf("nothing to generate, the files are up to date")
+			return nil
+		}
+		fmt.Print(diff)
+		return nil
+	}
+
+	changes, err := generator.Write()
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		log.Printf("nothing to generate, the files are up to date")
+	}
+	for _, change := range changes {
+		if change.Created {
+			log.Printf("created %s", change.Path)
+		} else {
+			log.Printf("updated %s", change.Path)
+		}
+	}
+
+	return nil
+}
@@ -11,10 +11,6 @@ CREATE TABLE IF NOT EXISTS pembuatanartikel (
 	deleted_at TIMESTAMP(0) WITH TIME ZONE
 );
 
-DROP TABLE IF EXISTS pembuatanartikel;
-
 CREATE EXTENSION IF NOT EXISTS pg_trgm;
 
 CREATE INDEX IF NOT EXISTS idx_pembuatanartikel_properties ON pembuatanartikel USING gin (properties);
-
-	
\ No newline at end of file
@@ -1,6 +1,7 @@
 CREATE TABLE IF NOT EXISTS reviewingartikel (
 	id BIGSERIAL PRIMARY KEY,
 	name VARCHAR(256) NOT NULL,
+	task_id VARCHAR(256),
 	form_id VARCHAR(256),
 	properties JSONB,
 	created_by BIGINT NOT NULL,
@@ -10,10 +11,6 @@ CREATE TABLE IF NOT EXISTS reviewingartikel (
 	deleted_at TIMESTAMP(0) WITH TIME ZONE
 );
 
-DROP TABLE IF EXISTS reviewingartikel;
-
 CREATE EXTENSION IF NOT EXISTS pg_trgm;
 
 CREATE INDEX IF NOT EXISTS idx_reviewingartikel_properties ON reviewingartikel USING gin (properties);
-
-	
\ No newline at end of file
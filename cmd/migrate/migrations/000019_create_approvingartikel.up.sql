@@ -1,6 +1,7 @@
 CREATE TABLE IF NOT EXISTS approvingartikel (
 	id BIGSERIAL PRIMARY KEY,
 	name VARCHAR(256) NOT NULL,
+	task_id VARCHAR(256),
 	form_id VARCHAR(256),
 	properties JSONB,
 	created_by BIGINT NOT NULL,
@@ -10,10 +11,6 @@ CREATE TABLE IF NOT EXISTS approvingartikel (
 	deleted_at TIMESTAMP(0) WITH TIME ZONE
 );
 
-DROP TABLE IF EXISTS approvingartikel;
-
 CREATE EXTENSION IF NOT EXISTS pg_trgm;
 
 CREATE INDEX IF NOT EXISTS idx_approvingartikel_properties ON approvingartikel USING gin (properties);
-
-	
\ No newline at end of file
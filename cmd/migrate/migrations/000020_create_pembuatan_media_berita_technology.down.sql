@@ -0,0 +1 @@
+DROP TABLE IF EXISTS pembuatan_media_berita_technology;
@@ -0,0 +1,5 @@
+ALTER TABLE pembuatanartikel DROP COLUMN IF EXISTS title;
+ALTER TABLE pembuatanartikel DROP COLUMN IF EXISTS content;
+ALTER TABLE pembuatanartikel DROP COLUMN IF EXISTS version;
+ALTER TABLE pembuatanartikel DROP COLUMN IF EXISTS tags;
+ALTER TABLE pembuatanartikel DROP COLUMN IF EXISTS decision;
@@ -0,0 +1,5 @@
+ALTER TABLE pembuatanartikel ADD COLUMN IF NOT EXISTS title TEXT;
+ALTER TABLE pembuatanartikel ADD COLUMN IF NOT EXISTS content TEXT;
+ALTER TABLE pembuatanartikel ADD COLUMN IF NOT EXISTS version DOUBLE PRECISION;
+ALTER TABLE pembuatanartikel ADD COLUMN IF NOT EXISTS tags TEXT;
+ALTER TABLE pembuatanartikel ADD COLUMN IF NOT EXISTS decision VARCHAR(256);
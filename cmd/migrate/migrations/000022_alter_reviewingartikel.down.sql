@@ -0,0 +1,2 @@
+ALTER TABLE reviewingartikel DROP COLUMN IF EXISTS note;
+ALTER TABLE reviewingartikel DROP COLUMN IF EXISTS decision;
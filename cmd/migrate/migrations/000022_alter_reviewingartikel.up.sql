@@ -0,0 +1,2 @@
+ALTER TABLE reviewingartikel ADD COLUMN IF NOT EXISTS note TEXT;
+ALTER TABLE reviewingartikel ADD COLUMN IF NOT EXISTS decision VARCHAR(256);
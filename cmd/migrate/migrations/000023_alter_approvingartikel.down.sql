@@ -0,0 +1 @@
+ALTER TABLE approvingartikel DROP COLUMN IF EXISTS decision;
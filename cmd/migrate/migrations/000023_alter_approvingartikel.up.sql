@@ -0,0 +1 @@
+ALTER TABLE approvingartikel ADD COLUMN IF NOT EXISTS decision VARCHAR(256);
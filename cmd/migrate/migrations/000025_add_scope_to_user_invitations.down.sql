@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_user_invitations_user_id_scope;
+ALTER TABLE user_invitations DROP COLUMN email;
+ALTER TABLE user_invitations DROP COLUMN scope;
@@ -0,0 +1,8 @@
+ALTER TABLE user_invitations
+ADD COLUMN scope VARCHAR(20) NOT NULL DEFAULT 'activation';
+
+-- the address an email change is confirmed for
+ALTER TABLE user_invitations
+ADD COLUMN email citext;
+
+CREATE INDEX IF NOT EXISTS idx_user_invitations_user_id_scope ON user_invitations (user_id, scope);
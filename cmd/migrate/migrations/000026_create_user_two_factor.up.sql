@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS user_two_factor (
+    user_id BIGINT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    -- the totp secret encrypted with TWO_FACTOR_KEY
+    secret BYTEA NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT false,
+    -- the last time step used, a code is valid once
+    last_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP(0) WITH TIME ZONE,
+    UNIQUE (user_id, code)
+);
@@ -0,0 +1 @@
+ALTER TABLE pembuatanartikel DROP COLUMN IF EXISTS task_id;
@@ -0,0 +1,4 @@
+-- pembuatanartikel was created from scripts/pembuatanartikel_user_task.sql before the migrations,
+-- 000017 did nothing on those databases, its form columns
+-- are added by 000021
+ALTER TABLE pembuatanartikel ADD COLUMN IF NOT EXISTS task_id VARCHAR(256);
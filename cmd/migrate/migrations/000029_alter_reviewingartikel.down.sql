@@ -0,0 +1 @@
+ALTER TABLE reviewingartikel DROP COLUMN IF EXISTS task_id;
@@ -0,0 +1,4 @@
+-- reviewingartikel was created from scripts/reviewingartikel_user_task.sql before the migrations,
+-- 000018 did nothing on those databases, its form columns
+-- are added by 000022
+ALTER TABLE reviewingartikel ADD COLUMN IF NOT EXISTS task_id VARCHAR(256);
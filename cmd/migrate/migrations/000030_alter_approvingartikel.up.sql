@@ -0,0 +1,4 @@
+-- approvingartikel was created from scripts/approvingartikel_user_task.sql before the migrations,
+-- 000019 did nothing on those databases, its form columns
+-- are added by 000023
+ALTER TABLE approvingartikel ADD COLUMN IF NOT EXISTS task_id VARCHAR(256);
@@ -0,0 +1,12 @@
+-- the challenges of the logins waiting for a second factor, a challenge is
+-- used once and its failed codes count against the user
+CREATE TABLE IF NOT EXISTS two_factor_challenges (
+    jti VARCHAR(64) PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    failed_attempts INT NOT NULL DEFAULT 0,
+    used_at TIMESTAMP(0) WITH TIME ZONE,
+    expires_at TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_two_factor_challenges_user_id ON two_factor_challenges (user_id, created_at);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_outbox_created_by_idempotency_key;
+
+ALTER TABLE outbox ADD CONSTRAINT outbox_idempotency_key_key UNIQUE (idempotency_key);
+
+ALTER TABLE outbox DROP COLUMN IF EXISTS created_by;
@@ -0,0 +1,7 @@
+-- the idempotency keys are unique per user, the commands of the api itself
+-- have created_by 0
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS created_by BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE outbox DROP CONSTRAINT IF EXISTS outbox_idempotency_key_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_created_by_idempotency_key ON outbox (created_by, idempotency_key);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_posts_process_instance_key;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS process_instance_key;
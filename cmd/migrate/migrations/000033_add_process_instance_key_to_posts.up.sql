@@ -0,0 +1,5 @@
+-- the process instance that published a post, a retried publish job finds
+-- the post of its instance instead of creating another one
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS process_instance_key BIGINT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_process_instance_key ON posts (process_instance_key);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_outbox_pending;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (next_attempt_at) WHERE processed_at IS NULL;
+
+ALTER TABLE outbox DROP COLUMN IF EXISTS dead_at;
+
+ALTER TABLE outbox DROP COLUMN IF EXISTS last_attempt_at;
@@ -0,0 +1,9 @@
+-- a claim counts as an attempt, last_attempt_at is the time of the last claim
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS last_attempt_at TIMESTAMP WITH TIME ZONE;
+
+-- a message that used up its attempts is dead and never claimed again
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS dead_at TIMESTAMP(0) WITH TIME ZONE;
+
+DROP INDEX IF EXISTS idx_outbox_pending;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (next_attempt_at) WHERE processed_at IS NULL AND dead_at IS NULL;
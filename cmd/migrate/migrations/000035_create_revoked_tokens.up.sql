@@ -0,0 +1,13 @@
+-- the denylist of the access tokens when redis is disabled, a row is kept
+-- until the token expires
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP(0) WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
+
+-- every access token of the user issued before is revoked, except the ones
+-- of the session started along with the revocation
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tokens_revoked_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tokens_revoked_sid VARCHAR(64);
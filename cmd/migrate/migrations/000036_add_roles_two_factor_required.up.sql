@@ -0,0 +1,5 @@
+-- the users of a role with two_factor_required must log in with a second
+-- factor, the roles of the former TWO_FACTOR_ROLES default keep requiring it
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS two_factor_required BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE roles SET two_factor_required = true WHERE name IN ('moderator', 'admin');
@@ -239,7 +239,7 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Create PembuatanMediaBeritaTechnology",
+                "description": "Create PembuatanMediaBeritaTechnology, the process instance is started in the background: the response is 202 Accepted instead of 201 Created and process_instance_key is set once zeebe started the instance. A retry with the same Idempotency-Key returns the first row with 200.",
                 "consumes": [
                     "application/json"
                 ],
@@ -251,6 +251,12 @@ const docTemplate = `{
                 ],
                 "summary": "Create PembuatanMediaBeritaTechnology",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Retries with the same key return the first row",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
                     {
                         "description": "PembuatanMediaBeritaTechnology Payload",
                         "name": "payload",
@@ -262,7 +268,13 @@ const docTemplate = `{
                     }
                 ],
                 "responses": {
-                    "201": {
+                    "200": {
+                        "description": "PembuatanMediaBeritaTechnology Already Created",
+                        "schema": {
+                            "$ref": "#/definitions/main.DataStorePembuatanMediaBeritaTechnologyWrapper"
+                        }
+                    },
+                    "202": {
                         "description": "PembuatanMediaBeritaTechnology Created",
                         "schema": {
                             "$ref": "#/definitions/main.DataStorePembuatanMediaBeritaTechnologyWrapper"
@@ -272,6 +284,10 @@ const docTemplate = `{
                         "description": "Bad Request",
                         "schema": {}
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {}
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {}
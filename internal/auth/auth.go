@@ -6,3 +6,9 @@ type Authenticator interface {
 	GenerateToken(claims jwt.Claims) (string, error)
 	ValidateToken(token string) (*jwt.Token, error)
 }
+
+// KeySet publishes the public keys that verify the tokens of an
+// Authenticator.
+type KeySet interface {
+	JWKS() JSONWebKeySet
+}
@@ -2,24 +2,69 @@ package auth
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// JWTAuthenticator signs the tokens with a single key and verifies them with
+// every key it knows, the key of a token is picked by its kid header. A key
+// that signed tokens stays a verification key until they expire, so keys
+// rotate without logging the users out.
 type JWTAuthenticator struct {
-	secret string
-	aud    string
-	iss    string
+	signing *Key
+	keys    map[string]*Key
+	methods []string
+	aud     string
+	iss     string
 }
 
+// NewJWTAuthenticator signs with a shared HS256 secret, the tokens have no
+// kid.
 func NewJWTAuthenticator(secret, aud, iss string) *JWTAuthenticator {
-	return &JWTAuthenticator{secret: secret, aud: aud, iss: iss}
+	key := NewSecretKey(secret)
+
+	return &JWTAuthenticator{
+		signing: key,
+		keys:    map[string]*Key{key.ID: key},
+		methods: []string{key.Method.Alg()},
+		aud:     aud,
+		iss:     iss,
+	}
+}
+
+// NewKeyAuthenticator signs with the private key of signing, the tokens of
+// the keys of verification are accepted too.
+func NewKeyAuthenticator(signing *Key, verification []*Key, aud, iss string) (*JWTAuthenticator, error) {
+	if signing.private == nil {
+		return nil, fmt.Errorf("signing key %s has no private key", signing.ID)
+	}
+
+	a := &JWTAuthenticator{
+		signing: signing,
+		keys:    map[string]*Key{},
+		aud:     aud,
+		iss:     iss,
+	}
+	for _, key := range append([]*Key{signing}, verification...) {
+		if _, ok := a.keys[key.ID]; ok {
+			continue
+		}
+		a.keys[key.ID] = key
+		a.methods = append(a.methods, key.Method.Alg())
+	}
+
+	return a, nil
 }
 
 func (a *JWTAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(a.signing.Method, claims)
+	if a.signing.ID != "" {
+		token.Header["kid"] = a.signing.ID
+	}
 
-	tokenString, err := token.SignedString([]byte(a.secret))
+	tokenString, err := token.SignedString(a.signing.private)
 	if err != nil {
 		return "", err
 	}
@@ -28,15 +73,44 @@ func (a *JWTAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
 
 func (a *JWTAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
 	return jwt.Parse(token, func(t *jwt.Token) (any, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := a.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+		// the alg of the header must be the one of the key, a public key
+		// must never be used as a HMAC secret
+		if t.Method.Alg() != key.Method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
 		}
 
-		return []byte(a.secret), nil
+		return key.public, nil
 	},
 		jwt.WithExpirationRequired(),
 		jwt.WithAudience(a.aud),
-		jwt.WithIssuer(a.aud),
-		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}),
+		jwt.WithIssuer(a.iss),
+		jwt.WithValidMethods(a.methods),
 	)
 }
+
+// JWKS returns the public keys of the verification keys, a shared secret is
+// never published.
+func (a *JWTAuthenticator) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range a.keys {
+		if jwk, ok := key.JSONWebKey(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	// the signing key first, clients try the keys in order
+	slices.SortStableFunc(set.Keys, func(x, y JSONWebKey) int {
+		switch {
+		case x.Kid == a.signing.ID:
+			return -1
+		case y.Kid == a.signing.ID:
+			return 1
+		}
+		return strings.Compare(x.Kid, y.Kid)
+	})
+	return set
+}
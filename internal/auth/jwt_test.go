@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writeKeyFile(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestKeyAuthenticator(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPublicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldKey, err := LoadKey(writeKeyFile(t, "old.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPublic, err := LoadKey(writeKeyFile(t, "old.pub", "PUBLIC KEY", rsaPublicDER))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := LoadKey(writeKeyFile(t, "new.pem", "PRIVATE KEY", edDER))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oldKey.ID != oldPublic.ID {
+		t.Fatalf("expected the kid of a key and its public key to match, got %s and %s", oldKey.ID, oldPublic.ID)
+	}
+
+	claims := jwt.MapClaims{
+		"aud": "test-aud",
+		"iss": "test-iss",
+		"sub": 42,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+
+	before, err := NewKeyAuthenticator(oldKey, nil, "test-aud", "test-iss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := before.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewKeyAuthenticator(oldPublic, nil, "test-aud", "test-iss"); err == nil {
+		t.Error("expected a public key not to sign")
+	}
+
+	after, err := NewKeyAuthenticator(newKey, []*Key{oldPublic}, "test-aud", "test-iss")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should sign with the new key and its kid", func(t *testing.T) {
+		token, err := after.GenerateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := after.ValidateToken(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Method != jwt.SigningMethodEdDSA || parsed.Header["kid"] != newKey.ID {
+			t.Errorf("unexpected header %v", parsed.Header)
+		}
+
+		if _, err := before.ValidateToken(token); err == nil {
+			t.Error("expected the token of an unknown key to be rejected")
+		}
+	})
+
+	t.Run("should verify the tokens of a rotated key", func(t *testing.T) {
+		if _, err := after.ValidateToken(oldToken); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("should reject a token signed with a public key as secret", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		token.Header["kid"] = oldKey.ID
+		forged, err := token.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rsaPublicDER}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := after.ValidateToken(forged); err == nil {
+			t.Error("expected the forged token to be rejected")
+		}
+	})
+
+	t.Run("should publish the public keys", func(t *testing.T) {
+		set := after.JWKS()
+		if len(set.Keys) != 2 || set.Keys[0].Kid != newKey.ID || set.Keys[0].Kty != "OKP" || set.Keys[1].Kty != "RSA" {
+			t.Fatalf("unexpected key set %+v", set)
+		}
+
+		for _, jwk := range set.Keys {
+			if _, err := jwk.PublicKey(); err != nil {
+				t.Errorf("%s: %v", jwk.Kid, err)
+			}
+		}
+	})
+
+	t.Run("should keep the shared secret private", func(t *testing.T) {
+		if set := NewJWTAuthenticator("test", "test-aud", "test-iss").JWKS(); len(set.Keys) != 0 {
+			t.Errorf("expected no keys, got %+v", set)
+		}
+	})
+}
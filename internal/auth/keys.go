@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSABits is the smallest RSA key RFC 7518 allows for RS256.
+const minRSABits = 2048
+
+// Key is a key of JWTAuthenticator. The ID of an asymmetric key is the
+// thumbprint of its public key, the kid of its tokens.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	// private is nil for a key that only verifies
+	private any
+	public  any
+}
+
+func NewSecretKey(secret string) *Key {
+	return &Key{
+		Method:  jwt.SigningMethodHS256,
+		private: []byte(secret),
+		public:  []byte(secret),
+	}
+}
+
+// LoadKey reads a PEM key file, RSA keys sign with RS256 and Ed25519 keys
+// with EdDSA. A private key signs and verifies, a public key verifies.
+func LoadKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ParseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+func ParseKey(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM block", ErrUnsupportedKey)
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: PEM block %q", ErrUnsupportedKey, block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, parsed)
+	}
+
+	if rsaKey, ok := key.public.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < minRSABits {
+		return nil, fmt.Errorf("%w: RSA key of %d bits", ErrUnsupportedKey, rsaKey.N.BitLen())
+	}
+
+	jwk, err := NewJSONWebKey(key.public)
+	if err != nil {
+		return nil, err
+	}
+	key.ID, err = jwk.Thumbprint()
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// JSONWebKey returns the public key to publish, false for a shared secret.
+func (k *Key) JSONWebKey() (JSONWebKey, bool) {
+	jwk, err := NewJSONWebKey(k.public)
+	if err != nil {
+		return JSONWebKey{}, false
+	}
+
+	jwk.Kid = k.ID
+	jwk.Use = "sig"
+	jwk.Alg = k.Method.Alg()
+	return jwk, true
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrSecretCorrupted = errors.New("secret cannot be decrypted")
+	ErrWeakPassphrase  = errors.New("passphrase of the secret box is too short")
+)
+
+// MinPassphraseLength keeps a passphrase out of reach of a dictionary, the
+// key is only a hash of it.
+const MinPassphraseLength = 32
+
+// SecretBox encrypts the secrets kept in the database with AES-GCM, the key
+// is derived from a passphrase of the config.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// CheckPassphrase rejects a passphrase too short to derive a key from.
+func CheckPassphrase(passphrase string) error {
+	if len(passphrase) < MinPassphraseLength {
+		return fmt.Errorf("%w: %d characters, at least %d are required", ErrWeakPassphrase, len(passphrase), MinPassphraseLength)
+	}
+	return nil
+}
+
+func NewSecretBox(passphrase string) (*SecretBox, error) {
+	key := sha256.Sum256([]byte(passphrase))
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SecretBox{aead: aead}, nil
+}
+
+// Seal returns the nonce followed by the ciphertext.
+func (b *SecretBox) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return b.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (b *SecretBox) Open(sealed []byte) ([]byte, error) {
+	size := b.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, ErrSecretCorrupted
+	}
+
+	plaintext, err := b.aead.Open(nil, sealed[:size], sealed[size:], nil)
+	if err != nil {
+		return nil, ErrSecretCorrupted
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+func TestTOTP(t *testing.T) {
+	// the SHA1 secret of the test vectors of RFC 6238
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	tests := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, expected := range tests {
+		code, err := TOTPCode(secret, time.Unix(unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != expected {
+			t.Errorf("expected %s at %d got %s", expected, unix, code)
+		}
+	}
+
+	t.Run("should accept the codes of the next and previous period", func(t *testing.T) {
+		now := time.Unix(1234567890, 0)
+		for _, at := range []time.Time{now.Add(-totpPeriod * time.Second), now, now.Add(totpPeriod * time.Second)} {
+			code, _ := TOTPCode(secret, at)
+			if _, ok := ValidateTOTP(secret, code, now); !ok {
+				t.Errorf("expected the code of %s to be valid", at)
+			}
+		}
+
+		code, _ := TOTPCode(secret, now.Add(2*totpPeriod*time.Second))
+		if _, ok := ValidateTOTP(secret, code, now); ok {
+			t.Error("expected the code of two periods later to be invalid")
+		}
+	})
+}
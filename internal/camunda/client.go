@@ -0,0 +1,130 @@
+package camunda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Doer sends a request to camunda. zeebe.ZeebeClientRest implements it and
+// attaches its bearer token to every request.
+type Doer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+type Config struct {
+	ZeebeURL    string
+	OperateURL  string
+	TasklistURL string
+}
+
+type Client struct {
+	ProcessInstances interface {
+		Get(context.Context, int64) (*ProcessInstance, error)
+		Search(context.Context, Query[ProcessInstanceFilter]) (*Results[ProcessInstance], error)
+		SearchKeys(context.Context, []int64) ([]ProcessInstance, error)
+		Create(context.Context, CreateProcessInstanceRequest) (*CreateProcessInstanceResponse, error)
+		Cancel(context.Context, int64) error
+	}
+	FlowNodeInstances interface {
+		Search(context.Context, Query[FlowNodeInstanceFilter]) (*Results[FlowNodeInstance], error)
+	}
+	Incidents interface {
+		Search(context.Context, Query[IncidentFilter]) (*Results[Incident], error)
+		Resolve(context.Context, int64) error
+	}
+	ProcessDefinitions interface {
+		Get(context.Context, int64) (*ProcessDefinition, error)
+		Search(context.Context, Query[ProcessDefinitionFilter]) (*Results[ProcessDefinition], error)
+		XML(context.Context, int64) (string, error)
+		Delete(context.Context, int64) error
+	}
+	Variables interface {
+		Search(context.Context, Query[VariableFilter]) (*Results[Variable], error)
+	}
+	Statistics interface {
+		Core(context.Context) (*CoreStatistics, error)
+		IncidentsByProcess(context.Context) ([]ProcessStatistics, error)
+	}
+	Tasks interface {
+		Search(context.Context, TaskSearch) (*Results[Task], error)
+		Get(context.Context, string) (*Task, error)
+		Assign(context.Context, string, AssignTaskRequest) (*Task, error)
+		Unassign(context.Context, string) (*Task, error)
+		Complete(context.Context, string, []TaskVariable) (*Task, error)
+	}
+	UserTasks interface {
+		Search(context.Context, UserTaskQuery) (*UserTaskResults, error)
+	}
+}
+
+func NewClient(doer Doer, cfg Config) Client {
+	r := requester{doer}
+
+	return Client{
+		ProcessInstances:   &ProcessInstanceClient{r, cfg},
+		FlowNodeInstances:  &FlowNodeInstanceClient{r, cfg},
+		Incidents:          &IncidentClient{r, cfg},
+		ProcessDefinitions: &ProcessDefinitionClient{r, cfg},
+		Variables:          &VariableClient{r, cfg},
+		Statistics:         &StatisticsClient{r, cfg},
+		Tasks:              &TaskClient{r, cfg},
+		UserTasks:          &UserTaskClient{r, cfg},
+	}
+}
+
+type requester struct {
+	doer Doer
+}
+
+// do sends in as the json body of the request and decodes the response into
+// out. A nil in sends no body and a nil out discards the response.
+func (r requester) do(ctx context.Context, method, url string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.doer.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newError(resp.StatusCode, data)
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if raw, ok := out.(*string); ok {
+		*raw = string(data)
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package camunda_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/damarteplok/social/internal/camunda"
+	"github.com/damarteplok/social/internal/store"
+	"github.com/damarteplok/social/internal/zeebe"
+)
+
+func newTestClient(t *testing.T, instances int) (camunda.Client, []int64) {
+	t.Helper()
+
+	engine := zeebe.NewFakeEngine()
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+
+	rest, err := zeebe.NewZeebeClientRest(zeebe.RestConfig{
+		Token:    zeebe.TokenConfig{ClientID: "test", ClientSecret: "test", AuthURL: server.URL + "/token"},
+		ZeebeURL: server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	processes, _, err := engine.DeployProcessDefinition("pembuatan_media_berita_technology.bpmn", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []int64{}
+	for i := 0; i < instances; i++ {
+		instance, err := engine.StartWorkflow(context.Background(), processes[0].ProcessDefinitionKey, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, instance.GetProcessInstanceKey())
+	}
+
+	return camunda.NewClient(rest, camunda.Config{
+		ZeebeURL:    server.URL,
+		OperateURL:  server.URL,
+		TasklistURL: server.URL,
+	}), keys
+}
+
+func TestPager(t *testing.T) {
+	ctx := context.Background()
+	client, keys := newTestClient(t, 5)
+
+	t.Run("should page forward and back through process instances", func(t *testing.T) {
+		query := camunda.Query[camunda.ProcessInstanceFilter]{Size: 2}
+
+		var got []int64
+		var pages int
+		pager := camunda.PagesAfter(client.ProcessInstances.Search, query)
+		for pager.Next(ctx) {
+			pages++
+			got = append(got, instanceKeys(pager.Items())...)
+		}
+		if err := pager.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if pages != 3 || !equalKeys(got, keys) {
+			t.Fatalf("expected instances %v in 3 pages got %v in %d", keys, got, pages)
+		}
+
+		all, err := client.ProcessInstances.Search(ctx, camunda.Query[camunda.ProcessInstanceFilter]{Size: int32(len(keys))})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the pages before the last instance come newest first
+		query.SearchBefore = all.SortValues
+		var back []int64
+		pager = camunda.PagesBefore(client.ProcessInstances.Search, query)
+		for pager.Next(ctx) {
+			back = append(instanceKeys(pager.Items()), back...)
+		}
+		if err := pager.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if !equalKeys(back, keys[:len(keys)-1]) {
+			t.Fatalf("expected instances %v before the last got %v", keys[:len(keys)-1], back)
+		}
+	})
+
+	t.Run("should page through tasklist tasks", func(t *testing.T) {
+		pager := camunda.PagesAfter(client.Tasks.Search, camunda.TaskSearch{
+			State:    "CREATED",
+			PageSize: 2,
+			Sort:     []camunda.Sort{{Field: "creationTime", Order: "ASC"}},
+		})
+
+		var got int
+		for pager.Next(ctx) {
+			got += len(pager.Items())
+		}
+		if err := pager.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if got != len(keys) {
+			t.Errorf("expected %d tasks got %d", len(keys), got)
+		}
+	})
+}
+
+func instanceKeys(instances []camunda.ProcessInstance) []int64 {
+	keys := make([]int64, len(instances))
+	for i, instance := range instances {
+		keys[i] = instance.Key
+	}
+	return keys
+}
+
+func equalKeys(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	client, keys := newTestClient(t, 1)
+
+	_, err := client.ProcessInstances.Get(ctx, 1)
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected %v got %v", store.ErrNotFound, err)
+	}
+
+	var camundaErr *camunda.Error
+	if !errors.As(err, &camundaErr) || camundaErr.StatusCode != 404 {
+		t.Errorf("expected a camunda error with status 404 got %v", err)
+	}
+
+	if err := client.ProcessInstances.Cancel(ctx, keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ProcessInstances.Cancel(ctx, keys[0]); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected a canceled instance to be %v got %v", store.ErrNotFound, err)
+	}
+}
+
+func TestParseSortValues(t *testing.T) {
+	got := camunda.ParseSortValues("1731486859777, abc ,\"2251799814109407\"")
+	want := []interface{}{int64(1731486859777), "abc", int64(2251799814109407)}
+
+	if len(got) != len(want) {
+		t.Fatalf("expected %v got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected %v got %v", want, got)
+		}
+	}
+
+	if got := camunda.ParseSortValues(""); got != nil {
+		t.Errorf("expected no sort values got %v", got)
+	}
+}
@@ -0,0 +1,64 @@
+package camunda
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/damarteplok/social/internal/store"
+)
+
+// Error is a camunda response with an error status. It unwraps to the store
+// error of its status, so handlers can keep matching on store.ErrNotFound and
+// friends with errors.Is.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("request failed with status: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("request failed with status: %d: %s", e.StatusCode, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return store.ErrNotFound
+	case http.StatusBadRequest:
+		return store.ErrBadRequest
+	case http.StatusMethodNotAllowed:
+		return store.ErrMethodNotAllowed
+	case http.StatusConflict:
+		return store.ErrConflict
+	case http.StatusServiceUnavailable:
+		return store.ErrServiceUnavailable
+	default:
+		return nil
+	}
+}
+
+// newError reads the message of an error body. Operate and Tasklist answer
+// with a message, the v2 api with a problem detail.
+func newError(statusCode int, body []byte) error {
+	var problem struct {
+		Message string `json:"message"`
+		Detail  string `json:"detail"`
+		Title   string `json:"title"`
+	}
+	if err := json.Unmarshal(body, &problem); err != nil {
+		return &Error{StatusCode: statusCode}
+	}
+
+	message := problem.Message
+	if message == "" {
+		message = problem.Detail
+	}
+	if message == "" {
+		message = problem.Title
+	}
+
+	return &Error{StatusCode: statusCode, Message: message}
+}
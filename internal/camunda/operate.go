@@ -0,0 +1,290 @@
+package camunda
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/damarteplok/social/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ProcessInstance struct {
+	Key                       int64   `json:"key"`
+	ProcessVersion            int32   `json:"processVersion"`
+	ProcessVersionTag         string  `json:"processVersionTag,omitempty"`
+	BpmnProcessId             string  `json:"bpmnProcessId"`
+	ParentKey                 *int64  `json:"parentKey,omitempty"`
+	ParentFlowNodeInstanceKey *int64  `json:"parentFlowNodeInstanceKey,omitempty"`
+	StartDate                 string  `json:"startDate"`
+	EndDate                   *string `json:"endDate"`
+	State                     string  `json:"state"`
+	Incident                  bool    `json:"incident"`
+	ProcessDefinitionKey      int64   `json:"processDefinitionKey"`
+	TenantId                  string  `json:"tenantId"`
+}
+
+type ProcessInstanceFilter struct {
+	Key                  *int64 `json:"key,omitempty"`
+	ProcessVersion       *int32 `json:"processVersion,omitempty"`
+	BpmnProcessId        string `json:"bpmnProcessId,omitempty"`
+	ParentKey            *int64 `json:"parentKey,omitempty"`
+	StartDate            string `json:"startDate,omitempty"`
+	EndDate              string `json:"endDate,omitempty"`
+	State                string `json:"state,omitempty"`
+	Incident             *bool  `json:"incident,omitempty"`
+	ProcessDefinitionKey *int64 `json:"processDefinitionKey,omitempty"`
+	TenantId             string `json:"tenantId,omitempty"`
+}
+
+type FlowNodeInstance struct {
+	Key                  int64   `json:"key"`
+	ProcessInstanceKey   int64   `json:"processInstanceKey"`
+	ProcessDefinitionKey int64   `json:"processDefinitionKey"`
+	StartDate            string  `json:"startDate"`
+	EndDate              *string `json:"endDate"`
+	FlowNodeId           string  `json:"flowNodeId"`
+	FlowNodeName         string  `json:"flowNodeName"`
+	IncidentKey          *int64  `json:"incidentKey,omitempty"`
+	Type                 string  `json:"type"`
+	State                string  `json:"state"`
+	Incident             bool    `json:"incident"`
+	TenantId             string  `json:"tenantId"`
+}
+
+type FlowNodeInstanceFilter struct {
+	Key                  *int64 `json:"key,omitempty"`
+	ProcessInstanceKey   *int64 `json:"processInstanceKey,omitempty"`
+	ProcessDefinitionKey *int64 `json:"processDefinitionKey,omitempty"`
+	FlowNodeId           string `json:"flowNodeId,omitempty"`
+	Type                 string `json:"type,omitempty"`
+	State                string `json:"state,omitempty"`
+	Incident             *bool  `json:"incident,omitempty"`
+	TenantId             string `json:"tenantId,omitempty"`
+}
+
+type Incident struct {
+	Key                  int64  `json:"key"`
+	ProcessDefinitionKey int64  `json:"processDefinitionKey"`
+	ProcessInstanceKey   int64  `json:"processInstanceKey"`
+	Type                 string `json:"type"`
+	Message              string `json:"message"`
+	CreationTime         string `json:"creationTime"`
+	State                string `json:"state"`
+	JobKey               int64  `json:"jobKey,omitempty"`
+	TenantId             string `json:"tenantId"`
+}
+
+type IncidentFilter struct {
+	Key                  *int64 `json:"key,omitempty"`
+	ProcessDefinitionKey *int64 `json:"processDefinitionKey,omitempty"`
+	ProcessInstanceKey   *int64 `json:"processInstanceKey,omitempty"`
+	Type                 string `json:"type,omitempty"`
+	State                string `json:"state,omitempty"`
+	JobKey               *int64 `json:"jobKey,omitempty"`
+	TenantId             string `json:"tenantId,omitempty"`
+}
+
+type ProcessDefinition struct {
+	Key           int64  `json:"key"`
+	Name          string `json:"name"`
+	Version       int32  `json:"version"`
+	VersionTag    string `json:"versionTag,omitempty"`
+	BpmnProcessId string `json:"bpmnProcessId"`
+	TenantId      string `json:"tenantId"`
+}
+
+type ProcessDefinitionFilter struct {
+	Key           *int64 `json:"key,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Version       *int32 `json:"version,omitempty"`
+	BpmnProcessId string `json:"bpmnProcessId,omitempty"`
+	TenantId      string `json:"tenantId,omitempty"`
+}
+
+type Variable struct {
+	Key                int64  `json:"key,omitempty"`
+	ProcessInstanceKey int64  `json:"processInstanceKey"`
+	ScopeKey           int64  `json:"scopeKey"`
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Truncated          bool   `json:"truncated"`
+	TenantId           string `json:"tenantId"`
+}
+
+type VariableFilter struct {
+	ProcessInstanceKey *int64 `json:"processInstanceKey,omitempty"`
+	ScopeKey           *int64 `json:"scopeKey,omitempty"`
+	Name               string `json:"name,omitempty"`
+	// Value is json encoded, as operate stores it
+	Value    string `json:"value,omitempty"`
+	TenantId string `json:"tenantId,omitempty"`
+}
+
+type CoreStatistics struct {
+	Running       int64 `json:"running"`
+	Active        int64 `json:"active"`
+	WithIncidents int64 `json:"withIncidents"`
+}
+
+type ProcessStatistics struct {
+	BpmnProcessId                     string                     `json:"bpmnProcessId"`
+	TenantId                          string                     `json:"tenantId"`
+	ProcessName                       *string                    `json:"processName"`
+	InstancesWithActiveIncidentsCount int64                      `json:"instancesWithActiveIncidentsCount"`
+	ActiveInstancesCount              int64                      `json:"activeInstancesCount"`
+	Processes                         []ProcessVersionStatistics `json:"processes"`
+}
+
+type ProcessVersionStatistics struct {
+	ProcessId                         string  `json:"processId"`
+	Version                           int32   `json:"version"`
+	Name                              *string `json:"name"`
+	BpmnProcessId                     string  `json:"bpmnProcessId"`
+	TenantId                          string  `json:"tenantId"`
+	ErrorMessage                      *string `json:"errorMessage"`
+	InstancesWithActiveIncidentsCount int64   `json:"instancesWithActiveIncidentsCount"`
+	ActiveInstancesCount              int64   `json:"activeInstancesCount"`
+}
+
+type ProcessInstanceClient struct {
+	requester
+	config Config
+}
+
+func (c *ProcessInstanceClient) Get(ctx context.Context, key int64) (*ProcessInstance, error) {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.ProcessInstanceKey(key))
+
+	var instance ProcessInstance
+	url := fmt.Sprintf("%s/v1/process-instances/%d", c.config.OperateURL, key)
+	if err := c.do(ctx, http.MethodGet, url, nil, &instance); err != nil {
+		return nil, err
+	}
+	return &instance, nil
+}
+
+func (c *ProcessInstanceClient) Search(ctx context.Context, query Query[ProcessInstanceFilter]) (*Results[ProcessInstance], error) {
+	var results Results[ProcessInstance]
+	url := c.config.OperateURL + "/v1/process-instances/search"
+	if err := c.do(ctx, http.MethodPost, url, query, &results); err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
+type FlowNodeInstanceClient struct {
+	requester
+	config Config
+}
+
+func (c *FlowNodeInstanceClient) Search(ctx context.Context, query Query[FlowNodeInstanceFilter]) (*Results[FlowNodeInstance], error) {
+	if query.Filter.ProcessInstanceKey != nil {
+		trace.SpanFromContext(ctx).SetAttributes(tracing.ProcessInstanceKey(*query.Filter.ProcessInstanceKey))
+	}
+
+	var results Results[FlowNodeInstance]
+	url := c.config.OperateURL + "/v1/flownode-instances/search"
+	if err := c.do(ctx, http.MethodPost, url, query, &results); err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
+type IncidentClient struct {
+	requester
+	config Config
+}
+
+func (c *IncidentClient) Search(ctx context.Context, query Query[IncidentFilter]) (*Results[Incident], error) {
+	if query.Filter.ProcessInstanceKey != nil {
+		trace.SpanFromContext(ctx).SetAttributes(tracing.ProcessInstanceKey(*query.Filter.ProcessInstanceKey))
+	}
+
+	var results Results[Incident]
+	url := c.config.OperateURL + "/v1/incidents/search"
+	if err := c.do(ctx, http.MethodPost, url, query, &results); err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
+func (c *IncidentClient) Resolve(ctx context.Context, key int64) error {
+	url := fmt.Sprintf("%s/v2/incidents/%d/resolution", c.config.ZeebeURL, key)
+	return c.do(ctx, http.MethodPost, url, struct{}{}, nil)
+}
+
+type ProcessDefinitionClient struct {
+	requester
+	config Config
+}
+
+func (c *ProcessDefinitionClient) Get(ctx context.Context, key int64) (*ProcessDefinition, error) {
+	var definition ProcessDefinition
+	url := fmt.Sprintf("%s/v1/process-definitions/%d", c.config.OperateURL, key)
+	if err := c.do(ctx, http.MethodGet, url, nil, &definition); err != nil {
+		return nil, err
+	}
+	return &definition, nil
+}
+
+func (c *ProcessDefinitionClient) Search(ctx context.Context, query Query[ProcessDefinitionFilter]) (*Results[ProcessDefinition], error) {
+	var results Results[ProcessDefinition]
+	url := c.config.OperateURL + "/v1/process-definitions/search"
+	if err := c.do(ctx, http.MethodPost, url, query, &results); err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
+func (c *ProcessDefinitionClient) XML(ctx context.Context, key int64) (string, error) {
+	var xml string
+	url := fmt.Sprintf("%s/v1/process-definitions/%d/xml", c.config.OperateURL, key)
+	if err := c.do(ctx, http.MethodGet, url, nil, &xml); err != nil {
+		return "", err
+	}
+	return xml, nil
+}
+
+func (c *ProcessDefinitionClient) Delete(ctx context.Context, key int64) error {
+	url := fmt.Sprintf("%s/v2/resources/%d/deletion", c.config.ZeebeURL, key)
+	return c.do(ctx, http.MethodPost, url, struct{}{}, nil)
+}
+
+type VariableClient struct {
+	requester
+	config Config
+}
+
+func (c *VariableClient) Search(ctx context.Context, query Query[VariableFilter]) (*Results[Variable], error) {
+	var results Results[Variable]
+	url := c.config.OperateURL + "/v1/variables/search"
+	if err := c.do(ctx, http.MethodPost, url, query, &results); err != nil {
+		return nil, err
+	}
+	return &results, nil
+}
+
+// StatisticsClient reads the dashboard statistics of the internal Operate
+// api, which has no public equivalent.
+type StatisticsClient struct {
+	requester
+	config Config
+}
+
+func (c *StatisticsClient) Core(ctx context.Context) (*CoreStatistics, error) {
+	var stats CoreStatistics
+	url := c.config.OperateURL + "/api/process-instances/core-statistics"
+	if err := c.do(ctx, http.MethodGet, url, nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (c *StatisticsClient) IncidentsByProcess(ctx context.Context) ([]ProcessStatistics, error) {
+	var stats []ProcessStatistics
+	url := c.config.OperateURL + "/api/incidents/byProcess"
+	if err := c.do(ctx, http.MethodGet, url, nil, &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
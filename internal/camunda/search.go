@@ -0,0 +1,136 @@
+package camunda
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+type Sort struct {
+	Field string `json:"field" validate:"required"`
+	Order string `json:"order,omitempty"`
+}
+
+// Query is the body of an Operate search.
+type Query[F any] struct {
+	Filter       F             `json:"filter"`
+	Size         int32         `json:"size,omitempty"`
+	Sort         []Sort        `json:"sort,omitempty"`
+	SearchAfter  []interface{} `json:"searchAfter,omitempty"`
+	SearchBefore []interface{} `json:"searchBefore,omitempty"`
+}
+
+func (q Query[F]) cursorAfter() []interface{}  { return q.SearchAfter }
+func (q Query[F]) cursorBefore() []interface{} { return q.SearchBefore }
+
+func (q Query[F]) after(values []interface{}) Query[F] {
+	q.SearchAfter, q.SearchBefore = values, nil
+	return q
+}
+
+func (q Query[F]) before(values []interface{}) Query[F] {
+	q.SearchAfter, q.SearchBefore = nil, values
+	return q
+}
+
+// Results is a page of a search. SortValues is the cursor of the next page:
+// pass it as SearchAfter to continue, or as SearchBefore to go back.
+type Results[T any] struct {
+	Items      []T           `json:"items"`
+	SortValues []interface{} `json:"sortValues"`
+	Total      int64         `json:"total"`
+}
+
+type pageable[Q any] interface {
+	after([]interface{}) Q
+	before([]interface{}) Q
+	cursorAfter() []interface{}
+	cursorBefore() []interface{}
+}
+
+// Pager walks a search page by page until a page comes back empty.
+//
+//	pager := camunda.PagesAfter(client.Incidents.Search, query)
+//	for pager.Next(ctx) {
+//		for _, incident := range pager.Items() { ... }
+//	}
+//	if err := pager.Err(); err != nil { ... }
+type Pager[T any] struct {
+	fetch  func(context.Context, []interface{}) (*Results[T], error)
+	cursor []interface{}
+	items  []T
+	err    error
+	done   bool
+}
+
+// PagesAfter pages forward from the SearchAfter of the query.
+func PagesAfter[Q pageable[Q], T any](search func(context.Context, Q) (*Results[T], error), query Q) *Pager[T] {
+	return &Pager[T]{
+		cursor: query.cursorAfter(),
+		fetch: func(ctx context.Context, cursor []interface{}) (*Results[T], error) {
+			return search(ctx, query.after(cursor))
+		},
+	}
+}
+
+// PagesBefore pages backward from the SearchBefore of the query.
+func PagesBefore[Q pageable[Q], T any](search func(context.Context, Q) (*Results[T], error), query Q) *Pager[T] {
+	return &Pager[T]{
+		cursor: query.cursorBefore(),
+		fetch: func(ctx context.Context, cursor []interface{}) (*Results[T], error) {
+			return search(ctx, query.before(cursor))
+		},
+	}
+}
+
+// Next fetches the next page and reports whether it has items.
+func (p *Pager[T]) Next(ctx context.Context) bool {
+	if p.done || p.err != nil {
+		return false
+	}
+
+	results, err := p.fetch(ctx, p.cursor)
+	if err != nil {
+		p.err = err
+		return false
+	}
+	if len(results.Items) == 0 || len(results.SortValues) == 0 {
+		p.done = true
+	}
+	if len(results.Items) == 0 {
+		p.items = nil
+		return false
+	}
+
+	p.items = results.Items
+	p.cursor = results.SortValues
+	return true
+}
+
+func (p *Pager[T]) Items() []T {
+	return p.items
+}
+
+func (p *Pager[T]) Err() error {
+	return p.err
+}
+
+// ParseSortValues reads the comma separated sort values of a query
+// parameter, keeping numbers as numbers so they match the sort of the field.
+func ParseSortValues(s string) []interface{} {
+	s = strings.Trim(strings.TrimSpace(s), "[]")
+	if s == "" {
+		return nil
+	}
+
+	values := []interface{}{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.Trim(strings.TrimSpace(part), `"`)
+		if n, err := strconv.ParseInt(part, 10, 64); err == nil {
+			values = append(values, n)
+			continue
+		}
+		values = append(values, part)
+	}
+	return values
+}
@@ -0,0 +1,177 @@
+package camunda
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Assignment is the part of a task that decides who may work on it. Tasklist
+// and the v2 user task api share its field names.
+type Assignment struct {
+	Assignee        *string  `json:"assignee"`
+	CandidateGroups []string `json:"candidateGroups"`
+	CandidateUsers  []string `json:"candidateUsers"`
+}
+
+func (a Assignment) TaskAssignment() Assignment {
+	return a
+}
+
+type Task struct {
+	ID                   string   `json:"id"`
+	Name                 string   `json:"name"`
+	TaskDefinitionId     string   `json:"taskDefinitionId"`
+	ProcessName          string   `json:"processName"`
+	CreationDate         string   `json:"creationDate"`
+	CompletionDate       *string  `json:"completionDate"`
+	TaskState            string   `json:"taskState"`
+	FormKey              *string  `json:"formKey"`
+	FormId               *string  `json:"formId"`
+	ProcessDefinitionKey string   `json:"processDefinitionKey"`
+	ProcessInstanceKey   string   `json:"processInstanceKey"`
+	TenantId             string   `json:"tenantId,omitempty"`
+	SortValues           []string `json:"sortValues,omitempty"`
+	Assignment
+}
+
+// TaskSearch is the body of a Tasklist search. Unlike Operate it has no
+// filter object and pages with string sort values.
+type TaskSearch struct {
+	State                string   `json:"state,omitempty"`
+	Assigned             *bool    `json:"assigned,omitempty"`
+	Assignee             string   `json:"assignee,omitempty"`
+	Assignees            []string `json:"assignees,omitempty"`
+	TaskDefinitionId     string   `json:"taskDefinitionId,omitempty"`
+	CandidateGroup       string   `json:"candidateGroup,omitempty"`
+	CandidateGroups      []string `json:"candidateGroups,omitempty"`
+	CandidateUser        string   `json:"candidateUser,omitempty"`
+	CandidateUsers       []string `json:"candidateUsers,omitempty"`
+	ProcessDefinitionKey string   `json:"processDefinitionKey,omitempty"`
+	ProcessInstanceKey   string   `json:"processInstanceKey,omitempty"`
+	PageSize             int32    `json:"pageSize,omitempty"`
+	Sort                 []Sort   `json:"sort,omitempty"`
+	SearchAfter          []string `json:"searchAfter,omitempty"`
+	SearchAfterOrEqual   []string `json:"searchAfterOrEqual,omitempty"`
+	SearchBefore         []string `json:"searchBefore,omitempty"`
+	SearchBeforeOrEqual  []string `json:"searchBeforeOrEqual,omitempty"`
+}
+
+func (s TaskSearch) cursorAfter() []interface{}  { return toValues(s.SearchAfter) }
+func (s TaskSearch) cursorBefore() []interface{} { return toValues(s.SearchBefore) }
+
+func (s TaskSearch) after(values []interface{}) TaskSearch {
+	s.SearchAfter, s.SearchBefore = toStrings(values), nil
+	return s
+}
+
+func (s TaskSearch) before(values []interface{}) TaskSearch {
+	s.SearchAfter, s.SearchBefore = nil, toStrings(values)
+	return s
+}
+
+type AssignTaskRequest struct {
+	Assignee                string `json:"assignee"`
+	AllowOverrideAssignment bool   `json:"allowOverrideAssignment"`
+}
+
+// TaskVariable is a variable of a completed task, Value is json encoded.
+type TaskVariable struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type TaskClient struct {
+	requester
+	config Config
+}
+
+// Search returns a page of tasks. Tasklist answers with a bare list, the
+// sort values of the page are taken from its last task, or its first when
+// searching before.
+func (c *TaskClient) Search(ctx context.Context, search TaskSearch) (*Results[Task], error) {
+	var tasks []Task
+	if err := c.do(ctx, http.MethodPost, c.config.TasklistURL+"/v1/tasks/search", search, &tasks); err != nil {
+		return nil, err
+	}
+
+	results := &Results[Task]{Items: tasks, Total: int64(len(tasks))}
+	if len(tasks) > 0 {
+		edge := tasks[len(tasks)-1]
+		if search.SearchBefore != nil {
+			edge = tasks[0]
+		}
+		results.SortValues = toValues(edge.SortValues)
+	}
+	if results.Items == nil {
+		results.Items = []Task{}
+	}
+
+	return results, nil
+}
+
+func (c *TaskClient) Get(ctx context.Context, id string) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodGet, c.taskURL(id, ""), nil, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (c *TaskClient) Assign(ctx context.Context, id string, request AssignTaskRequest) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodPatch, c.taskURL(id, "/assign"), request, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (c *TaskClient) Unassign(ctx context.Context, id string) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodPatch, c.taskURL(id, "/unassign"), nil, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (c *TaskClient) Complete(ctx context.Context, id string, variables []TaskVariable) (*Task, error) {
+	if variables == nil {
+		variables = []TaskVariable{}
+	}
+
+	var task Task
+	body := struct {
+		Variables []TaskVariable `json:"variables"`
+	}{variables}
+	if err := c.do(ctx, http.MethodPatch, c.taskURL(id, "/complete"), body, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (c *TaskClient) taskURL(id, action string) string {
+	return fmt.Sprintf("%s/v1/tasks/%s%s", c.config.TasklistURL, url.PathEscape(id), action)
+}
+
+func toValues(values []string) []interface{} {
+	if values == nil {
+		return nil
+	}
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
+
+func toStrings(values []interface{}) []string {
+	if values == nil {
+		return nil
+	}
+	result := make([]string, len(values))
+	for i, v := range values {
+		result[i] = fmt.Sprint(v)
+	}
+	return result
+}
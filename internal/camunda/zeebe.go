@@ -0,0 +1,166 @@
+package camunda
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/damarteplok/social/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type StartInstruction struct {
+	ElementID *string `json:"elementId,omitempty"`
+}
+
+type CreateProcessInstanceRequest struct {
+	ProcessDefinitionKey int64                  `json:"processDefinitionKey" validate:"required"`
+	Variables            map[string]interface{} `json:"variables,omitempty"`
+	TenantID             *string                `json:"tenantId,omitempty"`
+	OperationReference   *int64                 `json:"operationReference,omitempty"`
+	StartInstructions    []StartInstruction     `json:"startInstructions,omitempty"`
+	AwaitCompletion      *bool                  `json:"awaitCompletion,omitempty"`
+	FetchVariables       []string               `json:"fetchVariables,omitempty"`
+	RequestTimeout       *int64                 `json:"requestTimeout,omitempty"`
+}
+
+type CreateProcessInstanceResponse struct {
+	ProcessDefinitionKey     int64                  `json:"processDefinitionKey"`
+	ProcessDefinitionId      string                 `json:"processDefinitionId"`
+	ProcessDefinitionVersion int32                  `json:"processDefinitionVersion"`
+	ProcessInstanceKey       int64                  `json:"processInstanceKey"`
+	TenantId                 string                 `json:"tenantId"`
+	Variables                map[string]interface{} `json:"variables"`
+}
+
+type UserTaskVariable struct {
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+type UserTaskFilter struct {
+	Key                  int64              `json:"key,omitempty"`
+	State                string             `json:"state,omitempty"`
+	Assignee             string             `json:"assignee,omitempty"`
+	ElementId            string             `json:"elementId,omitempty"`
+	CandidateGroup       string             `json:"candidateGroup,omitempty"`
+	CandidateUser        string             `json:"candidateUser,omitempty"`
+	ProcessDefinitionKey int64              `json:"processDefinitionKey,omitempty"`
+	ProcessInstanceKey   int64              `json:"processInstanceKey,omitempty"`
+	TenantIds            string             `json:"tenantIds,omitempty"`
+	ProcessDefinitionId  string             `json:"processDefinitionId,omitempty"`
+	Variables            []UserTaskVariable `json:"variables,omitempty"`
+}
+
+type UserTaskPage struct {
+	From         int64         `json:"from,omitempty"`
+	Limit        int64         `json:"limit,omitempty"`
+	SearchAfter  []interface{} `json:"searchAfter,omitempty"`
+	SearchBefore []interface{} `json:"searchBefore,omitempty"`
+}
+
+// UserTaskQuery is the body of a v2 user task search, which has to be
+// enabled in the camunda platform config first.
+type UserTaskQuery struct {
+	Sort   []Sort         `json:"sort,omitempty" validate:"dive"`
+	Filter UserTaskFilter `json:"filter"`
+	Page   UserTaskPage   `json:"page"`
+}
+
+type UserTask struct {
+	Key                  int64   `json:"key"`
+	State                string  `json:"state"`
+	ElementId            string  `json:"elementId"`
+	ElementInstanceKey   int64   `json:"elementInstanceKey"`
+	BpmnProcessId        string  `json:"bpmnProcessId"`
+	ProcessDefinitionKey int64   `json:"processDefinitionKey"`
+	ProcessInstanceKey   int64   `json:"processInstanceKey"`
+	FormKey              *int64  `json:"formKey,omitempty"`
+	CreationDate         string  `json:"creationDate"`
+	CompletionDate       *string `json:"completionDate,omitempty"`
+	DueDate              *string `json:"dueDate,omitempty"`
+	FollowUpDate         *string `json:"followUpDate,omitempty"`
+	TenantIds            string  `json:"tenantIds"`
+	Assignment
+}
+
+type UserTaskPageResponse struct {
+	TotalItems      int64         `json:"totalItems"`
+	FirstSortValues []interface{} `json:"firstSortValues"`
+	LastSortValues  []interface{} `json:"lastSortValues"`
+}
+
+type UserTaskResults struct {
+	Items []UserTask           `json:"items"`
+	Page  UserTaskPageResponse `json:"page"`
+}
+
+// ProcessInstanceKeysQuery is the body of a v2 process instance search, unlike
+// operate v1 it filters a set of keys with one request.
+type ProcessInstanceKeysQuery struct {
+	Filter struct {
+		ProcessInstanceKeys []int64 `json:"processInstanceKeys"`
+	} `json:"filter"`
+	Page UserTaskPage `json:"page"`
+}
+
+type processInstanceResults struct {
+	Items []ProcessInstance    `json:"items"`
+	Page  UserTaskPageResponse `json:"page"`
+}
+
+// SearchKeys returns the instances of keys that are exported already, an
+// instance operate does not know yet is missing from the result.
+func (c *ProcessInstanceClient) SearchKeys(ctx context.Context, keys []int64) ([]ProcessInstance, error) {
+	if len(keys) == 0 {
+		return []ProcessInstance{}, nil
+	}
+
+	var query ProcessInstanceKeysQuery
+	query.Filter.ProcessInstanceKeys = keys
+	query.Page.Limit = int64(len(keys))
+
+	var results processInstanceResults
+	if err := c.do(ctx, http.MethodPost, c.config.ZeebeURL+"/v2/process-instances/search", query, &results); err != nil {
+		return nil, err
+	}
+	if results.Items == nil {
+		results.Items = []ProcessInstance{}
+	}
+	return results.Items, nil
+}
+
+func (c *ProcessInstanceClient) Create(ctx context.Context, request CreateProcessInstanceRequest) (*CreateProcessInstanceResponse, error) {
+	var created CreateProcessInstanceResponse
+	if err := c.do(ctx, http.MethodPost, c.config.ZeebeURL+"/v2/process-instances", request, &created); err != nil {
+		return nil, err
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		tracing.ProcessDefinitionKey(created.ProcessDefinitionKey),
+		tracing.ProcessInstanceKey(created.ProcessInstanceKey),
+	)
+	return &created, nil
+}
+
+func (c *ProcessInstanceClient) Cancel(ctx context.Context, key int64) error {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.ProcessInstanceKey(key))
+
+	url := fmt.Sprintf("%s/v2/process-instances/%d/cancellation", c.config.ZeebeURL, key)
+	return c.do(ctx, http.MethodPost, url, struct{}{}, nil)
+}
+
+type UserTaskClient struct {
+	requester
+	config Config
+}
+
+func (c *UserTaskClient) Search(ctx context.Context, query UserTaskQuery) (*UserTaskResults, error) {
+	var results UserTaskResults
+	if err := c.do(ctx, http.MethodPost, c.config.ZeebeURL+"/v2/user-tasks/search", query, &results); err != nil {
+		return nil, err
+	}
+	if results.Items == nil {
+		results.Items = []UserTask{}
+	}
+	return &results, nil
+}
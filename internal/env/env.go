@@ -22,15 +22,51 @@ type Config struct {
 	ZeebeClientID          string
 	ZeebeClientSecret      string
 	ZeebeAuthServerUrl     string
+	ZeebeAudience          string
+	OperateAudience        string
+	TasklistAudience       string
+	OptimizeAudience       string
+	ZeebeTokenTimeout      time.Duration
+	ZeebeTokenRefresh      time.Duration
+	CamundaRetryAttempts   int
+	CamundaRetryBackoff    time.Duration
+	CamundaRetryMaxBackoff time.Duration
+	CamundaBreakerFailures int
+	CamundaBreakerTimeout  time.Duration
+	ZeebeTimeout           time.Duration
+	OperateTimeout         time.Duration
+	TasklistTimeout        time.Duration
+	OptimizeTimeout        time.Duration
 	FrontendURL            string
 	MailerFromEmail        string
 	MailerApiKey           string
 	MailerExp              time.Duration
+	PasswordResetExp       time.Duration
+	ActivationResendLimit  int
+	ActivationResendWindow time.Duration
 	AdminUser              string
 	AdminPass              string
 	JwtSecret              string
+	JwtSigningKeyFile      string
+	JwtVerifyKeyFiles      []string
 	JwtIss                 string
+	TwoFactorKey           string
+	TwoFactorChallengeExp  time.Duration
+	TwoFactorMaxAttempts   int
+	TwoFactorAttemptWindow time.Duration
+	OidcEnabled            bool
+	OidcIssuerURL          string
+	OidcClientID           string
+	OidcClientSecret       string
+	OidcRedirectURL        string
+	OidcAudiences          []string
+	OidcGroupsClaim        string
+	OidcRoleMapping        map[string]string
+	OidcMFAAcrValues       []string
+	OidcMFAAmrValues       []string
 	JwtExp                 time.Duration
+	JwtAud                 string
+	JwtRefreshExp          time.Duration
 	RedisAddr              string
 	RedisPass              string
 	RedisDB                int
@@ -52,6 +88,32 @@ type Config struct {
 	CamundaTasklistBaseUrl string
 	CamundaOperateBaseUrl  string
 	CamundaOptimizeBaseUrl string
+	ZeebeWorkerEnabled     bool
+	ZeebeWorkerConcurrency int
+	ZeebeWorkerMaxActive   int
+	ZeebeWorkerTimeout     time.Duration
+	ZeebeWorkerInterval    time.Duration
+	ZeebeWorkerBackoff     time.Duration
+	ReconcilerEnabled      bool
+	ReconcilerInterval     time.Duration
+	ReconcilerBatchSize    int
+	OutboxEnabled          bool
+	OutboxInterval         time.Duration
+	OutboxBatchSize        int
+	OutboxRetryBackoff     time.Duration
+	OutboxMaxAttempts      int
+	OutboxExportLag        time.Duration
+	CleanupEnabled         bool
+	CleanupInterval        time.Duration
+	InactiveUserGrace      time.Duration
+	HealthCheckTimeout     time.Duration
+	TracingEnabled         bool
+	TracingEndpoint        string
+	TracingInsecure        bool
+	TracingServiceName     string
+	TracingSampleRatio     float64
+	LogLevel               string
+	LogFormat              string
 }
 
 var Envs = initConfig()
@@ -74,11 +136,32 @@ func initConfig() Config {
 		MailerFromEmail:        GetString("MAILIER_FROM_EMAIL", "damar@test.com"),
 		MailerApiKey:           GetString("MAILIER_API_KEY", ""),
 		MailerExp:              GetDay("MAILER_EXP", 3),
+		PasswordResetExp:       GetTimeSecond("PASSWORD_RESET_EXP", 3600),
+		ActivationResendLimit:  GetInt("ACTIVATION_RESEND_LIMIT", 3),
+		ActivationResendWindow: GetTimeSecond("ACTIVATION_RESEND_WINDOW", 3600),
 		AdminUser:              GetString("ADMIN_USER", "admin"),
 		AdminPass:              GetString("ADMIN_PASS", "admin"),
 		JwtSecret:              GetString("JWT_SECRET", "admin"),
+		JwtSigningKeyFile:      GetString("JWT_SIGNING_KEY_FILE", ""),
+		JwtVerifyKeyFiles:      GetStringSlice("JWT_VERIFY_KEY_FILES", ""),
 		JwtIss:                 GetString("JWT_ISS", "damar"),
+		TwoFactorKey:           GetString("TWO_FACTOR_KEY", ""),
+		TwoFactorChallengeExp:  GetTimeSecond("TWO_FACTOR_CHALLENGE_EXP", 300),
+		TwoFactorMaxAttempts:   GetInt("TWO_FACTOR_MAX_ATTEMPTS", 5),
+		TwoFactorAttemptWindow: GetTimeSecond("TWO_FACTOR_ATTEMPT_WINDOW", 900),
+		OidcEnabled:            GetBool("OIDC_ENABLED", false),
+		OidcIssuerURL:          GetString("OIDC_ISSUER_URL", "http://localhost:18080/auth/realms/camunda-platform"),
+		OidcClientID:           GetString("OIDC_CLIENT_ID", "social"),
+		OidcClientSecret:       GetString("OIDC_CLIENT_SECRET", ""),
+		OidcRedirectURL:        GetString("OIDC_REDIRECT_URL", "http://localhost:5173/oidc/callback"),
+		OidcAudiences:          GetStringSlice("OIDC_AUDIENCES", "social-api"),
+		OidcGroupsClaim:        GetString("OIDC_GROUPS_CLAIM", "groups"),
+		OidcRoleMapping:        GetStringMap("OIDC_ROLE_MAPPING", "admins:admin,moderators:moderator"),
+		OidcMFAAcrValues:       GetStringSlice("OIDC_MFA_ACR_VALUES", "2"),
+		OidcMFAAmrValues:       GetStringSlice("OIDC_MFA_AMR_VALUES", "mfa,otp,hwk,swk"),
 		JwtExp:                 GetDay("JWT_EXP", 3),
+		JwtAud:                 GetString("JWT_AUD", "damar"),
+		JwtRefreshExp:          GetDay("JWT_REFRESH_EXP", 30),
 		RedisAddr:              GetString("REDIS_ADDR", "localhost:6379"),
 		RedisPass:              GetString("REDIS_PASS", ""),
 		RedisDB:                GetInt("REDIS_DB", 0),
@@ -88,6 +171,21 @@ func initConfig() Config {
 		ZeebeClientID:          GetString("ZEEBE_CLIENT_ID", ""),
 		ZeebeClientSecret:      GetString("ZEEBE_CLIENT_SECRET", ""),
 		ZeebeAuthServerUrl:     GetString("ZEEBE_AUTH_SERVER_URL", ""),
+		ZeebeAudience:          GetString("ZEEBE_TOKEN_AUDIENCE", "zeebe-api"),
+		OperateAudience:        GetString("CAMUNDA_OPERATE_AUDIENCE", "operate-api"),
+		TasklistAudience:       GetString("CAMUNDA_TASKLIST_AUDIENCE", "tasklist-api"),
+		OptimizeAudience:       GetString("CAMUNDA_OPTIMIZE_AUDIENCE", "optimize-api"),
+		ZeebeTokenTimeout:      GetTimeSecond("ZEEBE_TOKEN_TIMEOUT", 10),
+		ZeebeTokenRefresh:      GetTimeSecond("ZEEBE_TOKEN_REFRESH_AHEAD", 30),
+		CamundaRetryAttempts:   GetInt("CAMUNDA_RETRY_MAX_ATTEMPTS", 3),
+		CamundaRetryBackoff:    GetTimeMillisecond("CAMUNDA_RETRY_BACKOFF_MS", 200),
+		CamundaRetryMaxBackoff: GetTimeMillisecond("CAMUNDA_RETRY_MAX_BACKOFF_MS", 2000),
+		CamundaBreakerFailures: GetInt("CAMUNDA_BREAKER_FAILURES", 5),
+		CamundaBreakerTimeout:  GetTimeSecond("CAMUNDA_BREAKER_OPEN_TIMEOUT", 30),
+		ZeebeTimeout:           GetTimeSecond("ZEEBE_REST_TIMEOUT", 30),
+		OperateTimeout:         GetTimeSecond("CAMUNDA_OPERATE_TIMEOUT", 30),
+		TasklistTimeout:        GetTimeSecond("CAMUNDA_TASKLIST_TIMEOUT", 30),
+		OptimizeTimeout:        GetTimeSecond("CAMUNDA_OPTIMIZE_TIMEOUT", 60),
 		RequestPerTimeFrame:    GetInt("REQUEST_PER_TIME_FRAME", 60),
 		RateLimiterEnabled:     GetBool("RATE_LIMITER_ENABLED", true),
 		RateLimiterTimeFrame:   GetTimeSecond("RATE_LIMITER_TIME_FRAME", 5),
@@ -104,6 +202,32 @@ func initConfig() Config {
 		CamundaTasklistBaseUrl: GetString("CAMUNDA_TASKLIST_BASE_URL", ""),
 		CamundaOperateBaseUrl:  GetString("CAMUNDA_OPERATE_BASE_URL", ""),
 		CamundaOptimizeBaseUrl: GetString("CAMUNDA_OPTIMIZE_BASE_URL", ""),
+		ZeebeWorkerEnabled:     GetBool("ZEEBE_WORKER_ENABLED", true),
+		ZeebeWorkerConcurrency: GetInt("ZEEBE_WORKER_CONCURRENCY", 4),
+		ZeebeWorkerMaxActive:   GetInt("ZEEBE_WORKER_MAX_JOBS_ACTIVE", 32),
+		ZeebeWorkerTimeout:     GetTimeSecond("ZEEBE_WORKER_TIMEOUT", 300),
+		ZeebeWorkerInterval:    GetTimeSecond("ZEEBE_WORKER_POLL_INTERVAL", 1),
+		ZeebeWorkerBackoff:     GetTimeSecond("ZEEBE_WORKER_RETRY_BACKOFF", 10),
+		ReconcilerEnabled:      GetBool("RECONCILER_ENABLED", true),
+		ReconcilerInterval:     GetTimeSecond("RECONCILER_INTERVAL", 30),
+		ReconcilerBatchSize:    GetInt("RECONCILER_BATCH_SIZE", 100),
+		OutboxEnabled:          GetBool("OUTBOX_ENABLED", true),
+		OutboxInterval:         GetTimeSecond("OUTBOX_INTERVAL", 5),
+		OutboxBatchSize:        GetInt("OUTBOX_BATCH_SIZE", 100),
+		OutboxRetryBackoff:     GetTimeSecond("OUTBOX_RETRY_BACKOFF", 5),
+		OutboxMaxAttempts:      GetInt("OUTBOX_MAX_ATTEMPTS", 10),
+		OutboxExportLag:        GetTimeSecond("OUTBOX_EXPORT_LAG", 60),
+		CleanupEnabled:         GetBool("CLEANUP_ENABLED", true),
+		CleanupInterval:        GetTimeSecond("CLEANUP_INTERVAL", 3600),
+		InactiveUserGrace:      GetDay("INACTIVE_USER_GRACE", 0),
+		HealthCheckTimeout:     GetTimeSecond("HEALTH_CHECK_TIMEOUT", 2),
+		TracingEnabled:         GetBool("OTEL_ENABLED", false),
+		TracingEndpoint:        GetString("OTEL_EXPORTER_OTLP_ENDPOINT", "localhost:4318"),
+		TracingInsecure:        GetBool("OTEL_EXPORTER_OTLP_INSECURE", true),
+		TracingServiceName:     GetString("OTEL_SERVICE_NAME", "social-api"),
+		TracingSampleRatio:     GetFloat("OTEL_SAMPLE_RATIO", 1),
+		LogLevel:               GetString("LOG_LEVEL", "info"),
+		LogFormat:              GetString("LOG_FORMAT", "json"),
 	}
 }
 
@@ -121,6 +245,19 @@ func GetStringSlice(key, fallback string) []string {
 	return strings.Split(val, ",")
 }
 
+// GetStringMap reads pairs like "a:b,c:d".
+func GetStringMap(key, fallback string) map[string]string {
+	m := map[string]string{}
+	for _, pair := range GetStringSlice(key, fallback) {
+		k, v, ok := strings.Cut(pair, ":")
+		if !ok || k == "" {
+			continue
+		}
+		m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return m
+}
+
 func GetInt(key string, fallback int) int {
 	val, ok := os.LookupEnv(key)
 	if !ok {
@@ -135,6 +272,20 @@ func GetInt(key string, fallback int) int {
 	return valAsInt
 }
 
+func GetFloat(key string, fallback float64) float64 {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	valAsFloat, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return fallback
+	}
+
+	return valAsFloat
+}
+
 func GetBool(key string, fallback bool) bool {
 	val, ok := os.LookupEnv(key)
 	if !ok {
@@ -163,6 +314,20 @@ func GetTimeSecond(key string, fallback int) time.Duration {
 	return time.Second * time.Duration(valAsInt)
 }
 
+func GetTimeMillisecond(key string, fallback int) time.Duration {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return time.Millisecond * time.Duration(fallback)
+	}
+
+	valAsInt, err := strconv.Atoi(val)
+	if err != nil {
+		return time.Millisecond * time.Duration(fallback)
+	}
+
+	return time.Millisecond * time.Duration(valAsInt)
+}
+
 func GetDay(key string, fallback int) time.Duration {
 	val, ok := os.LookupEnv(key)
 	if !ok {
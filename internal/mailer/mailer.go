@@ -3,9 +3,12 @@ package mailer
 import "embed"
 
 const (
-	FromName            = "damarmunda"
-	maxRetires          = 3
-	UserWelcomeTemplate = "user_invitation.tmpl"
+	FromName              = "damarmunda"
+	maxRetires            = 3
+	UserWelcomeTemplate   = "user_invitation.tmpl"
+	PasswordResetTemplate = "password_reset.tmpl"
+	EmailChangeTemplate   = "email_change.tmpl"
+	TwoFactorTemplate     = "two_factor_enroll.tmpl"
 )
 
 //go:embed "templates"
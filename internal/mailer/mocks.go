@@ -0,0 +1,36 @@
+package mailer
+
+import "sync"
+
+// MockMailer records the emails instead of sending them.
+type MockMailer struct {
+	mu    sync.Mutex
+	Sends []MockSend
+}
+
+type MockSend struct {
+	TemplateFile string
+	Email        string
+	Data         any
+}
+
+func (m *MockMailer) Send(templateFile, username, email string, data any, isSandbox bool) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Sends = append(m.Sends, MockSend{TemplateFile: templateFile, Email: email, Data: data})
+	return 200, nil
+}
+
+// Last returns the last email sent with the template.
+func (m *MockMailer) Last(templateFile string) (MockSend, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.Sends) - 1; i >= 0; i-- {
+		if m.Sends[i].TemplateFile == templateFile {
+			return m.Sends[i], true
+		}
+	}
+	return MockSend{}, false
+}
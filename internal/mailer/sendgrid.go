@@ -6,6 +6,7 @@ import (
 	"text/template"
 	"time"
 
+	"github.com/damarteplok/social/internal/metrics"
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
 )
@@ -43,7 +44,7 @@ func (m *SendGridMailer) Send(templateFile, username, email string, data any, is
 	}
 
 	body := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(body, "subject", data)
+	err = tmpl.ExecuteTemplate(body, "body", data)
 	if err != nil {
 		return -1, err
 	}
@@ -63,8 +64,16 @@ func (m *SendGridMailer) Send(templateFile, username, email string, data any, is
 			time.Sleep(time.Second * time.Duration(i+1))
 			continue
 		}
+
+		outcome := "sent"
+		if response.StatusCode >= 400 {
+			outcome = "rejected"
+		}
+		metrics.MailerSends.WithLabelValues(templateFile, outcome).Inc()
+
 		return response.StatusCode, nil
 	}
 
+	metrics.MailerSends.WithLabelValues(templateFile, "failed").Inc()
 	return -1, fmt.Errorf("failed to send email after %d attempts, err: %v", maxRetires, retryErr)
 }
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "social"
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by chi route pattern and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by chi route pattern and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Redis cache lookups by store and result (hit, miss or error).",
+	}, []string{"store", "result"})
+
+	RateLimitRejections = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limiter_rejections_total",
+		Help:      "Requests rejected by the rate limiter.",
+	})
+
+	MailerSends = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mailer_sends_total",
+		Help:      "Emails by template and outcome (sent, rejected or failed).",
+	}, []string{"template", "outcome"})
+
+	ZeebeCommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "zeebe_command_duration_seconds",
+		Help:      "Latency of zeebe gateway commands by command and grpc code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"command", "code"})
+
+	CamundaRESTDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "camunda_rest_request_duration_seconds",
+		Help:      "Latency of the calls to the camunda rest apis by component, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"component", "method", "status"})
+
+	info = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "build_info",
+		Help:      "Version of the running api, always 1.",
+	}, []string{"version"})
+)
+
+// Register publishes the version and the stats of the database pool.
+func Register(version string, db *sql.DB) error {
+	info.WithLabelValues(version).Set(1)
+	return prometheus.Register(collectors.NewDBStatsCollector(db, "postgres"))
+}
+
+// Handler serves the metrics in the prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveHTTP records a served request, route is the chi route pattern so
+// path parameters do not blow up the label values.
+func ObserveHTTP(method, route string, status int, start time.Time) {
+	if route == "" {
+		route = "unmatched"
+	}
+	labels := []string{method, route, strconv.Itoa(status)}
+	HTTPRequests.WithLabelValues(labels...).Inc()
+	HTTPDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+}
@@ -3,6 +3,7 @@ package minioupload
 import (
 	"context"
 	"errors"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -12,12 +13,22 @@ import (
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/minio/minio-go/v7/pkg/tags"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 func NewMinioClient(endpoint, accessKeyID, secretAccessKey string, useSSL bool) (MinioApi, error) {
+	transport, err := minio.DefaultTransport(useSSL)
+	if err != nil {
+		return nil, err
+	}
+
+	// every s3 call gets a client span under the span of its context
 	client, err := minio.New(endpoint, &minio.Options{
 		Creds:  credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
 		Secure: useSSL,
+		Transport: otelhttp.NewTransport(transport, otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			return "minio " + r.Method
+		})),
 	})
 	if err != nil {
 		return nil, err
@@ -0,0 +1,110 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/damarteplok/social/internal/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const fakeKid = "fake-key"
+
+// FakeProvider is a keycloak realm for tests, it serves the discovery
+// document, the JWKS and the token endpoint. Issuer is set to the url the
+// fake is served at, e.g. by httptest.NewServer.
+type FakeProvider struct {
+	Issuer string
+
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]jwt.MapClaims
+}
+
+func NewFakeProvider() *FakeProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	return &FakeProvider{
+		key:   key,
+		codes: map[string]jwt.MapClaims{},
+	}
+}
+
+// Token signs the claims, the issuer and an expiry are added when missing.
+func (f *FakeProvider) Token(claims jwt.MapClaims) string {
+	signed := jwt.MapClaims{
+		"iss": f.Issuer,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		signed[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, signed)
+	token.Header["kid"] = fakeKid
+	s, err := token.SignedString(f.key)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// AddCode makes the token endpoint answer code with an id token of claims,
+// a code is used once.
+func (f *FakeProvider) AddCode(code string, claims jwt.MapClaims) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.codes[code] = claims
+}
+
+func (f *FakeProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeFakeJSON(w, http.StatusOK, discovery{
+			Issuer:                f.Issuer,
+			AuthorizationEndpoint: f.Issuer + "/protocol/openid-connect/auth",
+			TokenEndpoint:         f.Issuer + "/protocol/openid-connect/token",
+			JWKSURI:               f.Issuer + "/protocol/openid-connect/certs",
+		})
+	case "/protocol/openid-connect/certs":
+		jwk, err := auth.NewJSONWebKey(&f.key.PublicKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		jwk.Kid, jwk.Use, jwk.Alg = fakeKid, "sig", jwt.SigningMethodRS256.Name
+		writeFakeJSON(w, http.StatusOK, auth.JSONWebKeySet{Keys: []auth.JSONWebKey{jwk}})
+	case "/protocol/openid-connect/token":
+		f.mu.Lock()
+		claims, ok := f.codes[r.PostFormValue("code")]
+		delete(f.codes, r.PostFormValue("code"))
+		f.mu.Unlock()
+
+		if !ok {
+			writeFakeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		writeFakeJSON(w, http.StatusOK, Tokens{
+			AccessToken: f.Token(claims),
+			IDToken:     f.Token(claims),
+			ExpiresIn:   3600,
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeFakeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
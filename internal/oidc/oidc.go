@@ -0,0 +1,339 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/damarteplok/social/internal/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+var (
+	ErrInvalidToken = errors.New("oidc token is not valid")
+	ErrUnknownKey   = errors.New("oidc signing key is unknown")
+)
+
+// keysRefreshInterval bounds how often the keys are fetched again for an
+// unknown kid, a forged kid must not hammer the provider.
+const keysRefreshInterval = time.Minute
+
+type Config struct {
+	Enabled bool
+	// IssuerURL is the url of the realm, e.g.
+	// http://localhost:18080/auth/realms/camunda-platform
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Audiences a token may be issued for besides the client, e.g. the
+	// tasklist-api of camunda identity
+	Audiences []string
+	// GroupsClaim holds the groups of the user, keycloak adds it with a
+	// group membership mapper
+	GroupsClaim string
+	// RoleMapping maps the keycloak groups to the names of store.Role
+	RoleMapping map[string]string
+	// MFAAcrValues are the acr values of a login with a second factor, e.g.
+	// the level of authentication "2" of a keycloak step-up flow
+	MFAAcrValues []string
+	// MFAAmrValues are the amr methods of a second factor, e.g. otp
+	MFAAmrValues []string
+}
+
+// Claims are the claims of a token of the provider.
+type Claims struct {
+	jwt.MapClaims
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	Groups        []string
+}
+
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	IDToken      string `json:"id_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider logs users in with the authorization code flow and verifies the
+// RS256 tokens of the provider with its JWKS. The discovery document is
+// fetched on first use.
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu          sync.Mutex
+	discovery   *discovery
+	keys        map[string]any
+	keysFetched time.Time
+}
+
+func NewProvider(cfg Config) *Provider {
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	cfg.IssuerURL = strings.TrimSuffix(cfg.IssuerURL, "/")
+
+	return &Provider{
+		config: cfg,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: otelhttp.NewTransport(http.DefaultTransport, otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+				return "oidc " + r.Method + " " + r.URL.Path
+			})),
+		},
+	}
+}
+
+func (p *Provider) Issuer() string {
+	return p.config.IssuerURL
+}
+
+// AuthCodeURL is the url of the login page of the provider.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce string) (string, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.config.ClientID)
+	params.Set("redirect_uri", p.config.RedirectURL)
+	params.Set("scope", "openid profile email")
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+
+	return d.AuthorizationEndpoint + "?" + params.Encode(), nil
+}
+
+// Exchange trades an authorization code for the tokens of the user.
+func (p *Provider) Exchange(ctx context.Context, code string) (*Tokens, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("client_secret", p.config.ClientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	// an invalid or used code is answered with 400 invalid_grant
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("%w: code exchange failed: %s", ErrInvalidToken, body)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc code exchange failed with status %d: %s", resp.StatusCode, body)
+	}
+
+	tokens := &Tokens{}
+	if err := json.Unmarshal(body, tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Verify checks the signature, the issuer, the expiry and the audience of a
+// token. An access token of the client or of one of Audiences is accepted,
+// like an id token.
+func (p *Provider) Verify(ctx context.Context, token string) (*Claims, error) {
+	mapClaims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, mapClaims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.getKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Name}),
+		jwt.WithIssuer(p.config.IssuerURL),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		if errors.Is(err, ErrUnknownKey) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	if !p.validAudience(mapClaims) {
+		return nil, fmt.Errorf("%w: audience not accepted", ErrInvalidToken)
+	}
+
+	claims := &Claims{MapClaims: mapClaims}
+	claims.Subject, _ = mapClaims["sub"].(string)
+	claims.Email, _ = mapClaims["email"].(string)
+	claims.EmailVerified, _ = mapClaims["email_verified"].(bool)
+	claims.Username, _ = mapClaims["preferred_username"].(string)
+	if groups, ok := mapClaims[p.config.GroupsClaim].([]any); ok {
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				claims.Groups = append(claims.Groups, name)
+			}
+		}
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// RoleNames returns the names of the roles the groups of the user map to,
+// keycloak prefixes the group paths with a slash.
+func (p *Provider) RoleNames(claims *Claims) []string {
+	var roles []string
+	for _, group := range claims.Groups {
+		role, ok := p.config.RoleMapping[strings.TrimPrefix(group, "/")]
+		if ok && !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// MultiFactor tells if the user logged in with a second factor, by the acr
+// or by one of the amr methods of the token.
+func (p *Provider) MultiFactor(claims *Claims) bool {
+	if acr, _ := claims.MapClaims["acr"].(string); acr != "" && slices.Contains(p.config.MFAAcrValues, acr) {
+		return true
+	}
+
+	methods, _ := claims.MapClaims["amr"].([]any)
+	for _, method := range methods {
+		if name, ok := method.(string); ok && name != "" && slices.Contains(p.config.MFAAmrValues, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Provider) validAudience(claims jwt.MapClaims) bool {
+	if azp, _ := claims["azp"].(string); azp == p.config.ClientID {
+		return true
+	}
+
+	audiences, _ := claims.GetAudience()
+	for _, aud := range audiences {
+		if aud == p.config.ClientID || slices.Contains(p.config.Audiences, aud) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Provider) getDiscovery(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	d := &discovery{}
+	if err := p.getJSON(ctx, p.config.IssuerURL+"/.well-known/openid-configuration", d); err != nil {
+		return nil, err
+	}
+	if d.Issuer != p.config.IssuerURL {
+		return nil, fmt.Errorf("oidc issuer %q does not match the configured %q", d.Issuer, p.config.IssuerURL)
+	}
+
+	p.discovery = d
+	return d, nil
+}
+
+// getKey returns the key of kid, the keys are fetched again when the
+// provider rotated them.
+func (p *Provider) getKey(ctx context.Context, kid string) (any, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < keysRefreshInterval {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+
+	set := &auth.JSONWebKeySet{}
+	if err := p.getJSON(ctx, d.JWKSURI, set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for _, jwk := range set.Keys {
+		key, err := jwk.PublicKey()
+		if err != nil {
+			// keycloak publishes its encryption keys too
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc request to %s failed with status %d", url, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
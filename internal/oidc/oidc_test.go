@@ -0,0 +1,85 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestProviderVerify(t *testing.T) {
+	fake := NewFakeProvider()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	fake.Issuer = server.URL
+
+	provider := NewProvider(Config{
+		IssuerURL:   server.URL,
+		ClientID:    "social",
+		Audiences:   []string{"tasklist-api"},
+		RoleMapping: map[string]string{"admins": "admin", "moderators": "moderator"},
+	})
+	ctx := context.Background()
+
+	t.Run("should accept a token of an accepted audience", func(t *testing.T) {
+		claims, err := provider.Verify(ctx, fake.Token(jwt.MapClaims{
+			"sub":                "f3a1",
+			"aud":                []string{"tasklist-api", "account"},
+			"email":              "damar@test.com",
+			"email_verified":     true,
+			"preferred_username": "damar",
+			"groups":             []string{"/admins", "/moderators", "/other"},
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if claims.Subject != "f3a1" || claims.Email != "damar@test.com" || !claims.EmailVerified || claims.Username != "damar" {
+			t.Errorf("unexpected claims %+v", claims)
+		}
+		if roles := provider.RoleNames(claims); !slices.Equal(roles, []string{"admin", "moderator"}) {
+			t.Errorf("expected the roles admin and moderator, got %v", roles)
+		}
+	})
+
+	t.Run("should accept a token of the client by azp", func(t *testing.T) {
+		_, err := provider.Verify(ctx, fake.Token(jwt.MapClaims{"sub": "f3a1", "azp": "social"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("should reject invalid tokens", func(t *testing.T) {
+		cases := map[string]jwt.MapClaims{
+			"other audience": {"sub": "f3a1", "aud": "operate-api"},
+			"other issuer":   {"sub": "f3a1", "azp": "social", "iss": "http://other"},
+			"expired":        {"sub": "f3a1", "azp": "social", "exp": time.Now().Add(-time.Hour).Unix()},
+			"no subject":     {"azp": "social"},
+		}
+		for name, claims := range cases {
+			if _, err := provider.Verify(ctx, fake.Token(claims)); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("should exchange a code once", func(t *testing.T) {
+		fake.AddCode("code", jwt.MapClaims{"sub": "f3a1", "aud": "social"})
+
+		tokens, err := provider.Exchange(ctx, "code")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := provider.Verify(ctx, tokens.IDToken); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := provider.Exchange(ctx, "code"); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+}
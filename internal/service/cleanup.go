@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/damarteplok/social/internal/store"
+	"go.uber.org/zap"
+)
+
+type CleanupConfig struct {
+	Enabled  bool
+	Interval time.Duration
+	// InactiveUserGrace is how long a user may stay never activated before
+	// being deleted, zero keeps them.
+	InactiveUserGrace time.Duration
+	// ChallengeRetention is how long the expired two-factor challenges are
+	// kept, their failed attempts count for that long. Zero keeps them.
+	ChallengeRetention time.Duration
+}
+
+// Cleaner deletes the expired tokens of user_invitations every Interval, the
+// users never activated once their grace period is over, the expired
+// two-factor challenges and the revoked access tokens that expired.
+type Cleaner struct {
+	store  store.Storage
+	logger *zap.SugaredLogger
+	config CleanupConfig
+	poller *poller
+}
+
+func NewCleaner(store store.Storage, logger *zap.SugaredLogger, cfg CleanupConfig) *Cleaner {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+
+	return &Cleaner{
+		store:  store,
+		logger: logger,
+		config: cfg,
+		poller: newPoller(),
+	}
+}
+
+func (c *Cleaner) Start() {
+	if !c.config.Enabled {
+		return
+	}
+
+	c.poller.start(c.config.Interval, c.Cleanup)
+}
+
+// Close stops the loop, a cleanup in progress is canceled.
+func (c *Cleaner) Close() {
+	c.poller.stop()
+}
+
+func (c *Cleaner) Cleanup(ctx context.Context) {
+	if c.config.InactiveUserGrace > 0 {
+		deleted, err := c.store.Users.DeleteInactive(ctx, time.Now().Add(-c.config.InactiveUserGrace))
+		if err != nil {
+			c.logger.Errorw("failed to delete inactive users", "error", err)
+		} else if deleted > 0 {
+			c.logger.Infow("inactive users deleted", "count", deleted)
+		}
+	}
+
+	if c.config.ChallengeRetention > 0 {
+		deleted, err := c.store.TwoFactor.DeleteExpiredChallenges(ctx, time.Now().Add(-c.config.ChallengeRetention))
+		if err != nil {
+			c.logger.Errorw("failed to delete expired two-factor challenges", "error", err)
+		} else if deleted > 0 {
+			c.logger.Infow("expired two-factor challenges deleted", "count", deleted)
+		}
+	}
+
+	revoked, err := c.store.RevokedTokens.DeleteExpired(ctx)
+	if err != nil {
+		c.logger.Errorw("failed to delete expired revoked tokens", "error", err)
+	} else if revoked > 0 {
+		c.logger.Infow("expired revoked tokens deleted", "count", revoked)
+	}
+
+	deleted, err := c.store.Users.DeleteExpiredInvitations(ctx)
+	if err != nil {
+		c.logger.Errorw("failed to delete expired invitations", "error", err)
+		return
+	}
+	if deleted > 0 {
+		c.logger.Infow("expired invitations deleted", "count", deleted)
+	}
+}
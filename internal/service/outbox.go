@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/pb"
+	"github.com/damarteplok/social/internal/camunda"
+	"github.com/damarteplok/social/internal/store"
+	"github.com/damarteplok/social/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	// IdempotencyKeyVariable is set on every process instance started from the
+	// outbox, it is used to find an instance whose start was not acknowledged.
+	// Its value is the store.OutboxMessage InstanceKey, unique across users.
+	IdempotencyKeyVariable = "idempotency_key"
+
+	// outboxLease hides claimed messages from other dispatchers, a batch is
+	// abandoned when it takes longer.
+	outboxLease      = 2 * time.Minute
+	outboxMaxBackoff = 10 * time.Minute
+)
+
+var (
+	errProcessNotStarted  = errors.New("process instance is not started yet")
+	errProcessNotExported = errors.New("process instance of the previous attempt may not be exported to operate yet")
+)
+
+// DispatcherConfig of the outbox. A message is dead after MaxAttempts, and
+// ExportLag is how long operate may take to show an instance started by a
+// previous attempt.
+type DispatcherConfig struct {
+	Enabled      bool
+	Interval     time.Duration
+	BatchSize    int
+	RetryBackoff time.Duration
+	MaxAttempts  int
+	ExportLag    time.Duration
+}
+
+// ProcessClient is the part of zeebe.ZeebeCamunda used to send outbox commands.
+type ProcessClient interface {
+	StartWorkflow(ctx context.Context, processDefinitionKey int64, variables map[string]interface{}) (*pb.CreateProcessInstanceResponse, error)
+	CancelWorkflow(context.Context, int64) error
+}
+
+// Dispatcher sends the zeebe commands written to the outbox table together
+// with the rows of the generated process tables. A command is retried with an
+// exponential backoff until zeebe acknowledges it.
+type Dispatcher struct {
+	store   store.Storage
+	zeebe   ProcessClient
+	camunda *camunda.Client
+	logger  *zap.SugaredLogger
+	config  DispatcherConfig
+	poller  *poller
+}
+
+func NewDispatcher(store store.Storage, zeebeClient ProcessClient, camundaClient *camunda.Client, logger *zap.SugaredLogger, cfg DispatcherConfig) *Dispatcher {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Second
+	}
+
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = 100
+	}
+
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 5 * time.Second
+	}
+
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 10
+	}
+
+	if cfg.ExportLag <= 0 {
+		cfg.ExportLag = time.Minute
+	}
+
+	return &Dispatcher{
+		store:   store,
+		zeebe:   zeebeClient,
+		camunda: camundaClient,
+		logger:  logger,
+		config:  cfg,
+		poller:  newPoller(),
+	}
+}
+
+func (d *Dispatcher) Start() {
+	if !d.config.Enabled {
+		return
+	}
+
+	d.poller.start(d.config.Interval, d.Dispatch)
+}
+
+// Trigger asks for a dispatch without waiting for the next tick, e.g. after a
+// message was written.
+func (d *Dispatcher) Trigger() {
+	d.poller.wake()
+}
+
+// Close stops the loop, messages of an abandoned batch are sent again once
+// their lease expires.
+func (d *Dispatcher) Close() {
+	d.poller.stop()
+}
+
+// Dispatch sends one batch of pending messages.
+func (d *Dispatcher) Dispatch(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, outboxLease)
+	defer cancel()
+
+	messages, err := d.store.Outbox.ClaimPending(ctx, d.config.BatchSize, outboxLease)
+	if err != nil {
+		d.logger.Errorw("failed to claim outbox messages", "error", err)
+		return
+	}
+
+	for i := range messages {
+		msg := &messages[i]
+
+		if err := d.send(ctx, msg); err != nil {
+			// the claim counted the attempt, the message is sent again once
+			// its lease expires
+			if ctx.Err() != nil {
+				return
+			}
+
+			if msg.Attempts >= d.config.MaxAttempts {
+				d.logger.Errorw("outbox message is dead", "id", msg.ID, "command", msg.Command, "table", msg.TableName, "rowId", msg.RowID, "attempts", msg.Attempts, "error", err)
+
+				if err := d.store.Outbox.MarkDead(ctx, msg.ID, err.Error()); err != nil {
+					d.logger.Errorw("failed to mark outbox message as dead", "id", msg.ID, "error", err)
+				}
+				continue
+			}
+
+			retryAfter := d.backoff(msg.Attempts - 1)
+			d.logger.Warnw("failed to send outbox message", "id", msg.ID, "command", msg.Command, "attempts", msg.Attempts, "retryAfter", retryAfter, "error", err)
+
+			if err := d.store.Outbox.MarkFailed(ctx, msg.ID, err.Error(), retryAfter); err != nil {
+				d.logger.Errorw("failed to mark outbox message as failed", "id", msg.ID, "error", err)
+			}
+			continue
+		}
+
+		d.logger.Infow("outbox message sent", "id", msg.ID, "command", msg.Command, "table", msg.TableName, "rowId", msg.RowID)
+	}
+
+	// more messages may be waiting
+	if len(messages) == d.config.BatchSize {
+		d.Trigger()
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, msg *store.OutboxMessage) (err error) {
+	// the zeebe client records the process instance key on this span
+	ctx, span := tracing.Start(ctx, "outbox "+msg.Command,
+		attribute.Int64("outbox.id", msg.ID),
+		attribute.String("outbox.table", msg.TableName),
+		attribute.Int64("outbox.row_id", msg.RowID),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	switch msg.Command {
+	case store.OutboxStartProcess:
+		return d.startProcess(ctx, msg)
+	case store.OutboxCancelProcess:
+		return d.cancelProcess(ctx, msg)
+	default:
+		return fmt.Errorf("unknown outbox command %q", msg.Command)
+	}
+}
+
+func (d *Dispatcher) startProcess(ctx context.Context, msg *store.OutboxMessage) error {
+	var payload store.StartProcessPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return err
+	}
+
+	// a previous attempt may have started the instance without being
+	// acknowledged, e.g. the dispatcher stopped or the response timed out
+	if msg.Attempts > 1 {
+		processInstanceKey, err := d.findProcessInstance(ctx, msg.InstanceKey())
+		if err != nil {
+			return err
+		}
+
+		if processInstanceKey != 0 {
+			return d.store.Outbox.MarkStarted(ctx, msg, processInstanceKey)
+		}
+
+		// operate exports the instances with a delay, the instance is only
+		// started again once the one of the previous attempt would be found
+		if msg.LastAttemptAt != nil && time.Since(*msg.LastAttemptAt) < d.config.ExportLag {
+			return errProcessNotExported
+		}
+	}
+
+	variables := payload.Variables
+	if variables == nil {
+		variables = map[string]interface{}{}
+	}
+	variables[IdempotencyKeyVariable] = msg.InstanceKey()
+
+	commandCtx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	resp, err := d.zeebe.StartWorkflow(commandCtx, payload.ProcessDefinitionKey, variables)
+	if err != nil {
+		return err
+	}
+
+	return d.store.Outbox.MarkStarted(ctx, msg, resp.GetProcessInstanceKey())
+}
+
+func (d *Dispatcher) cancelProcess(ctx context.Context, msg *store.OutboxMessage) error {
+	processInstanceKey, err := d.store.Outbox.GetProcessInstanceKey(ctx, msg.TableName, msg.RowID)
+	if err != nil {
+		return err
+	}
+
+	// the start command of the row is still pending, retry after it
+	if processInstanceKey == 0 {
+		return errProcessNotStarted
+	}
+
+	commandCtx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	if err := d.zeebe.CancelWorkflow(commandCtx, processInstanceKey); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	return d.store.Outbox.MarkProcessed(ctx, msg.ID)
+}
+
+// findProcessInstance returns the key of the process instance started with the
+// idempotency key or 0 when operate does not know one.
+func (d *Dispatcher) findProcessInstance(ctx context.Context, idempotencyKey string) (int64, error) {
+	// operate stores variable values json encoded
+	value, err := json.Marshal(idempotencyKey)
+	if err != nil {
+		return 0, err
+	}
+
+	variables, err := d.camunda.Variables.Search(ctx, camunda.Query[camunda.VariableFilter]{
+		Filter: camunda.VariableFilter{
+			Name:  IdempotencyKeyVariable,
+			Value: string(value),
+		},
+		Size: 1,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(variables.Items) == 0 {
+		return 0, nil
+	}
+
+	return variables.Items[0].ProcessInstanceKey, nil
+}
+
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	backoff := d.config.RetryBackoff
+	for i := 0; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, outboxMaxBackoff)
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// poller runs a function every interval and whenever it is triggered, it is
+// shared by the background loops of this package.
+type poller struct {
+	trigger chan struct{}
+	done    chan struct{}
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	stopped sync.Once
+}
+
+func newPoller() *poller {
+	return &poller{
+		trigger: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+func (p *poller) start(interval time.Duration, fn func(context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+			case <-p.trigger:
+			}
+
+			fn(ctx)
+		}
+	}()
+}
+
+func (p *poller) wake() {
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// stop may be called more than once, e.g. by Close and a deferred Close.
+func (p *poller) stop() {
+	p.stopped.Do(func() {
+		close(p.done)
+		if p.cancel != nil {
+			p.cancel()
+		}
+	})
+	p.wg.Wait()
+}
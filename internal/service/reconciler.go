@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/damarteplok/social/internal/camunda"
+	"github.com/damarteplok/social/internal/store"
+	"github.com/damarteplok/social/internal/store/cache"
+	"go.uber.org/zap"
+)
+
+type ReconcilerConfig struct {
+	Enabled   bool
+	Interval  time.Duration
+	BatchSize int
+}
+
+type processInstanceStore interface {
+	GetRunning(context.Context, int64, int) ([]store.ProcessInstanceState, error)
+	UpdateState(context.Context, *store.ProcessInstanceState) error
+}
+
+type processInstanceCache interface {
+	Delete(context.Context, int64)
+}
+
+type reconciledProcess struct {
+	store processInstanceStore
+	cache processInstanceCache
+}
+
+// Reconciler keeps task_state and task_definition_id of the generated process
+// tables in sync with operate. It runs every Interval and whenever Trigger is
+// called, e.g. after a user task was completed. Every run syncs one page of
+// BatchSize rows per process, with a search of their instances by key and a
+// search of the active elements of their definitions. The next run continues
+// after the page.
+//
+// A FAILED row goes back to CREATED once its incidents are resolved, the
+// instance is running again then.
+type Reconciler struct {
+	store   store.Storage
+	cache   cache.Storage
+	camunda *camunda.Client
+	logger  *zap.SugaredLogger
+	config  ReconcilerConfig
+	poller  *poller
+	// cursors holds the last id synced of every process, only the loop of
+	// the poller reconciles
+	cursors map[string]int64
+}
+
+// NewReconciler expects an empty cache.Storage when redis is disabled.
+func NewReconciler(store store.Storage, cacheStorage cache.Storage, camundaClient *camunda.Client, logger *zap.SugaredLogger, cfg ReconcilerConfig) *Reconciler {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = 100
+	}
+
+	return &Reconciler{
+		store:   store,
+		cache:   cacheStorage,
+		camunda: camundaClient,
+		logger:  logger,
+		config:  cfg,
+		poller:  newPoller(),
+		cursors: map[string]int64{},
+	}
+}
+
+func (r *Reconciler) processes() map[string]reconciledProcess {
+	return map[string]reconciledProcess{
+		// GENERATED RECONCILER PROCESSES
+		"pembuatan_media_berita_technology": {r.store.PembuatanMediaBeritaTechnology, r.cache.PembuatanMediaBeritaTechnology},
+	}
+}
+
+func (r *Reconciler) Start() {
+	if !r.config.Enabled {
+		return
+	}
+
+	r.poller.start(r.config.Interval, r.Reconcile)
+}
+
+// Trigger asks for a reconcile without waiting for the next tick.
+func (r *Reconciler) Trigger() {
+	r.poller.wake()
+}
+
+// Close stops the loop, a reconcile in progress is canceled.
+func (r *Reconciler) Close() {
+	r.poller.stop()
+}
+
+// Reconcile syncs the next page of running process instances of every
+// generated process.
+func (r *Reconciler) Reconcile(ctx context.Context) {
+	for name, process := range r.processes() {
+		if err := r.reconcileProcess(ctx, name, process); err != nil {
+			r.logger.Errorw("failed to reconcile process instances", "process", name, "error", err)
+		}
+	}
+}
+
+func (r *Reconciler) reconcileProcess(ctx context.Context, name string, process reconciledProcess) error {
+	rows, err := process.store.GetRunning(ctx, r.cursors[name], r.config.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	// the last page starts over on the next run
+	r.cursors[name] = 0
+	if len(rows) == r.config.BatchSize {
+		r.cursors[name] = rows[len(rows)-1].ID
+	}
+
+	states, err := r.processInstanceStates(ctx, rows)
+	if err != nil {
+		return err
+	}
+
+	for i := range rows {
+		row := &rows[i]
+
+		// operate exports with a delay, a new instance may not be there yet
+		state, ok := states[row.ProcessInstanceKey]
+		if !ok {
+			continue
+		}
+		if state.TaskDefinitionId == nil {
+			state.TaskDefinitionId = row.TaskDefinitionId
+		}
+
+		if state.TaskState == row.TaskState && equalStringPtr(state.TaskDefinitionId, row.TaskDefinitionId) {
+			continue
+		}
+
+		state.ID = row.ID
+		if err := process.store.UpdateState(ctx, state); err != nil {
+			return err
+		}
+
+		if process.cache != nil {
+			process.cache.Delete(ctx, row.ID)
+		}
+
+		r.logger.Infow("process instance state synced", "id", row.ID, "processInstanceKey", row.ProcessInstanceKey, "state", state.TaskState)
+	}
+
+	return nil
+}
+
+// processInstanceStates maps the operate state of the process instances of
+// rows to the task_state values, by key. The instances are searched by their
+// keys at once, the incident flag of an instance saves the incident search.
+// TaskDefinitionId is the element a running instance is currently at, it is
+// nil for an ended instance so the row keeps its last element.
+func (r *Reconciler) processInstanceStates(ctx context.Context, rows []store.ProcessInstanceState) (map[int64]*store.ProcessInstanceState, error) {
+	keys := make([]int64, len(rows))
+	for i, row := range rows {
+		keys[i] = row.ProcessInstanceKey
+	}
+
+	instances, err := r.camunda.ProcessInstances.SearchKeys(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[int64]*store.ProcessInstanceState, len(instances))
+	var definitions []int64
+	for _, instance := range instances {
+		state := &store.ProcessInstanceState{ProcessInstanceKey: instance.Key}
+		switch instance.State {
+		case "ACTIVE":
+			state.TaskState = store.ProcessStateCreated
+			if instance.Incident {
+				state.TaskState = store.ProcessStateFailed
+			}
+			if !slices.Contains(definitions, instance.ProcessDefinitionKey) {
+				definitions = append(definitions, instance.ProcessDefinitionKey)
+			}
+		case "COMPLETED":
+			state.TaskState = store.ProcessStateCompleted
+		case "CANCELED":
+			state.TaskState = store.ProcessStateCanceled
+		default:
+			r.logger.Warnw("unknown process instance state", "processInstanceKey", instance.Key, "state", instance.State)
+			continue
+		}
+		states[instance.Key] = state
+	}
+
+	// the active elements of a definition are the ones of its running
+	// instances, the latest started one is where an instance is at
+	for _, definition := range definitions {
+		pager := camunda.PagesAfter(r.camunda.FlowNodeInstances.Search, camunda.Query[camunda.FlowNodeInstanceFilter]{
+			Filter: camunda.FlowNodeInstanceFilter{ProcessDefinitionKey: &definition, State: "ACTIVE"},
+			Size:   int32(r.config.BatchSize),
+			Sort:   []camunda.Sort{{Field: "startDate", Order: "ASC"}},
+		})
+		for pager.Next(ctx) {
+			for _, flowNode := range pager.Items() {
+				if state, ok := states[flowNode.ProcessInstanceKey]; ok && state.TaskState != store.ProcessStateCompleted && state.TaskState != store.ProcessStateCanceled {
+					state.TaskDefinitionId = &flowNode.FlowNodeId
+				}
+			}
+		}
+		if err := pager.Err(); err != nil {
+			return nil, fmt.Errorf("failed to search the active elements: %w", err)
+		}
+	}
+
+	return states, nil
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
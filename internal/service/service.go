@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/entities"
+	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/worker"
+	"github.com/damarteplok/social/internal/store"
+	"github.com/damarteplok/social/internal/zeebe"
+	"go.uber.org/zap"
+)
+
+// Handler executes a single activated job. The returned variables are
+// merged into the process instance when the job is completed.
+type Handler func(ctx context.Context, job entities.Job) (map[string]interface{}, error)
+
+const commandTimeout = 10 * time.Second
+
+type Config struct {
+	Enabled       bool
+	Concurrency   int
+	MaxJobsActive int
+	Timeout       time.Duration
+	PollInterval  time.Duration
+	RetryBackoff  time.Duration
+}
+
+type Service struct {
+	store   store.Storage
+	zeebe   zeebe.ZeebeCamunda
+	logger  *zap.SugaredLogger
+	config  Config
+	workers []worker.JobWorker
+}
+
+func NewService(store store.Storage, zeebeClient zeebe.ZeebeCamunda, logger *zap.SugaredLogger, cfg Config) *Service {
+	return &Service{
+		store:  store,
+		zeebe:  zeebeClient,
+		logger: logger,
+		config: cfg,
+	}
+}
+
+func (s *Service) handlers() map[string]Handler {
+	return map[string]Handler{
+		// GENERATED SERVICE TASK HANDLERS
+		ServiceTaskArchivedType:         s.serviceTaskArchived,
+		ServiceTaskPublishedArtikelType: s.serviceTaskPublishedArtikel,
+	}
+}
+
+// Start opens one job worker per service task type.
+func (s *Service) Start() error {
+	if !s.config.Enabled {
+		return nil
+	}
+
+	for jobType, handler := range s.handlers() {
+		w, err := s.zeebe.StartWorker(
+			jobType,
+			fmt.Sprintf("social-%s", jobType),
+			zeebe.WorkerConfig{
+				Concurrency:   s.config.Concurrency,
+				MaxJobsActive: s.config.MaxJobsActive,
+				Timeout:       s.config.Timeout,
+				PollInterval:  s.config.PollInterval,
+			},
+			s.jobHandler(jobType, handler),
+		)
+		if err != nil {
+			s.Close()
+			return fmt.Errorf("failed to start worker %s: %w", jobType, err)
+		}
+		s.workers = append(s.workers, w)
+		s.logger.Infow("job worker started", "type", jobType, "concurrency", s.config.Concurrency)
+	}
+
+	return nil
+}
+
+// Close stops polling for new jobs and waits for the active ones to finish.
+func (s *Service) Close() {
+	for _, w := range s.workers {
+		w.Close()
+	}
+	s.workers = nil
+}
+
+func (s *Service) jobHandler(jobType string, handler Handler) worker.JobHandler {
+	return func(client worker.JobClient, job entities.Job) {
+		variables, err := s.run(handler, job)
+
+		// the handler may have used up its own deadline, so the job
+		// commands get a fresh one
+		ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+		defer cancel()
+
+		if err != nil {
+			s.failJob(ctx, client, jobType, job, err)
+			return
+		}
+
+		if variables == nil {
+			variables = map[string]interface{}{}
+		}
+
+		request, err := client.NewCompleteJobCommand().JobKey(job.GetKey()).VariablesFromMap(variables)
+		if err != nil {
+			s.failJob(ctx, client, jobType, job, err)
+			return
+		}
+
+		if _, err := request.Send(ctx); err != nil {
+			s.logger.Errorw("failed to complete job", "type", jobType, "key", job.GetKey(), "error", err)
+			return
+		}
+
+		s.logger.Infow("job completed", "type", jobType, "key", job.GetKey(), "processInstanceKey", job.GetProcessInstanceKey())
+	}
+}
+
+func (s *Service) run(handler Handler, job entities.Job) (variables map[string]interface{}, err error) {
+	ctx := context.Background()
+	if s.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.Timeout)
+		defer cancel()
+	}
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("panic in job handler: %v", rec)
+		}
+	}()
+
+	return handler(ctx, job)
+}
+
+func (s *Service) failJob(ctx context.Context, client worker.JobClient, jobType string, job entities.Job, jobErr error) {
+	retries := job.GetRetries() - 1
+	if retries < 0 {
+		retries = 0
+	}
+
+	s.logger.Warnw("job failed", "type", jobType, "key", job.GetKey(), "retries", retries, "error", jobErr)
+
+	_, err := client.NewFailJobCommand().
+		JobKey(job.GetKey()).
+		Retries(retries).
+		RetryBackoff(s.config.RetryBackoff).
+		ErrorMessage(jobErr.Error()).
+		Send(ctx)
+	if err != nil {
+		s.logger.Errorw("failed to fail job", "type", jobType, "key", job.GetKey(), "error", err)
+	}
+}
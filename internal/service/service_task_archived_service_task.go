@@ -1,7 +1,20 @@
-package store
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/entities"
+)
+
 const (
-	ServiceTaskArchivedID = "archived"
+	ServiceTaskArchivedID   = "archived"
 	ServiceTaskArchivedName = "archived"
 	ServiceTaskArchivedType = "service_task_archived"
 )
-// TODO: DO SOMETHING IN SERVICE TASK
+
+func (s *Service) serviceTaskArchived(ctx context.Context, job entities.Job) (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"archived_at": time.Now().Unix(),
+	}, nil
+}
@@ -1,7 +1,97 @@
-package store
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/entities"
+	"github.com/damarteplok/social/internal/store"
+)
+
 const (
-	ServiceTaskPublishedArtikelID = "published"
+	ServiceTaskPublishedArtikelID   = "published"
 	ServiceTaskPublishedArtikelName = "publish"
 	ServiceTaskPublishedArtikelType = "service_task_published_artikel"
 )
-// TODO: DO SOMETHING IN SERVICE TASK
+
+// variables written by FormDataPembuatanArtikel and the process create handler
+type publishedArtikelVariables struct {
+	Title     string      `json:"title"`
+	Content   string      `json:"content"`
+	Tags      interface{} `json:"tags"`
+	PostID    *int64      `json:"post_id"`
+	CreatedBy struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	} `json:"created_by"`
+}
+
+func (s *Service) serviceTaskPublishedArtikel(ctx context.Context, job entities.Job) (map[string]interface{}, error) {
+	var vars publishedArtikelVariables
+	if err := job.GetVariablesAs(&vars); err != nil {
+		return nil, fmt.Errorf("failed to read process variables: %w", err)
+	}
+
+	// a job completed before has set post_id
+	if vars.PostID != nil {
+		return map[string]interface{}{
+			"post_id": *vars.PostID,
+		}, nil
+	}
+
+	if vars.Title == "" {
+		return nil, errors.New("variable title is required")
+	}
+
+	if vars.CreatedBy.ID == 0 {
+		return nil, errors.New("variable created_by is required")
+	}
+
+	post := &store.Post{
+		Title:   vars.Title,
+		Content: vars.Content,
+		Tags:    parseTags(vars.Tags),
+		UserID:  vars.CreatedBy.ID,
+	}
+
+	// a job retried after the post was created but before it was completed
+	// gets the post of the instance back, the article is published once
+	if err := s.store.Posts.CreateForProcess(ctx, post, job.GetProcessInstanceKey()); err != nil {
+		return nil, fmt.Errorf("failed to create post: %w", err)
+	}
+
+	s.logger.Infow("artikel published", "postID", post.ID, "userID", post.UserID, "processInstanceKey", job.GetProcessInstanceKey())
+
+	return map[string]interface{}{
+		"post_id":      post.ID,
+		"published_at": time.Now().Unix(),
+	}, nil
+}
+
+// parseTags accepts the value of a select (comma separated string) or a
+// checklist/taglist (array) form component.
+func parseTags(value interface{}) []string {
+	tags := []string{}
+
+	switch v := value.(type) {
+	case string:
+		for _, tag := range strings.Split(v, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "" || tag == "<none>" {
+				continue
+			}
+			tags = append(tags, tag)
+		}
+	case []interface{}:
+		for _, item := range v {
+			if tag, ok := item.(string); ok && strings.TrimSpace(tag) != "" {
+				tags = append(tags, strings.TrimSpace(tag))
+			}
+		}
+	}
+
+	return tags
+}
@@ -0,0 +1,626 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/commands"
+	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/entities"
+	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/pb"
+	"github.com/damarteplok/social/internal/camunda"
+	"github.com/damarteplok/social/internal/store"
+	"github.com/damarteplok/social/internal/store/cache"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+type fakePostStore struct {
+	created   []*store.Post
+	instances map[int64]*store.Post
+}
+
+func (f *fakePostStore) GetByID(ctx context.Context, id int64) (*store.Post, error) {
+	return nil, store.ErrNotFound
+}
+
+func (f *fakePostStore) Create(ctx context.Context, post *store.Post) error {
+	post.ID = int64(len(f.created) + 1)
+	f.created = append(f.created, post)
+	return nil
+}
+
+func (f *fakePostStore) CreateForProcess(ctx context.Context, post *store.Post, processInstanceKey int64) error {
+	if published, ok := f.instances[processInstanceKey]; ok {
+		*post = *published
+		return nil
+	}
+	if err := f.Create(ctx, post); err != nil {
+		return err
+	}
+	f.instances[processInstanceKey] = post
+	return nil
+}
+
+func (f *fakePostStore) Delete(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (f *fakePostStore) Update(ctx context.Context, post *store.Post) error {
+	return nil
+}
+
+func (f *fakePostStore) GetUserFeed(ctx context.Context, userID int64, fq store.PaginatedFeedQuery) ([]store.PostWithMetadata, error) {
+	return nil, nil
+}
+
+func newJob(variables string) entities.Job {
+	return entities.Job{ActivatedJob: &pb.ActivatedJob{Key: 1, ProcessInstanceKey: 1, Variables: variables}}
+}
+
+// fakeJobClient records the job commands a handler sends, the commands are
+// the ones of the real client.
+type fakeJobClient struct {
+	pb.GatewayClient
+	completed *pb.CompleteJobRequest
+	failed    *pb.FailJobRequest
+}
+
+func (c *fakeJobClient) NewCompleteJobCommand() commands.CompleteJobCommandStep1 {
+	return commands.NewCompleteJobCommand(c, noRetry)
+}
+
+func (c *fakeJobClient) NewFailJobCommand() commands.FailJobCommandStep1 {
+	return commands.NewFailJobCommand(c, noRetry)
+}
+
+func (c *fakeJobClient) NewThrowErrorCommand() commands.ThrowErrorCommandStep1 {
+	return commands.NewThrowErrorCommand(c, noRetry)
+}
+
+func (c *fakeJobClient) CompleteJob(ctx context.Context, in *pb.CompleteJobRequest, opts ...grpc.CallOption) (*pb.CompleteJobResponse, error) {
+	c.completed = in
+	return &pb.CompleteJobResponse{}, nil
+}
+
+func (c *fakeJobClient) FailJob(ctx context.Context, in *pb.FailJobRequest, opts ...grpc.CallOption) (*pb.FailJobResponse, error) {
+	c.failed = in
+	return &pb.FailJobResponse{}, nil
+}
+
+func noRetry(ctx context.Context, err error) bool {
+	return false
+}
+
+func TestJobHandler(t *testing.T) {
+	s := NewService(store.Storage{}, nil, zap.NewNop().Sugar(), Config{RetryBackoff: 5 * time.Second})
+
+	tests := []struct {
+		name      string
+		handler   Handler
+		retries   int32
+		variables string
+		failure   string
+	}{
+		{
+			name: "should complete the job with the variables of the handler",
+			handler: func(ctx context.Context, job entities.Job) (map[string]interface{}, error) {
+				return map[string]interface{}{"post_id": 3}, nil
+			},
+			retries:   3,
+			variables: `{"post_id":3}`,
+		},
+		{
+			name: "should complete the job without variables",
+			handler: func(ctx context.Context, job entities.Job) (map[string]interface{}, error) {
+				return nil, nil
+			},
+			retries:   3,
+			variables: `{}`,
+		},
+		{
+			name: "should fail the job with one retry less and the backoff",
+			handler: func(ctx context.Context, job entities.Job) (map[string]interface{}, error) {
+				return nil, errors.New("database is down")
+			},
+			retries: 3,
+			failure: "database is down",
+		},
+		{
+			name: "should fail the job of a recovered panic",
+			handler: func(ctx context.Context, job entities.Job) (map[string]interface{}, error) {
+				panic("nil map")
+			},
+			retries: 1,
+			failure: "panic in job handler: nil map",
+		},
+		{
+			name: "should not retry a job without retries",
+			handler: func(ctx context.Context, job entities.Job) (map[string]interface{}, error) {
+				return nil, errors.New("database is down")
+			},
+			retries: 0,
+			failure: "database is down",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeJobClient{}
+			job := entities.Job{ActivatedJob: &pb.ActivatedJob{Key: 42, ProcessInstanceKey: 1, Retries: tt.retries}}
+
+			s.jobHandler("test", tt.handler)(client, job)
+
+			if tt.failure == "" {
+				if client.failed != nil {
+					t.Fatalf("expected the job completed got a failure %q", client.failed.GetErrorMessage())
+				}
+				if client.completed == nil || client.completed.GetJobKey() != 42 {
+					t.Fatalf("expected job 42 completed got %v", client.completed)
+				}
+				if client.completed.GetVariables() != tt.variables {
+					t.Errorf("expected variables %s got %s", tt.variables, client.completed.GetVariables())
+				}
+				return
+			}
+
+			if client.completed != nil {
+				t.Fatal("expected the job failed got it completed")
+			}
+			if client.failed == nil || client.failed.GetJobKey() != 42 {
+				t.Fatalf("expected job 42 failed got %v", client.failed)
+			}
+			if retries := max(tt.retries-1, 0); client.failed.GetRetries() != retries {
+				t.Errorf("expected %d retries got %d", retries, client.failed.GetRetries())
+			}
+			if client.failed.GetRetryBackOff() != 5000 {
+				t.Errorf("expected a backoff of 5000ms got %d", client.failed.GetRetryBackOff())
+			}
+			if client.failed.GetErrorMessage() != tt.failure {
+				t.Errorf("expected the error %q got %q", tt.failure, client.failed.GetErrorMessage())
+			}
+		})
+	}
+}
+
+func TestServiceTaskPublishedArtikel(t *testing.T) {
+	posts := &fakePostStore{instances: map[int64]*store.Post{}}
+	s := NewService(store.Storage{Posts: posts}, nil, zap.NewNop().Sugar(), Config{})
+
+	t.Run("should create a post owned by created_by", func(t *testing.T) {
+		job := newJob(`{"title":"judul","content":"isi","tags":"go, camunda","created_by":{"id":7,"username":"damar"}}`)
+
+		vars, err := s.serviceTaskPublishedArtikel(context.Background(), job)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(posts.created) != 1 {
+			t.Fatalf("expected 1 post got %d", len(posts.created))
+		}
+		post := posts.created[0]
+		if post.UserID != 7 || post.Title != "judul" || len(post.Tags) != 2 {
+			t.Errorf("unexpected post %+v", post)
+		}
+		if vars["post_id"] != post.ID {
+			t.Errorf("expected post_id %d got %v", post.ID, vars["post_id"])
+		}
+	})
+
+	t.Run("should not create a post twice for a retried job", func(t *testing.T) {
+		job := newJob(`{"title":"judul","content":"isi","created_by":{"id":7}}`)
+
+		vars, err := s.serviceTaskPublishedArtikel(context.Background(), job)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(posts.created) != 1 {
+			t.Errorf("expected 1 post got %d", len(posts.created))
+		}
+		if vars["post_id"] != posts.created[0].ID {
+			t.Errorf("expected post_id %d got %v", posts.created[0].ID, vars["post_id"])
+		}
+	})
+
+	t.Run("should not create a post twice", func(t *testing.T) {
+		posts.created = nil
+		job := newJob(`{"title":"judul","post_id":3,"created_by":{"id":7}}`)
+
+		vars, err := s.serviceTaskPublishedArtikel(context.Background(), job)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(posts.created) != 0 {
+			t.Errorf("expected no post got %d", len(posts.created))
+		}
+		if vars["post_id"] != int64(3) {
+			t.Errorf("expected post_id 3 got %v", vars["post_id"])
+		}
+	})
+
+	t.Run("should fail without created_by", func(t *testing.T) {
+		job := newJob(`{"title":"judul"}`)
+
+		if _, err := s.serviceTaskPublishedArtikel(context.Background(), job); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+type fakeProcessStore struct {
+	running []store.ProcessInstanceState
+	updated []store.ProcessInstanceState
+}
+
+func (f *fakeProcessStore) Create(ctx context.Context, model *store.PembuatanMediaBeritaTechnology) error {
+	return nil
+}
+
+func (f *fakeProcessStore) Delete(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (f *fakeProcessStore) GetByID(ctx context.Context, id int64) (*store.PembuatanMediaBeritaTechnology, error) {
+	return nil, store.ErrNotFound
+}
+
+func (f *fakeProcessStore) Update(ctx context.Context, model *store.PembuatanMediaBeritaTechnology) error {
+	return nil
+}
+
+func (f *fakeProcessStore) Search(ctx context.Context, pq store.PaginatedQuery) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+func (f *fakeProcessStore) GetRunning(ctx context.Context, afterID int64, limit int) ([]store.ProcessInstanceState, error) {
+	var rows []store.ProcessInstanceState
+	for _, row := range f.running {
+		if row.ID > afterID && len(rows) < limit {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func (f *fakeProcessStore) UpdateState(ctx context.Context, state *store.ProcessInstanceState) error {
+	f.updated = append(f.updated, *state)
+	return nil
+}
+
+func (f *fakeProcessStore) CreateWithOutbox(ctx context.Context, model *store.PembuatanMediaBeritaTechnology, msg *store.OutboxMessage) error {
+	return nil
+}
+
+func (f *fakeProcessStore) DeleteWithOutbox(ctx context.Context, id int64, msg *store.OutboxMessage) error {
+	return nil
+}
+
+// newCamundaClient serves the operate and zeebe api of the test with handler.
+func newCamundaClient(t *testing.T, handler http.HandlerFunc) *camunda.Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := camunda.NewClient(server.Client(), camunda.Config{OperateURL: server.URL, ZeebeURL: server.URL})
+	return &client
+}
+
+// fakeOperate answers the searches of the reconciler with the instances of
+// their keys, every active one is at reviewing_artikel.
+type fakeOperate struct {
+	instances   map[int64]string
+	keySearches int
+}
+
+func (f *fakeOperate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v2/process-instances/search":
+		f.keySearches++
+
+		var query camunda.ProcessInstanceKeysQuery
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		items := []string{}
+		for _, key := range query.Filter.ProcessInstanceKeys {
+			if instance, ok := f.instances[key]; ok {
+				items = append(items, instance)
+			}
+		}
+		fmt.Fprintf(w, `{"items":[%s]}`, strings.Join(items, ","))
+	case "/v1/flownode-instances/search":
+		var query camunda.Query[camunda.FlowNodeInstanceFilter]
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if query.SearchAfter != nil {
+			w.Write([]byte(`{"items":[]}`))
+			return
+		}
+		items := []string{}
+		for key := range f.instances {
+			items = append(items, fmt.Sprintf(`{"processInstanceKey":%d,"flowNodeId":"reviewing_artikel"}`, key))
+		}
+		fmt.Fprintf(w, `{"items":[%s],"sortValues":[1]}`, strings.Join(items, ","))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestReconcilerReconcile(t *testing.T) {
+	current := "reviewing_artikel"
+	processes := &fakeProcessStore{
+		running: []store.ProcessInstanceState{
+			{ID: 1, ProcessInstanceKey: 11, TaskState: store.ProcessStateCreated},
+			{ID: 2, ProcessInstanceKey: 12, TaskState: store.ProcessStateCreated},
+			{ID: 3, ProcessInstanceKey: 13, TaskState: store.ProcessStateCreated, TaskDefinitionId: &current},
+			{ID: 4, ProcessInstanceKey: 14, TaskState: store.ProcessStateCreated},
+			{ID: 5, ProcessInstanceKey: 15, TaskState: store.ProcessStateFailed, TaskDefinitionId: &current},
+		},
+	}
+	operate := &fakeOperate{instances: map[int64]string{
+		11: `{"key":11,"state":"COMPLETED"}`,
+		12: `{"key":12,"state":"ACTIVE","incident":true,"processDefinitionKey":1}`,
+		13: `{"key":13,"state":"ACTIVE","processDefinitionKey":1}`,
+		15: `{"key":15,"state":"ACTIVE","processDefinitionKey":1}`,
+	}}
+
+	r := NewReconciler(
+		store.Storage{PembuatanMediaBeritaTechnology: processes},
+		cache.Storage{},
+		newCamundaClient(t, operate.ServeHTTP),
+		zap.NewNop().Sugar(),
+		ReconcilerConfig{BatchSize: 2},
+	)
+
+	// a run syncs a page of two rows
+	for range 3 {
+		r.Reconcile(context.Background())
+	}
+
+	expected := map[int64]struct {
+		state   string
+		element *string
+	}{
+		// an ended instance keeps its last element
+		1: {store.ProcessStateCompleted, nil},
+		2: {store.ProcessStateFailed, &current},
+		// the incident was resolved
+		5: {store.ProcessStateCreated, &current},
+	}
+	if len(processes.updated) != len(expected) {
+		t.Fatalf("expected %d updates got %+v", len(expected), processes.updated)
+	}
+	for _, state := range processes.updated {
+		if expected[state.ID].state != state.TaskState {
+			t.Errorf("expected state %s for %d got %s", expected[state.ID].state, state.ID, state.TaskState)
+		}
+		if !equalStringPtr(expected[state.ID].element, state.TaskDefinitionId) {
+			t.Errorf("expected task definition %v for %d got %v", expected[state.ID].element, state.ID, state.TaskDefinitionId)
+		}
+	}
+	if operate.keySearches != 3 {
+		t.Errorf("expected one instance search per page, got %d", operate.keySearches)
+	}
+}
+
+type fakeOutboxStore struct {
+	pending   []store.OutboxMessage
+	keys      map[int64]int64
+	started   map[int64]int64
+	processed []int64
+	failed    []int64
+	dead      []int64
+}
+
+func (f *fakeOutboxStore) GetByIdempotencyKey(ctx context.Context, createdBy int64, key string) (*store.OutboxMessage, error) {
+	return nil, store.ErrNotFound
+}
+
+func (f *fakeOutboxStore) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]store.OutboxMessage, error) {
+	return f.pending, nil
+}
+
+func (f *fakeOutboxStore) GetProcessInstanceKey(ctx context.Context, tableName string, rowID int64) (int64, error) {
+	return f.keys[rowID], nil
+}
+
+func (f *fakeOutboxStore) MarkStarted(ctx context.Context, msg *store.OutboxMessage, processInstanceKey int64) error {
+	f.started[msg.ID] = processInstanceKey
+	return nil
+}
+
+func (f *fakeOutboxStore) MarkProcessed(ctx context.Context, id int64) error {
+	f.processed = append(f.processed, id)
+	return nil
+}
+
+func (f *fakeOutboxStore) MarkFailed(ctx context.Context, id int64, lastError string, retryAfter time.Duration) error {
+	f.failed = append(f.failed, id)
+	return nil
+}
+
+func (f *fakeOutboxStore) MarkDead(ctx context.Context, id int64, lastError string) error {
+	f.dead = append(f.dead, id)
+	return nil
+}
+
+type fakeZeebe struct {
+	started  []map[string]interface{}
+	canceled []int64
+}
+
+func (f *fakeZeebe) StartWorkflow(ctx context.Context, processDefinitionKey int64, variables map[string]interface{}) (*pb.CreateProcessInstanceResponse, error) {
+	f.started = append(f.started, variables)
+	return &pb.CreateProcessInstanceResponse{ProcessInstanceKey: 100 + int64(len(f.started))}, nil
+}
+
+func (f *fakeZeebe) CancelWorkflow(ctx context.Context, processInstanceKey int64) error {
+	f.canceled = append(f.canceled, processInstanceKey)
+	if processInstanceKey == 22 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// fakeVariables answers a variable search with the instance started with the
+// idempotency key "retried".
+func fakeVariables(w http.ResponseWriter, r *http.Request) {
+	var query camunda.Query[camunda.VariableFilter]
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if query.Filter.Name == IdempotencyKeyVariable && query.Filter.Value == `"retried"` {
+		w.Write([]byte(`{"items":[{"processInstanceKey":55}]}`))
+		return
+	}
+	w.Write([]byte(`{"items":[]}`))
+}
+
+func TestDispatcherDispatch(t *testing.T) {
+	justNow, longAgo := time.Now(), time.Now().Add(-time.Hour)
+	outbox := &fakeOutboxStore{
+		pending: []store.OutboxMessage{
+			{ID: 1, IdempotencyKey: "new", CreatedBy: 7, Command: store.OutboxStartProcess, Payload: []byte(`{"process_definition_key":1,"variables":{"title":"judul"}}`), Attempts: 1},
+			{ID: 2, IdempotencyKey: "retried", Command: store.OutboxStartProcess, Payload: []byte(`{"process_definition_key":1}`), Attempts: 2, LastAttemptAt: &justNow},
+			{ID: 3, IdempotencyKey: "lost", Command: store.OutboxStartProcess, Payload: []byte(`{"process_definition_key":1}`), Attempts: 3, LastAttemptAt: &longAgo},
+			{ID: 4, Command: store.OutboxCancelProcess, RowID: 1, Attempts: 1},
+			{ID: 5, Command: store.OutboxCancelProcess, RowID: 2, Attempts: 1},
+			{ID: 6, Command: store.OutboxCancelProcess, RowID: 3, Attempts: 1},
+			{ID: 7, IdempotencyKey: "exporting", Command: store.OutboxStartProcess, Payload: []byte(`{"process_definition_key":1}`), Attempts: 2, LastAttemptAt: &justNow},
+			{ID: 8, Command: store.OutboxCancelProcess, RowID: 3, Attempts: 10},
+		},
+		keys:    map[int64]int64{1: 21, 2: 22},
+		started: map[int64]int64{},
+	}
+	zeebeClient := &fakeZeebe{}
+
+	d := NewDispatcher(store.Storage{Outbox: outbox}, zeebeClient, newCamundaClient(t, fakeVariables), zap.NewNop().Sugar(), DispatcherConfig{})
+	d.Dispatch(context.Background())
+
+	if len(zeebeClient.started) != 2 {
+		t.Fatalf("expected 2 started instances got %d", len(zeebeClient.started))
+	}
+	if zeebeClient.started[0][IdempotencyKeyVariable] != "7:new" || zeebeClient.started[0]["title"] != "judul" {
+		t.Errorf("unexpected variables %v", zeebeClient.started[0])
+	}
+
+	expectedStarted := map[int64]int64{1: 101, 2: 55, 3: 102}
+	for id, key := range expectedStarted {
+		if outbox.started[id] != key {
+			t.Errorf("expected message %d started with %d got %d", id, key, outbox.started[id])
+		}
+	}
+
+	// a not found instance was already canceled, an unstarted one is retried
+	if len(outbox.processed) != 2 || outbox.processed[0] != 4 || outbox.processed[1] != 5 {
+		t.Errorf("expected messages 4 and 5 processed got %v", outbox.processed)
+	}
+	// the instance of a recent attempt may not be exported yet, it is not
+	// started again
+	if len(outbox.failed) != 2 || outbox.failed[0] != 6 || outbox.failed[1] != 7 {
+		t.Errorf("expected messages 6 and 7 failed got %v", outbox.failed)
+	}
+	if len(outbox.dead) != 1 || outbox.dead[0] != 8 {
+		t.Errorf("expected message 8 dead got %v", outbox.dead)
+	}
+}
+
+func TestDispatcherBackoff(t *testing.T) {
+	d := NewDispatcher(store.Storage{}, nil, nil, zap.NewNop().Sugar(), DispatcherConfig{RetryBackoff: time.Second})
+
+	tests := map[int]time.Duration{
+		0:   time.Second,
+		3:   8 * time.Second,
+		100: outboxMaxBackoff,
+	}
+	for attempts, expected := range tests {
+		if got := d.backoff(attempts); got != expected {
+			t.Errorf("expected backoff %s after %d attempts got %s", expected, attempts, got)
+		}
+	}
+}
+
+type fakeUserStore struct {
+	*store.MockUserStore
+	createdBefore time.Time
+	cleaned       bool
+}
+
+func (f *fakeUserStore) DeleteInactive(ctx context.Context, createdBefore time.Time) (int64, error) {
+	f.createdBefore = createdBefore
+	return 1, nil
+}
+
+func (f *fakeUserStore) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
+	f.cleaned = true
+	return 2, nil
+}
+
+type fakeRevokedTokens struct {
+	cleaned bool
+}
+
+func (f *fakeRevokedTokens) Revoke(ctx context.Context, jti string, exp time.Duration) error {
+	return nil
+}
+
+func (f *fakeRevokedTokens) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	return false, nil
+}
+
+func (f *fakeRevokedTokens) RevokeUser(ctx context.Context, userID int64, revocation store.TokenRevocation, exp time.Duration) error {
+	return nil
+}
+
+func (f *fakeRevokedTokens) UserRevocation(ctx context.Context, userID int64) (*store.TokenRevocation, error) {
+	return nil, nil
+}
+
+func (f *fakeRevokedTokens) DeleteExpired(ctx context.Context) (int64, error) {
+	f.cleaned = true
+	return 1, nil
+}
+
+func TestCleanerCleanup(t *testing.T) {
+	users := &fakeUserStore{MockUserStore: &store.MockUserStore{}}
+	tokens := &fakeRevokedTokens{}
+	storage := store.Storage{Users: users, RevokedTokens: tokens}
+
+	NewCleaner(storage, zap.NewNop().Sugar(), CleanupConfig{}).Cleanup(context.Background())
+	if !users.cleaned {
+		t.Error("expected the expired invitations deleted")
+	}
+	if !tokens.cleaned {
+		t.Error("expected the expired revoked tokens deleted")
+	}
+	if !users.createdBefore.IsZero() {
+		t.Error("expected the inactive users kept without a grace period")
+	}
+
+	grace := 7 * 24 * time.Hour
+	NewCleaner(storage, zap.NewNop().Sugar(), CleanupConfig{InactiveUserGrace: grace}).Cleanup(context.Background())
+	if since := time.Since(users.createdBefore); since < grace || since > grace+time.Minute {
+		t.Errorf("expected the users registered %s ago deleted got %s", grace, since)
+	}
+}
+
+func TestPollerStop(t *testing.T) {
+	p := newPoller()
+	p.start(time.Hour, func(ctx context.Context) {})
+
+	p.stop()
+	p.stop()
+}
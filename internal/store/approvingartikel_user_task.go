@@ -1,32 +1,39 @@
 package store
+
 import (
 	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"strconv"
 )
 
 const (
-	ApprovingArtikelID = "approving_artikel"
-	ApprovingArtikelName = "Approving Artikel"
-	ApprovingArtikelFormID = "approving_artikel_form"
-	ApprovingArtikelAssignee = ""
+	ApprovingArtikelID             = "approving_artikel"
+	ApprovingArtikelProcessID      = "pembuatan_media_berita_technology"
+	ApprovingArtikelName           = "Approving Artikel"
+	ApprovingArtikelFormID         = "approving_artikel_form"
+	ApprovingArtikelAssignee       = ""
 	ApprovingArtikelCandidateGroup = ""
-	ApprovingArtikelCandidateUser = ""
-	ApprovingArtikelSchedule = ``
-
+	ApprovingArtikelCandidateUser  = ""
+	ApprovingArtikelSchedule       = ``
 )
 
+// approvingArtikelColumns are read by scanApprovingArtikel in this order
+const approvingArtikelColumns = `id, name, form_id, task_id, properties, created_by, updated_by, created_at, updated_at, decision`
+
 type ApprovingArtikel struct {
-    ID         int64    `json:"id"`
-	Name       string   `json:"name"`
-	FormId     string   `json:"form_id"`
-	Properties []string `json:"properties"`
-	CreatedBy  int64    `json:"created_by"`
-	UpdatedBy  *int64   `json:"updated_by"`
-	CreatedAt  string   `json:"created_at"`
-	UpdatedAt  string   `json:"updated_at"`
-	DeletedAt  *string  `json:"deleted_at"`
+	ID         int64                  `json:"id"`
+	Name       string                 `json:"name"`
+	TaskId     string                 `json:"task_id"`
+	FormId     string                 `json:"form_id"`
+	Properties map[string]interface{} `json:"properties"`
+	CreatedBy  int64                  `json:"created_by"`
+	UpdatedBy  *int64                 `json:"updated_by"`
+	CreatedAt  string                 `json:"created_at"`
+	UpdatedAt  string                 `json:"updated_at"`
+	DeletedAt  *string                `json:"deleted_at"`
+	Decision   *string                `json:"decision"`
 }
 
 type ApprovingArtikelStore struct {
@@ -48,7 +55,7 @@ func (s *ApprovingArtikelStore) Delete(ctx context.Context, id int64) error {
 			return err
 		}
 		return nil
-	})	
+	})
 }
 
 func (s *ApprovingArtikelStore) Update(ctx context.Context, model *ApprovingArtikel) error {
@@ -59,61 +66,28 @@ func (s *ApprovingArtikelStore) Update(ctx context.Context, model *ApprovingArti
 		return nil
 	})
 }
-	
-func (s *ApprovingArtikelStore) create(ctx context.Context, tx *sql.Tx, model *ApprovingArtikel) error {
-	if model.Properties == nil {
-		model.Properties = []string{}
-	}
 
-	propertiesJSON, errProperties := json.Marshal(model.Properties)
-	if errProperties != nil {
-		return errProperties
+func (s *ApprovingArtikelStore) create(ctx context.Context, tx *sql.Tx, model *ApprovingArtikel) error {
+	values, err := model.values()
+	if err != nil {
+		return err
 	}
 
 	query := `
-		INSERT INTO approvingartikel (name, form_id, properties, created_by)
-		VALUES (
-			$1,
-			$2,
-			$3,
-			$4
-		) RETURNING 
-		 	id, name, form_id, properties, created_by, updated_by,
-			created_at, updated_at
-		`
+		INSERT INTO approvingartikel (name, form_id, created_by, task_id, properties, decision)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + approvingArtikelColumns
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	var propertiesData []byte
-	err := tx.QueryRowContext(
-		ctx,
-		query,
-		model.Name,
-		model.FormId,
-		propertiesJSON,
-		model.CreatedBy,
-		model.UpdatedAt,
-	).Scan(
-		&model.ID,
-		&model.Name,
-		&model.FormId,
-		&propertiesData,
-		&model.CreatedBy,
-		&model.UpdatedBy,
-		&model.CreatedAt,
-		&model.UpdatedAt,
-	)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	args := append([]interface{}{model.Name, model.FormId, model.CreatedBy, model.TaskId}, values...)
+	return scanApprovingArtikel(tx.QueryRowContext(ctx, query, args...), model)
 }
 
 func (s *ApprovingArtikelStore) GetByID(ctx context.Context, id int64) (*ApprovingArtikel, error) {
 	query := `
-		SELECT id, name, form_id, properties, created_by, 
-		updated_by, created_at, updated_at
+		SELECT ` + approvingArtikelColumns + `
 		FROM approvingartikel
 		WHERE id = $1 AND deleted_at IS NULL
 	`
@@ -122,18 +96,7 @@ func (s *ApprovingArtikelStore) GetByID(ctx context.Context, id int64) (*Approvi
 	defer cancel()
 
 	var model ApprovingArtikel
-	var propertiesData []byte
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
-		&model.ID,
-		&model.Name,
-		&model.FormId,
-		&propertiesData,
-		&model.CreatedBy,
-		&model.UpdatedBy,
-		&model.CreatedAt,
-		&model.UpdatedAt,
-	)
-	if err != nil {
+	if err := scanApprovingArtikel(s.db.QueryRowContext(ctx, query, id), &model); err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return nil, ErrNotFound
@@ -141,16 +104,97 @@ func (s *ApprovingArtikelStore) GetByID(ctx context.Context, id int64) (*Approvi
 			return nil, err
 		}
 	}
-		
-	if len(propertiesData) > 0 {
-		if err := json.Unmarshal(propertiesData, &model.Properties); err != nil {
+
+	return &model, nil
+}
+
+func (s *ApprovingArtikelStore) Search(ctx context.Context, pq PaginatedQuery) (map[string]interface{}, error) {
+	sortOrder := "DESC"
+	if pq.Sort == "asc" || pq.Sort == "ASC" {
+		sortOrder = "ASC"
+	}
+
+	// filters are shared by the page and the count query
+	where := `
+		WHERE deleted_at IS NULL
+	`
+	var args []interface{}
+
+	if pq.Search != "" {
+		args = append(args, pq.Search)
+		search := "$" + strconv.Itoa(len(args))
+		where += `
+			AND (
+				name ILIKE '%' || ` + search + ` || '%' OR
+				task_id ILIKE '%' || ` + search + ` || '%' OR
+				decision ILIKE '%' || ` + search + ` || '%'
+			)
+		`
+	}
+
+	if pq.Since != "" && pq.Until != "" {
+		args = append(args, pq.Since, pq.Until)
+		where += `
+			AND created_at BETWEEN $` + strconv.Itoa(len(args)-1) + ` AND $` + strconv.Itoa(len(args))
+	}
+
+	query := `
+		SELECT ` + approvingArtikelColumns + `
+		FROM approvingartikel
+	` + where + `
+		ORDER BY created_at ` + sortOrder + `
+		LIMIT $` + strconv.Itoa(len(args)+1) + ` OFFSET $` + strconv.Itoa(len(args)+2)
+
+	params := append([]interface{}{}, args...)
+	params = append(params, pq.Limit, pq.Offset)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*ApprovingArtikel{}
+	for rows.Next() {
+		var model ApprovingArtikel
+		if err := scanApprovingArtikel(rows, &model); err != nil {
 			return nil, err
 		}
-	} else {
-		model.Properties = []string{}
+		results = append(results, &model)
 	}
 
-	return &model, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	countQuery := `
+		SELECT COUNT(*)
+		FROM approvingartikel
+	` + where
+
+	var totalCount int
+	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&totalCount); err != nil {
+		return nil, err
+	}
+
+	totalPages := (totalCount + pq.Limit - 1) / pq.Limit
+
+	response := map[string]interface{}{
+		"content":      results,
+		"totalElement": totalCount,
+		"totalPages":   totalPages,
+		"limit":        pq.Limit,
+		"offset":       pq.Offset,
+		"sort":         pq.Sort,
+		"search":       pq.Search,
+		"since":        pq.Since,
+		"until":        pq.Until,
+	}
+
+	return response, nil
 }
 
 func (s *ApprovingArtikelStore) delete(ctx context.Context, tx *sql.Tx, id int64) error {
@@ -177,44 +221,23 @@ func (s *ApprovingArtikelStore) delete(ctx context.Context, tx *sql.Tx, id int64
 }
 
 func (s *ApprovingArtikelStore) update(ctx context.Context, tx *sql.Tx, model *ApprovingArtikel) error {
-
-	if model.Properties == nil {
-		model.Properties = []string{}
+	values, err := model.values()
+	if err != nil {
+		return err
 	}
 
-	propertiesJSON, errProperties := json.Marshal(model.Properties)
-	if errProperties != nil {
-		return errProperties
-	}
 	query := `
 		UPDATE approvingartikel
-		SET name = $1, form_id = $2, properties = $3, updated_by = $4  updated_at = NOW()
-		WHERE id = $5 AND deleted_at IS NULL
-		RETURNING id, name, form_id, properties, created_by, updated_by, created_at, updated_at;
-	`
+		SET name = $1, form_id = $2, updated_by = $3, task_id = $4, properties = $5, decision = $6, updated_at = NOW()
+		WHERE id = $7 AND deleted_at IS NULL
+		RETURNING ` + approvingArtikelColumns
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	var propertiesData []byte
-	err := tx.QueryRowContext(
-		ctx,
-		query,
-		model.Name,
-		model.FormId,
-		propertiesJSON,
-		model.UpdatedBy,
-		model.ID,
-	).Scan(&model.ID, 
-		&model.Name, 
-		&model.FormId, 
-		propertiesData, 
-		&model.CreatedBy, 
-		&model.UpdatedBy, 
-		&model.CreatedAt, 
-		&model.UpdatedAt,
-	)
-	if err != nil {
+	args := append([]interface{}{model.Name, model.FormId, model.UpdatedBy, model.TaskId}, values...)
+	args = append(args, model.ID)
+	if err := scanApprovingArtikel(tx.QueryRowContext(ctx, query, args...), model); err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrNotFound
@@ -226,3 +249,48 @@ func (s *ApprovingArtikelStore) update(ctx context.Context, tx *sql.Tx, model *A
 	return nil
 }
 
+// values returns the properties and the form fields in the order of their
+// columns.
+func (model *ApprovingArtikel) values() ([]interface{}, error) {
+	if model.Properties == nil {
+		model.Properties = map[string]interface{}{}
+	}
+
+	propertiesJSON, err := json.Marshal(model.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{
+		propertiesJSON,
+		model.Decision,
+	}, nil
+}
+
+// scanApprovingArtikel reads a row of approvingArtikelColumns into the model.
+func scanApprovingArtikel(row interface{ Scan(...interface{}) error }, model *ApprovingArtikel) error {
+	var propertiesData []byte
+	if err := row.Scan(
+		&model.ID,
+		&model.Name,
+		&model.FormId,
+		&model.TaskId,
+		&propertiesData,
+		&model.CreatedBy,
+		&model.UpdatedBy,
+		&model.CreatedAt,
+		&model.UpdatedAt,
+		&model.Decision,
+	); err != nil {
+		return err
+	}
+
+	model.Properties = map[string]interface{}{}
+	if len(propertiesData) > 0 {
+		if err := json.Unmarshal(propertiesData, &model.Properties); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
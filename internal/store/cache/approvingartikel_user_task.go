@@ -15,7 +15,7 @@ type ApprovingArtikelStore struct {
 }
 
 const ApprovingArtikelExpTime = time.Hour * 24 * 7
-	
+
 func (s *ApprovingArtikelStore) Get(ctx context.Context, modelID int64) (*store.ApprovingArtikel, error) {
 	cacheKey := fmt.Sprintf("ApprovingArtikel-%v", modelID)
 
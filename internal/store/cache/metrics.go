@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"context"
+	"strings"
+
+	"github.com/damarteplok/social/internal/metrics"
+	"github.com/go-redis/redis/v8"
+)
+
+// metricsHook counts the cache lookups per store, every store prefixes its
+// keys with its name, e.g. user-1 for the Users store.
+type metricsHook struct{}
+
+func (metricsHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (metricsHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	if cmd.Name() != "get" || len(cmd.Args()) < 2 {
+		return nil
+	}
+
+	key, _ := cmd.Args()[1].(string)
+	store := key
+	if i := strings.LastIndex(key, "-"); i > 0 {
+		store = key[:i]
+	}
+
+	result := "hit"
+	switch err := cmd.Err(); {
+	case err == redis.Nil:
+		result = "miss"
+	case err != nil:
+		result = "error"
+	}
+	metrics.CacheRequests.WithLabelValues(store, result).Inc()
+
+	return nil
+}
+
+func (metricsHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (metricsHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
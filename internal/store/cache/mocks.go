@@ -2,13 +2,52 @@ package cache
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/damarteplok/social/internal/store"
 	"github.com/stretchr/testify/mock"
 )
 
 func NewMockStore() Storage {
-	return Storage{Users: &MockUserStore{}}
+	return Storage{Users: &MockUserStore{}, Tokens: &MockTokensStore{revoked: map[string]bool{}, users: map[int64]store.TokenRevocation{}}}
+}
+
+// MockTokensStore keeps the denylist in memory.
+type MockTokensStore struct {
+	mu      sync.Mutex
+	revoked map[string]bool
+	users   map[int64]store.TokenRevocation
+}
+
+func (m *MockTokensStore) Revoke(ctx context.Context, jti string, exp time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revoked[jti] = true
+	return nil
+}
+
+func (m *MockTokensStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.revoked[jti], nil
+}
+
+func (m *MockTokensStore) RevokeUser(ctx context.Context, userID int64, revocation store.TokenRevocation, exp time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[userID] = revocation
+	return nil
+}
+
+func (m *MockTokensStore) UserRevocation(ctx context.Context, userID int64) (*store.TokenRevocation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	revocation, ok := m.users[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &revocation, nil
 }
 
 type MockUserStore struct {
@@ -15,7 +15,7 @@ type PembuatanMediaBeritaTechnologyStore struct {
 }
 
 const PembuatanMediaBeritaTechnologyExpTime = time.Hour * 24 * 7
-	
+
 func (s *PembuatanMediaBeritaTechnologyStore) Get(ctx context.Context, modelID int64) (*store.PembuatanMediaBeritaTechnology, error) {
 	cacheKey := fmt.Sprintf("PembuatanMediaBeritaTechnology-%v", modelID)
 
@@ -15,7 +15,7 @@ type PembuatanArtikelStore struct {
 }
 
 const PembuatanArtikelExpTime = time.Hour * 24 * 7
-	
+
 func (s *PembuatanArtikelStore) Get(ctx context.Context, modelID int64) (*store.PembuatanArtikel, error) {
 	cacheKey := fmt.Sprintf("PembuatanArtikel-%v", modelID)
 
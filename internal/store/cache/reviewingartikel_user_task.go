@@ -15,7 +15,7 @@ type ReviewingArtikelStore struct {
 }
 
 const ReviewingArtikelExpTime = time.Hour * 24 * 7
-	
+
 func (s *ReviewingArtikelStore) Get(ctx context.Context, modelID int64) (*store.ReviewingArtikel, error) {
 	cacheKey := fmt.Sprintf("ReviewingArtikel-%v", modelID)
 
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"time"
 
 	"github.com/damarteplok/social/internal/store"
 	"github.com/go-redis/redis/v8"
@@ -13,6 +14,12 @@ type Storage struct {
 		Set(context.Context, *store.User) error
 		Delete(context.Context, int64)
 	}
+	Tokens interface {
+		Revoke(ctx context.Context, jti string, exp time.Duration) error
+		IsRevoked(ctx context.Context, jti string) (bool, error)
+		RevokeUser(ctx context.Context, userID int64, revocation store.TokenRevocation, exp time.Duration) error
+		UserRevocation(ctx context.Context, userID int64) (*store.TokenRevocation, error)
+	}
 	// GENERATED CACHE CODE INTERFACE
 
 	PembuatanMediaBeritaTechnology interface {
@@ -45,6 +52,9 @@ func NewRedisStorage(rbd *redis.Client) Storage {
 		Users: &UsersStore{
 			rdb: rbd,
 		},
+		Tokens: &TokensStore{
+			rdb: rbd,
+		},
 		// GENERATED CACHE CODE CONSTRUCTOR
 
 		PembuatanMediaBeritaTechnology: &PembuatanMediaBeritaTechnologyStore{
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/damarteplok/social/internal/store"
+	"github.com/go-redis/redis/v8"
+)
+
+// secondsBefore tells a revocation in seconds from one in milliseconds, a
+// millisecond value is past it since 1973.
+const secondsBefore = 100_000_000_000
+
+// TokensStore is the denylist of the access tokens, an entry lives as long
+// as the tokens it revokes.
+type TokensStore struct {
+	rdb *redis.Client
+}
+
+func (s *TokensStore) Revoke(ctx context.Context, jti string, exp time.Duration) error {
+	if exp <= 0 {
+		return nil
+	}
+	cacheKey := fmt.Sprintf("revoked-jti-%s", jti)
+	return s.rdb.SetEX(ctx, cacheKey, 1, exp).Err()
+}
+
+func (s *TokensStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	cacheKey := fmt.Sprintf("revoked-jti-%s", jti)
+
+	n, err := s.rdb.Exists(ctx, cacheKey).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// RevokeUser revokes the access tokens of the user, the time is kept to the
+// millisecond like the iat of the tokens.
+func (s *TokensStore) RevokeUser(ctx context.Context, userID int64, revocation store.TokenRevocation, exp time.Duration) error {
+	cacheKey := fmt.Sprintf("revoked-user-%d", userID)
+
+	data, err := json.Marshal(redisRevocation{At: revocation.At.UnixMilli(), SessionID: revocation.SessionID})
+	if err != nil {
+		return err
+	}
+	return s.rdb.SetEX(ctx, cacheKey, data, exp).Err()
+}
+
+// UserRevocation returns the last revocation of the tokens of the user, nil
+// if they never were revoked.
+func (s *TokensStore) UserRevocation(ctx context.Context, userID int64) (*store.TokenRevocation, error) {
+	cacheKey := fmt.Sprintf("revoked-user-%d", userID)
+
+	data, err := s.rdb.Get(ctx, cacheKey).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// the revocations written before were the time alone, in seconds or in
+	// milliseconds
+	if unix, err := strconv.ParseInt(data, 10, 64); err == nil {
+		if unix < secondsBefore {
+			return &store.TokenRevocation{At: time.Unix(unix, 0)}, nil
+		}
+		return &store.TokenRevocation{At: time.UnixMilli(unix)}, nil
+	}
+
+	var revocation redisRevocation
+	if err := json.Unmarshal([]byte(data), &revocation); err != nil {
+		return nil, err
+	}
+	return &store.TokenRevocation{At: time.UnixMilli(revocation.At), SessionID: revocation.SessionID}, nil
+}
+
+type redisRevocation struct {
+	At        int64  `json:"at"`
+	SessionID string `json:"sid,omitempty"`
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/damarteplok/social/internal/tracing"
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingHook wraps every redis command in a span of the context it runs
+// with, the span name is the command such as redis get.
+type tracingHook struct{}
+
+func (tracingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	attrs := []attribute.KeyValue{attribute.String("db.system", "redis")}
+	if len(cmd.Args()) > 1 {
+		if key, ok := cmd.Args()[1].(string); ok {
+			attrs = append(attrs, attribute.String("db.redis.key", key))
+		}
+	}
+
+	ctx, _ = tracing.Start(ctx, "redis "+cmd.Name(), attrs...)
+	return ctx, nil
+}
+
+func (tracingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	err := cmd.Err()
+	if err == redis.Nil {
+		// a miss is not an error of the command
+		err = nil
+	}
+	tracing.End(trace.SpanFromContext(ctx), err)
+
+	return nil
+}
+
+func (tracingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	ctx, _ = tracing.Start(ctx, "redis pipeline", attribute.String("db.system", "redis"), attribute.Int("db.redis.commands", len(cmds)))
+	return ctx, nil
+}
+
+func (tracingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmd.Err() != nil && cmd.Err() != redis.Nil {
+			err = cmd.Err()
+			break
+		}
+	}
+	tracing.End(trace.SpanFromContext(ctx), err)
+
+	return nil
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"sync"
 	"time"
 )
 
@@ -11,7 +12,287 @@ func NewMockStore() Storage {
 		Users: &MockUserStore{
 			users: []User{},
 		},
+		Roles: &MockRolesStore{
+			twoFactorRequired: map[string]bool{"moderator": true, "admin": true},
+		},
+		RefreshTokens: &MockRefreshTokenStore{tokens: map[string]*RefreshToken{}},
+		TwoFactor: &MockTwoFactorStore{
+			enrollments: map[int64]*mockTwoFactor{},
+			challenges:  map[string]*mockChallenge{},
+		},
+	}
+}
+
+// MockTwoFactorStore keeps the enrollments in memory with the rules of
+// TwoFactorStore.
+type MockTwoFactorStore struct {
+	mu          sync.Mutex
+	enrollments map[int64]*mockTwoFactor
+	challenges  map[string]*mockChallenge
+}
+
+type mockChallenge struct {
+	userID    int64
+	failed    int
+	used      bool
+	expiresAt time.Time
+	createdAt time.Time
+}
+
+type mockTwoFactor struct {
+	TwoFactor
+	recoveryCodes map[string]bool
+}
+
+func (m *MockTwoFactorStore) Get(ctx context.Context, userID int64) (*TwoFactor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tf, ok := m.enrollments[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := tf.TwoFactor
+	return &found, nil
+}
+
+func (m *MockTwoFactorStore) SetSecret(ctx context.Context, userID int64, secret []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if tf, ok := m.enrollments[userID]; ok && tf.Enabled {
+		return ErrConflict
+	}
+	m.enrollments[userID] = &mockTwoFactor{TwoFactor: TwoFactor{UserID: userID, Secret: secret, CreatedAt: time.Now()}}
+	return nil
+}
+
+func (m *MockTwoFactorStore) Enable(ctx context.Context, userID, step int64, recoveryCodes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tf, ok := m.enrollments[userID]
+	if !ok || tf.Enabled || tf.LastStep >= step {
+		return ErrNotFound
+	}
+	tf.Enabled = true
+	tf.LastStep = step
+	tf.recoveryCodes = mockRecoveryCodes(recoveryCodes)
+	return nil
+}
+
+func (m *MockTwoFactorStore) UseStep(ctx context.Context, userID, step int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tf, ok := m.enrollments[userID]
+	if !ok || !tf.Enabled || tf.LastStep >= step {
+		return ErrNotFound
+	}
+	tf.LastStep = step
+	return nil
+}
+
+func (m *MockTwoFactorStore) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tf, ok := m.enrollments[userID]
+	if !ok || !tf.recoveryCodes[code] {
+		return ErrNotFound
+	}
+	tf.recoveryCodes[code] = false
+	return nil
+}
+
+func (m *MockTwoFactorStore) ReplaceRecoveryCodes(ctx context.Context, userID int64, recoveryCodes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if tf, ok := m.enrollments[userID]; ok {
+		tf.recoveryCodes = mockRecoveryCodes(recoveryCodes)
+	}
+	return nil
+}
+
+func (m *MockTwoFactorStore) Disable(ctx context.Context, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.enrollments, userID)
+	return nil
+}
+
+func (m *MockTwoFactorStore) CreateChallenge(ctx context.Context, userID int64, jti string, exp time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.challenges[jti] = &mockChallenge{userID: userID, expiresAt: now.Add(exp), createdAt: now}
+	return nil
+}
+
+func (m *MockTwoFactorStore) AttemptChallenge(ctx context.Context, userID int64, jti string, maxFailures int, window time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.challenges[jti]
+	if !ok || c.userID != userID || c.used || !c.expiresAt.After(time.Now()) {
+		return ErrNotFound
 	}
+
+	failures := 0
+	since := time.Now().Add(-window)
+	for _, other := range m.challenges {
+		if other.userID == userID && other.createdAt.After(since) {
+			failures += other.failed
+		}
+	}
+	if failures >= maxFailures {
+		return ErrTooManyAttempts
+	}
+	c.failed++
+	return nil
+}
+
+func (m *MockTwoFactorStore) UseChallenge(ctx context.Context, jti string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.challenges[jti]
+	if !ok || c.used {
+		return ErrNotFound
+	}
+	c.used = true
+	c.failed = max(c.failed-1, 0)
+	return nil
+}
+
+func (m *MockTwoFactorStore) DeleteExpiredChallenges(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	for jti, c := range m.challenges {
+		if c.expiresAt.Before(before) {
+			delete(m.challenges, jti)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func mockRecoveryCodes(codes []string) map[string]bool {
+	unused := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		unused[code] = true
+	}
+	return unused
+}
+
+// MockRefreshTokenStore keeps the tokens in memory with the rotation rules
+// of RefreshTokenStore.
+type MockRefreshTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]*RefreshToken
+}
+
+func (m *MockRefreshTokenStore) Create(ctx context.Context, token string, rt *RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.create(token, rt)
+	return nil
+}
+
+func (m *MockRefreshTokenStore) Rotate(ctx context.Context, token, next string, exp time.Duration) (*RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.tokens[token]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if current.RevokedAt != nil {
+		m.revoke(func(rt *RefreshToken) bool { return rt.SessionID == current.SessionID })
+		return nil, ErrTokenReused
+	}
+	if !current.Expiry.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+
+	now := time.Now()
+	current.RevokedAt = &now
+	rotated := &RefreshToken{UserID: current.UserID, SessionID: current.SessionID, Expiry: now.Add(exp)}
+	m.create(next, rotated)
+	return rotated, nil
+}
+
+func (m *MockRefreshTokenStore) RevokeSession(ctx context.Context, userID int64, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.revoke(func(rt *RefreshToken) bool { return rt.UserID == userID && rt.SessionID == sessionID })
+	return nil
+}
+
+func (m *MockRefreshTokenStore) RevokeAllForUser(ctx context.Context, userID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.revoke(func(rt *RefreshToken) bool { return rt.UserID == userID })
+	return nil
+}
+
+func (m *MockRefreshTokenStore) create(token string, rt *RefreshToken) {
+	rt.ID = int64(len(m.tokens) + 1)
+	rt.CreatedAt = time.Now()
+	m.tokens[token] = rt
+}
+
+func (m *MockRefreshTokenStore) revoke(match func(*RefreshToken) bool) {
+	now := time.Now()
+	for _, rt := range m.tokens {
+		if rt.RevokedAt == nil && match(rt) {
+			rt.RevokedAt = &now
+		}
+	}
+}
+
+type MockRolesStore struct {
+	mu                sync.Mutex
+	twoFactorRequired map[string]bool
+}
+
+// GetByName returns the roles seeded by the roles migration.
+func (m *MockRolesStore) GetByName(ctx context.Context, name string) (*Role, error) {
+	levels := map[string]int64{
+		"user":      1,
+		"moderator": 2,
+		"admin":     3,
+	}
+
+	level, ok := levels[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return &Role{Name: name, Level: level, TwoFactorRequired: m.twoFactorRequired[name]}, nil
+}
+
+func (m *MockRolesStore) SetTwoFactorRequired(ctx context.Context, name string, required bool) (*Role, error) {
+	if _, err := m.GetByName(ctx, name); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.twoFactorRequired[name] = required
+	m.mu.Unlock()
+
+	return m.GetByName(ctx, name)
 }
 
 type MockUserStore struct {
@@ -49,3 +330,57 @@ func (m *MockUserStore) Activate(ctx context.Context, token string) error {
 func (m *MockUserStore) Delete(ctx context.Context, userID int64) error {
 	return nil
 }
+
+func (m *MockUserStore) CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	return nil
+}
+
+func (m *MockUserStore) ResetPassword(ctx context.Context, token string, user *User) error {
+	return nil
+}
+
+func (m *MockUserStore) UpdatePassword(ctx context.Context, user *User) error {
+	return nil
+}
+
+func (m *MockUserStore) CreateEmailChange(ctx context.Context, userID int64, email, token string, exp time.Duration) error {
+	return nil
+}
+
+func (m *MockUserStore) ChangeEmail(ctx context.Context, token string) (*User, error) {
+	return &User{}, nil
+}
+
+func (m *MockUserStore) CreateTwoFactorEnrollment(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	return nil
+}
+
+func (m *MockUserStore) ConfirmTwoFactorEnrollment(ctx context.Context, token string) (*User, error) {
+	return &User{}, nil
+}
+
+func (m *MockUserStore) RenewInvitation(ctx context.Context, email, token string, exp time.Duration) (*User, error) {
+	return &User{Email: email}, nil
+}
+
+func (m *MockUserStore) ActivateByID(ctx context.Context, userID int64) error {
+	return nil
+}
+
+func (m *MockUserStore) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockUserStore) DeleteInactive(ctx context.Context, createdBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockUserStore) GetByIdentity(ctx context.Context, issuer, subject string) (*User, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockUserStore) ProvisionIdentity(ctx context.Context, issuer, subject string, user *User, linkEmail bool) (*User, error) {
+	provisioned := *user
+	provisioned.IsActive = true
+	return &provisioned, nil
+}
@@ -0,0 +1,295 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	OutboxStartProcess  = "start_process"
+	OutboxCancelProcess = "cancel_process"
+)
+
+// OutboxMessage is a zeebe command written in the same transaction as the
+// row it belongs to and sent by the outbox dispatcher until it succeeds.
+// CreatedBy is the user the idempotency key belongs to, 0 for the commands of
+// the api. Attempts counts the claims of the message, the current one
+// included, and LastAttemptAt is when it was claimed before, nil on its first
+// attempt.
+type OutboxMessage struct {
+	ID             int64           `json:"id"`
+	IdempotencyKey string          `json:"idempotency_key"`
+	CreatedBy      int64           `json:"created_by"`
+	Command        string          `json:"command"`
+	TableName      string          `json:"table_name"`
+	RowID          int64           `json:"row_id"`
+	Payload        json.RawMessage `json:"payload"`
+	Attempts       int             `json:"attempts"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at"`
+	LastError      *string         `json:"last_error"`
+	ProcessedAt    *string         `json:"processed_at"`
+	CreatedAt      string          `json:"created_at"`
+}
+
+type StartProcessPayload struct {
+	ProcessDefinitionKey int64                  `json:"process_definition_key"`
+	Variables            map[string]interface{} `json:"variables"`
+}
+
+// InstanceKey identifies the process instance of a start command across
+// users. The keys of the api are unique already and kept as they are.
+func (m *OutboxMessage) InstanceKey() string {
+	if m.CreatedBy == 0 {
+		return m.IdempotencyKey
+	}
+	return fmt.Sprintf("%d:%s", m.CreatedBy, m.IdempotencyKey)
+}
+
+type OutboxStore struct {
+	db *sql.DB
+}
+
+func createOutboxMessage(ctx context.Context, tx *sql.Tx, msg *OutboxMessage) error {
+	query := `
+		INSERT INTO outbox (idempotency_key, created_by, command, table_name, row_id, payload)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, attempts, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	payload := msg.Payload
+	if payload == nil {
+		payload = json.RawMessage("{}")
+	}
+
+	err := tx.QueryRowContext(
+		ctx,
+		query,
+		msg.IdempotencyKey,
+		msg.CreatedBy,
+		msg.Command,
+		msg.TableName,
+		msg.RowID,
+		payload,
+	).Scan(
+		&msg.ID,
+		&msg.Attempts,
+		&msg.CreatedAt,
+	)
+	if err != nil {
+		// the idempotency key of the user is the only unique column
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetByIdempotencyKey returns the message of the key of a user, the keys of
+// other users are not found.
+func (s *OutboxStore) GetByIdempotencyKey(ctx context.Context, createdBy int64, key string) (*OutboxMessage, error) {
+	query := `
+		SELECT id, idempotency_key, created_by, command, table_name, row_id, payload,
+			attempts, last_error, processed_at, created_at
+		FROM outbox
+		WHERE created_by = $1 AND idempotency_key = $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var msg OutboxMessage
+	var payload []byte
+	err := s.db.QueryRowContext(ctx, query, createdBy, key).Scan(
+		&msg.ID,
+		&msg.IdempotencyKey,
+		&msg.CreatedBy,
+		&msg.Command,
+		&msg.TableName,
+		&msg.RowID,
+		&payload,
+		&msg.Attempts,
+		&msg.LastError,
+		&msg.ProcessedAt,
+		&msg.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	msg.Payload = payload
+
+	return &msg, nil
+}
+
+// ClaimPending returns the messages due for sending and hides them from other
+// dispatchers for the lease duration. A claim counts as an attempt, so a
+// dispatcher that stops before acknowledging a message still leaves a trace
+// of the attempt.
+func (s *OutboxStore) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]OutboxMessage, error) {
+	query := `
+		WITH due AS (
+			SELECT id, last_attempt_at FROM outbox
+			WHERE processed_at IS NULL AND dead_at IS NULL AND next_attempt_at <= NOW()
+			ORDER BY id ASC
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE outbox o
+		SET attempts = o.attempts + 1, last_attempt_at = NOW(),
+			next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM due
+		WHERE o.id = due.id
+		RETURNING o.id, o.idempotency_key, o.created_by, o.command, o.table_name, o.row_id, o.payload,
+			o.attempts, due.last_attempt_at, o.last_error, o.processed_at, o.created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []OutboxMessage
+	for rows.Next() {
+		var msg OutboxMessage
+		var payload []byte
+		if err := rows.Scan(
+			&msg.ID,
+			&msg.IdempotencyKey,
+			&msg.CreatedBy,
+			&msg.Command,
+			&msg.TableName,
+			&msg.RowID,
+			&payload,
+			&msg.Attempts,
+			&msg.LastAttemptAt,
+			&msg.LastError,
+			&msg.ProcessedAt,
+			&msg.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		msg.Payload = payload
+		messages = append(messages, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// keep the order of the commands of a row, a cancel must follow its start
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].ID < messages[j].ID
+	})
+
+	return messages, nil
+}
+
+// GetProcessInstanceKey returns 0 while the start command of the row is not
+// acknowledged yet. Deleted rows are included so they can still be canceled.
+func (s *OutboxStore) GetProcessInstanceKey(ctx context.Context, tableName string, rowID int64) (int64, error) {
+	query := fmt.Sprintf(`
+		SELECT COALESCE(process_instance_key, 0) FROM %s WHERE id = $1
+	`, pq.QuoteIdentifier(tableName))
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var processInstanceKey int64
+	err := s.db.QueryRowContext(ctx, query, rowID).Scan(&processInstanceKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return processInstanceKey, nil
+}
+
+// MarkStarted stores the process instance key on the row and acknowledges the
+// start command in one transaction.
+func (s *OutboxStore) MarkStarted(ctx context.Context, msg *OutboxMessage, processInstanceKey int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := fmt.Sprintf(`
+			UPDATE %s SET process_instance_key = $1, updated_at = NOW() WHERE id = $2
+		`, pq.QuoteIdentifier(msg.TableName))
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, query, processInstanceKey, msg.RowID); err != nil {
+			return err
+		}
+
+		return markProcessed(ctx, tx, msg.ID)
+	})
+}
+
+func (s *OutboxStore) MarkProcessed(ctx context.Context, id int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return markProcessed(ctx, tx, id)
+	})
+}
+
+func markProcessed(ctx context.Context, tx *sql.Tx, id int64) error {
+	query := `UPDATE outbox SET processed_at = NOW(), last_error = NULL WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, id)
+	return err
+}
+
+// MarkFailed schedules the next attempt of a message, the attempt was counted
+// when it was claimed.
+func (s *OutboxStore) MarkFailed(ctx context.Context, id int64, lastError string, retryAfter time.Duration) error {
+	query := `
+		UPDATE outbox
+		SET last_error = $1, next_attempt_at = NOW() + make_interval(secs => $2)
+		WHERE id = $3
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, lastError, retryAfter.Seconds(), id)
+	return err
+}
+
+// MarkDead stops the attempts of a message, it is kept with its last error
+// until it is fixed by hand.
+func (s *OutboxStore) MarkDead(ctx context.Context, id int64, lastError string) error {
+	query := `UPDATE outbox SET dead_at = NOW(), last_error = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, lastError, id)
+	return err
+}
@@ -13,6 +13,7 @@ type PaginatedQuery struct {
 	Offset int    `json:"offset" validate:"gte=0"`
 	Sort   string `json:"sort" validate:"oneof=asc desc"`
 	Search string `json:"search" validate:"max=100"`
+	State  string `json:"state" validate:"omitempty,oneof=CREATED COMPLETED CANCELED FAILED"`
 	Since  string `json:"since"`
 	Until  string `json:"until"`
 }
@@ -63,6 +64,11 @@ func (pq *PaginatedQuery) Parse(r *http.Request) error {
 		pq.Search = search
 	}
 
+	state := qs.Get("state")
+	if state != "" {
+		pq.State = strings.ToUpper(state)
+	}
+
 	since := qs.Get("since")
 	if since != "" {
 		pq.Since = parseTime(since)
@@ -4,12 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strconv"
 )
 
 const (
 	PembuatanMediaBeritaTechnologyVersion              = 1
 	PembuatanMediaBeritaTechnologyProcessDefinitionKey = 2251799814076217
 	PembuatanMediaBeritaTechnologyResourceName         = "pembuatan_media_berita_technology.bpmn"
+	PembuatanMediaBeritaTechnologyTableName            = "pembuatan_media_berita_technology"
 )
 
 // TODO: UPDATE THIS STRUCT AND CODE BELOW
@@ -72,10 +74,10 @@ func (s *PembuatanMediaBeritaTechnologyStore) create(ctx context.Context, tx *sq
 			$1, 
 			$2, 
 			$3,
-			$4,
+			NULLIF($4, 0),
 			$5
 		) RETURNING 
-		 	id, process_definition_key, version, resource_name, process_instance_key, created_by, updated_by,
+		 	id, process_definition_key, version, resource_name, COALESCE(process_instance_key, 0), created_by, updated_by,
 			created_at, updated_at
 		`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -110,7 +112,7 @@ func (s *PembuatanMediaBeritaTechnologyStore) create(ctx context.Context, tx *sq
 func (s *PembuatanMediaBeritaTechnologyStore) GetByID(ctx context.Context, id int64) (*PembuatanMediaBeritaTechnology, error) {
 	query := `
 		SELECT id, process_definition_key, version, 
-			resource_name, process_instance_key, 
+			resource_name, COALESCE(process_instance_key, 0),
 			task_definition_id, task_state,
 			created_by, updated_by, created_at, updated_at
 		FROM pembuatan_media_berita_technology
@@ -174,14 +176,14 @@ func (s *PembuatanMediaBeritaTechnologyStore) update(ctx context.Context, tx *sq
 		SET process_definition_key = $1, 
 			version = $2, 
 			resource_name = $3, 
-			process_instance_key = $4, 
+			process_instance_key = COALESCE(NULLIF($4, 0), process_instance_key),
 			updated_by = $5, 
 			updated_at = NOW()
 		WHERE id = $6 AND deleted_at IS NULL
 		RETURNING id, process_definition_key, 
 			version, 
 			resource_name, 
-			process_instance_key, 
+			COALESCE(process_instance_key, 0),
 			created_by, 
 			updated_by, 
 			created_at, updated_at
@@ -223,53 +225,57 @@ func (s *PembuatanMediaBeritaTechnologyStore) update(ctx context.Context, tx *sq
 
 func (s *PembuatanMediaBeritaTechnologyStore) Search(ctx context.Context, pq PaginatedQuery) (map[string]interface{}, error) {
 	sortOrder := "DESC"
-	if pq.Sort == "desc" || pq.Sort == "DESC" {
-		sortOrder = "DESC"
-	}
 	if pq.Sort == "asc" || pq.Sort == "ASC" {
 		sortOrder = "ASC"
 	}
 
-	// Base Query
-	query := `
-        SELECT p.id, p.process_definition_key, p.version,
-            p.resource_name, p.process_instance_key,
-            p.task_definition_id, p.task_state,
-            p.created_by, p.updated_by, p.created_at, p.updated_at
-        FROM pembuatan_media_berita_technology p
-        LEFT JOIN users u ON p.created_by = u.id
+	// filters are shared by the page and the count query
+	where := `
         WHERE p.deleted_at IS NULL
     `
-
-	var params []interface{}
-	params = append(params, pq.Limit, pq.Offset)
+	var args []interface{}
 
 	if pq.Search != "" {
-		query += `
+		args = append(args, pq.Search)
+		search := "$" + strconv.Itoa(len(args))
+		where += `
 			AND (
-				p.process_definition_key::text ILIKE '%' || $3 || '%' OR 
-				p.resource_name ILIKE '%' || $3 || '%' OR
-				p.process_instance_key::text ILIKE '%' || $3 || '%' OR
-				p.task_definition_id::text ILIKE '%' || $3 || '%' OR
-				p.task_state ILIKE '%' || $3 || '%' OR
-				u.email ILIKE '%' || $3 || '%' OR
-				u.username ILIKE '%' || $3 || '%'
+				p.process_definition_key::text ILIKE '%' || ` + search + ` || '%' OR
+				p.resource_name ILIKE '%' || ` + search + ` || '%' OR
+				p.process_instance_key::text ILIKE '%' || ` + search + ` || '%' OR
+				p.task_definition_id::text ILIKE '%' || ` + search + ` || '%' OR
+				p.task_state ILIKE '%' || ` + search + ` || '%' OR
+				u.email ILIKE '%' || ` + search + ` || '%' OR
+				u.username ILIKE '%' || ` + search + ` || '%'
 			)
 		`
-		params = append(params, pq.Search)
+	}
+
+	if pq.State != "" {
+		args = append(args, pq.State)
+		where += `
+			AND p.task_state = $` + strconv.Itoa(len(args))
 	}
 
 	if pq.Since != "" && pq.Until != "" {
-		query += `
-            AND p.created_at BETWEEN $4 AND $5
-        `
-		params = append(params, pq.Since, pq.Until)
+		args = append(args, pq.Since, pq.Until)
+		where += `
+			AND p.created_at BETWEEN $` + strconv.Itoa(len(args)-1) + ` AND $` + strconv.Itoa(len(args))
 	}
 
-	query += `
+	query := `
+        SELECT p.id, p.process_definition_key, p.version,
+            p.resource_name, COALESCE(p.process_instance_key, 0),
+            p.task_definition_id, p.task_state,
+            p.created_by, p.updated_by, p.created_at, p.updated_at
+        FROM pembuatan_media_berita_technology p
+        LEFT JOIN users u ON p.created_by = u.id
+    ` + where + `
         ORDER BY p.created_at ` + sortOrder + `
-        LIMIT $1 OFFSET $2
-    `
+        LIMIT $` + strconv.Itoa(len(args)+1) + ` OFFSET $` + strconv.Itoa(len(args)+2)
+
+	params := append([]interface{}{}, args...)
+	params = append(params, pq.Limit, pq.Offset)
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
@@ -309,23 +315,7 @@ func (s *PembuatanMediaBeritaTechnologyStore) Search(ctx context.Context, pq Pag
         SELECT COUNT(*)
         FROM pembuatan_media_berita_technology p
         LEFT JOIN users u ON p.created_by = u.id
-        WHERE p.deleted_at IS NULL
-    `
-	var args []interface{}
-	if pq.Search != "" {
-		countQuery += `
-            AND (
-                p.process_definition_key::text ILIKE '%' || $1 || '%' OR 
-                p.resource_name ILIKE '%' || $1 || '%' OR
-                p.process_instance_key::text ILIKE '%' || $1 || '%' OR
-                p.task_definition_id::text ILIKE '%' || $1 || '%' OR
-                p.task_state ILIKE '%' || $1 || '%' OR
-                u.email ILIKE '%' || $1 || '%' OR
-                u.username ILIKE '%' || $1 || '%'
-            )
-        `
-		args = append(args, pq.Search)
-	}
+    ` + where
 
 	var totalCount int
 	err = s.db.QueryRowContext(ctx, countQuery, args...).Scan(&totalCount)
@@ -343,9 +333,108 @@ func (s *PembuatanMediaBeritaTechnologyStore) Search(ctx context.Context, pq Pag
 		"offset":       pq.Offset,
 		"sort":         pq.Sort,
 		"search":       pq.Search,
+		"state":        pq.State,
 		"since":        pq.Since,
 		"until":        pq.Until,
 	}
 
 	return response, nil
 }
+
+// GetRunning returns the rows whose process instance can still change state,
+// ordered by id so they can be paged through with afterID. FAILED rows are
+// included, they go back to CREATED once the incidents are resolved.
+func (s *PembuatanMediaBeritaTechnologyStore) GetRunning(ctx context.Context, afterID int64, limit int) ([]ProcessInstanceState, error) {
+	query := `
+		SELECT id, process_instance_key, task_definition_id, task_state
+		FROM pembuatan_media_berita_technology
+		WHERE deleted_at IS NULL AND process_instance_key IS NOT NULL
+			AND task_state IN ('CREATED', 'FAILED') AND id > $1
+		ORDER BY id ASC
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ProcessInstanceState
+	for rows.Next() {
+		var item ProcessInstanceState
+		if err := rows.Scan(
+			&item.ID,
+			&item.ProcessInstanceKey,
+			&item.TaskDefinitionId,
+			&item.TaskState,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (s *PembuatanMediaBeritaTechnologyStore) UpdateState(ctx context.Context, state *ProcessInstanceState) error {
+	query := `
+		UPDATE pembuatan_media_berita_technology
+		SET task_definition_id = $1, task_state = $2, updated_at = NOW()
+		WHERE id = $3 AND deleted_at IS NULL
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, state.TaskDefinitionId, state.TaskState, state.ID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CreateWithOutbox inserts the row and the command starting its process
+// instance in one transaction.
+func (s *PembuatanMediaBeritaTechnologyStore) CreateWithOutbox(ctx context.Context, model *PembuatanMediaBeritaTechnology, msg *OutboxMessage) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.create(ctx, tx, model); err != nil {
+			return err
+		}
+
+		msg.TableName = PembuatanMediaBeritaTechnologyTableName
+		msg.RowID = model.ID
+		return createOutboxMessage(ctx, tx, msg)
+	})
+}
+
+// DeleteWithOutbox deletes the row and inserts the command canceling its
+// process instance in one transaction.
+func (s *PembuatanMediaBeritaTechnologyStore) DeleteWithOutbox(ctx context.Context, id int64, msg *OutboxMessage) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.delete(ctx, tx, id); err != nil {
+			return err
+		}
+
+		msg.TableName = PembuatanMediaBeritaTechnologyTableName
+		msg.RowID = id
+		return createOutboxMessage(ctx, tx, msg)
+	})
+}
@@ -5,10 +5,12 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"strconv"
 )
 
 const (
 	PembuatanArtikelID             = "creating_artikel"
+	PembuatanArtikelProcessID      = "pembuatan_media_berita_technology"
 	PembuatanArtikelName           = "Pembuatan Artikel"
 	PembuatanArtikelFormID         = "creating_artikel_form"
 	PembuatanArtikelAssignee       = ""
@@ -17,17 +19,25 @@ const (
 	PembuatanArtikelSchedule       = ``
 )
 
+// pembuatanArtikelColumns are read by scanPembuatanArtikel in this order
+const pembuatanArtikelColumns = `id, name, form_id, task_id, properties, created_by, updated_by, created_at, updated_at, title, content, version, tags, decision`
+
 type PembuatanArtikel struct {
-	ID         int64    `json:"id"`
-	Name       string   `json:"name"`
-	TaskId     string   `json:"task_id"`
-	FormId     string   `json:"form_id"`
-	Properties []string `json:"properties"`
-	CreatedBy  int64    `json:"created_by"`
-	UpdatedBy  *int64   `json:"updated_by"`
-	CreatedAt  string   `json:"created_at"`
-	UpdatedAt  string   `json:"updated_at"`
-	DeletedAt  *string  `json:"deleted_at"`
+	ID         int64                  `json:"id"`
+	Name       string                 `json:"name"`
+	TaskId     string                 `json:"task_id"`
+	FormId     string                 `json:"form_id"`
+	Properties map[string]interface{} `json:"properties"`
+	CreatedBy  int64                  `json:"created_by"`
+	UpdatedBy  *int64                 `json:"updated_by"`
+	CreatedAt  string                 `json:"created_at"`
+	UpdatedAt  string                 `json:"updated_at"`
+	DeletedAt  *string                `json:"deleted_at"`
+	Title      *string                `json:"title"`
+	Content    *string                `json:"content"`
+	Version    *float64               `json:"version"`
+	Tags       *string                `json:"tags"`
+	Decision   *string                `json:"decision"`
 }
 
 type PembuatanArtikelStore struct {
@@ -62,62 +72,26 @@ func (s *PembuatanArtikelStore) Update(ctx context.Context, model *PembuatanArti
 }
 
 func (s *PembuatanArtikelStore) create(ctx context.Context, tx *sql.Tx, model *PembuatanArtikel) error {
-	if model.Properties == nil {
-		model.Properties = []string{}
-	}
-
-	propertiesJSON, errProperties := json.Marshal(model.Properties)
-	if errProperties != nil {
-		return errProperties
+	values, err := model.values()
+	if err != nil {
+		return err
 	}
 
 	query := `
-		INSERT INTO pembuatanartikel (name, form_id, properties, created_by, task_id)
-		VALUES (
-			$1,
-			$2,
-			$3,
-			$4,
-			$5
-		) RETURNING 
-		 	id, name, form_id, task_id, properties, created_by, updated_by,
-			created_at, updated_at
-		`
+		INSERT INTO pembuatanartikel (name, form_id, created_by, task_id, properties, title, content, version, tags, decision)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING ` + pembuatanArtikelColumns
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	var propertiesData []byte
-	err := tx.QueryRowContext(
-		ctx,
-		query,
-		model.Name,
-		model.FormId,
-		propertiesJSON,
-		model.CreatedBy,
-		model.UpdatedAt,
-		model.TaskId,
-	).Scan(
-		&model.ID,
-		&model.Name,
-		&model.FormId,
-		&model.TaskId,
-		&propertiesData,
-		&model.CreatedBy,
-		&model.UpdatedBy,
-		&model.CreatedAt,
-		&model.UpdatedAt,
-	)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	args := append([]interface{}{model.Name, model.FormId, model.CreatedBy, model.TaskId}, values...)
+	return scanPembuatanArtikel(tx.QueryRowContext(ctx, query, args...), model)
 }
 
 func (s *PembuatanArtikelStore) GetByID(ctx context.Context, id int64) (*PembuatanArtikel, error) {
 	query := `
-		SELECT id, name, form_id, task_id, properties, created_by,
-		updated_by, created_at, updated_at
+		SELECT ` + pembuatanArtikelColumns + `
 		FROM pembuatanartikel
 		WHERE id = $1 AND deleted_at IS NULL
 	`
@@ -126,19 +100,7 @@ func (s *PembuatanArtikelStore) GetByID(ctx context.Context, id int64) (*Pembuat
 	defer cancel()
 
 	var model PembuatanArtikel
-	var propertiesData []byte
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
-		&model.ID,
-		&model.Name,
-		&model.FormId,
-		&model.TaskId,
-		&propertiesData,
-		&model.CreatedBy,
-		&model.UpdatedBy,
-		&model.CreatedAt,
-		&model.UpdatedAt,
-	)
-	if err != nil {
+	if err := scanPembuatanArtikel(s.db.QueryRowContext(ctx, query, id), &model); err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return nil, ErrNotFound
@@ -147,15 +109,99 @@ func (s *PembuatanArtikelStore) GetByID(ctx context.Context, id int64) (*Pembuat
 		}
 	}
 
-	if len(propertiesData) > 0 {
-		if err := json.Unmarshal(propertiesData, &model.Properties); err != nil {
+	return &model, nil
+}
+
+func (s *PembuatanArtikelStore) Search(ctx context.Context, pq PaginatedQuery) (map[string]interface{}, error) {
+	sortOrder := "DESC"
+	if pq.Sort == "asc" || pq.Sort == "ASC" {
+		sortOrder = "ASC"
+	}
+
+	// filters are shared by the page and the count query
+	where := `
+		WHERE deleted_at IS NULL
+	`
+	var args []interface{}
+
+	if pq.Search != "" {
+		args = append(args, pq.Search)
+		search := "$" + strconv.Itoa(len(args))
+		where += `
+			AND (
+				name ILIKE '%' || ` + search + ` || '%' OR
+				task_id ILIKE '%' || ` + search + ` || '%' OR
+				title ILIKE '%' || ` + search + ` || '%' OR
+				content ILIKE '%' || ` + search + ` || '%' OR
+				tags ILIKE '%' || ` + search + ` || '%' OR
+				decision ILIKE '%' || ` + search + ` || '%'
+			)
+		`
+	}
+
+	if pq.Since != "" && pq.Until != "" {
+		args = append(args, pq.Since, pq.Until)
+		where += `
+			AND created_at BETWEEN $` + strconv.Itoa(len(args)-1) + ` AND $` + strconv.Itoa(len(args))
+	}
+
+	query := `
+		SELECT ` + pembuatanArtikelColumns + `
+		FROM pembuatanartikel
+	` + where + `
+		ORDER BY created_at ` + sortOrder + `
+		LIMIT $` + strconv.Itoa(len(args)+1) + ` OFFSET $` + strconv.Itoa(len(args)+2)
+
+	params := append([]interface{}{}, args...)
+	params = append(params, pq.Limit, pq.Offset)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*PembuatanArtikel{}
+	for rows.Next() {
+		var model PembuatanArtikel
+		if err := scanPembuatanArtikel(rows, &model); err != nil {
 			return nil, err
 		}
-	} else {
-		model.Properties = []string{}
+		results = append(results, &model)
 	}
 
-	return &model, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	countQuery := `
+		SELECT COUNT(*)
+		FROM pembuatanartikel
+	` + where
+
+	var totalCount int
+	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&totalCount); err != nil {
+		return nil, err
+	}
+
+	totalPages := (totalCount + pq.Limit - 1) / pq.Limit
+
+	response := map[string]interface{}{
+		"content":      results,
+		"totalElement": totalCount,
+		"totalPages":   totalPages,
+		"limit":        pq.Limit,
+		"offset":       pq.Offset,
+		"sort":         pq.Sort,
+		"search":       pq.Search,
+		"since":        pq.Since,
+		"until":        pq.Until,
+	}
+
+	return response, nil
 }
 
 func (s *PembuatanArtikelStore) delete(ctx context.Context, tx *sql.Tx, id int64) error {
@@ -182,49 +228,81 @@ func (s *PembuatanArtikelStore) delete(ctx context.Context, tx *sql.Tx, id int64
 }
 
 func (s *PembuatanArtikelStore) update(ctx context.Context, tx *sql.Tx, model *PembuatanArtikel) error {
-	if model.Properties == nil {
-		model.Properties = []string{}
+	values, err := model.values()
+	if err != nil {
+		return err
 	}
 
-	propertiesJSON, errProperties := json.Marshal(model.Properties)
-	if errProperties != nil {
-		return errProperties
-	}
 	query := `
 		UPDATE pembuatanartikel
-		SET name = $1, form_id = $2, properties = $3, updated_by = $4, task_id = $5,  updated_at = NOW()
-		WHERE id = $6 AND deleted_at IS NULL
-		RETURNING id, name, form_id, task_id, properties, created_by, updated_by, created_at, updated_at;
-	`
+		SET name = $1, form_id = $2, updated_by = $3, task_id = $4, properties = $5, title = $6, content = $7, version = $8, tags = $9, decision = $10, updated_at = NOW()
+		WHERE id = $11 AND deleted_at IS NULL
+		RETURNING ` + pembuatanArtikelColumns
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	var propertiesData []byte
-	err := tx.QueryRowContext(
-		ctx,
-		query,
-		model.Name,
-		model.FormId,
+	args := append([]interface{}{model.Name, model.FormId, model.UpdatedBy, model.TaskId}, values...)
+	args = append(args, model.ID)
+	if err := scanPembuatanArtikel(tx.QueryRowContext(ctx, query, args...), model); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// values returns the properties and the form fields in the order of their
+// columns.
+func (model *PembuatanArtikel) values() ([]interface{}, error) {
+	if model.Properties == nil {
+		model.Properties = map[string]interface{}{}
+	}
+
+	propertiesJSON, err := json.Marshal(model.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{
 		propertiesJSON,
-		model.UpdatedBy,
-		model.TaskId,
-		model.ID,
-	).Scan(&model.ID,
+		model.Title,
+		model.Content,
+		model.Version,
+		model.Tags,
+		model.Decision,
+	}, nil
+}
+
+// scanPembuatanArtikel reads a row of pembuatanArtikelColumns into the model.
+func scanPembuatanArtikel(row interface{ Scan(...interface{}) error }, model *PembuatanArtikel) error {
+	var propertiesData []byte
+	if err := row.Scan(
+		&model.ID,
 		&model.Name,
 		&model.FormId,
 		&model.TaskId,
-		propertiesData,
+		&propertiesData,
 		&model.CreatedBy,
 		&model.UpdatedBy,
 		&model.CreatedAt,
 		&model.UpdatedAt,
-	)
-	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrNotFound
-		default:
+		&model.Title,
+		&model.Content,
+		&model.Version,
+		&model.Tags,
+		&model.Decision,
+	); err != nil {
+		return err
+	}
+
+	model.Properties = map[string]interface{}{}
+	if len(propertiesData) > 0 {
+		if err := json.Unmarshal(propertiesData, &model.Properties); err != nil {
 			return err
 		}
 	}
@@ -101,6 +101,19 @@ func (s *PostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedF
 }
 
 func (s *PostStore) Create(ctx context.Context, post *Post) error {
+	return s.insert(ctx, post, sql.NullInt64{})
+}
+
+// CreateForProcess creates the post published by a process instance once, a
+// post already published by the instance is returned in post instead.
+func (s *PostStore) CreateForProcess(ctx context.Context, post *Post, processInstanceKey int64) error {
+	return s.insert(ctx, post, sql.NullInt64{Int64: processInstanceKey, Valid: true})
+}
+
+// insert creates the post and fills it with the stored row. The posts of a
+// process instance are unique, the no-op update makes RETURNING give the
+// existing row on a conflict; a post without one never conflicts.
+func (s *PostStore) insert(ctx context.Context, post *Post, processInstanceKey sql.NullInt64) error {
 	if post.Tags == nil {
 		post.Tags = []string{}
 	}
@@ -111,13 +124,17 @@ func (s *PostStore) Create(ctx context.Context, post *Post) error {
 	}
 
 	query := `
-		INSERT INTO posts (content, title, user_id, tags)
-		VALUES ($1,$2, $3, $4) RETURNING id, created_at, updated_at;
+		INSERT INTO posts (content, title, user_id, tags, process_instance_key)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (process_instance_key) DO UPDATE
+		SET process_instance_key = EXCLUDED.process_instance_key
+		RETURNING id, user_id, title, content, tags, created_at, updated_at, version;
 	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
+	var tagsData []byte
 	err := s.db.QueryRowContext(
 		ctx,
 		query,
@@ -125,15 +142,29 @@ func (s *PostStore) Create(ctx context.Context, post *Post) error {
 		post.Title,
 		post.UserID,
 		tagsJSON,
+		processInstanceKey,
 	).Scan(
 		&post.ID,
+		&post.UserID,
+		&post.Title,
+		&post.Content,
+		&tagsData,
 		&post.CreatedAt,
 		&post.UpdatedAt,
+		&post.Version,
 	)
 	if err != nil {
 		return err
 	}
 
+	if len(tagsData) > 0 {
+		if err := json.Unmarshal(tagsData, &post.Tags); err != nil {
+			return err
+		}
+	} else {
+		post.Tags = []string{}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,18 @@
+package store
+
+// task_state values of the generated process tables
+const (
+	ProcessStateCreated   = "CREATED"
+	ProcessStateCompleted = "COMPLETED"
+	ProcessStateCanceled  = "CANCELED"
+	ProcessStateFailed    = "FAILED"
+)
+
+// ProcessInstanceState is the part of a generated process row that follows
+// the process instance in operate.
+type ProcessInstanceState struct {
+	ID                 int64   `json:"id"`
+	ProcessInstanceKey int64   `json:"process_instance_key"`
+	TaskDefinitionId   *string `json:"task_definition_id"`
+	TaskState          string  `json:"task_state"`
+}
@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+)
+
+// RefreshToken is a one-time token exchanged for a new access token. The
+// tokens rotated from one login share its session id, so a session is
+// revoked as a whole. Only the hash of the token is stored.
+type RefreshToken struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	SessionID string     `json:"session_id"`
+	Expiry    time.Time  `json:"expiry"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type RefreshTokenStore struct {
+	db *sql.DB
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func (s *RefreshTokenStore) Create(ctx context.Context, token string, rt *RefreshToken) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.create(ctx, tx, token, rt)
+	})
+}
+
+// Rotate revokes token and stores next in its session. A token used twice
+// was stolen or replayed, the whole session is revoked and ErrTokenReused
+// returned.
+func (s *RefreshTokenStore) Rotate(ctx context.Context, token, next string, exp time.Duration) (*RefreshToken, error) {
+	var rotated *RefreshToken
+	var reused bool
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		current, err := s.getForUpdate(ctx, tx, token)
+		if err != nil {
+			return err
+		}
+
+		if current.RevokedAt != nil {
+			reused = true
+			return s.revokeSession(ctx, tx, current.SessionID)
+		}
+		if !current.Expiry.After(time.Now()) {
+			return ErrNotFound
+		}
+
+		if err := s.revoke(ctx, tx, current.ID); err != nil {
+			return err
+		}
+
+		rotated = &RefreshToken{
+			UserID:    current.UserID,
+			SessionID: current.SessionID,
+			Expiry:    time.Now().Add(exp),
+		}
+		return s.create(ctx, tx, next, rotated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, ErrTokenReused
+	}
+
+	return rotated, nil
+}
+
+// RevokeSession revokes every token of a session, the tokens already revoked
+// are left as they are.
+func (s *RefreshTokenStore) RevokeSession(ctx context.Context, userID int64, sessionID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND session_id = $2 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, sessionID)
+	return err
+}
+
+func (s *RefreshTokenStore) RevokeAllForUser(ctx context.Context, userID int64) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+func (s *RefreshTokenStore) create(ctx context.Context, tx *sql.Tx, token string, rt *RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (token, user_id, session_id, expiry)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return tx.QueryRowContext(
+		ctx,
+		query,
+		hashToken(token),
+		rt.UserID,
+		rt.SessionID,
+		rt.Expiry,
+	).Scan(
+		&rt.ID,
+		&rt.CreatedAt,
+	)
+}
+
+func (s *RefreshTokenStore) getForUpdate(ctx context.Context, tx *sql.Tx, token string) (*RefreshToken, error) {
+	query := `
+		SELECT id, user_id, session_id, expiry, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE token = $1
+		FOR UPDATE
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rt := &RefreshToken{}
+	err := tx.QueryRowContext(ctx, query, hashToken(token)).Scan(
+		&rt.ID,
+		&rt.UserID,
+		&rt.SessionID,
+		&rt.Expiry,
+		&rt.RevokedAt,
+		&rt.CreatedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return rt, nil
+}
+
+func (s *RefreshTokenStore) revoke(ctx context.Context, tx *sql.Tx, id int64) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, id)
+	return err
+}
+
+func (s *RefreshTokenStore) revokeSession(ctx context.Context, tx *sql.Tx, sessionID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE session_id = $1 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, sessionID)
+	return err
+}
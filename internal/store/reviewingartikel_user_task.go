@@ -1,32 +1,40 @@
 package store
+
 import (
 	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"strconv"
 )
 
 const (
-	ReviewingArtikelID = "reviewing_artikel"
-	ReviewingArtikelName = "Reviewing Artikel"
-	ReviewingArtikelFormID = "reviewing_artikel_form"
-	ReviewingArtikelAssignee = ""
+	ReviewingArtikelID             = "reviewing_artikel"
+	ReviewingArtikelProcessID      = "pembuatan_media_berita_technology"
+	ReviewingArtikelName           = "Reviewing Artikel"
+	ReviewingArtikelFormID         = "reviewing_artikel_form"
+	ReviewingArtikelAssignee       = ""
 	ReviewingArtikelCandidateGroup = ""
-	ReviewingArtikelCandidateUser = ""
-	ReviewingArtikelSchedule = ``
-
+	ReviewingArtikelCandidateUser  = ""
+	ReviewingArtikelSchedule       = ``
 )
 
+// reviewingArtikelColumns are read by scanReviewingArtikel in this order
+const reviewingArtikelColumns = `id, name, form_id, task_id, properties, created_by, updated_by, created_at, updated_at, note, decision`
+
 type ReviewingArtikel struct {
-    ID         int64    `json:"id"`
-	Name       string   `json:"name"`
-	FormId     string   `json:"form_id"`
-	Properties []string `json:"properties"`
-	CreatedBy  int64    `json:"created_by"`
-	UpdatedBy  *int64   `json:"updated_by"`
-	CreatedAt  string   `json:"created_at"`
-	UpdatedAt  string   `json:"updated_at"`
-	DeletedAt  *string  `json:"deleted_at"`
+	ID         int64                  `json:"id"`
+	Name       string                 `json:"name"`
+	TaskId     string                 `json:"task_id"`
+	FormId     string                 `json:"form_id"`
+	Properties map[string]interface{} `json:"properties"`
+	CreatedBy  int64                  `json:"created_by"`
+	UpdatedBy  *int64                 `json:"updated_by"`
+	CreatedAt  string                 `json:"created_at"`
+	UpdatedAt  string                 `json:"updated_at"`
+	DeletedAt  *string                `json:"deleted_at"`
+	Note       *string                `json:"note"`
+	Decision   *string                `json:"decision"`
 }
 
 type ReviewingArtikelStore struct {
@@ -48,7 +56,7 @@ func (s *ReviewingArtikelStore) Delete(ctx context.Context, id int64) error {
 			return err
 		}
 		return nil
-	})	
+	})
 }
 
 func (s *ReviewingArtikelStore) Update(ctx context.Context, model *ReviewingArtikel) error {
@@ -59,61 +67,28 @@ func (s *ReviewingArtikelStore) Update(ctx context.Context, model *ReviewingArti
 		return nil
 	})
 }
-	
-func (s *ReviewingArtikelStore) create(ctx context.Context, tx *sql.Tx, model *ReviewingArtikel) error {
-	if model.Properties == nil {
-		model.Properties = []string{}
-	}
 
-	propertiesJSON, errProperties := json.Marshal(model.Properties)
-	if errProperties != nil {
-		return errProperties
+func (s *ReviewingArtikelStore) create(ctx context.Context, tx *sql.Tx, model *ReviewingArtikel) error {
+	values, err := model.values()
+	if err != nil {
+		return err
 	}
 
 	query := `
-		INSERT INTO reviewingartikel (name, form_id, properties, created_by)
-		VALUES (
-			$1,
-			$2,
-			$3,
-			$4
-		) RETURNING 
-		 	id, name, form_id, properties, created_by, updated_by,
-			created_at, updated_at
-		`
+		INSERT INTO reviewingartikel (name, form_id, created_by, task_id, properties, note, decision)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + reviewingArtikelColumns
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	var propertiesData []byte
-	err := tx.QueryRowContext(
-		ctx,
-		query,
-		model.Name,
-		model.FormId,
-		propertiesJSON,
-		model.CreatedBy,
-		model.UpdatedAt,
-	).Scan(
-		&model.ID,
-		&model.Name,
-		&model.FormId,
-		&propertiesData,
-		&model.CreatedBy,
-		&model.UpdatedBy,
-		&model.CreatedAt,
-		&model.UpdatedAt,
-	)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	args := append([]interface{}{model.Name, model.FormId, model.CreatedBy, model.TaskId}, values...)
+	return scanReviewingArtikel(tx.QueryRowContext(ctx, query, args...), model)
 }
 
 func (s *ReviewingArtikelStore) GetByID(ctx context.Context, id int64) (*ReviewingArtikel, error) {
 	query := `
-		SELECT id, name, form_id, properties, created_by, 
-		updated_by, created_at, updated_at
+		SELECT ` + reviewingArtikelColumns + `
 		FROM reviewingartikel
 		WHERE id = $1 AND deleted_at IS NULL
 	`
@@ -122,18 +97,7 @@ func (s *ReviewingArtikelStore) GetByID(ctx context.Context, id int64) (*Reviewi
 	defer cancel()
 
 	var model ReviewingArtikel
-	var propertiesData []byte
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
-		&model.ID,
-		&model.Name,
-		&model.FormId,
-		&propertiesData,
-		&model.CreatedBy,
-		&model.UpdatedBy,
-		&model.CreatedAt,
-		&model.UpdatedAt,
-	)
-	if err != nil {
+	if err := scanReviewingArtikel(s.db.QueryRowContext(ctx, query, id), &model); err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return nil, ErrNotFound
@@ -141,16 +105,98 @@ func (s *ReviewingArtikelStore) GetByID(ctx context.Context, id int64) (*Reviewi
 			return nil, err
 		}
 	}
-		
-	if len(propertiesData) > 0 {
-		if err := json.Unmarshal(propertiesData, &model.Properties); err != nil {
+
+	return &model, nil
+}
+
+func (s *ReviewingArtikelStore) Search(ctx context.Context, pq PaginatedQuery) (map[string]interface{}, error) {
+	sortOrder := "DESC"
+	if pq.Sort == "asc" || pq.Sort == "ASC" {
+		sortOrder = "ASC"
+	}
+
+	// filters are shared by the page and the count query
+	where := `
+		WHERE deleted_at IS NULL
+	`
+	var args []interface{}
+
+	if pq.Search != "" {
+		args = append(args, pq.Search)
+		search := "$" + strconv.Itoa(len(args))
+		where += `
+			AND (
+				name ILIKE '%' || ` + search + ` || '%' OR
+				task_id ILIKE '%' || ` + search + ` || '%' OR
+				note ILIKE '%' || ` + search + ` || '%' OR
+				decision ILIKE '%' || ` + search + ` || '%'
+			)
+		`
+	}
+
+	if pq.Since != "" && pq.Until != "" {
+		args = append(args, pq.Since, pq.Until)
+		where += `
+			AND created_at BETWEEN $` + strconv.Itoa(len(args)-1) + ` AND $` + strconv.Itoa(len(args))
+	}
+
+	query := `
+		SELECT ` + reviewingArtikelColumns + `
+		FROM reviewingartikel
+	` + where + `
+		ORDER BY created_at ` + sortOrder + `
+		LIMIT $` + strconv.Itoa(len(args)+1) + ` OFFSET $` + strconv.Itoa(len(args)+2)
+
+	params := append([]interface{}{}, args...)
+	params = append(params, pq.Limit, pq.Offset)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*ReviewingArtikel{}
+	for rows.Next() {
+		var model ReviewingArtikel
+		if err := scanReviewingArtikel(rows, &model); err != nil {
 			return nil, err
 		}
-	} else {
-		model.Properties = []string{}
+		results = append(results, &model)
 	}
 
-	return &model, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	countQuery := `
+		SELECT COUNT(*)
+		FROM reviewingartikel
+	` + where
+
+	var totalCount int
+	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&totalCount); err != nil {
+		return nil, err
+	}
+
+	totalPages := (totalCount + pq.Limit - 1) / pq.Limit
+
+	response := map[string]interface{}{
+		"content":      results,
+		"totalElement": totalCount,
+		"totalPages":   totalPages,
+		"limit":        pq.Limit,
+		"offset":       pq.Offset,
+		"sort":         pq.Sort,
+		"search":       pq.Search,
+		"since":        pq.Since,
+		"until":        pq.Until,
+	}
+
+	return response, nil
 }
 
 func (s *ReviewingArtikelStore) delete(ctx context.Context, tx *sql.Tx, id int64) error {
@@ -177,44 +223,23 @@ func (s *ReviewingArtikelStore) delete(ctx context.Context, tx *sql.Tx, id int64
 }
 
 func (s *ReviewingArtikelStore) update(ctx context.Context, tx *sql.Tx, model *ReviewingArtikel) error {
-
-	if model.Properties == nil {
-		model.Properties = []string{}
+	values, err := model.values()
+	if err != nil {
+		return err
 	}
 
-	propertiesJSON, errProperties := json.Marshal(model.Properties)
-	if errProperties != nil {
-		return errProperties
-	}
 	query := `
 		UPDATE reviewingartikel
-		SET name = $1, form_id = $2, properties = $3, updated_by = $4  updated_at = NOW()
-		WHERE id = $5 AND deleted_at IS NULL
-		RETURNING id, name, form_id, properties, created_by, updated_by, created_at, updated_at;
-	`
+		SET name = $1, form_id = $2, updated_by = $3, task_id = $4, properties = $5, note = $6, decision = $7, updated_at = NOW()
+		WHERE id = $8 AND deleted_at IS NULL
+		RETURNING ` + reviewingArtikelColumns
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	var propertiesData []byte
-	err := tx.QueryRowContext(
-		ctx,
-		query,
-		model.Name,
-		model.FormId,
-		propertiesJSON,
-		model.UpdatedBy,
-		model.ID,
-	).Scan(&model.ID, 
-		&model.Name, 
-		&model.FormId, 
-		propertiesData, 
-		&model.CreatedBy, 
-		&model.UpdatedBy, 
-		&model.CreatedAt, 
-		&model.UpdatedAt,
-	)
-	if err != nil {
+	args := append([]interface{}{model.Name, model.FormId, model.UpdatedBy, model.TaskId}, values...)
+	args = append(args, model.ID)
+	if err := scanReviewingArtikel(tx.QueryRowContext(ctx, query, args...), model); err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrNotFound
@@ -226,3 +251,50 @@ func (s *ReviewingArtikelStore) update(ctx context.Context, tx *sql.Tx, model *R
 	return nil
 }
 
+// values returns the properties and the form fields in the order of their
+// columns.
+func (model *ReviewingArtikel) values() ([]interface{}, error) {
+	if model.Properties == nil {
+		model.Properties = map[string]interface{}{}
+	}
+
+	propertiesJSON, err := json.Marshal(model.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{
+		propertiesJSON,
+		model.Note,
+		model.Decision,
+	}, nil
+}
+
+// scanReviewingArtikel reads a row of reviewingArtikelColumns into the model.
+func scanReviewingArtikel(row interface{ Scan(...interface{}) error }, model *ReviewingArtikel) error {
+	var propertiesData []byte
+	if err := row.Scan(
+		&model.ID,
+		&model.Name,
+		&model.FormId,
+		&model.TaskId,
+		&propertiesData,
+		&model.CreatedBy,
+		&model.UpdatedBy,
+		&model.CreatedAt,
+		&model.UpdatedAt,
+		&model.Note,
+		&model.Decision,
+	); err != nil {
+		return err
+	}
+
+	model.Properties = map[string]interface{}{}
+	if len(propertiesData) > 0 {
+		if err := json.Unmarshal(propertiesData, &model.Properties); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
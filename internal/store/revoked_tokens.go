@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// TokenRevocation revokes the access tokens of a user issued before At, the
+// tokens of SessionID are kept: it is the session started along with the
+// revocation, e.g. by a password change.
+type TokenRevocation struct {
+	At        time.Time
+	SessionID string
+}
+
+// RevokedTokenStore keeps the revoked access tokens in postgres, it stands in
+// for the redis denylist of cache.TokensStore when redis is disabled.
+type RevokedTokenStore struct {
+	db *sql.DB
+}
+
+func (s *RevokedTokenStore) Revoke(ctx context.Context, jti string, exp time.Duration) error {
+	if exp <= 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO revoked_tokens (jti, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, jti, time.Now().Add(exp))
+	return err
+}
+
+func (s *RevokedTokenStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1 AND expires_at > NOW())`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var revoked bool
+	err := s.db.QueryRowContext(ctx, query, jti).Scan(&revoked)
+	return revoked, err
+}
+
+// RevokeUser revokes the access tokens of the user, the revocation is kept on
+// the user so exp is not needed.
+func (s *RevokedTokenStore) RevokeUser(ctx context.Context, userID int64, revocation TokenRevocation, exp time.Duration) error {
+	query := `UPDATE users SET tokens_revoked_at = $2, tokens_revoked_sid = $3 WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, revocation.At, revocation.SessionID)
+	return err
+}
+
+// UserRevocation returns the last revocation of the tokens of the user, nil
+// if they never were revoked.
+func (s *RevokedTokenStore) UserRevocation(ctx context.Context, userID int64) (*TokenRevocation, error) {
+	query := `SELECT tokens_revoked_at, COALESCE(tokens_revoked_sid, '') FROM users WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var revokedAt sql.NullTime
+	var revocation TokenRevocation
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&revokedAt, &revocation.SessionID)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	case !revokedAt.Valid:
+		return nil, nil
+	}
+
+	revocation.At = revokedAt.Time
+	return &revocation, nil
+}
+
+// DeleteExpired deletes the tokens that expired anyway and returns how many
+// were deleted.
+func (s *RevokedTokenStore) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -10,6 +10,9 @@ type Role struct {
 	Name        string `json:"name"`
 	Level       int64  `json:"level"`
 	Description string `json:"description"`
+	// TwoFactorRequired makes the users of the role log in with a second
+	// factor
+	TwoFactorRequired bool `json:"two_factor_required"`
 }
 
 type RoleStore struct {
@@ -18,9 +21,12 @@ type RoleStore struct {
 
 func (s *RoleStore) GetByName(ctx context.Context, slug string) (*Role, error) {
 	query := `
-		SELECT id, name, description, level FROM roles WHERE name = $1
+		SELECT id, name, description, level, two_factor_required FROM roles WHERE name = $1
 	`
 
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
 	role := &Role{}
 	err := s.db.QueryRowContext(
 		ctx,
@@ -31,6 +37,38 @@ func (s *RoleStore) GetByName(ctx context.Context, slug string) (*Role, error) {
 		&role.Name,
 		&role.Description,
 		&role.Level,
+		&role.TwoFactorRequired,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return role, nil
+}
+
+// SetTwoFactorRequired sets whether the users of the role must log in with a
+// second factor and returns the role.
+func (s *RoleStore) SetTwoFactorRequired(ctx context.Context, name string, required bool) (*Role, error) {
+	query := `
+		UPDATE roles SET two_factor_required = $2 WHERE name = $1
+		RETURNING id, name, description, level, two_factor_required
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	role := &Role{}
+	err := s.db.QueryRowContext(ctx, query, name, required).Scan(
+		&role.ID,
+		&role.Name,
+		&role.Description,
+		&role.Level,
+		&role.TwoFactorRequired,
 	)
 	if err != nil {
 		switch err {
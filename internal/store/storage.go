@@ -8,20 +8,24 @@ import (
 )
 
 var (
-	ErrMethodNotAllowed  = errors.New("method not allowed")
-	ErrNotFound          = errors.New("resource not found")
-	ErrBadRequest        = errors.New("bad request")
-	ErrConflict          = errors.New("resource already exist")
-	ErrDuplicateEmail    = errors.New("a user with that email already exist")
-	ErrDuplicateUsername = errors.New("a user with that username already exist")
-	ErrTypeNotAllowed    = errors.New("file extension not allowed")
-	QueryTimeoutDuration = time.Second * 5
+	ErrMethodNotAllowed   = errors.New("method not allowed")
+	ErrNotFound           = errors.New("resource not found")
+	ErrBadRequest         = errors.New("bad request")
+	ErrConflict           = errors.New("resource already exist")
+	ErrDuplicateEmail     = errors.New("a user with that email already exist")
+	ErrDuplicateUsername  = errors.New("a user with that username already exist")
+	ErrTypeNotAllowed     = errors.New("file extension not allowed")
+	ErrServiceUnavailable = errors.New("service unavailable")
+	ErrTokenReused        = errors.New("refresh token was already used")
+	ErrTooManyAttempts    = errors.New("too many failed attempts")
+	QueryTimeoutDuration  = time.Second * 5
 )
 
 type Storage struct {
 	Posts interface {
 		GetByID(context.Context, int64) (*Post, error)
 		Create(context.Context, *Post) error
+		CreateForProcess(ctx context.Context, post *Post, processInstanceKey int64) error
 		Delete(context.Context, int64) error
 		Update(context.Context, *Post) error
 		GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetadata, error)
@@ -35,6 +39,19 @@ type Storage struct {
 		CreateAndInvite(ctx context.Context, user *User, token string, exp time.Duration) error
 		Activate(context.Context, string) error
 		Delete(context.Context, int64) error
+		CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration) error
+		ResetPassword(ctx context.Context, token string, user *User) error
+		UpdatePassword(context.Context, *User) error
+		CreateEmailChange(ctx context.Context, userID int64, email, token string, exp time.Duration) error
+		ChangeEmail(context.Context, string) (*User, error)
+		CreateTwoFactorEnrollment(ctx context.Context, userID int64, token string, exp time.Duration) error
+		ConfirmTwoFactorEnrollment(context.Context, string) (*User, error)
+		RenewInvitation(ctx context.Context, email, token string, exp time.Duration) (*User, error)
+		ActivateByID(context.Context, int64) error
+		DeleteExpiredInvitations(context.Context) (int64, error)
+		DeleteInactive(ctx context.Context, createdBefore time.Time) (int64, error)
+		GetByIdentity(ctx context.Context, issuer, subject string) (*User, error)
+		ProvisionIdentity(ctx context.Context, issuer, subject string, user *User, linkEmail bool) (*User, error)
 	}
 	Comments interface {
 		GetByPostID(context.Context, int64) ([]Comment, error)
@@ -46,6 +63,42 @@ type Storage struct {
 	}
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
+		SetTwoFactorRequired(context.Context, string, bool) (*Role, error)
+	}
+	RefreshTokens interface {
+		Create(context.Context, string, *RefreshToken) error
+		Rotate(ctx context.Context, token, next string, exp time.Duration) (*RefreshToken, error)
+		RevokeSession(ctx context.Context, userID int64, sessionID string) error
+		RevokeAllForUser(context.Context, int64) error
+	}
+	RevokedTokens interface {
+		Revoke(ctx context.Context, jti string, exp time.Duration) error
+		IsRevoked(ctx context.Context, jti string) (bool, error)
+		RevokeUser(ctx context.Context, userID int64, revocation TokenRevocation, exp time.Duration) error
+		UserRevocation(ctx context.Context, userID int64) (*TokenRevocation, error)
+		DeleteExpired(context.Context) (int64, error)
+	}
+	TwoFactor interface {
+		Get(context.Context, int64) (*TwoFactor, error)
+		SetSecret(ctx context.Context, userID int64, secret []byte) error
+		Enable(ctx context.Context, userID, step int64, recoveryCodes []string) error
+		UseStep(ctx context.Context, userID, step int64) error
+		UseRecoveryCode(ctx context.Context, userID int64, code string) error
+		ReplaceRecoveryCodes(ctx context.Context, userID int64, recoveryCodes []string) error
+		Disable(context.Context, int64) error
+		CreateChallenge(ctx context.Context, userID int64, jti string, exp time.Duration) error
+		AttemptChallenge(ctx context.Context, userID int64, jti string, maxFailures int, window time.Duration) error
+		UseChallenge(context.Context, string) error
+		DeleteExpiredChallenges(context.Context, time.Time) (int64, error)
+	}
+	Outbox interface {
+		GetByIdempotencyKey(ctx context.Context, createdBy int64, key string) (*OutboxMessage, error)
+		ClaimPending(context.Context, int, time.Duration) ([]OutboxMessage, error)
+		GetProcessInstanceKey(context.Context, string, int64) (int64, error)
+		MarkStarted(context.Context, *OutboxMessage, int64) error
+		MarkProcessed(context.Context, int64) error
+		MarkFailed(context.Context, int64, string, time.Duration) error
+		MarkDead(context.Context, int64, string) error
 	}
 	// GENERATED CODE INTERFACE
 
@@ -55,24 +108,31 @@ type Storage struct {
 		GetByID(context.Context, int64) (*PembuatanMediaBeritaTechnology, error)
 		Update(context.Context, *PembuatanMediaBeritaTechnology) error
 		Search(context.Context, PaginatedQuery) (map[string]interface{}, error)
+		GetRunning(context.Context, int64, int) ([]ProcessInstanceState, error)
+		UpdateState(context.Context, *ProcessInstanceState) error
+		CreateWithOutbox(context.Context, *PembuatanMediaBeritaTechnology, *OutboxMessage) error
+		DeleteWithOutbox(context.Context, int64, *OutboxMessage) error
 	}
 
 	ApprovingArtikel interface {
 		Create(context.Context, *ApprovingArtikel) error
 		Delete(context.Context, int64) error
 		GetByID(context.Context, int64) (*ApprovingArtikel, error)
+		Search(context.Context, PaginatedQuery) (map[string]interface{}, error)
 	}
 
 	ReviewingArtikel interface {
 		Create(context.Context, *ReviewingArtikel) error
 		Delete(context.Context, int64) error
 		GetByID(context.Context, int64) (*ReviewingArtikel, error)
+		Search(context.Context, PaginatedQuery) (map[string]interface{}, error)
 	}
 
 	PembuatanArtikel interface {
 		Create(context.Context, *PembuatanArtikel) error
 		Delete(context.Context, int64) error
 		GetByID(context.Context, int64) (*PembuatanArtikel, error)
+		Search(context.Context, PaginatedQuery) (map[string]interface{}, error)
 	}
 }
 
@@ -83,6 +143,11 @@ func NewStorage(db *sql.DB) Storage {
 		Comments:  &CommentStore{db},
 		Followers: &FollowerStore{db},
 		Roles:     &RoleStore{db},
+		Outbox:    &OutboxStore{db},
+
+		RefreshTokens: &RefreshTokenStore{db},
+		RevokedTokens: &RevokedTokenStore{db},
+		TwoFactor:     &TwoFactorStore{db},
 		// GENERATED CODE CONSTRUCTOR
 
 		PembuatanMediaBeritaTechnology: &PembuatanMediaBeritaTechnologyStore{db},
@@ -0,0 +1,271 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// TwoFactor is the TOTP enrollment of a user, it is pending until the user
+// confirms a first code. The secret is stored encrypted, the recovery codes
+// hashed.
+type TwoFactor struct {
+	UserID    int64     `json:"user_id"`
+	Secret    []byte    `json:"-"`
+	Enabled   bool      `json:"enabled"`
+	LastStep  int64     `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type TwoFactorStore struct {
+	db *sql.DB
+}
+
+func (s *TwoFactorStore) Get(ctx context.Context, userID int64) (*TwoFactor, error) {
+	query := `
+		SELECT user_id, secret, enabled, last_step, created_at
+		FROM user_two_factor
+		WHERE user_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tf := &TwoFactor{}
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(
+		&tf.UserID,
+		&tf.Secret,
+		&tf.Enabled,
+		&tf.LastStep,
+		&tf.CreatedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return tf, nil
+}
+
+// SetSecret starts an enrollment, a pending one is replaced. An enabled
+// enrollment gives ErrConflict.
+func (s *TwoFactorStore) SetSecret(ctx context.Context, userID int64, secret []byte) error {
+	query := `
+		INSERT INTO user_two_factor (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_step = 0, created_at = NOW()
+		WHERE user_two_factor.enabled = false
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrConflict
+	}
+
+	return nil
+}
+
+// Enable confirms a pending enrollment with the time step of its first code
+// and stores its recovery codes.
+func (s *TwoFactorStore) Enable(ctx context.Context, userID, step int64, recoveryCodes []string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE user_two_factor SET enabled = true, last_step = $2
+			WHERE user_id = $1 AND enabled = false AND last_step < $2
+		`
+		if err := s.execOne(ctx, tx, query, userID, step); err != nil {
+			return err
+		}
+
+		return s.replaceRecoveryCodes(ctx, tx, userID, recoveryCodes)
+	})
+}
+
+// UseStep records the time step of a code, a step already used gives
+// ErrNotFound so a code cannot be replayed.
+func (s *TwoFactorStore) UseStep(ctx context.Context, userID, step int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE user_two_factor SET last_step = $2
+			WHERE user_id = $1 AND enabled = true AND last_step < $2
+		`
+		return s.execOne(ctx, tx, query, userID, step)
+	})
+}
+
+// UseRecoveryCode uses up a recovery code, an unknown or used one gives
+// ErrNotFound.
+func (s *TwoFactorStore) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE user_recovery_codes SET used_at = NOW()
+			WHERE user_id = $1 AND code = $2 AND used_at IS NULL
+		`
+		return s.execOne(ctx, tx, query, userID, hashToken(code))
+	})
+}
+
+func (s *TwoFactorStore) ReplaceRecoveryCodes(ctx context.Context, userID int64, recoveryCodes []string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.replaceRecoveryCodes(ctx, tx, userID, recoveryCodes)
+	})
+}
+
+func (s *TwoFactorStore) Disable(ctx context.Context, userID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM user_two_factor WHERE user_id = $1`, userID)
+		return err
+	})
+}
+
+// CreateChallenge records the challenge jti of a login waiting for the second
+// factor of userID.
+func (s *TwoFactorStore) CreateChallenge(ctx context.Context, userID int64, jti string, exp time.Duration) error {
+	query := `
+		INSERT INTO two_factor_challenges (jti, user_id, expires_at)
+		VALUES ($1, $2, $3)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, jti, userID, time.Now().Add(exp))
+	return err
+}
+
+// AttemptChallenge counts an attempt at the code of a challenge, it is
+// counted as failed until UseChallenge. A used, expired or unknown challenge
+// gives ErrNotFound, and ErrTooManyAttempts once the challenges of the user
+// created in the last window failed maxFailures times.
+func (s *TwoFactorStore) AttemptChallenge(ctx context.Context, userID int64, jti string, maxFailures int, window time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// the attempts of a user are counted one at a time
+		var id int64
+		err := tx.QueryRowContext(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&id)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		query := `
+			SELECT jti FROM two_factor_challenges
+			WHERE jti = $1 AND user_id = $2 AND used_at IS NULL AND expires_at > NOW()
+		`
+		var found string
+		if err := tx.QueryRowContext(ctx, query, jti, userID).Scan(&found); err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		query = `
+			SELECT COALESCE(SUM(failed_attempts), 0) FROM two_factor_challenges
+			WHERE user_id = $1 AND created_at > $2
+		`
+		var failures int
+		if err := tx.QueryRowContext(ctx, query, userID, time.Now().Add(-window)).Scan(&failures); err != nil {
+			return err
+		}
+		if failures >= maxFailures {
+			return ErrTooManyAttempts
+		}
+
+		query = `UPDATE two_factor_challenges SET failed_attempts = failed_attempts + 1 WHERE jti = $1`
+		return s.execOne(ctx, tx, query, jti)
+	})
+}
+
+// UseChallenge uses up a challenge after a right code, its attempt is no
+// longer counted as failed. A used challenge gives ErrNotFound.
+func (s *TwoFactorStore) UseChallenge(ctx context.Context, jti string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			UPDATE two_factor_challenges
+			SET used_at = NOW(), failed_attempts = GREATEST(failed_attempts - 1, 0)
+			WHERE jti = $1 AND used_at IS NULL
+		`
+		return s.execOne(ctx, tx, query, jti)
+	})
+}
+
+// DeleteExpiredChallenges deletes the challenges expired before before and
+// returns how many were deleted.
+func (s *TwoFactorStore) DeleteExpiredChallenges(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM two_factor_challenges WHERE expires_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *TwoFactorStore) replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64, recoveryCodes []string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO user_recovery_codes (user_id, code) VALUES ($1, $2)`
+	for _, code := range recoveryCodes {
+		if _, err := tx.ExecContext(ctx, query, userID, hashToken(code)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// execOne runs an update that must match a single row.
+func (s *TwoFactorStore) execOne(ctx context.Context, tx *sql.Tx, query string, args ...any) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -5,11 +5,20 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
+	"errors"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+// the scopes of the tokens of user_invitations
+const (
+	ScopeActivation    = "activation"
+	ScopePasswordReset = "password_reset"
+	ScopeEmailChange   = "email_change"
+	ScopeTwoFactor     = "2fa_enroll"
+)
+
 type User struct {
 	ID        int64    `json:"id"`
 	Username  string   `json:"username"`
@@ -106,6 +115,7 @@ func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 		&user.Role.Name,
 		&user.Role.Level,
 		&user.Role.Description,
+		&user.Role.TwoFactorRequired,
 	)
 	if err != nil {
 		switch err {
@@ -142,6 +152,7 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 		&user.Role.Name,
 		&user.Role.Level,
 		&user.Role.Description,
+		&user.Role.TwoFactorRequired,
 	)
 	if err != nil {
 		switch err {
@@ -178,6 +189,7 @@ func (s *UserStore) GetByEmailAndPassword(ctx context.Context, email, password s
 		&user.Role.Name,
 		&user.Role.Level,
 		&user.Role.Description,
+		&user.Role.TwoFactorRequired,
 	)
 	if err != nil {
 		switch err {
@@ -216,7 +228,7 @@ func (s *UserStore) CreateAndInvite(ctx context.Context, user *User, token strin
 func (s *UserStore) Activate(ctx context.Context, token string) error {
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
 		// find the user that this token belongs to
-		user, err := s.getUserFromInvitation(ctx, tx, token)
+		user, _, err := s.getUserFromToken(ctx, tx, ScopeActivation, token)
 		if err != nil {
 			return err
 		}
@@ -225,8 +237,8 @@ func (s *UserStore) Activate(ctx context.Context, token string) error {
 		if err := s.update(ctx, tx, user); err != nil {
 			return err
 		}
-		// clean up the invitations
-		if err := s.deleteUserInvitations(ctx, tx, user.ID); err != nil {
+		// clean up the invitations, the other tokens of the user stay
+		if err := s.deleteUserTokens(ctx, tx, user.ID, ScopeActivation); err != nil {
 			return err
 		}
 
@@ -234,6 +246,307 @@ func (s *UserStore) Activate(ctx context.Context, token string) error {
 	})
 }
 
+// GetByIdentity returns the active user linked to the subject of an oidc
+// issuer.
+func (s *UserStore) GetByIdentity(ctx context.Context, issuer, subject string) (*User, error) {
+	query := `
+		SELECT users.id, username, email, password, users.created_at, roles.*
+		FROM users
+		JOIN roles ON (users.role_id = roles.id)
+		JOIN user_identities ui ON (ui.user_id = users.id)
+		WHERE ui.issuer = $1 AND ui.subject = $2 AND is_active = true
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+	err := s.db.QueryRowContext(ctx, query, issuer, subject).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.Password.hash,
+		&user.CreatedAt,
+		&user.Role.ID,
+		&user.Role.Name,
+		&user.Role.Level,
+		&user.Role.Description,
+		&user.Role.TwoFactorRequired,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// ProvisionIdentity links the subject of an oidc issuer to a user, created
+// when needed, and gives the user the role of user.Role.Name. With linkEmail
+// a local user with the email of user is linked instead of creating one. The
+// users of an identity have no password, they cannot log in locally.
+func (s *UserStore) ProvisionIdentity(ctx context.Context, issuer, subject string, user *User, linkEmail bool) (*User, error) {
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		userID, err := s.getIdentityUserID(ctx, tx, issuer, subject)
+		switch {
+		case err == nil:
+			return s.updateRole(ctx, tx, userID, user.Role.Name)
+		case !errors.Is(err, ErrNotFound):
+			return err
+		}
+
+		if linkEmail {
+			userID, err = s.getIDByEmail(ctx, tx, user.Email)
+			switch {
+			case err == nil:
+				// the provider verified the email, it activates the account
+				if err := s.activate(ctx, tx, userID); err != nil {
+					return err
+				}
+				if err := s.updateRole(ctx, tx, userID, user.Role.Name); err != nil {
+					return err
+				}
+				return s.createIdentity(ctx, tx, userID, issuer, subject)
+			case !errors.Is(err, ErrNotFound):
+				return err
+			}
+		}
+
+		created := &User{
+			Username: user.Username,
+			Email:    user.Email,
+			Password: password{hash: []byte{}},
+			Role:     user.Role,
+		}
+		if err := s.uniqueUsername(ctx, tx, created, subject); err != nil {
+			return err
+		}
+		if err := s.Create(ctx, tx, created); err != nil {
+			return err
+		}
+		if err := s.activate(ctx, tx, created.ID); err != nil {
+			return err
+		}
+		return s.createIdentity(ctx, tx, created.ID, issuer, subject)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetByIdentity(ctx, issuer, subject)
+}
+
+// RenewInvitation replaces the activation token of the inactive user with
+// email, an active or unknown email gives ErrNotFound.
+func (s *UserStore) RenewInvitation(ctx context.Context, email, token string, invitationExp time.Duration) (*User, error) {
+	var user *User
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		found, err := s.getInactiveByEmail(ctx, tx, email)
+		if err != nil {
+			return err
+		}
+
+		if err := s.deleteUserTokens(ctx, tx, found.ID, ScopeActivation); err != nil {
+			return err
+		}
+		if err := s.createUserInvitation(ctx, tx, token, invitationExp, found.ID); err != nil {
+			return err
+		}
+		user = found
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ActivateByID activates a user without a token, for the admins.
+func (s *UserStore) ActivateByID(ctx context.Context, userID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `UPDATE users SET is_active = true WHERE id = $1`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		return s.deleteUserTokens(ctx, tx, userID, ScopeActivation)
+	})
+}
+
+// DeleteExpiredInvitations deletes the expired tokens of every scope.
+func (s *UserStore) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
+	query := `DELETE FROM user_invitations WHERE expiry <= $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// DeleteInactive deletes the users never activated that registered before
+// createdBefore, along with their tokens.
+func (s *UserStore) DeleteInactive(ctx context.Context, createdBefore time.Time) (int64, error) {
+	var deleted int64
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+			DELETE FROM user_invitations
+			WHERE user_id IN (SELECT id FROM users WHERE is_active = false AND created_at < $1)
+		`
+		if _, err := tx.ExecContext(ctx, query, createdBefore); err != nil {
+			return err
+		}
+
+		query = `DELETE FROM users WHERE is_active = false AND created_at < $1`
+		res, err := tx.ExecContext(ctx, query, createdBefore)
+		if err != nil {
+			return err
+		}
+		deleted, err = res.RowsAffected()
+
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// CreatePasswordReset stores a reset token, the previous ones of the user
+// are dropped so only the last email works.
+func (s *UserStore) CreatePasswordReset(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.deleteUserTokens(ctx, tx, userID, ScopePasswordReset); err != nil {
+			return err
+		}
+
+		return s.createUserToken(ctx, tx, ScopePasswordReset, token, exp, userID, nil)
+	})
+}
+
+// ResetPassword sets the password of the user the token belongs to, the
+// token is used up.
+func (s *UserStore) ResetPassword(ctx context.Context, token string, user *User) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		found, _, err := s.getUserFromToken(ctx, tx, ScopePasswordReset, token)
+		if err != nil {
+			return err
+		}
+		user.ID = found.ID
+		user.Username = found.Username
+		user.Email = found.Email
+
+		if err := s.updatePassword(ctx, tx, user); err != nil {
+			return err
+		}
+
+		return s.deleteUserTokens(ctx, tx, user.ID, ScopePasswordReset)
+	})
+}
+
+func (s *UserStore) UpdatePassword(ctx context.Context, user *User) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.updatePassword(ctx, tx, user)
+	})
+}
+
+// CreateEmailChange stores the token confirming email for the user, the
+// address is only changed once it is confirmed.
+func (s *UserStore) CreateEmailChange(ctx context.Context, userID int64, email, token string, exp time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.deleteUserTokens(ctx, tx, userID, ScopeEmailChange); err != nil {
+			return err
+		}
+
+		return s.createUserToken(ctx, tx, ScopeEmailChange, token, exp, userID, &email)
+	})
+}
+
+// ChangeEmail confirms an email change, the user gets the address the token
+// was created for.
+func (s *UserStore) ChangeEmail(ctx context.Context, token string) (*User, error) {
+	var user *User
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		found, email, err := s.getUserFromToken(ctx, tx, ScopeEmailChange, token)
+		if err != nil {
+			return err
+		}
+		if email == nil {
+			return ErrNotFound
+		}
+
+		found.Email = *email
+		if err := s.update(ctx, tx, found); err != nil {
+			return err
+		}
+		user = found
+
+		return s.deleteUserTokens(ctx, tx, found.ID, ScopeEmailChange)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// CreateTwoFactorEnrollment stores the token confirming a two-factor
+// enrollment at login, the previous ones of the user are dropped.
+func (s *UserStore) CreateTwoFactorEnrollment(ctx context.Context, userID int64, token string, exp time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.deleteUserTokens(ctx, tx, userID, ScopeTwoFactor); err != nil {
+			return err
+		}
+
+		return s.createUserToken(ctx, tx, ScopeTwoFactor, token, exp, userID, nil)
+	})
+}
+
+// ConfirmTwoFactorEnrollment uses up an enrollment token and returns its
+// user.
+func (s *UserStore) ConfirmTwoFactorEnrollment(ctx context.Context, token string) (*User, error) {
+	var user *User
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		found, _, err := s.getUserFromToken(ctx, tx, ScopeTwoFactor, token)
+		if err != nil {
+			return err
+		}
+		user = found
+
+		return s.deleteUserTokens(ctx, tx, found.ID, ScopeTwoFactor)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
 func (s *UserStore) Delete(ctx context.Context, userID int64) error {
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
 		if err := s.delete(ctx, tx, userID); err != nil {
@@ -249,12 +562,16 @@ func (s *UserStore) Delete(ctx context.Context, userID int64) error {
 }
 
 func (s *UserStore) createUserInvitation(ctx context.Context, tx *sql.Tx, token string, exp time.Duration, userID int64) error {
-	query := `INSERT INTO user_invitations (token,user_id,expiry) VALUES ($1, $2, $3)`
+	return s.createUserToken(ctx, tx, ScopeActivation, token, exp, userID, nil)
+}
+
+func (s *UserStore) createUserToken(ctx context.Context, tx *sql.Tx, scope, token string, exp time.Duration, userID int64, email *string) error {
+	query := `INSERT INTO user_invitations (token,user_id,expiry,scope,email) VALUES ($1, $2, $3, $4, $5)`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := tx.ExecContext(ctx, query, token, userID, time.Now().Add(exp))
+	_, err := tx.ExecContext(ctx, query, token, userID, time.Now().Add(exp), scope, email)
 	if err != nil {
 		return err
 	}
@@ -262,12 +579,14 @@ func (s *UserStore) createUserInvitation(ctx context.Context, tx *sql.Tx, token
 	return nil
 }
 
-func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token string) (*User, error) {
+// getUserFromToken finds the user of an unexpired token of the scope, along
+// with the email the token carries.
+func (s *UserStore) getUserFromToken(ctx context.Context, tx *sql.Tx, scope, token string) (*User, *string, error) {
 	query := `
-		SELECT u.id, u.username, u.email, u.created_at, u.is_active
+		SELECT u.id, u.username, u.email, u.created_at, u.is_active, ui.email
 		FROM users u
 		JOIN user_invitations ui ON u.id = ui.user_id
-		WHERE ui.token = $1 AND ui.expiry > $2 
+		WHERE ui.token = $1 AND ui.expiry > $2 AND ui.scope = $3
 	`
 	// store
 	hash := sha256.Sum256([]byte(token))
@@ -277,7 +596,133 @@ func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token
 	defer cancel()
 
 	user := &User{}
-	err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(
+	var email *string
+	err := tx.QueryRowContext(ctx, query, hashToken, time.Now(), scope).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.IsActive,
+		&email,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, nil, ErrNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+	return user, email, nil
+}
+
+func (s *UserStore) getIdentityUserID(ctx context.Context, tx *sql.Tx, issuer, subject string) (int64, error) {
+	query := `SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var userID int64
+	if err := tx.QueryRowContext(ctx, query, issuer, subject).Scan(&userID); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+func (s *UserStore) getIDByEmail(ctx context.Context, tx *sql.Tx, email string) (int64, error) {
+	query := `SELECT id FROM users WHERE email = $1 FOR UPDATE`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var userID int64
+	if err := tx.QueryRowContext(ctx, query, email).Scan(&userID); err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+func (s *UserStore) createIdentity(ctx context.Context, tx *sql.Tx, userID int64, issuer, subject string) error {
+	query := `INSERT INTO user_identities (user_id, issuer, subject) VALUES ($1, $2, $3)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID, issuer, subject)
+	return err
+}
+
+// uniqueUsername suffixes the username of user when a local user has it
+// already, a failed insert would abort the transaction.
+func (s *UserStore) uniqueUsername(ctx context.Context, tx *sql.Tx, user *User, subject string) error {
+	query := `SELECT EXISTS (SELECT 1 FROM users WHERE username = $1)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, query, user.Username).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		suffix := subject
+		if len(suffix) > 8 {
+			suffix = suffix[:8]
+		}
+		user.Username = user.Username + "-" + suffix
+	}
+
+	return nil
+}
+
+func (s *UserStore) activate(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `UPDATE users SET is_active = true WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	return err
+}
+
+func (s *UserStore) updateRole(ctx context.Context, tx *sql.Tx, userID int64, role string) error {
+	if role == "" {
+		role = "user"
+	}
+	query := `UPDATE users SET role_id = (SELECT id FROM roles WHERE name = $1) WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, role, userID)
+	return err
+}
+
+func (s *UserStore) getInactiveByEmail(ctx context.Context, tx *sql.Tx, email string) (*User, error) {
+	query := `
+		SELECT id, username, email, created_at, is_active
+		FROM users
+		WHERE email = $1 AND is_active = false
+		FOR UPDATE
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	user := &User{}
+	err := tx.QueryRowContext(ctx, query, email).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
@@ -292,6 +737,7 @@ func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token
 			return nil, err
 		}
 	}
+
 	return user, nil
 }
 
@@ -302,6 +748,25 @@ func (s *UserStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
 	defer cancel()
 
 	_, err := tx.ExecContext(ctx, query, user.Username, user.Email, user.IsActive, user.ID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+			return ErrDuplicateEmail
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *UserStore) updatePassword(ctx context.Context, tx *sql.Tx, user *User) error {
+	query := `UPDATE users SET password = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, user.Password.hash, user.ID)
 	if err != nil {
 		return err
 	}
@@ -323,6 +788,20 @@ func (s *UserStore) deleteUserInvitations(ctx context.Context, tx *sql.Tx, userI
 	return nil
 }
 
+func (s *UserStore) deleteUserTokens(ctx context.Context, tx *sql.Tx, userID int64, scope string) error {
+	query := `DELETE FROM user_invitations WHERE user_id = $1 AND scope = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID, scope)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (s *UserStore) delete(ctx context.Context, tx *sql.Tx, id int64) error {
 	query := `DELETE FROM users WHERE id = $1`
 
@@ -0,0 +1,127 @@
+package zeebe
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/damarteplok/social/internal/store"
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failures opening the breaker.
+	FailureThreshold int
+	// OpenTimeout is how long an open breaker rejects requests before it lets
+	// a single probe through.
+	OpenTimeout time.Duration
+}
+
+// BreakerStatus is the state of the breaker of a component as reported by
+// the health check.
+type BreakerStatus struct {
+	State     BreakerState `json:"state"`
+	Failures  int          `json:"failures"`
+	OpenUntil *time.Time   `json:"openUntil,omitempty"`
+}
+
+// CircuitOpenError is returned without calling the component while its
+// breaker is open, it unwraps to store.ErrServiceUnavailable.
+type CircuitOpenError struct {
+	Component  Component
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s is unavailable, circuit breaker is open", e.Component)
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return store.ErrServiceUnavailable
+}
+
+// circuitBreaker counts consecutive failures of a component, 5xx responses
+// and transport errors are failures while any other response is a success.
+type circuitBreaker struct {
+	component Component
+	config    BreakerConfig
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(component Component, cfg BreakerConfig) *circuitBreaker {
+	return &circuitBreaker{component: component, config: cfg, state: BreakerClosed}
+}
+
+// allow reports whether a request may be sent, an open breaker turns half
+// open after its timeout and then lets one probe through at a time.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if b.state == BreakerOpen && now.After(b.openUntil) {
+		b.state = BreakerHalfOpen
+	}
+
+	switch {
+	case b.state == BreakerOpen:
+		return &CircuitOpenError{Component: b.component, RetryAfter: b.openUntil.Sub(now)}
+	case b.state == BreakerHalfOpen && b.probing:
+		return &CircuitOpenError{Component: b.component, RetryAfter: b.config.OpenTimeout}
+	case b.state == BreakerHalfOpen:
+		b.probing = true
+	}
+
+	return nil
+}
+
+func (b *circuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.config.FailureThreshold {
+		b.state = BreakerOpen
+		b.openUntil = time.Now().Add(b.config.OpenTimeout)
+	}
+}
+
+// release ends a probe without a result, such as one canceled by its caller.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *circuitBreaker) status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{State: b.state, Failures: b.failures}
+	if b.state == BreakerOpen {
+		openUntil := b.openUntil
+		status.OpenUntil = &openUntil
+	}
+
+	return status
+}
@@ -0,0 +1,1037 @@
+package zeebe
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/commands"
+	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/entities"
+	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/pb"
+	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/worker"
+	"github.com/damarteplok/social/internal/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	FakeStateActive    = "ACTIVE"
+	FakeStateCompleted = "COMPLETED"
+	FakeStateCanceled  = "CANCELED"
+	FakeStateCreated   = "CREATED"
+	FakeStateResolved  = "RESOLVED"
+
+	fakeStateTerminated = "TERMINATED"
+	fakeJobRetries      = 3
+	// the first key zeebe hands out on partition 1
+	fakeFirstKey = 2251799813685249
+)
+
+var variableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// FakeEngine is an in-memory ZeebeCamunda for tests, no cluster needed. It
+// walks the instances along the sequence flows of the deployed bpmn: user
+// tasks wait for CompleteUserTask, service tasks become jobs handed to the
+// worker of their type and exclusive gateways take the first flow whose
+// condition holds. Conditions compare a variable with a literal, e.g.
+// =decision="submit", other elements are passed through.
+//
+// The engine also serves the operate, tasklist and zeebe rest endpoints used
+// through ZeebeClientRest, see ServeHTTP.
+type FakeEngine struct {
+	mu          sync.Mutex
+	key         int64
+	definitions []*fakeDefinition
+	instances   []*FakeInstance
+	elements    []*fakeElementInstance
+	tasks       []*FakeUserTask
+	jobs        []*fakeJob
+	incidents   []*FakeIncident
+	workers     map[string]*fakeWorker
+	mux         *http.ServeMux
+	now         func() time.Time
+}
+
+type FakeInstance struct {
+	Key                  int64
+	ProcessDefinitionKey int64
+	BpmnProcessID        string
+	Version              int32
+	State                string
+	Variables            map[string]interface{}
+	StartDate            time.Time
+	EndDate              *time.Time
+	definition           *fakeDefinition
+}
+
+type FakeUserTask struct {
+	Key                  int64
+	ProcessInstanceKey   int64
+	ProcessDefinitionKey int64
+	BpmnProcessID        string
+	ElementID            string
+	Name                 string
+	FormID               string
+	Assignee             string
+	CandidateGroups      []string
+	CandidateUsers       []string
+	State                string
+	CreationDate         time.Time
+	CompletionDate       *time.Time
+	element              *fakeElementInstance
+}
+
+type FakeIncident struct {
+	Key                  int64
+	ProcessInstanceKey   int64
+	ProcessDefinitionKey int64
+	JobKey               int64
+	ElementID            string
+	Type                 string
+	Message              string
+	State                string
+	CreationTime         time.Time
+	element              *fakeElementInstance
+}
+
+type fakeDefinition struct {
+	metadata *pb.ProcessMetadata
+	name     string
+	resource []byte
+	nodes    map[string]fakeNode
+	outgoing map[string][]fakeNode
+	starts   []string
+}
+
+type fakeElementInstance struct {
+	key       int64
+	instance  *FakeInstance
+	node      fakeNode
+	state     string
+	incident  bool
+	startDate time.Time
+	endDate   *time.Time
+}
+
+type fakeJob struct {
+	key       int64
+	jobType   string
+	retries   int32
+	activated bool
+	element   *fakeElementInstance
+}
+
+type fakeWorker struct {
+	engine  *FakeEngine
+	jobType string
+	name    string
+	handler worker.JobHandler
+}
+
+// fakeNode is any element of a process, sequence flows included.
+type fakeNode struct {
+	XMLName             xml.Name
+	ID                  string             `xml:"id,attr"`
+	Name                string             `xml:"name,attr"`
+	SourceRef           string             `xml:"sourceRef,attr"`
+	TargetRef           string             `xml:"targetRef,attr"`
+	Default             string             `xml:"default,attr"`
+	ConditionExpression string             `xml:"conditionExpression"`
+	ExtensionElements   []ExtensionElement `xml:"extensionElements"`
+}
+
+type fakeDocument struct {
+	Processes []struct {
+		ID           string     `xml:"id,attr"`
+		Name         string     `xml:"name,attr"`
+		IsExecutable bool       `xml:"isExecutable,attr"`
+		Nodes        []fakeNode `xml:",any"`
+	} `xml:"process"`
+}
+
+func NewFakeEngine() *FakeEngine {
+	e := &FakeEngine{
+		key:     fakeFirstKey,
+		workers: map[string]*fakeWorker{},
+		now:     time.Now,
+	}
+	e.routes()
+	return e
+}
+
+func (e *FakeEngine) Close() error {
+	return nil
+}
+
+// Topology reports a single healthy broker.
+func (e *FakeEngine) Topology(ctx context.Context) (*pb.TopologyResponse, error) {
+	return &pb.TopologyResponse{
+		Brokers: []*pb.BrokerInfo{{
+			NodeId: 0,
+			Host:   "fake",
+			Partitions: []*pb.Partition{{
+				PartitionId: 1,
+				Role:        pb.Partition_LEADER,
+				Health:      pb.Partition_HEALTHY,
+			}},
+		}},
+		ClusterSize:       1,
+		PartitionsCount:   1,
+		ReplicationFactor: 1,
+	}, nil
+}
+
+// DeployProcessDefinitionFromFiles deploys the executable processes of the
+// file, the forms are not checked.
+func (e *FakeEngine) DeployProcessDefinitionFromFiles(file *os.File, formResources []*os.File) ([]*pb.ProcessMetadata, []BPMNProcess, error) {
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read file content: %w", err)
+	}
+
+	return e.deploy(file.Name(), content)
+}
+
+// DeployProcessDefinition deploys a bpmn of the embedded resources.
+func (e *FakeEngine) DeployProcessDefinition(resourceName string, formResources []string) ([]*pb.ProcessMetadata, []BPMNProcess, error) {
+	definition, err := MustReadFile(resourceName)
+	if err != nil {
+		return nil, nil, errors.New("failed to read file")
+	}
+
+	for _, formResource := range formResources {
+		if _, err := MustReadFile(formResource); err != nil {
+			return nil, nil, fmt.Errorf("failed to read form file %s: %w", formResource, err)
+		}
+	}
+
+	return e.deploy(resourceName, definition)
+}
+
+func (e *FakeEngine) deploy(resourceName string, content []byte) ([]*pb.ProcessMetadata, []BPMNProcess, error) {
+	bpmnProcess, err := unMarshalBpmn(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal xml: %w", err)
+	}
+
+	var document fakeDocument
+	if err := xml.Unmarshal(content, &document); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal xml: %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var processes []*pb.ProcessMetadata
+	for _, process := range document.Processes {
+		if !process.IsExecutable {
+			continue
+		}
+
+		var version int32 = 1
+		for _, definition := range e.definitions {
+			if definition.metadata.BpmnProcessId == process.ID && definition.metadata.Version >= version {
+				version = definition.metadata.Version + 1
+			}
+		}
+
+		definition := &fakeDefinition{
+			metadata: &pb.ProcessMetadata{
+				BpmnProcessId:        process.ID,
+				Version:              version,
+				ProcessDefinitionKey: e.nextKey(),
+				ResourceName:         resourceName,
+			},
+			name:     process.Name,
+			resource: content,
+			nodes:    map[string]fakeNode{},
+			outgoing: map[string][]fakeNode{},
+		}
+		for _, node := range process.Nodes {
+			switch node.XMLName.Local {
+			case "sequenceFlow":
+				definition.outgoing[node.SourceRef] = append(definition.outgoing[node.SourceRef], node)
+			case "startEvent":
+				definition.starts = append(definition.starts, node.ID)
+				definition.nodes[node.ID] = node
+			default:
+				definition.nodes[node.ID] = node
+			}
+		}
+
+		e.definitions = append(e.definitions, definition)
+		processes = append(processes, definition.metadata)
+	}
+
+	if len(processes) < 1 {
+		return nil, nil, errors.New("failed to deploy model; nothing was deployed")
+	}
+
+	return processes, bpmnProcess, nil
+}
+
+// StartWorkflow starts an instance, the jobs it reaches are handed to the
+// workers before it returns.
+func (e *FakeEngine) StartWorkflow(ctx context.Context, processDefinitionKey int64, variables map[string]interface{}) (*pb.CreateProcessInstanceResponse, error) {
+	e.mu.Lock()
+	instance, jobs, err := e.start(processDefinitionKey, variables)
+	e.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start workflow: %w", err)
+	}
+
+	e.dispatch(jobs)
+
+	return &pb.CreateProcessInstanceResponse{
+		ProcessDefinitionKey: instance.ProcessDefinitionKey,
+		BpmnProcessId:        instance.BpmnProcessID,
+		Version:              instance.Version,
+		ProcessInstanceKey:   instance.Key,
+	}, nil
+}
+
+func (e *FakeEngine) start(processDefinitionKey int64, variables map[string]interface{}) (*FakeInstance, []*fakeJob, error) {
+	var definition *fakeDefinition
+	for _, d := range e.definitions {
+		if d.metadata.ProcessDefinitionKey == processDefinitionKey {
+			definition = d
+		}
+	}
+	if definition == nil {
+		return nil, nil, store.ErrNotFound
+	}
+
+	instance := &FakeInstance{
+		Key:                  e.nextKey(),
+		ProcessDefinitionKey: processDefinitionKey,
+		BpmnProcessID:        definition.metadata.BpmnProcessId,
+		Version:              definition.metadata.Version,
+		State:                FakeStateActive,
+		Variables:            map[string]interface{}{},
+		StartDate:            e.now(),
+		definition:           definition,
+	}
+	if err := mergeVariables(instance.Variables, variables); err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", store.ErrBadRequest, err)
+	}
+	e.instances = append(e.instances, instance)
+
+	var jobs []*fakeJob
+	for _, start := range definition.starts {
+		jobs = append(jobs, e.enter(instance, start)...)
+	}
+	e.settle(instance)
+
+	return instance, jobs, nil
+}
+
+// CancelWorkflow cancels an active instance, it returns store.ErrNotFound
+// otherwise like the real client.
+func (e *FakeEngine) CancelWorkflow(ctx context.Context, processInstanceKey int64) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	instance := e.findInstance(processInstanceKey)
+	if instance == nil || instance.State != FakeStateActive {
+		return store.ErrNotFound
+	}
+
+	now := e.now()
+	instance.State = FakeStateCanceled
+	instance.EndDate = &now
+
+	for _, element := range e.elements {
+		if element.instance == instance && element.state == FakeStateActive {
+			element.state = fakeStateTerminated
+			element.endDate = &now
+		}
+	}
+	for _, task := range e.tasks {
+		if task.ProcessInstanceKey == instance.Key && task.State == FakeStateCreated {
+			task.State = FakeStateCanceled
+		}
+	}
+	for _, incident := range e.incidents {
+		if incident.ProcessInstanceKey == instance.Key {
+			incident.State = FakeStateResolved
+		}
+	}
+	jobs := e.jobs[:0]
+	for _, job := range e.jobs {
+		if job.element.instance != instance {
+			jobs = append(jobs, job)
+		}
+	}
+	e.jobs = jobs
+
+	return nil
+}
+
+// UpdateProcessInstance merges the variables into an active instance.
+func (e *FakeEngine) UpdateProcessInstance(ctx context.Context, processInstanceKey int64, variables map[string]interface{}) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	instance := e.findInstance(processInstanceKey)
+	if instance == nil || instance.State != FakeStateActive {
+		return fmt.Errorf("failed to update variables from process instance: %w", status.Errorf(codes.NotFound, "process instance %d not found", processInstanceKey))
+	}
+
+	return mergeVariables(instance.Variables, variables)
+}
+
+// StartWorker registers the handler of a job type, the jobs already waiting
+// are handed to it right away.
+func (e *FakeEngine) StartWorker(jobType, nameWorker string, cfg WorkerConfig, handler worker.JobHandler) (worker.JobWorker, error) {
+	w := &fakeWorker{engine: e, jobType: jobType, name: nameWorker, handler: handler}
+
+	e.mu.Lock()
+	e.workers[jobType] = w
+	var jobs []*fakeJob
+	for _, job := range e.jobs {
+		if job.jobType == jobType {
+			jobs = append(jobs, job)
+		}
+	}
+	e.mu.Unlock()
+
+	e.dispatch(jobs)
+
+	return w, nil
+}
+
+func (w *fakeWorker) Close() {
+	w.engine.mu.Lock()
+	defer w.engine.mu.Unlock()
+
+	if w.engine.workers[w.jobType] == w {
+		delete(w.engine.workers, w.jobType)
+	}
+}
+
+func (w *fakeWorker) AwaitClose() {}
+
+// CompleteUserTask completes a created user task with the variables, like a
+// user submitting its form in tasklist.
+func (e *FakeEngine) CompleteUserTask(userTaskKey int64, variables map[string]interface{}) error {
+	e.mu.Lock()
+	task := e.findUserTask(userTaskKey)
+	if task == nil {
+		e.mu.Unlock()
+		return store.ErrNotFound
+	}
+	if task.State != FakeStateCreated {
+		e.mu.Unlock()
+		return fmt.Errorf("%w: user task %d is not active", store.ErrBadRequest, userTaskKey)
+	}
+
+	instance := task.element.instance
+	if err := mergeVariables(instance.Variables, variables); err != nil {
+		e.mu.Unlock()
+		return fmt.Errorf("%w: %s", store.ErrBadRequest, err)
+	}
+
+	now := e.now()
+	task.State = FakeStateCompleted
+	task.CompletionDate = &now
+	jobs := e.leave(task.element)
+	e.settle(instance)
+	e.mu.Unlock()
+
+	e.dispatch(jobs)
+
+	return nil
+}
+
+// AssignUserTask claims a created user task for the assignee, a task assigned
+// to someone else is only taken over with allowOverride.
+func (e *FakeEngine) AssignUserTask(userTaskKey int64, assignee string, allowOverride bool) (FakeUserTask, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	task := e.findUserTask(userTaskKey)
+	if task == nil {
+		return FakeUserTask{}, store.ErrNotFound
+	}
+	if task.State != FakeStateCreated || assignee == "" {
+		return FakeUserTask{}, store.ErrBadRequest
+	}
+	if task.Assignee != "" && task.Assignee != assignee && !allowOverride {
+		return FakeUserTask{}, fmt.Errorf("%w: user task %d is already assigned", store.ErrBadRequest, userTaskKey)
+	}
+
+	task.Assignee = assignee
+	return *task, nil
+}
+
+// UnassignUserTask releases an assigned user task.
+func (e *FakeEngine) UnassignUserTask(userTaskKey int64) (FakeUserTask, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	task := e.findUserTask(userTaskKey)
+	if task == nil {
+		return FakeUserTask{}, store.ErrNotFound
+	}
+	if task.State != FakeStateCreated || task.Assignee == "" {
+		return FakeUserTask{}, store.ErrBadRequest
+	}
+
+	task.Assignee = ""
+	return *task, nil
+}
+
+// ResolveIncident resolves an active incident. A job gets one more retry, like
+// updating its retries in operate, a gateway evaluates its conditions again.
+func (e *FakeEngine) ResolveIncident(incidentKey int64) error {
+	e.mu.Lock()
+	incident := e.findIncident(incidentKey)
+	if incident == nil || incident.State != FakeStateActive {
+		e.mu.Unlock()
+		return store.ErrNotFound
+	}
+
+	incident.State = FakeStateResolved
+	incident.element.incident = false
+
+	var jobs []*fakeJob
+	if job := e.findJob(incident.JobKey); job != nil {
+		if job.retries < 1 {
+			job.retries = 1
+		}
+		jobs = append(jobs, job)
+	} else {
+		jobs = e.leave(incident.element)
+	}
+	e.settle(incident.element.instance)
+	e.mu.Unlock()
+
+	e.dispatch(jobs)
+
+	return nil
+}
+
+// DeleteProcessDefinition removes a definition, its running instances go on.
+func (e *FakeEngine) DeleteProcessDefinition(processDefinitionKey int64) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i, definition := range e.definitions {
+		if definition.metadata.ProcessDefinitionKey == processDefinitionKey {
+			e.definitions = append(e.definitions[:i], e.definitions[i+1:]...)
+			return nil
+		}
+	}
+
+	return store.ErrNotFound
+}
+
+// Instance returns a copy of a process instance.
+func (e *FakeEngine) Instance(processInstanceKey int64) (FakeInstance, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	instance := e.findInstance(processInstanceKey)
+	if instance == nil {
+		return FakeInstance{}, false
+	}
+
+	return instance.copy(), true
+}
+
+// UserTasks returns copies of the user tasks of every instance in the order
+// they were created.
+func (e *FakeEngine) UserTasks() []FakeUserTask {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	tasks := make([]FakeUserTask, len(e.tasks))
+	for i, task := range e.tasks {
+		tasks[i] = *task
+	}
+	return tasks
+}
+
+// Incidents returns copies of the incidents in the order they were raised.
+func (e *FakeEngine) Incidents() []FakeIncident {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	incidents := make([]FakeIncident, len(e.incidents))
+	for i, incident := range e.incidents {
+		incidents[i] = *incident
+	}
+	return incidents
+}
+
+// enter activates an element of the instance. The jobs it creates are
+// returned to be dispatched once the lock is released.
+func (e *FakeEngine) enter(instance *FakeInstance, elementID string) []*fakeJob {
+	node, ok := instance.definition.nodes[elementID]
+	if !ok {
+		return nil
+	}
+
+	element := &fakeElementInstance{
+		key:       e.nextKey(),
+		instance:  instance,
+		node:      node,
+		state:     FakeStateActive,
+		startDate: e.now(),
+	}
+	e.elements = append(e.elements, element)
+
+	if node.XMLName.Local == "userTask" {
+		task := &FakeUserTask{
+			Key:                  e.nextKey(),
+			ProcessInstanceKey:   instance.Key,
+			ProcessDefinitionKey: instance.ProcessDefinitionKey,
+			BpmnProcessID:        instance.BpmnProcessID,
+			ElementID:            node.ID,
+			Name:                 node.Name,
+			State:                FakeStateCreated,
+			CreationDate:         element.startDate,
+			element:              element,
+		}
+		for _, extension := range node.ExtensionElements {
+			for _, form := range extension.FormDefinitions {
+				task.FormID = form.FormID
+			}
+			for _, assignment := range extension.AssignmentDefinitions {
+				task.Assignee = staticValue(assignment.Assignee)
+				task.CandidateGroups = staticValues(assignment.CandidateGroups)
+				task.CandidateUsers = staticValues(assignment.CandidateUsers)
+			}
+		}
+		e.tasks = append(e.tasks, task)
+		return nil
+	}
+
+	if jobType := node.jobType(); jobType != "" {
+		job := &fakeJob{
+			key:     e.nextKey(),
+			jobType: jobType,
+			retries: fakeJobRetries,
+			element: element,
+		}
+		e.jobs = append(e.jobs, job)
+		return []*fakeJob{job}
+	}
+
+	return e.leave(element)
+}
+
+// leave completes an element and enters the targets of the flows it takes,
+// an incident is raised when a gateway has no flow to take.
+func (e *FakeEngine) leave(element *fakeElementInstance) []*fakeJob {
+	flows, err := e.outgoing(element)
+	if err != nil {
+		e.raise(element, 0, "CONDITION_ERROR", err.Error())
+		return nil
+	}
+
+	now := e.now()
+	element.state = FakeStateCompleted
+	element.endDate = &now
+
+	var jobs []*fakeJob
+	for _, flow := range flows {
+		jobs = append(jobs, e.enter(element.instance, flow.TargetRef)...)
+	}
+	return jobs
+}
+
+func (e *FakeEngine) outgoing(element *fakeElementInstance) ([]fakeNode, error) {
+	flows := element.instance.definition.outgoing[element.node.ID]
+	variables := element.instance.Variables
+
+	if element.node.XMLName.Local == "exclusiveGateway" {
+		var fallback []fakeNode
+		for _, flow := range flows {
+			if flow.ID == element.node.Default {
+				fallback = []fakeNode{flow}
+				continue
+			}
+			ok, err := evaluateCondition(flow.ConditionExpression, variables)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return []fakeNode{flow}, nil
+			}
+		}
+		if fallback == nil {
+			return nil, fmt.Errorf("expected at least one condition to evaluate to true, or to have a default flow at %s", element.node.ID)
+		}
+		return fallback, nil
+	}
+
+	var taken []fakeNode
+	for _, flow := range flows {
+		ok, err := evaluateCondition(flow.ConditionExpression, variables)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			taken = append(taken, flow)
+		}
+	}
+	return taken, nil
+}
+
+func (e *FakeEngine) raise(element *fakeElementInstance, jobKey int64, incidentType, message string) {
+	element.incident = true
+	e.incidents = append(e.incidents, &FakeIncident{
+		Key:                  e.nextKey(),
+		ProcessInstanceKey:   element.instance.Key,
+		ProcessDefinitionKey: element.instance.ProcessDefinitionKey,
+		JobKey:               jobKey,
+		ElementID:            element.node.ID,
+		Type:                 incidentType,
+		Message:              message,
+		State:                FakeStateActive,
+		CreationTime:         e.now(),
+		element:              element,
+	})
+}
+
+// settle completes the instance once none of its elements is active anymore.
+func (e *FakeEngine) settle(instance *FakeInstance) {
+	if instance.State != FakeStateActive {
+		return
+	}
+
+	for _, element := range e.elements {
+		if element.instance == instance && element.state == FakeStateActive {
+			return
+		}
+	}
+
+	now := e.now()
+	instance.State = FakeStateCompleted
+	instance.EndDate = &now
+}
+
+// dispatch hands the jobs to the workers of their type, one after the other.
+// The handlers run without the lock so they can complete or fail their job.
+func (e *FakeEngine) dispatch(jobs []*fakeJob) {
+	for _, job := range jobs {
+		e.mu.Lock()
+		w, ok := e.workers[job.jobType]
+		if !ok || job.activated || job.retries < 1 || job.element.incident || e.findJob(job.key) != job {
+			e.mu.Unlock()
+			continue
+		}
+
+		variables, err := json.Marshal(job.element.instance.Variables)
+		if err != nil {
+			e.mu.Unlock()
+			continue
+		}
+
+		job.activated = true
+		instance := job.element.instance
+		activated := &pb.ActivatedJob{
+			Key:                      job.key,
+			Type:                     job.jobType,
+			ProcessInstanceKey:       instance.Key,
+			BpmnProcessId:            instance.BpmnProcessID,
+			ProcessDefinitionVersion: instance.Version,
+			ProcessDefinitionKey:     instance.ProcessDefinitionKey,
+			ElementId:                job.element.node.ID,
+			ElementInstanceKey:       job.element.key,
+			CustomHeaders:            "{}",
+			Worker:                   w.name,
+			Retries:                  job.retries,
+			Deadline:                 e.now().Add(5 * time.Minute).UnixMilli(),
+			Variables:                string(variables),
+		}
+		e.mu.Unlock()
+
+		w.handler(&fakeJobClient{gateway: &fakeGateway{engine: e}}, entities.Job{ActivatedJob: activated})
+	}
+}
+
+func (e *FakeEngine) completeJob(jobKey int64, variables string) error {
+	e.mu.Lock()
+	job := e.findJob(jobKey)
+	if job == nil {
+		e.mu.Unlock()
+		return status.Errorf(codes.NotFound, "job %d not found", jobKey)
+	}
+
+	instance := job.element.instance
+	if variables != "" {
+		if err := json.Unmarshal([]byte(variables), &instance.Variables); err != nil {
+			e.mu.Unlock()
+			return status.Errorf(codes.InvalidArgument, "invalid variables: %s", err)
+		}
+	}
+
+	e.removeJob(job)
+	jobs := e.leave(job.element)
+	e.settle(instance)
+	e.mu.Unlock()
+
+	e.dispatch(jobs)
+
+	return nil
+}
+
+// failJob raises an incident when the job has no retries left, otherwise the
+// job is handed to the worker again right away, the backoff is ignored.
+func (e *FakeEngine) failJob(jobKey int64, retries int32, message, incidentType string) error {
+	e.mu.Lock()
+	job := e.findJob(jobKey)
+	if job == nil {
+		e.mu.Unlock()
+		return status.Errorf(codes.NotFound, "job %d not found", jobKey)
+	}
+
+	job.activated = false
+	job.retries = retries
+	if retries < 1 {
+		e.raise(job.element, job.key, incidentType, message)
+	}
+	e.mu.Unlock()
+
+	e.dispatch([]*fakeJob{job})
+
+	return nil
+}
+
+func (e *FakeEngine) nextKey() int64 {
+	key := e.key
+	e.key++
+	return key
+}
+
+func (e *FakeEngine) findInstance(key int64) *FakeInstance {
+	for _, instance := range e.instances {
+		if instance.Key == key {
+			return instance
+		}
+	}
+	return nil
+}
+
+func (e *FakeEngine) findDefinition(key int64) *fakeDefinition {
+	for _, definition := range e.definitions {
+		if definition.metadata.ProcessDefinitionKey == key {
+			return definition
+		}
+	}
+	return nil
+}
+
+func (e *FakeEngine) findUserTask(key int64) *FakeUserTask {
+	for _, task := range e.tasks {
+		if task.Key == key {
+			return task
+		}
+	}
+	return nil
+}
+
+func (e *FakeEngine) findJob(key int64) *fakeJob {
+	for _, job := range e.jobs {
+		if job.key == key {
+			return job
+		}
+	}
+	return nil
+}
+
+func (e *FakeEngine) findIncident(key int64) *FakeIncident {
+	for _, incident := range e.incidents {
+		if incident.Key == key {
+			return incident
+		}
+	}
+	return nil
+}
+
+func (e *FakeEngine) removeJob(job *fakeJob) {
+	for i, j := range e.jobs {
+		if j == job {
+			e.jobs = append(e.jobs[:i], e.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+// hasIncident reports whether an incident of the instance is active.
+func (e *FakeEngine) hasIncident(instance *FakeInstance) bool {
+	for _, incident := range e.incidents {
+		if incident.ProcessInstanceKey == instance.Key && incident.State == FakeStateActive {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *FakeInstance) copy() FakeInstance {
+	instance := *i
+	instance.Variables = make(map[string]interface{}, len(i.Variables))
+	for name, value := range i.Variables {
+		instance.Variables[name] = value
+	}
+	return instance
+}
+
+func (n fakeNode) jobType() string {
+	for _, extension := range n.ExtensionElements {
+		for _, definition := range extension.TaskDefinitions {
+			if definition.Type != "" {
+				return definition.Type
+			}
+		}
+	}
+	return ""
+}
+
+// mergeVariables stores the values the way they come back from json, like the
+// engine does.
+func mergeVariables(variables map[string]interface{}, values interface{}) error {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	if string(data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(data, &variables)
+}
+
+// evaluateCondition evaluates the condition of a sequence flow, only a
+// comparison of a variable with a json literal is understood, e.g.
+// =decision="submit" or =approved != true.
+func evaluateCondition(expression string, variables map[string]interface{}) (bool, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return true, nil
+	}
+
+	condition, ok := strings.CutPrefix(expression, "=")
+	if !ok {
+		return false, fmt.Errorf("unsupported condition %q", expression)
+	}
+
+	operator := "="
+	name, literal, ok := strings.Cut(condition, "!=")
+	if ok {
+		operator = "!="
+	} else if name, literal, ok = strings.Cut(condition, "="); !ok {
+		return false, fmt.Errorf("unsupported condition %q", expression)
+	}
+	name = strings.TrimSpace(name)
+	literal = strings.TrimSpace(literal)
+	if !variableNameRegex.MatchString(name) {
+		return false, fmt.Errorf("unsupported condition %q", expression)
+	}
+
+	var expected interface{}
+	if err := json.Unmarshal([]byte(literal), &expected); err != nil {
+		return false, fmt.Errorf("unsupported condition %q", expression)
+	}
+
+	value, err := json.Marshal(variables[name])
+	if err != nil {
+		return false, err
+	}
+	want, err := json.Marshal(expected)
+	if err != nil {
+		return false, err
+	}
+
+	equal := string(value) == string(want)
+	if operator == "!=" {
+		return !equal, nil
+	}
+	return equal, nil
+}
+
+// staticValue drops an assignment set by an expression.
+func staticValue(value string) string {
+	if strings.HasPrefix(value, "=") {
+		return ""
+	}
+	return strings.TrimSpace(value)
+}
+
+func staticValues(value string) []string {
+	value = staticValue(value)
+	if value == "" {
+		return nil
+	}
+
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// fakeJobClient lets the job handlers complete and fail their job with the
+// commands of the real client.
+type fakeJobClient struct {
+	gateway *fakeGateway
+}
+
+func (c *fakeJobClient) NewCompleteJobCommand() commands.CompleteJobCommandStep1 {
+	return commands.NewCompleteJobCommand(c.gateway, noRetry)
+}
+
+func (c *fakeJobClient) NewFailJobCommand() commands.FailJobCommandStep1 {
+	return commands.NewFailJobCommand(c.gateway, noRetry)
+}
+
+func (c *fakeJobClient) NewThrowErrorCommand() commands.ThrowErrorCommandStep1 {
+	return commands.NewThrowErrorCommand(c.gateway, noRetry)
+}
+
+func noRetry(ctx context.Context, err error) bool {
+	return false
+}
+
+// fakeGateway implements the job commands of the gateway, the other calls
+// are not supported.
+type fakeGateway struct {
+	pb.GatewayClient
+	engine *FakeEngine
+}
+
+func (g *fakeGateway) CompleteJob(ctx context.Context, in *pb.CompleteJobRequest, opts ...grpc.CallOption) (*pb.CompleteJobResponse, error) {
+	if err := g.engine.completeJob(in.GetJobKey(), in.GetVariables()); err != nil {
+		return nil, err
+	}
+	return &pb.CompleteJobResponse{}, nil
+}
+
+func (g *fakeGateway) FailJob(ctx context.Context, in *pb.FailJobRequest, opts ...grpc.CallOption) (*pb.FailJobResponse, error) {
+	if err := g.engine.failJob(in.GetJobKey(), in.GetRetries(), in.GetErrorMessage(), "JOB_NO_RETRIES"); err != nil {
+		return nil, err
+	}
+	return &pb.FailJobResponse{}, nil
+}
+
+// ThrowError raises an incident, the fake has no error boundary events.
+func (g *fakeGateway) ThrowError(ctx context.Context, in *pb.ThrowErrorRequest, opts ...grpc.CallOption) (*pb.ThrowErrorResponse, error) {
+	message := fmt.Sprintf("expected to throw an error event with the code '%s', but it was not caught: %s", in.GetErrorCode(), in.GetErrorMessage())
+	if err := g.engine.failJob(in.GetJobKey(), 0, message, "UNHANDLED_ERROR_EVENT"); err != nil {
+		return nil, err
+	}
+	return &pb.ThrowErrorResponse{}, nil
+}
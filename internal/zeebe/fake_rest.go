@@ -0,0 +1,864 @@
+package zeebe
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/damarteplok/social/internal/store"
+)
+
+const (
+	fakeDateLayout = "2006-01-02T15:04:05.000-0700"
+	fakeTenantID   = "<default>"
+	fakeSearchSize = 50
+)
+
+// fakeSearch is the body of the operate and zeebe v2 searches, the filter
+// keeps the items whose field has the same value, fields an item does not
+// have are ignored.
+type fakeSearch struct {
+	Filter map[string]interface{} `json:"filter"`
+	Size   int                    `json:"size"`
+	fakeCursor
+	Page struct {
+		Limit int `json:"limit"`
+		fakeCursor
+	} `json:"page"`
+	Sort []struct {
+		Field string `json:"field"`
+		Order string `json:"order"`
+	} `json:"sort"`
+}
+
+// ServeHTTP serves the endpoints of operate, tasklist and the zeebe rest api
+// the api uses, on a single address. A token for ZeebeClientRest is issued on
+// /token.
+// fakeCursor pages a search. The fake sorts by creation, so the sort value
+// of an item is its position in the list of its kind.
+type fakeCursor struct {
+	SearchAfter  []interface{} `json:"searchAfter"`
+	SearchBefore []interface{} `json:"searchBefore"`
+}
+
+func (e *FakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mux.ServeHTTP(w, r)
+}
+
+func (e *FakeEngine) routes() {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /token", e.tokenHandler)
+
+	// zeebe
+	mux.HandleFunc("POST /v2/process-instances", e.createProcessInstanceHandler)
+	mux.HandleFunc("POST /v2/process-instances/search", e.searchProcessInstanceKeysHandler)
+	mux.HandleFunc("POST /v2/process-instances/{key}/cancellation", e.cancelProcessInstanceHandler)
+	mux.HandleFunc("POST /v2/resources/{key}/deletion", e.deleteResourceHandler)
+	mux.HandleFunc("POST /v2/incidents/{key}/resolution", e.resolveIncidentHandler)
+	mux.HandleFunc("POST /v2/user-tasks/search", e.searchUserTasksHandler)
+
+	// tasklist
+	mux.HandleFunc("POST /v1/tasks/search", e.searchTasksHandler)
+	mux.HandleFunc("GET /v1/tasks/{key}", e.getTaskHandler)
+	mux.HandleFunc("PATCH /v1/tasks/{key}/assign", e.assignTaskHandler)
+	mux.HandleFunc("PATCH /v1/tasks/{key}/unassign", e.unassignTaskHandler)
+	mux.HandleFunc("PATCH /v1/tasks/{key}/complete", e.completeTaskHandler)
+
+	// operate
+	mux.HandleFunc("GET /v1/process-instances/{key}", e.getProcessInstanceHandler)
+	mux.HandleFunc("POST /v1/process-instances/search", e.searchProcessInstancesHandler)
+	mux.HandleFunc("POST /v1/flownode-instances/search", e.searchFlowNodesHandler)
+	mux.HandleFunc("POST /v1/incidents/search", e.searchIncidentsHandler)
+	mux.HandleFunc("POST /v1/variables/search", e.searchVariablesHandler)
+	mux.HandleFunc("GET /v1/process-definitions/{key}", e.getProcessDefinitionHandler)
+	mux.HandleFunc("POST /v1/process-definitions/search", e.searchProcessDefinitionsHandler)
+	mux.HandleFunc("GET /v1/process-definitions/{key}/xml", e.processDefinitionXMLHandler)
+	mux.HandleFunc("GET /api/process-instances/core-statistics", e.coreStatisticsHandler)
+	mux.HandleFunc("GET /api/incidents/byProcess", e.incidentsByProcessHandler)
+
+	e.mux = mux
+}
+
+func (e *FakeEngine) tokenHandler(w http.ResponseWriter, r *http.Request) {
+	writeFakeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token":  "fake-token",
+		"refresh_token": "fake-refresh-token",
+		"expires_in":    3600,
+	})
+}
+
+func (e *FakeEngine) createProcessInstanceHandler(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		ProcessDefinitionKey json.Number            `json:"processDefinitionKey"`
+		Variables            map[string]interface{} `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeFakeError(w, store.ErrBadRequest)
+		return
+	}
+
+	processDefinitionKey, err := payload.ProcessDefinitionKey.Int64()
+	if err != nil {
+		writeFakeError(w, store.ErrBadRequest)
+		return
+	}
+
+	response, err := e.StartWorkflow(r.Context(), processDefinitionKey, payload.Variables)
+	if err != nil {
+		writeFakeError(w, err)
+		return
+	}
+
+	writeFakeJSON(w, http.StatusOK, map[string]interface{}{
+		"processDefinitionKey":     response.ProcessDefinitionKey,
+		"processDefinitionId":      response.BpmnProcessId,
+		"processDefinitionVersion": response.Version,
+		"processInstanceKey":       response.ProcessInstanceKey,
+		"tenantId":                 fakeTenantID,
+		"variables":                map[string]string{},
+	})
+}
+
+func (e *FakeEngine) cancelProcessInstanceHandler(w http.ResponseWriter, r *http.Request) {
+	key, ok := fakeKeyParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := e.CancelWorkflow(r.Context(), key); err != nil {
+		writeFakeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *FakeEngine) deleteResourceHandler(w http.ResponseWriter, r *http.Request) {
+	key, ok := fakeKeyParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := e.DeleteProcessDefinition(key); err != nil {
+		writeFakeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *FakeEngine) resolveIncidentHandler(w http.ResponseWriter, r *http.Request) {
+	key, ok := fakeKeyParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := e.ResolveIncident(key); err != nil {
+		writeFakeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *FakeEngine) searchUserTasksHandler(w http.ResponseWriter, r *http.Request) {
+	search, ok := readFakeSearch(w, r)
+	if !ok {
+		return
+	}
+
+	e.mu.Lock()
+	items := make([]map[string]interface{}, len(e.tasks))
+	for i, task := range e.tasks {
+		items[i] = map[string]interface{}{
+			"key":                  task.Key,
+			"elementId":            task.ElementID,
+			"elementInstanceKey":   task.element.key,
+			"processInstanceKey":   task.ProcessInstanceKey,
+			"processDefinitionKey": task.ProcessDefinitionKey,
+			"bpmnProcessId":        task.BpmnProcessID,
+			"state":                task.State,
+			"assignee":             fakeOptional(task.Assignee),
+			"candidateGroups":      task.CandidateGroups,
+			"candidateUsers":       task.CandidateUsers,
+			"formKey":              nil,
+			"creationDate":         task.CreationDate.Format(fakeDateLayout),
+			"completionDate":       fakeDate(task.CompletionDate),
+			"tenantIds":            fakeTenantID,
+		}
+	}
+	e.mu.Unlock()
+
+	page := filterFakeItems(items, search.Filter, search.descending(), search.Page.Limit, search.Page.fakeCursor)
+	result := map[string]interface{}{"totalItems": len(page.items)}
+	if len(page.items) > 0 {
+		result["firstSortValues"] = page.sortValues(0)
+		result["lastSortValues"] = page.sortValues(len(page.items) - 1)
+	}
+	writeFakeJSON(w, http.StatusOK, map[string]interface{}{
+		"items": page.items,
+		"page":  result,
+	})
+}
+
+// searchTasksHandler searches the tasks of tasklist v1, its filters are not
+// nested in a filter object.
+func (e *FakeEngine) searchTasksHandler(w http.ResponseWriter, r *http.Request) {
+	var filter map[string]interface{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&filter); err != nil {
+		writeFakeError(w, store.ErrBadRequest)
+		return
+	}
+
+	size := 0
+	if pageSize, ok := filter["pageSize"].(json.Number); ok {
+		n, _ := pageSize.Int64()
+		size = int(n)
+	}
+	descending := true
+	if sort, ok := filter["sort"].([]interface{}); ok && len(sort) > 0 {
+		if field, ok := sort[0].(map[string]interface{}); ok {
+			descending = field["order"] != "ASC"
+		}
+	}
+	if state, ok := filter["state"]; ok {
+		filter["taskState"] = state
+	}
+	var cursor fakeCursor
+	cursor.SearchAfter, _ = filter["searchAfter"].([]interface{})
+	cursor.SearchBefore, _ = filter["searchBefore"].([]interface{})
+	for _, key := range []string{"state", "pageSize", "sort", "searchAfter", "searchAfterOrEqual", "searchBefore", "searchBeforeOrEqual"} {
+		delete(filter, key)
+	}
+
+	e.mu.Lock()
+	items := make([]map[string]interface{}, len(e.tasks))
+	for i, task := range e.tasks {
+		items[i] = e.tasklistTask(task)
+	}
+	e.mu.Unlock()
+
+	page := filterFakeItems(items, filter, descending, size, cursor)
+	for i, item := range page.items {
+		item["sortValues"] = []string{strconv.Itoa(page.positions[i])}
+	}
+	writeFakeJSON(w, http.StatusOK, page.items)
+}
+
+func (e *FakeEngine) getTaskHandler(w http.ResponseWriter, r *http.Request) {
+	key, ok := fakeKeyParam(w, r)
+	if !ok {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	task := e.findUserTask(key)
+	if task == nil {
+		writeFakeError(w, store.ErrNotFound)
+		return
+	}
+
+	writeFakeJSON(w, http.StatusOK, e.tasklistTask(task))
+}
+
+func (e *FakeEngine) assignTaskHandler(w http.ResponseWriter, r *http.Request) {
+	key, ok := fakeKeyParam(w, r)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		Assignee                string `json:"assignee"`
+		AllowOverrideAssignment bool   `json:"allowOverrideAssignment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeFakeError(w, store.ErrBadRequest)
+		return
+	}
+
+	task, err := e.AssignUserTask(key, payload.Assignee, payload.AllowOverrideAssignment)
+	if err != nil {
+		writeFakeError(w, err)
+		return
+	}
+
+	e.mu.Lock()
+	item := e.tasklistTask(&task)
+	e.mu.Unlock()
+
+	writeFakeJSON(w, http.StatusOK, item)
+}
+
+func (e *FakeEngine) unassignTaskHandler(w http.ResponseWriter, r *http.Request) {
+	key, ok := fakeKeyParam(w, r)
+	if !ok {
+		return
+	}
+
+	task, err := e.UnassignUserTask(key)
+	if err != nil {
+		writeFakeError(w, err)
+		return
+	}
+
+	e.mu.Lock()
+	item := e.tasklistTask(&task)
+	e.mu.Unlock()
+
+	writeFakeJSON(w, http.StatusOK, item)
+}
+
+// completeTaskHandler completes a task, tasklist sends every variable value
+// json encoded.
+func (e *FakeEngine) completeTaskHandler(w http.ResponseWriter, r *http.Request) {
+	key, ok := fakeKeyParam(w, r)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		Variables []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeFakeError(w, store.ErrBadRequest)
+		return
+	}
+
+	variables := map[string]interface{}{}
+	for _, variable := range payload.Variables {
+		var value interface{}
+		if err := json.Unmarshal([]byte(variable.Value), &value); err != nil {
+			writeFakeError(w, store.ErrBadRequest)
+			return
+		}
+		variables[variable.Name] = value
+	}
+
+	if err := e.CompleteUserTask(key, variables); err != nil {
+		writeFakeError(w, err)
+		return
+	}
+
+	e.mu.Lock()
+	task := e.tasklistTask(e.findUserTask(key))
+	e.mu.Unlock()
+
+	writeFakeJSON(w, http.StatusOK, task)
+}
+
+func (e *FakeEngine) getProcessInstanceHandler(w http.ResponseWriter, r *http.Request) {
+	key, ok := fakeKeyParam(w, r)
+	if !ok {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	instance := e.findInstance(key)
+	if instance == nil {
+		writeFakeError(w, store.ErrNotFound)
+		return
+	}
+
+	writeFakeJSON(w, http.StatusOK, e.operateInstance(instance))
+}
+
+func (e *FakeEngine) searchProcessInstancesHandler(w http.ResponseWriter, r *http.Request) {
+	search, ok := readFakeSearch(w, r)
+	if !ok {
+		return
+	}
+
+	e.mu.Lock()
+	items := make([]map[string]interface{}, len(e.instances))
+	for i, instance := range e.instances {
+		items[i] = e.operateInstance(instance)
+	}
+	e.mu.Unlock()
+
+	writeFakeOperateItems(w, filterFakeItems(items, search.Filter, search.descending(), search.Size, search.fakeCursor))
+}
+
+// searchProcessInstanceKeysHandler searches the instances of a set of keys
+// like the v2 api, the items have the fields of operate.
+func (e *FakeEngine) searchProcessInstanceKeysHandler(w http.ResponseWriter, r *http.Request) {
+	var search struct {
+		Filter struct {
+			ProcessInstanceKeys []int64 `json:"processInstanceKeys"`
+		} `json:"filter"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&search); err != nil {
+		writeFakeError(w, store.ErrBadRequest)
+		return
+	}
+
+	e.mu.Lock()
+	items := []map[string]interface{}{}
+	for _, key := range search.Filter.ProcessInstanceKeys {
+		if instance := e.findInstance(key); instance != nil {
+			items = append(items, e.operateInstance(instance))
+		}
+	}
+	e.mu.Unlock()
+
+	writeFakeJSON(w, http.StatusOK, map[string]interface{}{
+		"items": items,
+		"page":  map[string]interface{}{"totalItems": len(items)},
+	})
+}
+
+func (e *FakeEngine) searchFlowNodesHandler(w http.ResponseWriter, r *http.Request) {
+	search, ok := readFakeSearch(w, r)
+	if !ok {
+		return
+	}
+
+	e.mu.Lock()
+	items := make([]map[string]interface{}, len(e.elements))
+	for i, element := range e.elements {
+		items[i] = map[string]interface{}{
+			"key":                  element.key,
+			"processInstanceKey":   element.instance.Key,
+			"processDefinitionKey": element.instance.ProcessDefinitionKey,
+			"startDate":            element.startDate.Format(fakeDateLayout),
+			"endDate":              fakeDate(element.endDate),
+			"flowNodeId":           element.node.ID,
+			"flowNodeName":         element.node.Name,
+			"type":                 strings.ToUpper(toSnakeCase(element.node.XMLName.Local)),
+			"state":                element.state,
+			"incident":             element.incident,
+			"tenantId":             fakeTenantID,
+		}
+	}
+	e.mu.Unlock()
+
+	writeFakeOperateItems(w, filterFakeItems(items, search.Filter, search.descending(), search.Size, search.fakeCursor))
+}
+
+func (e *FakeEngine) searchIncidentsHandler(w http.ResponseWriter, r *http.Request) {
+	search, ok := readFakeSearch(w, r)
+	if !ok {
+		return
+	}
+
+	e.mu.Lock()
+	items := make([]map[string]interface{}, len(e.incidents))
+	for i, incident := range e.incidents {
+		items[i] = map[string]interface{}{
+			"key":                  incident.Key,
+			"processDefinitionKey": incident.ProcessDefinitionKey,
+			"processInstanceKey":   incident.ProcessInstanceKey,
+			"type":                 incident.Type,
+			"message":              incident.Message,
+			"creationTime":         incident.CreationTime.Format(fakeDateLayout),
+			"state":                incident.State,
+			"jobKey":               incident.JobKey,
+			"tenantId":             fakeTenantID,
+		}
+	}
+	e.mu.Unlock()
+
+	writeFakeOperateItems(w, filterFakeItems(items, search.Filter, search.descending(), search.Size, search.fakeCursor))
+}
+
+// searchVariablesHandler searches the variables of the instances, operate
+// keeps the values json encoded.
+func (e *FakeEngine) searchVariablesHandler(w http.ResponseWriter, r *http.Request) {
+	search, ok := readFakeSearch(w, r)
+	if !ok {
+		return
+	}
+
+	e.mu.Lock()
+	var items []map[string]interface{}
+	for _, instance := range e.instances {
+		names := make([]string, 0, len(instance.Variables))
+		for name := range instance.Variables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			value, err := json.Marshal(instance.Variables[name])
+			if err != nil {
+				continue
+			}
+			items = append(items, map[string]interface{}{
+				"processInstanceKey": instance.Key,
+				"scopeKey":           instance.Key,
+				"name":               name,
+				"value":              string(value),
+				"truncated":          false,
+				"tenantId":           fakeTenantID,
+			})
+		}
+	}
+	e.mu.Unlock()
+
+	writeFakeOperateItems(w, filterFakeItems(items, search.Filter, search.descending(), search.Size, search.fakeCursor))
+}
+
+func (e *FakeEngine) getProcessDefinitionHandler(w http.ResponseWriter, r *http.Request) {
+	key, ok := fakeKeyParam(w, r)
+	if !ok {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	definition := e.findDefinition(key)
+	if definition == nil {
+		writeFakeError(w, store.ErrNotFound)
+		return
+	}
+
+	writeFakeJSON(w, http.StatusOK, operateDefinition(definition))
+}
+
+func (e *FakeEngine) searchProcessDefinitionsHandler(w http.ResponseWriter, r *http.Request) {
+	search, ok := readFakeSearch(w, r)
+	if !ok {
+		return
+	}
+
+	e.mu.Lock()
+	items := make([]map[string]interface{}, len(e.definitions))
+	for i, definition := range e.definitions {
+		items[i] = operateDefinition(definition)
+	}
+	e.mu.Unlock()
+
+	writeFakeOperateItems(w, filterFakeItems(items, search.Filter, search.descending(), search.Size, search.fakeCursor))
+}
+
+func (e *FakeEngine) processDefinitionXMLHandler(w http.ResponseWriter, r *http.Request) {
+	key, ok := fakeKeyParam(w, r)
+	if !ok {
+		return
+	}
+
+	e.mu.Lock()
+	definition := e.findDefinition(key)
+	e.mu.Unlock()
+	if definition == nil {
+		writeFakeError(w, store.ErrNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(definition.resource)
+}
+
+func (e *FakeEngine) coreStatisticsHandler(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var running, withIncidents int
+	for _, instance := range e.instances {
+		if instance.State != FakeStateActive {
+			continue
+		}
+		running++
+		if e.hasIncident(instance) {
+			withIncidents++
+		}
+	}
+
+	writeFakeJSON(w, http.StatusOK, map[string]interface{}{
+		"running":       running,
+		"active":        running - withIncidents,
+		"withIncidents": withIncidents,
+	})
+}
+
+// incidentsByProcessHandler counts the active instances of every deployed
+// process, grouped by bpmn process id.
+func (e *FakeEngine) incidentsByProcessHandler(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	type processStats struct {
+		stats     map[string]interface{}
+		processes []map[string]interface{}
+		active    int
+		incidents int
+	}
+
+	var ids []string
+	byID := map[string]*processStats{}
+	for _, definition := range e.definitions {
+		var active, incidents int
+		for _, instance := range e.instances {
+			if instance.definition != definition || instance.State != FakeStateActive {
+				continue
+			}
+			active++
+			if e.hasIncident(instance) {
+				incidents++
+			}
+		}
+
+		id := definition.metadata.BpmnProcessId
+		stats, ok := byID[id]
+		if !ok {
+			stats = &processStats{}
+			byID[id] = stats
+			ids = append(ids, id)
+		}
+		stats.active += active
+		stats.incidents += incidents
+		stats.processes = append(stats.processes, map[string]interface{}{
+			"processId":                         strconv.FormatInt(definition.metadata.ProcessDefinitionKey, 10),
+			"version":                           definition.metadata.Version,
+			"name":                              definition.name,
+			"bpmnProcessId":                     id,
+			"tenantId":                          fakeTenantID,
+			"errorMessage":                      nil,
+			"instancesWithActiveIncidentsCount": incidents,
+			"activeInstancesCount":              active - incidents,
+		})
+		stats.stats = map[string]interface{}{
+			"bpmnProcessId": id,
+			"tenantId":      fakeTenantID,
+			"processName":   definition.name,
+		}
+	}
+
+	result := make([]map[string]interface{}, len(ids))
+	for i, id := range ids {
+		stats := byID[id]
+		stats.stats["instancesWithActiveIncidentsCount"] = stats.incidents
+		stats.stats["activeInstancesCount"] = stats.active - stats.incidents
+		stats.stats["processes"] = stats.processes
+		result[i] = stats.stats
+	}
+
+	writeFakeJSON(w, http.StatusOK, result)
+}
+
+func (e *FakeEngine) tasklistTask(task *FakeUserTask) map[string]interface{} {
+	var processName string
+	if definition := e.findDefinition(task.ProcessDefinitionKey); definition != nil {
+		processName = definition.name
+	}
+
+	return map[string]interface{}{
+		"id":                   strconv.FormatInt(task.Key, 10),
+		"name":                 task.Name,
+		"taskDefinitionId":     task.ElementID,
+		"processName":          processName,
+		"creationDate":         task.CreationDate.Format(fakeDateLayout),
+		"completionDate":       fakeDate(task.CompletionDate),
+		"assignee":             fakeOptional(task.Assignee),
+		"taskState":            task.State,
+		"formKey":              nil,
+		"formId":               fakeOptional(task.FormID),
+		"processDefinitionKey": strconv.FormatInt(task.ProcessDefinitionKey, 10),
+		"processInstanceKey":   strconv.FormatInt(task.ProcessInstanceKey, 10),
+		"candidateGroups":      task.CandidateGroups,
+		"candidateUsers":       task.CandidateUsers,
+		"tenantId":             fakeTenantID,
+	}
+}
+
+func operateDefinition(definition *fakeDefinition) map[string]interface{} {
+	return map[string]interface{}{
+		"key":           definition.metadata.ProcessDefinitionKey,
+		"name":          definition.name,
+		"version":       definition.metadata.Version,
+		"bpmnProcessId": definition.metadata.BpmnProcessId,
+		"tenantId":      fakeTenantID,
+	}
+}
+
+func (e *FakeEngine) operateInstance(instance *FakeInstance) map[string]interface{} {
+	return map[string]interface{}{
+		"key":                  instance.Key,
+		"processVersion":       instance.Version,
+		"bpmnProcessId":        instance.BpmnProcessID,
+		"startDate":            instance.StartDate.Format(fakeDateLayout),
+		"endDate":              fakeDate(instance.EndDate),
+		"state":                instance.State,
+		"processDefinitionKey": instance.ProcessDefinitionKey,
+		"incident":             e.hasIncident(instance),
+		"tenantId":             fakeTenantID,
+	}
+}
+
+func (s fakeSearch) descending() bool {
+	return len(s.Sort) > 0 && s.Sort[0].Order == "DESC"
+}
+
+// fakePage is a page of a search, positions holds the position of each item
+// in the list it was found in.
+type fakePage struct {
+	items     []map[string]interface{}
+	positions []int
+	backward  bool
+}
+
+func (p fakePage) sortValues(i int) []interface{} {
+	return []interface{}{p.positions[i]}
+}
+
+// cursor returns the sort values to continue from, those of the last item or
+// of the first when paging backward.
+func (p fakePage) cursor() []interface{} {
+	if len(p.items) == 0 {
+		return nil
+	}
+	if p.backward {
+		return p.sortValues(0)
+	}
+	return p.sortValues(len(p.items) - 1)
+}
+
+// filterFakeItems returns the items, in the order they were created, that
+// match the filter and lie after or before the cursor.
+func filterFakeItems(items []map[string]interface{}, filter map[string]interface{}, descending bool, size int, cursor fakeCursor) fakePage {
+	if size <= 0 {
+		size = fakeSearchSize
+	}
+
+	// order maps a position to its index in the order of the search and back
+	order := func(position int) int {
+		if descending {
+			return len(items) - 1 - position
+		}
+		return position
+	}
+	after, before := -1, len(items)
+	if position, ok := fakeCursorPosition(cursor.SearchAfter); ok {
+		after = order(position)
+	}
+	if position, ok := fakeCursorPosition(cursor.SearchBefore); ok {
+		before = order(position)
+	}
+
+	page := fakePage{items: []map[string]interface{}{}, backward: cursor.SearchBefore != nil}
+	for i := after + 1; i < before && i < len(items); i++ {
+		position := order(i)
+		item := items[position]
+
+		matches := true
+		for field, value := range filter {
+			if v, ok := item[field]; ok && fmt.Sprint(v) != fmt.Sprint(value) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			page.items = append(page.items, item)
+			page.positions = append(page.positions, position)
+		}
+	}
+
+	// paging backward keeps the items closest to the cursor
+	if len(page.items) > size {
+		if page.backward {
+			page.items = page.items[len(page.items)-size:]
+			page.positions = page.positions[len(page.positions)-size:]
+		} else {
+			page.items = page.items[:size]
+			page.positions = page.positions[:size]
+		}
+	}
+	return page
+}
+
+func fakeCursorPosition(values []interface{}) (int, bool) {
+	if len(values) == 0 {
+		return 0, false
+	}
+	position, err := strconv.Atoi(fmt.Sprint(values[0]))
+	if err != nil {
+		return 0, false
+	}
+	return position, true
+}
+
+func readFakeSearch(w http.ResponseWriter, r *http.Request) (fakeSearch, bool) {
+	var search fakeSearch
+
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(r.Body); err != nil {
+		writeFakeError(w, store.ErrBadRequest)
+		return search, false
+	}
+	if body.Len() == 0 {
+		return search, true
+	}
+
+	decoder := json.NewDecoder(&body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&search); err != nil {
+		writeFakeError(w, store.ErrBadRequest)
+		return search, false
+	}
+	return search, true
+}
+
+func fakeKeyParam(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	key, err := strconv.ParseInt(r.PathValue("key"), 10, 64)
+	if err != nil {
+		writeFakeError(w, store.ErrBadRequest)
+		return 0, false
+	}
+	return key, true
+}
+
+func fakeOptional(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func fakeDate(date *time.Time) interface{} {
+	if date == nil {
+		return nil
+	}
+	return date.Format(fakeDateLayout)
+}
+
+func writeFakeOperateItems(w http.ResponseWriter, page fakePage) {
+	writeFakeJSON(w, http.StatusOK, map[string]interface{}{
+		"items":      page.items,
+		"sortValues": page.cursor(),
+		"total":      len(page.items),
+	})
+}
+
+func writeFakeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeFakeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, store.ErrBadRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,204 @@
+package zeebe
+
+import (
+	"context"
+	"testing"
+
+	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/entities"
+	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/worker"
+)
+
+const fakeProcessResource = "pembuatan_media_berita_technology.bpmn"
+
+func activeUserTask(t *testing.T, engine *FakeEngine, processInstanceKey int64) FakeUserTask {
+	t.Helper()
+
+	for _, task := range engine.UserTasks() {
+		if task.ProcessInstanceKey == processInstanceKey && task.State == FakeStateCreated {
+			return task
+		}
+	}
+	t.Fatalf("no active user task in instance %d", processInstanceKey)
+	return FakeUserTask{}
+}
+
+func completeUserTask(t *testing.T, engine *FakeEngine, processInstanceKey int64, elementID, decision string) {
+	t.Helper()
+
+	task := activeUserTask(t, engine, processInstanceKey)
+	if task.ElementID != elementID {
+		t.Fatalf("expected user task %s got %s", elementID, task.ElementID)
+	}
+	if err := engine.CompleteUserTask(task.Key, map[string]interface{}{"decision": decision}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFakeEngine(t *testing.T) {
+	ctx := context.Background()
+	engine := NewFakeEngine()
+
+	processes, _, err := engine.DeployProcessDefinition(fakeProcessResource, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(processes) != 1 || processes[0].Version != 1 {
+		t.Fatalf("unexpected deployment %v", processes)
+	}
+	processDefinitionKey := processes[0].ProcessDefinitionKey
+
+	completed := []string{}
+	complete := func(client worker.JobClient, job entities.Job) {
+		completed = append(completed, job.GetElementId())
+		request, err := client.NewCompleteJobCommand().JobKey(job.GetKey()).VariablesFromMap(map[string]interface{}{"post_id": 1})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if _, err := request.Send(ctx); err != nil {
+			t.Error(err)
+		}
+	}
+
+	t.Run("should walk an instance through its user and service tasks", func(t *testing.T) {
+		w, err := engine.StartWorker("service_task_published_artikel", "test", WorkerConfig{}, complete)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer w.Close()
+
+		instance, err := engine.StartWorkflow(ctx, processDefinitionKey, map[string]interface{}{"title": "judul"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		key := instance.GetProcessInstanceKey()
+
+		completeUserTask(t, engine, key, "creating_artikel", "draft")
+		completeUserTask(t, engine, key, "creating_artikel", "submit")
+		completeUserTask(t, engine, key, "reviewing_artikel", "lolos")
+		completeUserTask(t, engine, key, "approving_artikel", "publish")
+
+		got, ok := engine.Instance(key)
+		if !ok {
+			t.Fatal("instance not found")
+		}
+		if got.State != FakeStateCompleted {
+			t.Errorf("expected state %s got %s", FakeStateCompleted, got.State)
+		}
+		if len(completed) != 1 || completed[0] != "published" {
+			t.Errorf("expected the published job to be completed got %v", completed)
+		}
+		if got.Variables["title"] != "judul" || got.Variables["post_id"] != float64(1) {
+			t.Errorf("unexpected variables %v", got.Variables)
+		}
+	})
+
+	t.Run("should raise and resolve incidents", func(t *testing.T) {
+		instance, err := engine.StartWorkflow(ctx, processDefinitionKey, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key := instance.GetProcessInstanceKey()
+
+		completeUserTask(t, engine, key, "creating_artikel", "unknown")
+
+		incidents := engine.Incidents()
+		incident := incidents[len(incidents)-1]
+		if incident.ProcessInstanceKey != key || incident.Type != "CONDITION_ERROR" || incident.State != FakeStateActive {
+			t.Fatalf("unexpected incident %+v", incident)
+		}
+
+		if err := engine.UpdateProcessInstance(ctx, key, map[string]interface{}{"decision": "submit"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := engine.ResolveIncident(incident.Key); err != nil {
+			t.Fatal(err)
+		}
+		activeUserTask(t, engine, key)
+
+		if err := engine.ResolveIncident(incident.Key); err == nil {
+			t.Error("expected a resolved incident not to be resolved again")
+		}
+	})
+
+	t.Run("should raise an incident when a job has no retries left", func(t *testing.T) {
+		fail := func(client worker.JobClient, job entities.Job) {
+			_, err := client.NewFailJobCommand().JobKey(job.GetKey()).Retries(job.GetRetries() - 1).ErrorMessage("boom").Send(ctx)
+			if err != nil {
+				t.Error(err)
+			}
+		}
+		w, err := engine.StartWorker("service_task_archived", "test", WorkerConfig{}, fail)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer w.Close()
+
+		instance, err := engine.StartWorkflow(ctx, processDefinitionKey, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key := instance.GetProcessInstanceKey()
+
+		completeUserTask(t, engine, key, "creating_artikel", "submit")
+		completeUserTask(t, engine, key, "reviewing_artikel", "lolos")
+		completeUserTask(t, engine, key, "approving_artikel", "archive")
+
+		incidents := engine.Incidents()
+		incident := incidents[len(incidents)-1]
+		if incident.ProcessInstanceKey != key || incident.Type != "JOB_NO_RETRIES" || incident.Message != "boom" {
+			t.Fatalf("unexpected incident %+v", incident)
+		}
+
+		if err := engine.CancelWorkflow(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := engine.Instance(key); got.State != FakeStateCanceled {
+			t.Errorf("expected state %s got %s", FakeStateCanceled, got.State)
+		}
+		if err := engine.CancelWorkflow(ctx, key); err == nil {
+			t.Error("expected a canceled instance not to be canceled again")
+		}
+	})
+
+	t.Run("should increase the version of a redeployed process", func(t *testing.T) {
+		processes, _, err := engine.DeployProcessDefinition(fakeProcessResource, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if processes[0].Version != 2 || processes[0].ProcessDefinitionKey == processDefinitionKey {
+			t.Errorf("unexpected deployment %v", processes[0])
+		}
+	})
+}
+
+func TestEvaluateCondition(t *testing.T) {
+	variables := map[string]interface{}{"decision": "submit", "count": float64(3), "approved": true}
+
+	tests := []struct {
+		expression string
+		want       bool
+		err        bool
+	}{
+		{expression: "", want: true},
+		{expression: `=decision="submit"`, want: true},
+		{expression: `= decision = "draft"`, want: false},
+		{expression: `=decision != "draft"`, want: true},
+		{expression: `=count = 3`, want: true},
+		{expression: `=approved = true`, want: true},
+		{expression: `=missing = null`, want: true},
+		{expression: `=count >= 3`, err: true},
+		{expression: `decision="submit"`, err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := evaluateCondition(tt.expression, variables)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: unexpected error %v", tt.expression, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %v got %v", tt.expression, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package zeebe
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// formField is a value submitted with a form. The payload and the user task
+// model share its type, it is stored in its own column unless Column is empty.
+type formField struct {
+	Key        string
+	Name       string
+	Type       string
+	Column     string
+	ColumnType string
+	Validate   string
+	// Item is the struct of the entries of a dynamic list, with Fields
+	Item   string
+	Fields []formField
+}
+
+// formFields returns the fields of a form, the ones whose column would clash
+// with the columns of the table or is not a valid name, e.g. a keyword, stay in
+// properties only.
+func formFields(form *Form, name string, columns []migrationColumn) []formField {
+	fields := componentFields(form.Components, name)
+
+	// submit buttons carry the gateway variable (e.g. decision) in their
+	// custom properties, one value per button
+	buttonKeys := []string{}
+	buttonValues := map[string][]FormValue{}
+	for _, component := range form.Components {
+		if component.Type != "button" || component.Action != "submit" {
+			continue
+		}
+		for key, value := range component.Properties {
+			if _, ok := buttonValues[key]; !ok {
+				buttonKeys = append(buttonKeys, key)
+			}
+			buttonValues[key] = append(buttonValues[key], FormValue{Value: value})
+		}
+	}
+	sort.Strings(buttonKeys)
+	for _, key := range buttonKeys {
+		validate := "required"
+		if oneof := oneOf(buttonValues[key]); oneof != "" {
+			validate += "," + oneof
+		}
+		fields = appendField(fields, formField{
+			Key:        key,
+			Name:       toCamelCase(key),
+			Type:       "*string",
+			ColumnType: "VARCHAR(256)",
+			Validate:   validate,
+		})
+	}
+
+	seen := map[string]bool{}
+	for _, column := range columns {
+		seen[column.Name] = true
+	}
+	for i := range fields {
+		column := toSnakeCase(fields[i].Key)
+		if seen[column] || !columnNameRegex.MatchString(column) || reservedWords[column] {
+			continue
+		}
+		seen[column] = true
+		fields[i].Column = column
+	}
+
+	return fields
+}
+
+// componentFields returns the fields of the components, prefix names the
+// structs of the dynamic lists.
+func componentFields(components []FormComponent, prefix string) []formField {
+	var fields []formField
+	for _, component := range components {
+		switch {
+		case component.Type == "group" && component.Path == "":
+			for _, field := range componentFields(component.Components, prefix) {
+				fields = appendField(fields, field)
+			}
+		case component.Type == "group":
+			fields = appendField(fields, formField{
+				Key:        component.Path,
+				Name:       toCamelCase(component.Path),
+				Type:       "map[string]interface{}",
+				ColumnType: getColumnType(component),
+				Validate:   fieldValidation(component),
+			})
+		case component.Type == "dynamiclist" && component.Path != "":
+			name := toCamelCase(component.Path)
+			item := prefix + name + "Item"
+			fields = appendField(fields, formField{
+				Key:        component.Path,
+				Name:       name,
+				Type:       "[]" + item,
+				ColumnType: getColumnType(component),
+				Validate:   fieldValidation(component),
+				Item:       item,
+				Fields:     componentFields(component.Components, prefix+name),
+			})
+		case component.Key != "":
+			fields = appendField(fields, formField{
+				Key:        component.Key,
+				Name:       toCamelCase(component.Key),
+				Type:       getFieldType(component),
+				ColumnType: getColumnType(component),
+				Validate:   fieldValidation(component),
+			})
+		}
+	}
+	return fields
+}
+
+func appendField(fields []formField, field formField) []formField {
+	for _, f := range fields {
+		if f.Name == field.Name {
+			return fields
+		}
+	}
+	return append(fields, field)
+}
+
+// fieldValidation returns the validate tag of the constraints form-js checks
+// in the browser.
+func fieldValidation(component FormComponent) string {
+	var rules []string
+	validate := component.Validate
+
+	switch component.Type {
+	case "number":
+		if validate.Min != "" {
+			rules = append(rules, "gte="+validate.Min.String())
+		}
+		if validate.Max != "" {
+			rules = append(rules, "lte="+validate.Max.String())
+		}
+	case "textfield", "textarea":
+		if validate.MinLength != "" {
+			rules = append(rules, "min="+validate.MinLength.String())
+		}
+		if validate.MaxLength != "" {
+			rules = append(rules, "max="+validate.MaxLength.String())
+		}
+		if validate.ValidationType == "email" {
+			rules = append(rules, "email")
+		}
+	case "select", "radio":
+		if oneof := oneOf(component.Values); oneof != "" {
+			rules = append(rules, oneof)
+		}
+	case "checklist", "taglist":
+		if oneof := oneOf(component.Values); oneof != "" {
+			rules = append(rules, "dive", oneof)
+		}
+	case "dynamiclist":
+		rules = append(rules, "dive")
+	case "datetime":
+		if component.Subtype == "" || component.Subtype == "date" {
+			rules = append(rules, "datetime=2006-01-02")
+		}
+	}
+
+	if validate.Required {
+		return strings.Join(append([]string{"required"}, rules...), ",")
+	}
+	if len(rules) > 0 {
+		return "omitempty," + strings.Join(rules, ",")
+	}
+	return ""
+}
+
+// oneOf returns the oneof rule of static values, values that cannot be
+// written in a struct tag leave the check to the process.
+func oneOf(values []FormValue) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	options := make([]string, 0, len(values))
+	for _, value := range values {
+		if value.Value == "" || strings.ContainsAny(value.Value, ",|'\"`") {
+			return ""
+		}
+		if strings.Contains(value.Value, " ") {
+			options = append(options, "'"+value.Value+"'")
+			continue
+		}
+		options = append(options, value.Value)
+	}
+
+	return "oneof=" + strings.Join(options, " ")
+}
+
+func (f formField) tag() string {
+	tag := `json:"` + f.Key
+	if !strings.HasPrefix(f.Validate, "required") {
+		tag += ",omitempty"
+	}
+	tag += `"`
+	if f.Validate != "" {
+		tag += ` validate:"` + f.Validate + `"`
+	}
+	return "`" + tag + "`"
+}
+
+func (f formField) isJSON() bool {
+	return f.ColumnType == "JSONB"
+}
+
+// itemStructCode returns the structs of the dynamic lists, they are declared
+// in the store package and used by the payload too.
+func itemStructCode(fields []formField) string {
+	var code strings.Builder
+	for _, field := range fields {
+		if field.Item == "" {
+			continue
+		}
+		fmt.Fprintf(&code, "\ntype %s struct {\n", field.Item)
+		for _, f := range field.Fields {
+			fmt.Fprintf(&code, "\t%s %s %s\n", f.Name, f.Type, f.tag())
+		}
+		code.WriteString("}\n")
+		code.WriteString(itemStructCode(field.Fields))
+	}
+	return code.String()
+}
+
+// storedFields returns the fields with a column.
+func storedFields(fields []formField) []formField {
+	var stored []formField
+	for _, field := range fields {
+		if field.Column != "" {
+			stored = append(stored, field)
+		}
+	}
+	return stored
+}
@@ -0,0 +1,359 @@
+package zeebe
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Generator renders the store, cache, handler, route and sql code of bpmn
+// processes into a module directory. Files are staged in memory until Write,
+// so a dry run can print the diff first, and generating the same model twice
+// changes nothing.
+type Generator struct {
+	outDir       string
+	migrationDir string
+	force        bool
+	dropColumns  bool
+	forms        map[string]string
+	files        map[string]string
+	skipped      []string
+	userTasks    map[string]string
+	missing      []string
+	keptColumns  []string
+}
+
+// FileChange is a file the generator creates or changes.
+type FileChange struct {
+	Path    string
+	Old     string
+	New     string
+	Created bool
+}
+
+// NewGenerator writes into outDir, the root of the module. Generated files
+// that already exist with other content are kept unless force is set, they
+// are meant to be edited after generation.
+func NewGenerator(outDir string, force bool) *Generator {
+	return &Generator{
+		outDir:       outDir,
+		migrationDir: "cmd/migrate/migrations",
+		force:        force,
+		forms:        map[string]string{},
+		files:        map[string]string{},
+		userTasks:    map[string]string{},
+	}
+}
+
+// SetMigrationDir sets the golang-migrate directory, relative to the module.
+func (g *Generator) SetMigrationDir(dir string) {
+	g.migrationDir = filepath.Clean(dir)
+}
+
+// SetDropColumns lets the migrations drop the columns of removed form fields,
+// without it the columns are kept and reported by KeptColumns.
+func (g *Generator) SetDropColumns(drop bool) {
+	g.dropColumns = drop
+}
+
+// ParseBpmn returns the processes of a bpmn definition.
+func ParseBpmn(content []byte) ([]BPMNProcess, error) {
+	return unMarshalBpmn(content)
+}
+
+// AddForm registers the file of a form id used by a user task, forms that are
+// not registered are read from internal/zeebe/resources of the module.
+func (g *Generator) AddForm(path string) error {
+	form, err := readFormFile(path)
+	if err != nil {
+		return err
+	}
+
+	formID := form.ID
+	if formID == "" {
+		formID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	g.forms[formID] = path
+
+	return nil
+}
+
+// GenerateProcess renders the crud code of a deployed process.
+func (g *Generator) GenerateProcess(bpmnProcessId, resourceName string, version int32, processDefinitionKey int64) error {
+	return g.generateCrudProcess(toCamelCase(bpmnProcessId), resourceName, bpmnProcessId, version, processDefinitionKey)
+}
+
+// GenerateTasks renders the code of the user tasks and service tasks of the
+// executable processes, including the ones in subprocesses. The processes
+// started by call activities are generated when they are passed too.
+func (g *Generator) GenerateTasks(bpmnProcess []BPMNProcess) error {
+	processes := map[string]bool{}
+	for _, process := range bpmnProcess {
+		processes[process.ID] = true
+	}
+
+	for _, process := range bpmnProcess {
+		if !process.IsExecutable {
+			continue
+		}
+
+		userTasks, serviceTasks := process.Tasks()
+		for _, serviceTask := range serviceTasks {
+			if err := g.generateCrudServiceTask(serviceTask); err != nil {
+				return err
+			}
+		}
+		for _, userTask := range userTasks {
+			// the code of a user task is named after it, not after its process
+			name := toCamelCase(userTask.Name)
+			if processID, ok := g.userTasks[name]; ok && processID != userTask.ProcessID {
+				return fmt.Errorf("user task %q of process %s has the name of a user task of process %s", userTask.Name, userTask.ProcessID, processID)
+			}
+			g.userTasks[name] = userTask.ProcessID
+
+			if err := g.generateCrudUserTask(userTask); err != nil {
+				return err
+			}
+		}
+
+		for _, called := range process.CalledProcesses() {
+			if !processes[called] && !slices.Contains(g.missing, called) {
+				g.missing = append(g.missing, called)
+			}
+		}
+	}
+	return nil
+}
+
+// Missing returns the processes called by call activities that were not
+// passed to GenerateTasks.
+func (g *Generator) Missing() []string {
+	sort.Strings(g.missing)
+	return g.missing
+}
+
+// Skipped returns the existing files that were kept because they differ from
+// the generated code.
+func (g *Generator) Skipped() []string {
+	sort.Strings(g.skipped)
+	return g.skipped
+}
+
+// KeptColumns returns the columns, as table.column, of removed form fields
+// that were not dropped.
+func (g *Generator) KeptColumns() []string {
+	sort.Strings(g.keptColumns)
+	return g.keptColumns
+}
+
+// Changes returns the staged files that differ from the files on disk.
+func (g *Generator) Changes() ([]FileChange, error) {
+	var changes []FileChange
+	for path, content := range g.files {
+		old, err := os.ReadFile(g.path(path))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		if err == nil && string(old) == content {
+			continue
+		}
+
+		changes = append(changes, FileChange{
+			Path:    path,
+			Old:     string(old),
+			New:     content,
+			Created: err != nil,
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes, nil
+}
+
+// Diff returns the unified diff of every change.
+func (g *Generator) Diff() (string, error) {
+	changes, err := g.Changes()
+	if err != nil {
+		return "", err
+	}
+
+	var diff strings.Builder
+	for _, change := range changes {
+		fromFile := "a/" + change.Path
+		if change.Created {
+			fromFile = "/dev/null"
+		}
+
+		text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(change.Old),
+			B:        difflib.SplitLines(change.New),
+			FromFile: fromFile,
+			ToFile:   "b/" + change.Path,
+			Context:  3,
+		})
+		if err != nil {
+			return "", err
+		}
+		diff.WriteString(text)
+	}
+
+	return diff.String(), nil
+}
+
+// Write writes the changed files and returns them.
+func (g *Generator) Write() ([]FileChange, error) {
+	changes, err := g.Changes()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, change := range changes {
+		path := g.path(change.Path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(change.New), 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write file %s: %w", change.Path, err)
+		}
+	}
+
+	return changes, nil
+}
+
+func (g *Generator) path(path string) string {
+	return filepath.Join(g.outDir, path)
+}
+
+// readFile returns the staged content of a file or the file on disk.
+func (g *Generator) readFile(path string) (string, error) {
+	path = filepath.Clean(path)
+	if content, ok := g.files[path]; ok {
+		return content, nil
+	}
+
+	content, err := os.ReadFile(g.path(path))
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// writeFile stages a generated file.
+func (g *Generator) writeFile(path, content string) error {
+	path = filepath.Clean(path)
+
+	content, err := formatGo(path, content)
+	if err != nil {
+		return err
+	}
+
+	if !g.force {
+		old, err := os.ReadFile(g.path(path))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		if err == nil && string(old) != content {
+			g.skipped = append(g.skipped, path)
+			return nil
+		}
+	}
+
+	g.files[path] = content
+	return nil
+}
+
+// insertGeneratedCode adds the code after every line containing the marker,
+// code that is already in the file is not added again.
+func (g *Generator) insertGeneratedCode(filePath, generateCode, containString string) error {
+	filePath = filepath.Clean(filePath)
+
+	content, err := g.readFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+
+	// gofmt may have realigned the code since it was inserted
+	if strings.Contains(" "+compactSpaces(content)+" ", " "+compactSpaces(generateCode)+" ") {
+		return nil
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		lines = append(lines, line)
+		if strings.Contains(line, containString) {
+			lines = append(lines, generateCode)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	output := strings.Join(lines, "\n")
+	if strings.HasSuffix(content, "\n") {
+		output += "\n"
+	}
+	output, err = formatGo(filePath, output)
+	if err != nil {
+		return err
+	}
+	g.files[filePath] = output
+
+	return nil
+}
+
+// formatGo gofmts the go files, the files on disk are compared with the
+// formatted code so gofmt alone never makes a file look edited.
+func formatGo(path, content string) (string, error) {
+	if filepath.Ext(path) != ".go" {
+		return content, nil
+	}
+
+	formatted, err := format.Source([]byte(content))
+	if err != nil {
+		return "", fmt.Errorf("generated %s is not valid go: %w", path, err)
+	}
+	return string(formatted), nil
+}
+
+func (g *Generator) moduleName() (string, error) {
+	content, err := os.ReadFile(g.path("go.mod"))
+	if err != nil {
+		return "", fmt.Errorf("failed to open go.mod: %w", err)
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, "module ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "module ")), nil
+		}
+	}
+
+	return "", fmt.Errorf("module name not found in go.mod")
+}
+
+func (g *Generator) formPath(formID string) string {
+	if path, ok := g.forms[formID]; ok {
+		return path
+	}
+	return g.path(fmt.Sprintf("./internal/zeebe/resources/%s.form", formID))
+}
+
+func compactSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
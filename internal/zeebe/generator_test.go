@@ -0,0 +1,324 @@
+package zeebe
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestModule(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	// the markers sit where they are in the module, the files are gofmted
+	// after the code is inserted
+	files := map[string]string{
+		"go.mod":                          "module example.com/social\n",
+		"cmd/api/api.go":                  "package main\n\nfunc (app *application) mount() {\n\t// GENERATE ROUTES API\n\t// GENERATE USER TASK ROUTES API\n}\n",
+		"internal/store/storage.go":       "package store\n\ntype Storage struct {\n\t// GENERATED CODE INTERFACE\n}\n\nfunc NewStorage(db *sql.DB) Storage {\n\treturn Storage{\n\t\t// GENERATED CODE CONSTRUCTOR\n\t}\n}\n",
+		"internal/store/cache/storage.go": "package cache\n\ntype Storage struct {\n\t// GENERATED CACHE CODE INTERFACE\n}\n\nfunc NewRedisStorage(rbd *redis.Client) Storage {\n\treturn Storage{\n\t\t// GENERATED CACHE CODE CONSTRUCTOR\n\t}\n}\n",
+		"internal/service/service.go":     "package service\n\nfunc (s *Service) handlers() map[string]Handler {\n\treturn map[string]Handler{\n\t\t// GENERATED SERVICE TASK HANDLERS\n\t}\n}\n",
+		"internal/service/reconciler.go":  "package service\n\nfunc (r *Reconciler) processes() map[string]reconciledProcess {\n\treturn map[string]reconciledProcess{\n\t\t// GENERATED RECONCILER PROCESSES\n\t}\n}\n",
+	}
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// generate renders the example process, forms replace the example form with
+// the same id.
+func generate(t *testing.T, dir string, forms ...string) *Generator {
+	t.Helper()
+	return generateWith(t, dir, false, forms...)
+}
+
+func generateWith(t *testing.T, dir string, dropColumns bool, forms ...string) *Generator {
+	t.Helper()
+
+	content, err := os.ReadFile("resources/pembuatan_media_berita_technology.bpmn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bpmnProcess, err := ParseBpmn(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewGenerator(dir, false)
+	g.SetDropColumns(dropColumns)
+	for _, form := range []string{"approving_artikel_form", "creating_artikel_form", "reviewing_artikel_form"} {
+		forms = append([]string{"resources/" + form + ".form"}, forms...)
+	}
+	for _, form := range forms {
+		if err := g.AddForm(form); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := g.GenerateTasks(bpmnProcess); err != nil {
+		t.Fatal(err)
+	}
+	for _, process := range bpmnProcess {
+		if err := g.GenerateProcess(process.ID, "pembuatan_media_berita_technology.bpmn", 1, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return g
+}
+
+func TestGenerator(t *testing.T) {
+	dir := newTestModule(t)
+
+	t.Run("should only show the diff on a dry run", func(t *testing.T) {
+		diff, err := generate(t, dir).Diff()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(diff, "+++ b/internal/store/pembuatan_media_berita_technology_process.go") {
+			t.Errorf("expected the process store in the diff")
+		}
+		if _, err := os.Stat(filepath.Join(dir, "internal/store/pembuatan_media_berita_technology_process.go")); !os.IsNotExist(err) {
+			t.Errorf("expected no file written, got %v", err)
+		}
+	})
+
+	t.Run("should write the files once", func(t *testing.T) {
+		changes, err := generate(t, dir).Write()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) == 0 {
+			t.Fatal("expected changes")
+		}
+
+		changes, err = generate(t, dir).Write()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, change := range changes {
+			t.Errorf("expected no change on rerun, got %s", change.Path)
+		}
+
+		routes, err := os.ReadFile(filepath.Join(dir, "cmd/api/api.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(routes), "createPembuatanMediaBeritaTechnologyHandler"); n != 1 {
+			t.Errorf("expected the route once, got %d", n)
+		}
+	})
+
+	t.Run("should keep edited files", func(t *testing.T) {
+		path := filepath.Join(dir, "internal/store/pembuatan_media_berita_technology_process.go")
+		if err := os.WriteFile(path, []byte("package store\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		g := generate(t, dir)
+		changes, err := g.Changes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 0 {
+			t.Errorf("expected no changes, got %d", len(changes))
+		}
+		if skipped := g.Skipped(); len(skipped) != 1 || skipped[0] != "internal/store/pembuatan_media_berita_technology_process.go" {
+			t.Errorf("unexpected skipped files %v", skipped)
+		}
+	})
+	t.Run("should alter the table of a changed form", func(t *testing.T) {
+		form := writeForm(t, `"key": "tags"`, `"key": "summary"`)
+
+		g := generate(t, dir, form)
+		up := alterMigration(t, g)
+		if !strings.Contains(up, "ADD COLUMN IF NOT EXISTS summary TEXT;") || strings.Contains(up, "DROP COLUMN") {
+			t.Errorf("unexpected alter migration %q", up)
+		}
+		if kept := g.KeptColumns(); len(kept) != 1 || kept[0] != "pembuatanartikel.tags" {
+			t.Errorf("expected the tags column to be kept, got %v", kept)
+		}
+
+		changes, err := generate(t, dir, form).Changes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, change := range changes {
+			t.Errorf("expected no change on rerun, got %s", change.Path)
+		}
+	})
+
+	t.Run("should drop the column of a removed field when asked", func(t *testing.T) {
+		form := writeForm(t, `"key": "tags"`, `"key": "summary"`)
+
+		up := alterMigration(t, generateWith(t, dir, true, form))
+		if up != "ALTER TABLE pembuatanartikel DROP COLUMN IF EXISTS tags;\n" {
+			t.Errorf("unexpected alter migration %q", up)
+		}
+	})
+
+	t.Run("should alter the type of a changed field", func(t *testing.T) {
+		form := writeForm(t, `"type": "number"`, `"type": "textfield"`)
+
+		up := alterMigration(t, generateWith(t, dir, true, form))
+		if !strings.Contains(up, "ALTER COLUMN version TYPE TEXT USING version::TEXT;") {
+			t.Errorf("unexpected alter migration %q", up)
+		}
+	})
+}
+
+// writeForm writes the example creating form with old replaced by new.
+func writeForm(t *testing.T, old, new string) string {
+	t.Helper()
+
+	content, err := os.ReadFile("resources/creating_artikel_form.form")
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := filepath.Join(t.TempDir(), "creating_artikel_form.form")
+	content = []byte(strings.Replace(string(content), old, new, 1))
+	if err := os.WriteFile(form, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return form
+}
+
+// alterMigration writes the changes of g and returns the alter migration of
+// the creating form.
+func alterMigration(t *testing.T, g *Generator) string {
+	t.Helper()
+
+	changes, err := g.Write()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range changes {
+		if strings.HasSuffix(change.Path, "_alter_pembuatanartikel.up.sql") {
+			return change.New
+		}
+	}
+	return ""
+}
+
+func TestFormFields(t *testing.T) {
+	var form Form
+	err := json.Unmarshal([]byte(`{"components": [
+		{"type": "textfield", "key": "name"},
+		{"type": "number", "key": "score", "validate": {"required": true, "min": 1, "max": "10"}},
+		{"type": "radio", "key": "priority", "values": [{"value": "low"}, {"value": "very high"}]},
+		{"type": "checklist", "key": "channels", "values": [{"value": "web"}, {"value": "mobile"}]},
+		{"type": "datetime", "key": "publishDate", "subtype": "date"},
+		{"type": "group", "components": [{"type": "checkbox", "key": "featured"}]},
+		{"type": "dynamiclist", "path": "references", "components": [{"type": "textfield", "key": "url", "validate": {"required": true}}]},
+		{"type": "button", "action": "submit", "properties": {"decision": "submit"}}
+	]}`), &form)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []formField{
+		{Key: "name", Name: "Name", Type: "*string", ColumnType: "TEXT"},
+		{Key: "score", Name: "Score", Type: "*float64", Column: "score", ColumnType: "DOUBLE PRECISION", Validate: "required,gte=1,lte=10"},
+		{Key: "priority", Name: "Priority", Type: "*string", Column: "priority", ColumnType: "TEXT", Validate: "omitempty,oneof=low 'very high'"},
+		{Key: "channels", Name: "Channels", Type: "[]string", Column: "channels", ColumnType: "JSONB", Validate: "omitempty,dive,oneof=web mobile"},
+		{Key: "publishDate", Name: "PublishDate", Type: "*string", Column: "publish_date", ColumnType: "DATE", Validate: "omitempty,datetime=2006-01-02"},
+		{Key: "featured", Name: "Featured", Type: "*bool", Column: "featured", ColumnType: "BOOLEAN"},
+		// references is a keyword, the list stays in properties
+		{Key: "references", Name: "References", Type: "[]ArtikelReferencesItem", ColumnType: "JSONB", Validate: "omitempty,dive", Item: "ArtikelReferencesItem"},
+		{Key: "decision", Name: "Decision", Type: "*string", Column: "decision", ColumnType: "VARCHAR(256)", Validate: "required,oneof=submit"},
+	}
+
+	fields := formFields(&form, "Artikel", []migrationColumn{{"name", "TEXT"}})
+	if len(fields) != len(expected) {
+		t.Fatalf("expected %d fields got %d", len(expected), len(fields))
+	}
+	for i, field := range fields {
+		field.Fields = nil
+		if !reflect.DeepEqual(field, expected[i]) {
+			t.Errorf("expected %+v got %+v", expected[i], field)
+		}
+	}
+
+	if items := fields[6].Fields; len(items) != 1 || items[0].Validate != "required" {
+		t.Errorf("unexpected dynamic list fields %+v", items)
+	}
+}
+
+func TestGeneratorNestedTasks(t *testing.T) {
+	bpmnProcess, err := ParseBpmn([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<bpmn:definitions xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL" xmlns:zeebe="http://camunda.org/schema/zeebe/1.0">
+  <bpmn:process id="editorial" isExecutable="true">
+    <bpmn:subProcess id="translation">
+      <bpmn:userTask id="translating" name="Translating Artikel" />
+      <bpmn:subProcess id="proofreading">
+        <bpmn:userTask id="proofreading_task" name="Proofreading Artikel" />
+      </bpmn:subProcess>
+    </bpmn:subProcess>
+    <bpmn:subProcess id="escalation" triggeredByEvent="true">
+      <bpmn:serviceTask id="notify">
+        <bpmn:extensionElements>
+          <zeebe:taskDefinition type="notify_editor" />
+        </bpmn:extensionElements>
+      </bpmn:serviceTask>
+    </bpmn:subProcess>
+    <bpmn:callActivity id="legal">
+      <bpmn:extensionElements>
+        <zeebe:calledElement processId="legal_review" />
+      </bpmn:extensionElements>
+    </bpmn:callActivity>
+  </bpmn:process>
+  <bpmn:process id="external" isExecutable="false">
+    <bpmn:userTask id="ignored" name="Ignored Artikel" />
+  </bpmn:process>
+</bpmn:definitions>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userTasks, serviceTasks := bpmnProcess[0].Tasks()
+	if len(userTasks) != 2 || len(serviceTasks) != 1 {
+		t.Fatalf("expected 2 user tasks and 1 service task got %d and %d", len(userTasks), len(serviceTasks))
+	}
+	for _, userTask := range userTasks {
+		if userTask.ProcessID != "editorial" {
+			t.Errorf("expected process editorial for %s got %q", userTask.ID, userTask.ProcessID)
+		}
+	}
+
+	dir := newTestModule(t)
+	g := NewGenerator(dir, false)
+	if err := g.GenerateTasks(bpmnProcess); err != nil {
+		t.Fatal(err)
+	}
+
+	if missing := g.Missing(); len(missing) != 1 || missing[0] != "legal_review" {
+		t.Errorf("expected legal_review to be missing got %v", missing)
+	}
+
+	routes, err := g.readFile("cmd/api/api.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(routes, `r.Route("/editorial/tasks/proofreadingartikel"`) {
+		t.Errorf("expected the nested user task to be routed below its process")
+	}
+	if !strings.Contains(routes, `r.Route("/proofreadingartikel/{taskKey}"`) {
+		t.Errorf("expected the nested user task to be claimed by its name")
+	}
+	if strings.Contains(routes, "IgnoredArtikel") {
+		t.Errorf("expected no routes for a process that is not executable")
+	}
+}
@@ -0,0 +1,257 @@
+package zeebe
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	migrationFileRegex = regexp.MustCompile(`^(\d+)_(create|alter)_(\w+)\.up\.sql$`)
+	addColumnRegex     = regexp.MustCompile(`ADD COLUMN IF NOT EXISTS (\w+) (.+);`)
+	dropColumnRegex    = regexp.MustCompile(`DROP COLUMN IF EXISTS (\w+);`)
+	alterColumnRegex   = regexp.MustCompile(`ALTER COLUMN (\w+) TYPE (.+) USING `)
+	constraintRegex    = regexp.MustCompile(`(?i)\b(NOT NULL|DEFAULT|PRIMARY|REFERENCES|UNIQUE|CHECK|SERIAL|BIGSERIAL)\b`)
+	anyMigrationRegex  = regexp.MustCompile(`^(\d+)_.*\.sql$`)
+	columnNameRegex    = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+)
+
+// reservedWords are the postgres key words that cannot name a column
+var reservedWords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`
+		all analyse analyze and any array as asc asymmetric authorization binary
+		both case cast check collate collation column concurrently constraint
+		create cross current_catalog current_date current_role current_schema
+		current_time current_timestamp current_user default deferrable desc
+		distinct do else end except false fetch for foreign freeze from full
+		grant group having ilike in initially inner intersect into is isnull join
+		lateral leading left like limit localtime localtimestamp natural not
+		notnull null offset on only or order outer overlaps placing primary
+		references returning right select session_user similar some symmetric
+		table tablesample then to trailing true union unique user using variadic
+		verbose when where window with`) {
+		reservedWords[word] = true
+	}
+}
+
+type migrationColumn struct {
+	Name       string
+	Definition string
+}
+
+// migrationTable is the schema of a generated table, Statements run after
+// the table is created, e.g. indexes.
+type migrationTable struct {
+	Name       string
+	Columns    []migrationColumn
+	Constraint string
+	Statements []string
+}
+
+// generateMigration writes a golang-migrate pair creating the table, or
+// altering it when the columns differ from the ones its earlier migrations
+// created. Nothing is written when the table is up to date. A column whose
+// type changed is altered, a column with constraints cannot be and gives an
+// error. A removed column is only dropped with SetDropColumns, its data would
+// be lost.
+func (g *Generator) generateMigration(table migrationTable) error {
+	files, err := g.migrationFiles()
+	if err != nil {
+		return err
+	}
+
+	current, created, err := g.migrationColumns(files, table.Name)
+	if err != nil {
+		return err
+	}
+
+	next := 1
+	for _, file := range files {
+		match := anyMigrationRegex.FindStringSubmatch(file)
+		if match == nil {
+			continue
+		}
+		if seq, _ := strconv.Atoi(match[1]); seq >= next {
+			next = seq + 1
+		}
+	}
+
+	if !created {
+		g.writeMigration(next, "create_"+table.Name, createTableSQL(table), fmt.Sprintf("DROP TABLE IF EXISTS %s;\n", table.Name))
+		return nil
+	}
+
+	var up, down strings.Builder
+	for _, column := range table.Columns {
+		definition, ok := current[column.Name]
+		if !ok {
+			fmt.Fprintf(&up, "ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s;\n", table.Name, column.Name, column.Definition)
+			fmt.Fprintf(&down, "ALTER TABLE %s DROP COLUMN IF EXISTS %s;\n", table.Name, column.Name)
+			continue
+		}
+		if sameDefinition(definition, column.Definition) {
+			continue
+		}
+		if constraintRegex.MatchString(definition) || constraintRegex.MatchString(column.Definition) {
+			return fmt.Errorf("column %s of %s changed from %q to %q, write its migration by hand", column.Name, table.Name, definition, column.Definition)
+		}
+		fmt.Fprintf(&up, "ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s;\n", table.Name, column.Name, column.Definition, column.Name, column.Definition)
+		fmt.Fprintf(&down, "ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s;\n", table.Name, column.Name, definition, column.Name, definition)
+	}
+
+	desired := map[string]bool{}
+	for _, column := range table.Columns {
+		desired[column.Name] = true
+	}
+
+	var removed []string
+	for name := range current {
+		if !desired[name] {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		if !g.dropColumns {
+			g.keptColumns = append(g.keptColumns, table.Name+"."+name)
+			continue
+		}
+		fmt.Fprintf(&up, "ALTER TABLE %s DROP COLUMN IF EXISTS %s;\n", table.Name, name)
+		fmt.Fprintf(&down, "ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s;\n", table.Name, name, current[name])
+	}
+
+	if up.Len() == 0 {
+		return nil
+	}
+
+	g.writeMigration(next, "alter_"+table.Name, up.String(), down.String())
+	return nil
+}
+
+// writeMigration stages a new pair, it is never an edit of an existing file.
+func (g *Generator) writeMigration(seq int, name, up, down string) {
+	path := filepath.Join(g.migrationDir, fmt.Sprintf("%06d_%s", seq, name))
+	g.files[filepath.Clean(path+".up.sql")] = up
+	g.files[filepath.Clean(path+".down.sql")] = down
+}
+
+// migrationFiles returns the migrations on disk and the staged ones in order.
+func (g *Generator) migrationFiles() ([]string, error) {
+	seen := map[string]bool{}
+
+	entries, err := os.ReadDir(g.path(g.migrationDir))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			seen[entry.Name()] = true
+		}
+	}
+
+	dir := filepath.Clean(g.migrationDir)
+	for path := range g.files {
+		if filepath.Dir(path) == dir {
+			seen[filepath.Base(path)] = true
+		}
+	}
+
+	files := make([]string, 0, len(seen))
+	for file := range seen {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// migrationColumns replays the generated migrations of a table and returns
+// its columns with their definitions.
+func (g *Generator) migrationColumns(files []string, tableName string) (map[string]string, bool, error) {
+	columns := map[string]string{}
+	created := false
+
+	for _, file := range files {
+		match := migrationFileRegex.FindStringSubmatch(file)
+		if match == nil || match[3] != tableName {
+			continue
+		}
+
+		content, err := g.readFile(filepath.Join(g.migrationDir, file))
+		if err != nil {
+			return nil, false, err
+		}
+
+		if match[2] == "create" {
+			created = true
+			for name, definition := range parseCreateTable(content) {
+				columns[name] = definition
+			}
+			continue
+		}
+
+		for _, line := range strings.Split(content, "\n") {
+			if m := addColumnRegex.FindStringSubmatch(line); m != nil {
+				columns[m[1]] = m[2]
+			} else if m := alterColumnRegex.FindStringSubmatch(line); m != nil {
+				columns[m[1]] = m[2]
+			} else if m := dropColumnRegex.FindStringSubmatch(line); m != nil {
+				delete(columns, m[1])
+			}
+		}
+	}
+
+	return columns, created, nil
+}
+
+func sameDefinition(a, b string) bool {
+	return strings.EqualFold(compactSpaces(a), compactSpaces(b))
+}
+
+func parseCreateTable(content string) map[string]string {
+	columns := map[string]string{}
+	inTable := false
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "CREATE TABLE"):
+			inTable = true
+		case !inTable || line == "":
+		case strings.HasPrefix(line, ")"):
+			return columns
+		case strings.HasPrefix(line, "CONSTRAINT"):
+		default:
+			name, definition, _ := strings.Cut(strings.TrimSuffix(line, ","), " ")
+			columns[name] = definition
+		}
+	}
+
+	return columns
+}
+
+func createTableSQL(table migrationTable) string {
+	var sql strings.Builder
+
+	lines := make([]string, 0, len(table.Columns)+1)
+	for _, column := range table.Columns {
+		lines = append(lines, fmt.Sprintf("\t%s %s", column.Name, column.Definition))
+	}
+	if table.Constraint != "" {
+		lines = append(lines, "\t"+table.Constraint)
+	}
+
+	fmt.Fprintf(&sql, "CREATE TABLE IF NOT EXISTS %s (\n%s\n);\n", table.Name, strings.Join(lines, ",\n"))
+	for _, statement := range table.Statements {
+		fmt.Fprintf(&sql, "\n%s\n", statement)
+	}
+
+	return sql.String()
+}
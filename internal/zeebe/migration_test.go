@@ -0,0 +1,70 @@
+package zeebe
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateMigration(t *testing.T) {
+	dir := t.TempDir()
+	table := migrationTable{
+		Name: "articles",
+		Columns: []migrationColumn{
+			{"id", "BIGSERIAL PRIMARY KEY"},
+			{"title", "TEXT"},
+		},
+	}
+
+	generate := func(t *testing.T, table migrationTable) []FileChange {
+		t.Helper()
+
+		g := NewGenerator(dir, false)
+		g.SetMigrationDir("migrations")
+		if err := g.generateMigration(table); err != nil {
+			t.Fatal(err)
+		}
+		changes, err := g.Write()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return changes
+	}
+
+	t.Run("should create the table in the first migration", func(t *testing.T) {
+		changes := generate(t, table)
+		if len(changes) != 2 {
+			t.Fatalf("expected a migration pair got %d files", len(changes))
+		}
+		for _, change := range changes {
+			if !strings.Contains(change.Path, "000001_create_articles.") {
+				t.Errorf("unexpected migration %s", change.Path)
+			}
+		}
+	})
+
+	t.Run("should alter the table of an added column", func(t *testing.T) {
+		table.Columns = append(table.Columns, migrationColumn{"content", "TEXT"})
+
+		var up, down string
+		for _, change := range generate(t, table) {
+			switch {
+			case strings.HasSuffix(change.Path, "000002_alter_articles.up.sql"):
+				up = change.New
+			case strings.HasSuffix(change.Path, "000002_alter_articles.down.sql"):
+				down = change.New
+			}
+		}
+		if up != "ALTER TABLE articles ADD COLUMN IF NOT EXISTS content TEXT;\n" {
+			t.Errorf("unexpected up migration %q", up)
+		}
+		if down != "ALTER TABLE articles DROP COLUMN IF EXISTS content;\n" {
+			t.Errorf("unexpected down migration %q", down)
+		}
+	})
+
+	t.Run("should not migrate an unchanged table", func(t *testing.T) {
+		if changes := generate(t, table); len(changes) != 0 {
+			t.Errorf("expected no change on rerun, got %d files", len(changes))
+		}
+	})
+}
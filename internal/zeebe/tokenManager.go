@@ -3,73 +3,163 @@ package zeebe
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
-func NewTokenManager(clientID, clientSecret, authURL string) *TokenManager {
+// Component is a camunda component with its own token audience.
+type Component string
+
+const (
+	ComponentZeebe    Component = "zeebe"
+	ComponentOperate  Component = "operate"
+	ComponentTasklist Component = "tasklist"
+	ComponentOptimize Component = "optimize"
+)
+
+// DefaultAudiences are the audiences of the camunda identity clients.
+var DefaultAudiences = map[Component]string{
+	ComponentZeebe:    "zeebe-api",
+	ComponentOperate:  "operate-api",
+	ComponentTasklist: "tasklist-api",
+	ComponentOptimize: "optimize-api",
+}
+
+type TokenConfig struct {
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	// Audiences overrides DefaultAudiences per component.
+	Audiences map[Component]string
+	// Timeout bounds a single request to the auth server.
+	Timeout time.Duration
+	// RefreshAhead is how long before expiry a token is refreshed, a random
+	// jitter of up to half of it spreads the refreshes of several instances.
+	RefreshAhead time.Duration
+}
+
+func NewTokenManager(cfg TokenConfig) *TokenManager {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.RefreshAhead <= 0 {
+		cfg.RefreshAhead = 30 * time.Second
+	}
+
+	audiences := map[Component]string{}
+	for component, audience := range DefaultAudiences {
+		audiences[component] = audience
+	}
+	for component, audience := range cfg.Audiences {
+		audiences[component] = audience
+	}
+	cfg.Audiences = audiences
+
 	return &TokenManager{
-		clientID:     clientID,
-		clientSecret: clientSecret,
-		authURL:      authURL,
+		config:     cfg,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+		tokens:     map[Component]*authToken{},
+		refreshing: map[Component]*tokenRefresh{},
 	}
 }
 
-func (t *TokenManager) isTokenExpired() bool {
-	return time.Now().After(t.expiry)
+// GetAuthToken returns a token for the zeebe rest api.
+func (t *TokenManager) GetAuthToken(ctx context.Context) (string, error) {
+	return t.GetComponentToken(ctx, ComponentZeebe)
 }
 
-func (t *TokenManager) refreshTokenFunc() error {
-	formData := url.Values{}
-	formData.Set("client_id", t.clientID)
-	formData.Set("client_secret", t.clientSecret)
-	formData.Set("grant_type", "refresh_token")
-	formData.Set("refresh_token", t.refreshToken)
+// GetComponentToken returns a token for the audience of the component. Only
+// one refresh per component runs at a time, callers arriving meanwhile wait
+// for it, or keep using the current token when it did not expire yet.
+func (t *TokenManager) GetComponentToken(ctx context.Context, component Component) (string, error) {
+	now := time.Now()
+
+	t.mu.Lock()
+	token := t.tokens[component]
+	if token != nil && now.Before(token.refreshAt) {
+		t.mu.Unlock()
+		return token.accessToken, nil
+	}
 
-	resp, err := http.PostForm(t.authURL, formData)
-	if err != nil {
-		return err
+	refresh, ok := t.refreshing[component]
+	if !ok {
+		refresh = &tokenRefresh{done: make(chan struct{})}
+		t.refreshing[component] = refresh
+		go t.refresh(component, token, refresh)
 	}
-	defer resp.Body.Close()
+	t.mu.Unlock()
 
-	if resp.StatusCode != http.StatusOK {
-		return errors.New("failed to refresh token")
+	if token != nil && now.Before(token.expiry) {
+		return token.accessToken, nil
 	}
 
-	var data struct {
-		AccessToken  string `json:"access_token"`
-		RefreshToken string `json:"refresh_token"`
-		ExpiresIn    int    `json:"expires_in"`
+	select {
+	case <-refresh.done:
+		if refresh.err != nil {
+			return "", refresh.err
+		}
+		return refresh.token.accessToken, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
 	}
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return err
+// refresh runs detached from the caller, so a canceled request does not fail
+// the refresh the other callers are waiting for.
+func (t *TokenManager) refresh(component Component, current *authToken, refresh *tokenRefresh) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*t.config.Timeout)
+	defer cancel()
+
+	var token *authToken
+	var err error
+	if current != nil && current.refreshToken != "" {
+		token, err = t.requestToken(ctx, component, url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {current.refreshToken},
+		})
+	}
+	if token == nil {
+		token, err = t.requestToken(ctx, component, url.Values{
+			"grant_type": {"client_credentials"},
+		})
 	}
 
-	t.authToken = data.AccessToken
-	t.refreshToken = data.RefreshToken
-	t.expiry = time.Now().Add(time.Duration(data.ExpiresIn) * time.Second)
+	t.mu.Lock()
+	if err == nil {
+		t.tokens[component] = token
+	}
+	refresh.token, refresh.err = token, err
+	delete(t.refreshing, component)
+	t.mu.Unlock()
 
-	return nil
+	close(refresh.done)
 }
 
-func (t *TokenManager) loginFromApp() error {
-	formData := url.Values{}
-	formData.Set("client_id", t.clientID)
-	formData.Set("client_secret", t.clientSecret)
-	formData.Set("grant_type", "client_credentials")
+func (t *TokenManager) requestToken(ctx context.Context, component Component, form url.Values) (*authToken, error) {
+	form.Set("client_id", t.config.ClientID)
+	form.Set("client_secret", t.config.ClientSecret)
+	if audience := t.config.Audiences[component]; audience != "" {
+		form.Set("audience", audience)
+	}
 
-	resp, err := http.PostForm(t.authURL, formData)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.config.AuthURL, strings.NewReader(form.Encode()))
 	if err != nil {
-		return err
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request %s token: %w", component, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return errors.New("failed to login")
+		return nil, fmt.Errorf("failed to request %s token (%s grant): status %d", component, form.Get("grant_type"), resp.StatusCode)
 	}
 
 	var data struct {
@@ -79,32 +169,21 @@ func (t *TokenManager) loginFromApp() error {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return err
+		return nil, err
 	}
 
-	t.authToken = data.AccessToken
-	t.refreshToken = data.RefreshToken
-	t.expiry = time.Now().Add(time.Duration(data.ExpiresIn) * time.Second)
-
-	return nil
-}
-
-func (t *TokenManager) GetAuthToken(ctx context.Context) (string, error) {
-	if t.authToken != "" {
-		if t.isTokenExpired() {
-			err := t.refreshTokenFunc()
-			if err != nil {
-				errLoginApp := t.loginFromApp()
-				if errLoginApp != nil {
-					return "", fmt.Errorf("failed to login from app token")
-				}
-			}
-		}
-	} else {
-		err := t.loginFromApp()
-		if err != nil {
-			return "", fmt.Errorf("failed to login from app token")
-		}
+	now := time.Now()
+	lifetime := time.Duration(data.ExpiresIn) * time.Second
+	ahead := t.config.RefreshAhead + time.Duration(rand.Int63n(int64(t.config.RefreshAhead)/2+1))
+	// short lived tokens are refreshed halfway through their lifetime
+	if ahead > lifetime/2 {
+		ahead = lifetime / 2
 	}
-	return t.authToken, nil
+
+	return &authToken{
+		accessToken:  data.AccessToken,
+		refreshToken: data.RefreshToken,
+		expiry:       now.Add(lifetime),
+		refreshAt:    now.Add(lifetime - ahead),
+	}, nil
 }
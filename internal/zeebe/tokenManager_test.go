@@ -0,0 +1,146 @@
+package zeebe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeAuthServer struct {
+	requests  atomic.Int32
+	expiresIn int
+	release   chan struct{}
+
+	mu     sync.Mutex
+	grants []string
+}
+
+func (s *fakeAuthServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+
+	r.ParseForm()
+	s.mu.Lock()
+	s.grants = append(s.grants, r.Form.Get("grant_type"))
+	s.mu.Unlock()
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token":  r.Form.Get("audience"),
+		"refresh_token": "refresh",
+		"expires_in":    s.expiresIn,
+	})
+}
+
+func newTestTokenManager(t *testing.T, auth *fakeAuthServer) *TokenManager {
+	t.Helper()
+
+	server := httptest.NewServer(auth)
+	t.Cleanup(server.Close)
+
+	return NewTokenManager(TokenConfig{
+		ClientID:     "test",
+		ClientSecret: "test",
+		AuthURL:      server.URL,
+		Audiences:    map[Component]string{ComponentOperate: "custom-operate"},
+	})
+}
+
+func TestTokenManager(t *testing.T) {
+	t.Run("should request a token once for concurrent callers", func(t *testing.T) {
+		auth := &fakeAuthServer{expiresIn: 3600, release: make(chan struct{})}
+		tokens := newTestTokenManager(t, auth)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 20)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				token, err := tokens.GetAuthToken(context.Background())
+				if err == nil && token != "zeebe-api" {
+					err = errors.New("unexpected token " + token)
+				}
+				errs <- err
+			}()
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		close(auth.release)
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := auth.requests.Load(); got != 1 {
+			t.Errorf("expected 1 token request got %d", got)
+		}
+	})
+
+	t.Run("should request a token per component audience", func(t *testing.T) {
+		auth := &fakeAuthServer{expiresIn: 3600}
+		tokens := newTestTokenManager(t, auth)
+
+		for component, want := range map[Component]string{
+			ComponentZeebe:    "zeebe-api",
+			ComponentOperate:  "custom-operate",
+			ComponentTasklist: "tasklist-api",
+		} {
+			token, err := tokens.GetComponentToken(context.Background(), component)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != want {
+				t.Errorf("expected %s token %s got %s", component, want, token)
+			}
+		}
+	})
+
+	t.Run("should refresh ahead of expiry with the refresh token", func(t *testing.T) {
+		auth := &fakeAuthServer{expiresIn: 1}
+		tokens := newTestTokenManager(t, auth)
+
+		if _, err := tokens.GetAuthToken(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(600 * time.Millisecond)
+		if _, err := tokens.GetAuthToken(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		// the token is still valid, so the refresh runs in the background
+		deadline := time.Now().Add(time.Second)
+		for auth.requests.Load() < 2 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		auth.mu.Lock()
+		defer auth.mu.Unlock()
+		if len(auth.grants) != 2 || auth.grants[1] != "refresh_token" {
+			t.Errorf("expected a client_credentials then a refresh_token grant got %v", auth.grants)
+		}
+	})
+
+	t.Run("should stop waiting when the context is canceled", func(t *testing.T) {
+		auth := &fakeAuthServer{expiresIn: 3600, release: make(chan struct{})}
+		tokens := newTestTokenManager(t, auth)
+		defer close(auth.release)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		if _, err := tokens.GetAuthToken(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected %v got %v", context.DeadlineExceeded, err)
+		}
+	})
+}
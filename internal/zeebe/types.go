@@ -2,9 +2,11 @@ package zeebe
 
 import (
 	"context"
+	"encoding/json"
 	"encoding/xml"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/pb"
@@ -16,13 +18,11 @@ import (
 type ZeebeCamunda interface {
 	DeployProcessDefinitionFromFiles(file *os.File, formResources []*os.File) ([]*pb.ProcessMetadata, []BPMNProcess, error)
 	DeployProcessDefinition(resourceName string, formResources []string) ([]*pb.ProcessMetadata, []BPMNProcess, error)
-	GenerateCRUDHandlers(processMetadata *pb.ProcessMetadata) error
-	GenerateCRUDUserTaskServiceTaskHandler(bpmnProcess *[]BPMNProcess) error
-	GenerateCRUDFromPayloadHandlers(processName, resourceName string, version int32, processDefinitionKey int64) error
 	StartWorkflow(ctx context.Context, processDefinitionKey int64, variables map[string]interface{}) (*pb.CreateProcessInstanceResponse, error)
 	CancelWorkflow(context.Context, int64) error
-	StartWorker(jobType, nameWorker string, handler worker.JobHandler) (worker.JobWorker, error)
+	StartWorker(jobType, nameWorker string, cfg WorkerConfig, handler worker.JobHandler) (worker.JobWorker, error)
 	UpdateProcessInstance(ctx context.Context, processInstanceKey int64, variables map[string]interface{}) error
+	Topology(ctx context.Context) (*pb.TopologyResponse, error)
 	Close() error
 }
 
@@ -30,6 +30,14 @@ type Client struct {
 	client zbc.Client
 }
 
+// job worker types
+type WorkerConfig struct {
+	Concurrency   int
+	MaxJobsActive int
+	Timeout       time.Duration
+	PollInterval  time.Duration
+}
+
 // bpmn types
 type FormDefinition struct {
 	FormID string `xml:"formId,attr"`
@@ -49,6 +57,10 @@ type AssignmentDefinition struct {
 	CandidateUsers  string `xml:"candidateUsers,attr"`
 }
 
+type CalledElement struct {
+	ProcessID string `xml:"processId,attr"`
+}
+
 type TaskSchedule struct {
 	DueDate string `xml:"dueDate,attr"`
 }
@@ -60,13 +72,17 @@ type ExtensionElement struct {
 	TaskSchedules         []TaskSchedule         `xml:"taskSchedule"`
 	TaskDefinitions       []TaskDefinition       `xml:"taskDefinition"`
 	Properties            []Propertie            `xml:"properties"`
+	CalledElements        []CalledElement        `xml:"calledElement"`
 }
 
+// UserTask and ServiceTask carry the id of the process they belong to, set
+// by BPMNProcess.Tasks.
 type UserTask struct {
 	XMLName           xml.Name           `xml:"userTask"`
 	ID                string             `xml:"id,attr"`
 	Name              string             `xml:"name,attr"`
 	ExtensionElements []ExtensionElement `xml:"extensionElements"`
+	ProcessID         string             `xml:"-"`
 }
 
 type ServiceTask struct {
@@ -74,12 +90,40 @@ type ServiceTask struct {
 	ID                string             `xml:"id,attr"`
 	Name              string             `xml:"name,attr"`
 	ExtensionElements []ExtensionElement `xml:"extensionElements"`
+	ProcessID         string             `xml:"-"`
+}
+
+type CallActivity struct {
+	ID                string             `xml:"id,attr"`
+	Name              string             `xml:"name,attr"`
+	ExtensionElements []ExtensionElement `xml:"extensionElements"`
+}
+
+// FlowElements are the tasks and the nested containers of a process or of a
+// subprocess.
+type FlowElements struct {
+	UserTasks         []UserTask     `xml:"userTask"`
+	ServiceTask       []ServiceTask  `xml:"serviceTask"`
+	SubProcesses      []SubProcess   `xml:"subProcess"`
+	AdHocSubProcesses []SubProcess   `xml:"adHocSubProcess"`
+	Transactions      []SubProcess   `xml:"transaction"`
+	CallActivities    []CallActivity `xml:"callActivity"`
+}
+
+// SubProcess is an embedded, event, ad-hoc or transaction subprocess.
+type SubProcess struct {
+	ID               string `xml:"id,attr"`
+	Name             string `xml:"name,attr"`
+	TriggeredByEvent bool   `xml:"triggeredByEvent,attr"`
+	FlowElements
 }
 
 type BPMNProcess struct {
-	XMLName     xml.Name      `xml:"process"`
-	UserTasks   []UserTask    `xml:"userTask"`
-	ServiceTask []ServiceTask `xml:"serviceTask"`
+	XMLName      xml.Name `xml:"process"`
+	ID           string   `xml:"id,attr"`
+	Name         string   `xml:"name,attr"`
+	IsExecutable bool     `xml:"isExecutable,attr"`
+	FlowElements
 }
 
 type BPMNDocument struct {
@@ -89,30 +133,69 @@ type BPMNDocument struct {
 
 // zeebe client rest
 type TokenManager struct {
-	clientID     string
-	clientSecret string
-	authURL      string
-	authToken    string
+	config     TokenConfig
+	httpClient *http.Client
+
+	mu         sync.Mutex
+	tokens     map[Component]*authToken
+	refreshing map[Component]*tokenRefresh
+}
+
+type authToken struct {
+	accessToken  string
 	refreshToken string
 	expiry       time.Time
+	refreshAt    time.Time
+}
+
+type tokenRefresh struct {
+	done  chan struct{}
+	token *authToken
+	err   error
 }
 
 type ZeebeClientRest struct {
 	httpClient   *http.Client
 	tokenManager *TokenManager
-	zeebeAddr    string
+	components   []componentURL
+	breakers     map[Component]*circuitBreaker
+	config       RestConfig
+}
+
+type componentURL struct {
+	component Component
+	url       string
 }
 
 // form id types
+type FormValue struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+type FormValidate struct {
+	Required       bool        `json:"required"`
+	Min            json.Number `json:"min"`
+	Max            json.Number `json:"max"`
+	MinLength      json.Number `json:"minLength"`
+	MaxLength      json.Number `json:"maxLength"`
+	ValidationType string      `json:"validationType"`
+}
+
 type FormComponent struct {
-	Label       string `json:"label"`
-	Type        string `json:"type"`
-	Key         string `json:"key"`
-	Description string `json:"description"`
-	Validate    struct {
-		Required bool `json:"required"`
-	} `json:"validate"`
+	Label       string            `json:"label"`
+	Type        string            `json:"type"`
+	Key         string            `json:"key"`
+	Path        string            `json:"path"`
+	Subtype     string            `json:"subtype"`
+	Description string            `json:"description"`
+	Validate    FormValidate      `json:"validate"`
+	Values      []FormValue       `json:"values"`
+	Components  []FormComponent   `json:"components"`
+	Action      string            `json:"action"`
+	Properties  map[string]string `json:"properties"`
 }
 type Form struct {
+	ID         string          `json:"id"`
 	Components []FormComponent `json:"components"`
 }
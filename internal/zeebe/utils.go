@@ -1,13 +1,13 @@
 package zeebe
 
 import (
-	"bufio"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"strings"
+	"unicode"
 )
 
 func MustReadFile(resourceFile string) ([]byte, error) {
@@ -37,56 +37,51 @@ func unMarshalBpmn(bpmnContent []byte) ([]BPMNProcess, error) {
 	return bpmn.Processes, nil
 }
 
-func insertGeneratedCode(filePath, generateCode, containString string) error {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
-	}
-	defer file.Close()
-
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		lines = append(lines, line)
-		if strings.Contains(line, containString) {
-			lines = append(lines, generateCode)
+// Tasks returns the user tasks and the service tasks of the process and of
+// its subprocesses.
+func (p BPMNProcess) Tasks() ([]UserTask, []ServiceTask) {
+	var userTasks []UserTask
+	var serviceTasks []ServiceTask
+	for _, elements := range p.FlowElements.all() {
+		for _, userTask := range elements.UserTasks {
+			userTask.ProcessID = p.ID
+			userTasks = append(userTasks, userTask)
+		}
+		for _, serviceTask := range elements.ServiceTask {
+			serviceTask.ProcessID = p.ID
+			serviceTasks = append(serviceTasks, serviceTask)
 		}
 	}
-
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
-	}
-
-	output := strings.Join(lines, "\n")
-	err = os.WriteFile(filePath, []byte(output), 0o644)
-	if err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
-	}
-
-	return nil
+	return userTasks, serviceTasks
 }
 
-func getModuleName() (string, error) {
-	file, err := os.Open("go.mod")
-	if err != nil {
-		return "", fmt.Errorf("failed to open go.mod: %w", err)
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "module ") {
-			return strings.TrimSpace(strings.TrimPrefix(line, "module ")), nil
+// CalledProcesses returns the ids of the processes started by the call
+// activities of the process, ids set by an expression are left out.
+func (p BPMNProcess) CalledProcesses() []string {
+	var called []string
+	for _, elements := range p.FlowElements.all() {
+		for _, callActivity := range elements.CallActivities {
+			for _, extensionElement := range callActivity.ExtensionElements {
+				for _, calledElement := range extensionElement.CalledElements {
+					if calledElement.ProcessID != "" && !strings.HasPrefix(calledElement.ProcessID, "=") {
+						called = append(called, calledElement.ProcessID)
+					}
+				}
+			}
 		}
 	}
+	return called
+}
 
-	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("failed to read go.mod: %w", err)
+// all returns the elements and the elements of every nested subprocess.
+func (e FlowElements) all() []FlowElements {
+	all := []FlowElements{e}
+	for _, subProcesses := range [][]SubProcess{e.SubProcesses, e.AdHocSubProcesses, e.Transactions} {
+		for _, subProcess := range subProcesses {
+			all = append(all, subProcess.FlowElements.all()...)
+		}
 	}
-
-	return "", fmt.Errorf("module name not found in go.mod")
+	return all
 }
 
 func readFormFile(filePath string) (*Form, error) {
@@ -109,43 +104,77 @@ func readFormFile(filePath string) (*Form, error) {
 	return &form, nil
 }
 
-func generateStructCode(form *Form, name string) string {
+func generateStructCode(fields []formField, name string) string {
 	structCode := "type FormData" + name + " struct {\n"
-	for _, component := range form.Components {
-		if component.Key == "" {
-			continue
-		}
-		fieldName := toCamelCaseForm(component.Key)
-		fieldType := getFieldType(component.Type)
-		tag := fmt.Sprintf("`json:\"%s", component.Key)
-		if component.Validate.Required {
-			tag += "\" validate:\"required\"`"
-		} else {
-			tag += ",omitempty\"`"
+	for _, field := range fields {
+		// dynamic list entries are declared in the store package
+		fieldType := field.Type
+		if field.Item != "" {
+			fieldType = strings.Replace(fieldType, field.Item, "store."+field.Item, 1)
 		}
-		structCode += fmt.Sprintf("\t%s %s %s\n", fieldName, fieldType, tag)
+		structCode += fmt.Sprintf("\t%s %s %s\n", field.Name, fieldType, field.tag())
 	}
 	structCode += "}\n"
 	return structCode
 }
 
-func toCamelCaseForm(snakeStr string) string {
-	parts := strings.Split(snakeStr, "_")
-	for i := range parts {
-		parts[i] = strings.Title(parts[i])
+func getFieldType(component FormComponent) string {
+	switch component.Type {
+	case "textfield", "textarea", "select", "radio", "datetime":
+		return "*string"
+	case "number":
+		return "*float64"
+	case "checkbox":
+		return "*bool"
+	case "checklist", "taglist":
+		return "[]string"
+	default:
+		return "interface{}"
 	}
-	return strings.Join(parts, "")
 }
 
-func getFieldType(fieldType string) string {
-	switch fieldType {
-	case "textfield", "textarea":
-		return "string"
+func getColumnType(component FormComponent) string {
+	switch component.Type {
+	case "textfield", "textarea", "select", "radio":
+		return "TEXT"
 	case "number":
-		return "float64"
-	case "select":
-		return "string"
+		return "DOUBLE PRECISION"
+	case "checkbox":
+		return "BOOLEAN"
+	case "datetime":
+		switch component.Subtype {
+		case "", "date":
+			return "DATE"
+		case "datetime":
+			return "TIMESTAMP(0) WITH TIME ZONE"
+		default:
+			// form-js keeps the offset of a time when it has one
+			return "TEXT"
+		}
 	default:
-		return "interface{}"
+		return "JSONB"
+	}
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+func toSnakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
 	}
+	return b.String()
 }
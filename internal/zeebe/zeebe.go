@@ -12,6 +12,14 @@ import (
 	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/pb"
 	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/worker"
 	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/zbc"
+	"github.com/damarteplok/social/internal/metrics"
+	"github.com/damarteplok/social/internal/store"
+	"github.com/damarteplok/social/internal/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 //go:embed "resources"
@@ -29,8 +37,12 @@ func NewZeebeClient(clientID, clientSecret, authURL, zeebeAddr string) (ZeebeCam
 	}
 	config := zbc.ClientConfig{
 		UsePlaintextConnection: true,
-		GatewayAddress:         "localhost:26500",
+		GatewayAddress:         zeebeAddr,
 		CredentialsProvider:    credentials,
+		DialOpts: []grpc.DialOption{
+			grpc.WithChainUnaryInterceptor(commandMetrics),
+			grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		},
 	}
 	client, err := zbc.NewClient(&config)
 	if err != nil {
@@ -39,12 +51,36 @@ func NewZeebeClient(clientID, clientSecret, authURL, zeebeAddr string) (ZeebeCam
 	return &Client{client: client}, nil
 }
 
+// commandMetrics records the latency of every unary gateway command, the
+// command is the last part of the grpc method such as CreateProcessInstance.
+func commandMetrics(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+
+	command := method[strings.LastIndex(method, "/")+1:]
+	metrics.ZeebeCommandDuration.WithLabelValues(command, status.Code(err).String()).Observe(time.Since(start).Seconds())
+
+	return err
+}
+
 func (c *Client) Close() error {
 	return c.client.Close()
 }
 
+// Topology returns the brokers known to the gateway.
+func (c *Client) Topology(ctx context.Context) (*pb.TopologyResponse, error) {
+	topology, err := c.client.NewTopologyCommand().Send(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get topology: %w", err)
+	}
+
+	return topology, nil
+}
+
 // UpdateProcessInstance updates the variables of a process instance.
 func (c *Client) UpdateProcessInstance(ctx context.Context, processInstanceKey int64, variables map[string]interface{}) error {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.ProcessInstanceKey(processInstanceKey))
+
 	request, err := c.client.NewSetVariablesCommand().ElementInstanceKey(processInstanceKey).VariablesFromMap(variables)
 	if err != nil {
 		return fmt.Errorf("failed to update process instance: %w", err)
@@ -70,13 +106,25 @@ func (c *Client) StartWorkflow(ctx context.Context, processDefinitionKey int64,
 		return nil, fmt.Errorf("failed to start workflow: %w", err)
 	}
 
+	// correlates the span of the caller with the instance in operate
+	trace.SpanFromContext(ctx).SetAttributes(
+		tracing.ProcessDefinitionKey(processDefinitionKey),
+		tracing.ProcessInstanceKey(result.GetProcessInstanceKey()),
+	)
+
 	return result, nil
 }
 
-// CancelWorkflow cancels a workflow instance.
+// CancelWorkflow cancels a workflow instance. It returns store.ErrNotFound
+// when the instance does not exist anymore, e.g. it was already canceled.
 func (c *Client) CancelWorkflow(ctx context.Context, processInstanceKey int64) error {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.ProcessInstanceKey(processInstanceKey))
+
 	_, err := c.client.NewCancelInstanceCommand().ProcessInstanceKey(processInstanceKey).Send(ctx)
 	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return store.ErrNotFound
+		}
 		return fmt.Errorf("failed to cancel workflow: %w", err)
 	}
 	return nil
@@ -183,65 +231,37 @@ func (z *Client) DeployProcessDefinition(resourceName string, formResources []st
 }
 
 // StartWorker starts a worker for a given job type.
-func (z *Client) StartWorker(jobType, nameWorker string, handler worker.JobHandler) (worker.JobWorker, error) {
-	w := z.client.NewJobWorker().
+func (z *Client) StartWorker(jobType, nameWorker string, cfg WorkerConfig, handler worker.JobHandler) (worker.JobWorker, error) {
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+	if cfg.MaxJobsActive < cfg.Concurrency {
+		cfg.MaxJobsActive = cfg.Concurrency
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 1 * time.Second
+	}
+
+	builder := z.client.NewJobWorker().
 		JobType(jobType).
 		Handler(handler).
-		Concurrency(1).
-		MaxJobsActive(10).
+		Concurrency(cfg.Concurrency).
+		MaxJobsActive(cfg.MaxJobsActive).
 		RequestTimeout(1 * time.Second).
-		PollInterval(1 * time.Second).
-		Name(nameWorker).
-		Open()
+		PollInterval(cfg.PollInterval).
+		Name(nameWorker)
 
-	return w, nil
-}
-
-// GenerateCRUDFromPayloadHandlers generates CRUD handlers from payload.
-func (c *Client) GenerateCRUDFromPayloadHandlers(processName, resourceName string, version int32, processDefinitionKey int64) error {
-	processNameTitle := toCamelCase(processName)
-	tableName := processName
-	if err := generateCrudProcess(processNameTitle, resourceName, tableName, version, processDefinitionKey); err != nil {
-		return err
+	if cfg.Timeout > 0 {
+		builder = builder.Timeout(cfg.Timeout)
 	}
 
-	return nil
-}
+	w := builder.Open()
 
-// GenerateCRUDHandlers generates CRUD handlers from process metadata.
-func (c *Client) GenerateCRUDHandlers(processMetadata *pb.ProcessMetadata) error {
-	processName := toCamelCase(processMetadata.GetBpmnProcessId())
-	tableName := processMetadata.GetBpmnProcessId()
-	version := processMetadata.GetVersion()
-	resourceName := processMetadata.GetResourceName()
-	processDefinitionKey := processMetadata.GetProcessDefinitionKey()
-
-	if err := generateCrudProcess(processName, resourceName, tableName, version, processDefinitionKey); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-// GenerateCRUDUserTaskServiceTaskHandler generates CRUD handlers for user task and service task.
-func (c *Client) GenerateCRUDUserTaskServiceTaskHandler(bpmnProcess *[]BPMNProcess) error {
-	for _, process := range *bpmnProcess {
-		for _, serviceTask := range process.ServiceTask {
-			if err := generateCrudServiceTask(serviceTask); err != nil {
-				return err
-			}
-		}
-		for _, userTask := range process.UserTasks {
-			if err := generateCrudUserTask(userTask); err != nil {
-				return err
-			}
-		}
-	}
-	return nil
+	return w, nil
 }
 
 // generate crud code for service task
-func generateCrudServiceTask(serviceTask ServiceTask) error {
+func (g *Generator) generateCrudServiceTask(serviceTask ServiceTask) error {
 	idServiceTask := serviceTask.ID
 	nameServiceTask := serviceTask.Name
 	var serviceTaskName string
@@ -255,26 +275,50 @@ func generateCrudServiceTask(serviceTask ServiceTask) error {
 			nameFile = strings.ToLower(taskDefinition.Type)
 		}
 	}
+	if taskDefinitionType == "" {
+		return fmt.Errorf("service task %s has no task definition type", idServiceTask)
+	}
+
 	filePathStore := fmt.Sprintf("./internal/service/%s_service_task.go", nameFile)
+	filePathEditService := "./internal/service/service.go"
+	handlerName := strings.ToLower(serviceTaskName[:1]) + serviceTaskName[1:]
+
+	serviceTaskCode := fmt.Sprintf(`package service
+
+import (
+	"context"
+
+	"github.com/camunda-community-hub/zeebe-client-go/v8/pkg/entities"
+)
 
-	serviceTaskCode := fmt.Sprintf(`package store
 const (
 	%sID = "%s"
 	%sName = "%s"
 	%sType = "%s"
 )
+
 // TODO: DO SOMETHING IN SERVICE TASK
-`, serviceTaskName, idServiceTask, serviceTaskName, nameServiceTask, serviceTaskName, taskDefinitionType)
-	err := os.WriteFile(filePathStore, []byte(serviceTaskCode), 0o644)
+func (s *Service) %s(ctx context.Context, job entities.Job) (map[string]interface{}, error) {
+	return nil, nil
+}
+`, serviceTaskName, idServiceTask, serviceTaskName, nameServiceTask, serviceTaskName, taskDefinitionType, handlerName)
+	err := g.writeFile(filePathStore, serviceTaskCode)
 	if err != nil {
 		return fmt.Errorf("failed to write service task file: %w", err)
 	}
 
+	generateCodeHandler := fmt.Sprintf(`		%sType: s.%s,`, serviceTaskName, handlerName)
+
+	err = g.insertGeneratedCode(filePathEditService, generateCodeHandler, "// GENERATED SERVICE TASK HANDLERS")
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // generate code crud for user task
-func generateCrudUserTask(userTask UserTask) error {
+func (g *Generator) generateCrudUserTask(userTask UserTask) error {
 	idServiceTask := userTask.ID
 	nameServiceTask := userTask.Name
 	userTaskName := toCamelCase(userTask.Name)
@@ -298,37 +342,114 @@ func generateCrudUserTask(userTask UserTask) error {
 		}
 	}
 
-	moduleName, errModule := getModuleName()
+	moduleName, errModule := g.moduleName()
 	if errModule != nil {
 		return errModule
 	}
 
 	filePathHandler := fmt.Sprintf("./cmd/api/%s_user_task.go", nameFile)
-	filePathScripts := fmt.Sprintf("./scripts/%s_user_task.sql", nameFile)
-	scriptCode := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
-	id BIGSERIAL PRIMARY KEY,
-	name VARCHAR(256) NOT NULL,
-	task_id VARCHAR(256),
-	form_id VARCHAR(256),
-	properties JSONB,
-	created_by BIGINT NOT NULL,
-	updated_by BIGINT,
-	created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
-	updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
-	deleted_at TIMESTAMP(0) WITH TIME ZONE
-);
 
-DROP TABLE IF EXISTS %s;
+	// user tasks are listed below the process they belong to, a task is
+	// claimed, unclaimed and completed by its name, which is unique as it
+	// names the table too
+	routePath := fmt.Sprintf("%s/tasks/%s", userTask.ProcessID, nameFile)
+	actionPath := nameFile
+
+	var form *Form
+	if formID != "" {
+		var err error
+		form, err = readFormFile(g.formPath(formID))
+		if err != nil {
+			return err
+		}
+	}
+
+	table := migrationTable{
+		Name: nameFile,
+		Columns: []migrationColumn{
+			{"id", "BIGSERIAL PRIMARY KEY"},
+			{"name", "VARCHAR(256) NOT NULL"},
+			{"task_id", "VARCHAR(256)"},
+			{"form_id", "VARCHAR(256)"},
+			{"properties", "JSONB"},
+			{"created_by", "BIGINT NOT NULL"},
+			{"updated_by", "BIGINT"},
+			{"created_at", "TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()"},
+			{"updated_at", "TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()"},
+			{"deleted_at", "TIMESTAMP(0) WITH TIME ZONE"},
+		},
+		Statements: []string{
+			"CREATE EXTENSION IF NOT EXISTS pg_trgm;",
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_properties ON %s USING gin (properties);", nameFile, nameFile),
+		},
+	}
+
+	var fields []formField
+	if form != nil {
+		fields = formFields(form, userTaskName, table.Columns)
+	}
+	stored := storedFields(fields)
+	for _, field := range stored {
+		table.Columns = append(table.Columns, migrationColumn{field.Column, field.ColumnType})
+	}
+
+	if err := g.generateMigration(table); err != nil {
+		return fmt.Errorf("failed to generate migration: %w", err)
+	}
+
+	// the form fields follow the columns every user task table has
+	columns := []string{"id", "name", "form_id", "task_id", "properties", "created_by", "updated_by", "created_at", "updated_at"}
+	scanData := []string{"propertiesData"}
+	insertColumns := []string{"name", "form_id", "created_by", "task_id", "properties"}
+	insertValues := []string{"$1", "$2", "$3", "$4", "$5"}
+	updateSet := []string{"name = $1", "form_id = $2", "updated_by = $3", "task_id = $4", "properties = $5"}
+	searchColumns := []string{"name", "task_id"}
+	var modelFields, encode, values, scanTargets, decode strings.Builder
+	values.WriteString("\t\tpropertiesJSON,\n")
+	for _, target := range []string{"model.ID", "model.Name", "model.FormId", "model.TaskId", "propertiesData", "model.CreatedBy", "model.UpdatedBy", "model.CreatedAt", "model.UpdatedAt"} {
+		fmt.Fprintf(&scanTargets, "\t\t&%s,\n", target)
+	}
 
-CREATE EXTENSION IF NOT EXISTS pg_trgm;
+	for _, field := range stored {
+		placeholder := fmt.Sprintf("$%d", len(insertValues)+1)
+		columns = append(columns, field.Column)
+		insertColumns = append(insertColumns, field.Column)
+		insertValues = append(insertValues, placeholder)
+		updateSet = append(updateSet, field.Column+" = "+placeholder)
+		if field.ColumnType == "TEXT" || field.ColumnType == "VARCHAR(256)" {
+			searchColumns = append(searchColumns, field.Column)
+		}
 
-CREATE INDEX IF NOT EXISTS idx_%s_properties ON %s USING gin (properties);
+		fmt.Fprintf(&modelFields, "\t%s %s `json:\"%s\"`\n", field.Name, field.Type, field.Key)
 
-	`, nameFile, nameFile, nameFile, nameFile)
+		if !field.isJSON() {
+			fmt.Fprintf(&values, "\t\tmodel.%s,\n", field.Name)
+			fmt.Fprintf(&scanTargets, "\t\t&model.%s,\n", field.Name)
+			continue
+		}
 
-	err := os.WriteFile(filePathScripts, []byte(scriptCode), 0o644)
+		variable := lowerFirst(field.Name)
+		scanData = append(scanData, variable+"Data")
+		fmt.Fprintf(&values, "\t\t%sJSON,\n", variable)
+		fmt.Fprintf(&scanTargets, "\t\t&%sData,\n", variable)
+		fmt.Fprintf(&encode, `
+	%[1]sJSON, err := json.Marshal(model.%[2]s)
 	if err != nil {
-		return fmt.Errorf("failed to write script file: %w", err)
+		return nil, err
+	}
+`, variable, field.Name)
+		fmt.Fprintf(&decode, `
+	if len(%[1]sData) > 0 {
+		if err := json.Unmarshal(%[1]sData, &model.%[2]s); err != nil {
+			return err
+		}
+	}
+`, variable, field.Name)
+	}
+
+	var searchConditions []string
+	for _, column := range searchColumns {
+		searchConditions = append(searchConditions, fmt.Sprintf("\t\t\t\t%s ILIKE '%%' || ` + search + ` || '%%'", column))
 	}
 
 	filePathStore := fmt.Sprintf("./internal/store/%s_user_task.go", nameFile)
@@ -338,37 +459,42 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"strconv"
 )
 
 const (
-	%sID = "%s"
-	%sName = "%s"
-	%sFormID = "%s"
-	%sAssignee = "%s"
-	%sCandidateGroup = "%s"
-	%sCandidateUser = "%s"
-	%sSchedule = %s%s%s
+	%[1]sID = "%[4]s"
+	%[1]sProcessID = "%[25]s"
+	%[1]sName = "%[5]s"
+	%[1]sFormID = "%[6]s"
+	%[1]sAssignee = "%[7]s"
+	%[1]sCandidateGroup = "%[8]s"
+	%[1]sCandidateUser = "%[9]s"
+	%[1]sSchedule = %[3]s%[10]s%[3]s
 
 )
 
-type %s struct {
-    ID         int64    `+"`json:\"id\"`"+`
-	Name       string   `+"`json:\"name\"`"+`
-	TaskId     string   `+"`json:\"task_id\"`"+`
-	FormId     string   `+"`json:\"form_id\"`"+`
-	Properties []string `+"`json:\"properties\"`"+`
-	CreatedBy  int64    `+"`json:\"created_by\"`"+`
-	UpdatedBy  *int64   `+"`json:\"updated_by\"`"+`
-	CreatedAt  string   `+"`json:\"created_at\"`"+`
-	UpdatedAt  string   `+"`json:\"updated_at\"`"+`
-	DeletedAt  *string  `+"`json:\"deleted_at\"`"+`
-}
-
-type %sStore struct {
+// %[11]sColumns are read by scan%[1]s in this order
+const %[11]sColumns = %[3]s%[12]s%[3]s
+
+type %[1]s struct {
+    ID         int64    %[3]sjson:"id"%[3]s
+	Name       string   %[3]sjson:"name"%[3]s
+	TaskId     string   %[3]sjson:"task_id"%[3]s
+	FormId     string   %[3]sjson:"form_id"%[3]s
+	Properties map[string]interface{} %[3]sjson:"properties"%[3]s
+	CreatedBy  int64    %[3]sjson:"created_by"%[3]s
+	UpdatedBy  *int64   %[3]sjson:"updated_by"%[3]s
+	CreatedAt  string   %[3]sjson:"created_at"%[3]s
+	UpdatedAt  string   %[3]sjson:"updated_at"%[3]s
+	DeletedAt  *string  %[3]sjson:"deleted_at"%[3]s
+%[13]s}
+%[14]s
+type %[1]sStore struct {
 	db *sql.DB
 }
 
-func (s *%sStore) Create(ctx context.Context, model *%s) error {
+func (s *%[1]sStore) Create(ctx context.Context, model *%[1]s) error {
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
 		if err := s.create(ctx, tx, model); err != nil {
 			return err
@@ -377,16 +503,16 @@ func (s *%sStore) Create(ctx context.Context, model *%s) error {
 	})
 }
 
-func (s *%sStore) Delete(ctx context.Context, id int64) error {
+func (s *%[1]sStore) Delete(ctx context.Context, id int64) error {
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
 		if err := s.delete(ctx, tx, id); err != nil {
 			return err
 		}
 		return nil
-	})	
+	})
 }
 
-func (s *%sStore) Update(ctx context.Context, model *%s) error {
+func (s *%[1]sStore) Update(ctx context.Context, model *%[1]s) error {
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
 		if err := s.update(ctx, tx, model); err != nil {
 			return err
@@ -394,84 +520,37 @@ func (s *%sStore) Update(ctx context.Context, model *%s) error {
 		return nil
 	})
 }
-	
-func (s *%sStore) create(ctx context.Context, tx *sql.Tx, model *%s) error {
-	if model.Properties == nil {
-		model.Properties = []string{}
-	}
 
-	propertiesJSON, errProperties := json.Marshal(model.Properties)
-	if errProperties != nil {
-		return errProperties
+func (s *%[1]sStore) create(ctx context.Context, tx *sql.Tx, model *%[1]s) error {
+	values, err := model.values()
+	if err != nil {
+		return err
 	}
 
-	query := %s
-		INSERT INTO %s (name, form_id, properties, created_by, task_id)
-		VALUES (
-			$1,
-			$2,
-			$3,
-			$4,
-			$5
-		) RETURNING 
-		 	id, name, form_id, task_id, properties, created_by, updated_by,
-			created_at, updated_at
-		%s
+	query := %[3]s
+		INSERT INTO %[2]s (%[15]s)
+		VALUES (%[16]s)
+		RETURNING %[3]s + %[11]sColumns
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	var propertiesData []byte
-	err := tx.QueryRowContext(
-		ctx,
-		query,
-		model.Name,
-		model.FormId,
-		propertiesJSON,
-		model.CreatedBy,
-		model.TaskId,
-	).Scan(
-		&model.ID,
-		&model.Name,
-		&model.FormId,
-		&model.TaskId,
-		&propertiesData,
-		&model.CreatedBy,
-		&model.UpdatedBy,
-		&model.CreatedAt,
-		&model.UpdatedAt,
-	)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	args := append([]interface{}{model.Name, model.FormId, model.CreatedBy, model.TaskId}, values...)
+	return scan%[1]s(tx.QueryRowContext(ctx, query, args...), model)
 }
 
-func (s *%sStore) GetByID(ctx context.Context, id int64) (*%s, error) {
-	query := %s
-		SELECT id, name, form_id, task_id, properties, created_by, 
-		updated_by, created_at, updated_at
-		FROM %s
+func (s *%[1]sStore) GetByID(ctx context.Context, id int64) (*%[1]s, error) {
+	query := %[3]s
+		SELECT %[3]s + %[11]sColumns + %[3]s
+		FROM %[2]s
 		WHERE id = $1 AND deleted_at IS NULL
-	%s
+	%[3]s
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	var model %s
-	var propertiesData []byte
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
-		&model.ID,
-		&model.Name,
-		&model.FormId,
-		&model.TaskId,
-		&propertiesData,
-		&model.CreatedBy,
-		&model.UpdatedBy,
-		&model.CreatedAt,
-		&model.UpdatedAt,
-	)
-	if err != nil {
+	var model %[1]s
+	if err := scan%[1]s(s.db.QueryRowContext(ctx, query, id), &model); err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return nil, ErrNotFound
@@ -479,20 +558,99 @@ func (s *%sStore) GetByID(ctx context.Context, id int64) (*%s, error) {
 			return nil, err
 		}
 	}
-		
-	if len(propertiesData) > 0 {
-		if err := json.Unmarshal(propertiesData, &model.Properties); err != nil {
+
+	return &model, nil
+}
+
+func (s *%[1]sStore) Search(ctx context.Context, pq PaginatedQuery) (map[string]interface{}, error) {
+	sortOrder := "DESC"
+	if pq.Sort == "asc" || pq.Sort == "ASC" {
+		sortOrder = "ASC"
+	}
+
+	// filters are shared by the page and the count query
+	where := %[3]s
+		WHERE deleted_at IS NULL
+	%[3]s
+	var args []interface{}
+
+	if pq.Search != "" {
+		args = append(args, pq.Search)
+		search := "$" + strconv.Itoa(len(args))
+		where += %[3]s
+			AND (
+%[19]s
+			)
+		%[3]s
+	}
+
+	if pq.Since != "" && pq.Until != "" {
+		args = append(args, pq.Since, pq.Until)
+		where += %[3]s
+			AND created_at BETWEEN $%[3]s + strconv.Itoa(len(args)-1) + %[3]s AND $%[3]s + strconv.Itoa(len(args))
+	}
+
+	query := %[3]s
+		SELECT %[3]s + %[11]sColumns + %[3]s
+		FROM %[2]s
+	%[3]s + where + %[3]s
+		ORDER BY created_at %[3]s + sortOrder + %[3]s
+		LIMIT $%[3]s + strconv.Itoa(len(args)+1) + %[3]s OFFSET $%[3]s + strconv.Itoa(len(args)+2)
+
+	params := append([]interface{}{}, args...)
+	params = append(params, pq.Limit, pq.Offset)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*%[1]s{}
+	for rows.Next() {
+		var model %[1]s
+		if err := scan%[1]s(rows, &model); err != nil {
 			return nil, err
 		}
-	} else {
-		model.Properties = []string{}
+		results = append(results, &model)
 	}
 
-	return &model, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	countQuery := %[3]s
+		SELECT COUNT(*)
+		FROM %[2]s
+	%[3]s + where
+
+	var totalCount int
+	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&totalCount); err != nil {
+		return nil, err
+	}
+
+	totalPages := (totalCount + pq.Limit - 1) / pq.Limit
+
+	response := map[string]interface{}{
+		"content":      results,
+		"totalElement": totalCount,
+		"totalPages":   totalPages,
+		"limit":        pq.Limit,
+		"offset":       pq.Offset,
+		"sort":         pq.Sort,
+		"search":       pq.Search,
+		"since":        pq.Since,
+		"until":        pq.Until,
+	}
+
+	return response, nil
 }
 
-func (s *%sStore) delete(ctx context.Context, tx *sql.Tx, id int64) error {
-	query := %sUPDATE %s SET deleted_at = NOW() WHERE id = $1;%s
+func (s *%[1]sStore) delete(ctx context.Context, tx *sql.Tx, id int64) error {
+	query := %[3]sUPDATE %[2]s SET deleted_at = NOW() WHERE id = $1;%[3]s
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
@@ -514,47 +672,24 @@ func (s *%sStore) delete(ctx context.Context, tx *sql.Tx, id int64) error {
 	return nil
 }
 
-func (s *%sStore) update(ctx context.Context, tx *sql.Tx, model *%s) error {
-
-	if model.Properties == nil {
-		model.Properties = []string{}
+func (s *%[1]sStore) update(ctx context.Context, tx *sql.Tx, model *%[1]s) error {
+	values, err := model.values()
+	if err != nil {
+		return err
 	}
 
-	propertiesJSON, errProperties := json.Marshal(model.Properties)
-	if errProperties != nil {
-		return errProperties
-	}
-	query := %s
-		UPDATE %s
-		SET name = $1, form_id = $2, properties = $3, updated_by = $4, task_id = $5,  updated_at = NOW()
-		WHERE id = $6 AND deleted_at IS NULL
-		RETURNING id, name, form_id, task_id, properties, created_by, updated_by, created_at, updated_at;
-	%s
+	query := %[3]s
+		UPDATE %[2]s
+		SET %[17]s, updated_at = NOW()
+		WHERE id = $%[18]d AND deleted_at IS NULL
+		RETURNING %[3]s + %[11]sColumns
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	var propertiesData []byte
-	err := tx.QueryRowContext(
-		ctx,
-		query,
-		model.Name,
-		model.FormId,
-		propertiesJSON,
-		model.UpdatedBy,
-		model.TaskId,
-		model.ID,
-	).Scan(&model.ID, 
-		&model.Name, 
-		&model.FormId, 
-		&model.TaskId,
-		propertiesData, 
-		&model.CreatedBy, 
-		&model.UpdatedBy, 
-		&model.CreatedAt, 
-		&model.UpdatedAt,
-	)
-	if err != nil {
+	args := append([]interface{}{model.Name, model.FormId, model.UpdatedBy, model.TaskId}, values...)
+	args = append(args, model.ID)
+	if err := scan%[1]s(tx.QueryRowContext(ctx, query, args...), model); err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrNotFound
@@ -566,81 +701,82 @@ func (s *%sStore) update(ctx context.Context, tx *sql.Tx, model *%s) error {
 	return nil
 }
 
-`,
-		userTaskName,
-		idServiceTask,
-		userTaskName,
-		nameServiceTask,
-		userTaskName,
-		formID,
-		userTaskName,
-		assignee,
-		userTaskName,
-		candidateGroup,
-		userTaskName,
-		candidateUser,
-		userTaskName,
-		"`",
-		dueDate,
-		"`",
-		userTaskName,
-		userTaskName,
+// values returns the properties and the form fields in the order of their
+// columns.
+func (model *%[1]s) values() ([]interface{}, error) {
+	if model.Properties == nil {
+		model.Properties = map[string]interface{}{}
+	}
 
-		userTaskName,
-		userTaskName,
-		userTaskName,
-		userTaskName,
-		userTaskName,
+	propertiesJSON, err := json.Marshal(model.Properties)
+	if err != nil {
+		return nil, err
+	}
+%[20]s
+	return []interface{}{
+%[21]s	}, nil
+}
 
-		userTaskName,
-		userTaskName,
-		"`",
-		nameFile,
-		"`",
-		userTaskName,
-		userTaskName,
-		"`",
-		nameFile,
-		"`",
-		userTaskName,
+// scan%[1]s reads a row of %[11]sColumns into the model.
+func scan%[1]s(row interface{ Scan(...interface{}) error }, model *%[1]s) error {
+	var %[22]s []byte
+	if err := row.Scan(
+%[23]s	); err != nil {
+		return err
+	}
 
+	model.Properties = map[string]interface{}{}
+	if len(propertiesData) > 0 {
+		if err := json.Unmarshal(propertiesData, &model.Properties); err != nil {
+			return err
+		}
+	}
+%[24]s
+	return nil
+}
+`,
 		userTaskName,
-		"`",
-		nameFile,
-		"`",
-		userTaskName,
-		userTaskName,
-		"`",
 		nameFile,
 		"`",
+		idServiceTask,
+		nameServiceTask,
+		formID,
+		assignee,
+		candidateGroup,
+		candidateUser,
+		dueDate,
+		lowerFirst(userTaskName),
+		strings.Join(columns, ", "),
+		modelFields.String(),
+		itemStructCode(fields),
+		strings.Join(insertColumns, ", "),
+		strings.Join(insertValues, ", "),
+		strings.Join(updateSet, ", "),
+		len(insertValues)+1,
+		strings.Join(searchConditions, " OR\n"),
+		encode.String(),
+		values.String(),
+		strings.Join(scanData, ", "),
+		scanTargets.String(),
+		decode.String(),
+		userTask.ProcessID,
 	)
 
-	err = os.WriteFile(filePathStore, []byte(modelCode), 0o644)
+	err := g.writeFile(filePathStore, modelCode)
 	if err != nil {
 		return fmt.Errorf("failed to write model file: %w", err)
 	}
 
 	// if formID is not empty then generate code form
 	structCode := ""
-	if formID != "" {
-		formFile := formID + ".form"
-		filePathForm := fmt.Sprintf("./internal/zeebe/resources/%s", formFile)
-
-		form, errForm := readFormFile(filePathForm)
-		if errForm != nil {
-			return errForm
-		}
-
-		structCode = generateStructCode(form, userTaskName)
+	if form != nil {
+		structCode = generateStructCode(fields, userTaskName)
 	}
 
 	// create handler usertask
 	handlerUserTaskCode := fmt.Sprintf(`package main
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
 	"net/http"
 	"time"
 
@@ -669,7 +805,7 @@ import (
 //	@Failure		404				{object}	error
 //	@Failure		500				{object}	error
 //	@Security		ApiKeyAuth
-//	@Router			/bpmn/%s/search  [get]
+//	@Router			/bpmn/%s  [get]
 func (app *application) getUserTaskActive%sHandler(w http.ResponseWriter, r *http.Request) {
 	taskListQueryParams, err := getTaskListQueryParams(r)
 	if err != nil {
@@ -690,60 +826,17 @@ func (app *application) getUserTaskActive%sHandler(w http.ResponseWriter, r *htt
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
 
-	jsonTemplate := %s
-{
-	"taskDefinitionId": "%s",
-	"state:": "%s",
-    "pageSize": %s, 
-    "sort": [{"field": "%s", "order": "%s"}]
-    %s
-}%s
-
-	var searchAfterStr, searchBeforeStr string
-
-	if taskListQueryParams.SearchAfter != "" {
-		searchAfterStr = fmt.Sprintf(%s, "searchAfter": %s%s, taskListQueryParams.SearchAfter)
-	}
-
-	if taskListQueryParams.SearchBefore != "" {
-		searchBeforeStr = fmt.Sprintf(%s, "searchBefore": %s%s, taskListQueryParams.SearchBefore)
-	}
-
-	searchParams := searchAfterStr + searchBeforeStr
-
-	body := []byte(fmt.Sprintf(
-		jsonTemplate,
-		store.%sID,
-		taskListQueryParams.State,
-		taskListQueryParams.Size,
-		taskListQueryParams.Sort,
-		taskListQueryParams.Order,
-		searchParams))
-
-	// get history from operate api rest api
-	url := fmt.Sprintf("%s%s/search", app.config.camundaRest.camundaTasklistBaseUrl, V1TasklistUrl)
-	resp, err := app.zeebeClientRest.SendRequest(
-		ctx,
-		http.MethodPost,
-		url,
-		bytes.NewBuffer(body),
-	)
+	tasks, err := app.searchUserTasks(ctx, store.%sID, taskListQueryParams, GetUserFromContext(r))
 	if err != nil {
 		app.handleRequestError(w, r, err)
 		return
 	}
 
-	var jsonData interface{}
-	if err := json.Unmarshal(resp, &jsonData); err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-
-	if err := app.jsonResponse(w, http.StatusOK, jsonData); err != nil {
+	if err := app.jsonResponse(w, http.StatusOK, tasks); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
-	
+}
 `,
 		moduleName,
 		structCode,
@@ -752,45 +845,201 @@ func (app *application) getUserTaskActive%sHandler(w http.ResponseWriter, r *htt
 		userTaskName,
 		userTaskName,
 		userTaskName,
-		nameFile,
+		routePath,
+		userTaskName,
 		userTaskName,
+	)
 
-		"`",
-		"%s",
-		"%s",
-		"%d",
-		"%s",
-		"%s",
-		"%s",
-		"`",
+	var modelAssignments strings.Builder
+	for _, field := range stored {
+		fmt.Fprintf(&modelAssignments, "\t\t%[1]s: payload.%[1]s,\n", field.Name)
+	}
 
-		"`",
-		"%s",
-		"`",
+	handlerUserTaskCode += fmt.Sprintf(`
+// Search %[1]s godoc
+//
+//	@Summary		Search %[1]s
+//	@Description	Search the submitted %[1]s forms
+//	@Tags			bpmn/%[1]s
+//	@Accept			json
+//	@produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			page	query		int		false	"Page"
+//	@Param			sort	query		string	false	"Sort asc desc"
+//	@Param			search	query		string	false	"Search"
+//	@Param			since	query		string	false	"Since"
+//	@Param			until	query		string	false	"Until"
+//	@Success		200		{string}	string	"%[1]s Search"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bpmn/%[2]s/submissions  [get]
+func (app *application) search%[1]sHandler(w http.ResponseWriter, r *http.Request) {
+	pq := store.PaginatedQuery{
+		Limit: 20,
+		Page:  1,
+		Sort:  "desc",
+	}
+	if err := pq.Parse(r); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(pq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
 
-		"`",
-		"%s",
-		"`",
+	ctx := r.Context()
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
 
-		userTaskName,
+	models, err := app.store.%[1]s.Search(ctx, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, models); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
 
-		"%s",
-		"%s",
-	)
+// Claim %[1]s godoc
+//
+//	@Summary		Claim %[1]s
+//	@Description	Assign the %[1]s user task to the current user
+//	@Tags			bpmn/%[1]s
+//	@Accept			json
+//	@produce		json
+//	@Param			taskKey	path		int	true	"Task Key"
+//	@Success		200		{object}	camunda.Task
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bpmn/%[4]s/{taskKey}/claim  [post]
+func (app *application) claim%[1]sHandler(w http.ResponseWriter, r *http.Request) {
+	app.claimUserTask(w, r, store.%[1]sID)
+}
+
+// Unclaim %[1]s godoc
+//
+//	@Summary		Unclaim %[1]s
+//	@Description	Unassign the %[1]s user task from the current user
+//	@Tags			bpmn/%[1]s
+//	@Accept			json
+//	@produce		json
+//	@Param			taskKey	path		int	true	"Task Key"
+//	@Success		200		{object}	camunda.Task
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bpmn/%[4]s/{taskKey}/unclaim  [post]
+func (app *application) unclaim%[1]sHandler(w http.ResponseWriter, r *http.Request) {
+	app.unclaimUserTask(w, r, store.%[1]sID)
+}
+
+// Complete %[1]s godoc
+//
+//	@Summary		Complete %[1]s
+//	@Description	Complete the %[1]s user task claimed by the current user
+//	@Tags			bpmn/%[1]s
+//	@Accept			json
+//	@produce		json
+//	@Param			taskKey	path		int				true	"Task Key"
+//	@Param			payload	body		FormData%[1]s	true	"Form Data %[1]s"
+//	@Success		201		{object}	store.%[1]s
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bpmn/%[4]s/{taskKey}/complete  [post]
+func (app *application) complete%[1]sHandler(w http.ResponseWriter, r *http.Request) {
+	taskKey, err := getTaskKeyParam(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload FormData%[1]s
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	user := GetUserFromContext(r)
+
+	task, err := app.getAssignedUserTask(ctx, taskKey, store.%[1]sID, user)
+	if err != nil {
+		app.userTaskErrorResponse(w, r, err)
+		return
+	}
+
+	variables, err := formVariables(payload)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	model := &store.%[1]s{
+		Name:       store.%[1]sName,
+		TaskId:     task.ID,
+		FormId:     store.%[1]sFormID,
+		Properties: variables,
+		CreatedBy:  user.ID,
+%[3]s	}
+
+	if err := app.store.%[1]s.Create(ctx, model); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.completeUserTask(ctx, task, variables); err != nil {
+		// the task is still open, drop the submission so it can be sent again
+		if errDelete := app.store.%[1]s.Delete(ctx, model.ID); errDelete != nil {
+			app.logger.Errorw("failed to delete user task submission", "id", model.ID, "error", errDelete)
+		}
+		app.handleRequestError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, model); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+`, userTaskName, routePath, modelAssignments.String(), actionPath)
 
-	err = os.WriteFile(filePathHandler, []byte(handlerUserTaskCode), 0o644)
+	err = g.writeFile(filePathHandler, handlerUserTaskCode)
 	if err != nil {
 		return fmt.Errorf("failed to write handler file: %w", err)
 	}
 
 	filePathEditRoutes := "./cmd/api/api.go"
 	generateCodeRoutes := fmt.Sprintf(`
-			r.Route("/%s", func(r chi.Router) {
-				r.Get("/search", app.getUserTaskActive%sHandler)
-			})	
-`, nameFile, userTaskName)
-
-	err = insertGeneratedCode(filePathEditRoutes, generateCodeRoutes, "// GENERATE USER TASK ROUTES API")
+			r.Route("/%[1]s", func(r chi.Router) {
+				r.Get("/", app.getUserTaskActive%[2]sHandler)
+				r.Get("/submissions", app.search%[2]sHandler)
+			})
+			r.Route("/%[3]s/{taskKey}", func(r chi.Router) {
+				r.Post("/claim", app.claim%[2]sHandler)
+				r.Post("/unclaim", app.unclaim%[2]sHandler)
+				r.Post("/complete", app.complete%[2]sHandler)
+			})
+`, routePath, userTaskName, actionPath)
+
+	err = g.insertGeneratedCode(filePathEditRoutes, generateCodeRoutes, "// GENERATE USER TASK ROUTES API")
 	if err != nil {
 		return err
 	}
@@ -803,6 +1052,7 @@ func (app *application) getUserTaskActive%sHandler(w http.ResponseWriter, r *htt
 		Create(context.Context, *%s) error
 		Delete(context.Context, int64) error
 		GetByID(context.Context, int64) (*%s, error)
+		Search(context.Context, PaginatedQuery) (map[string]interface{}, error)
 	}
 `,
 		userTaskName,
@@ -813,12 +1063,12 @@ func (app *application) getUserTaskActive%sHandler(w http.ResponseWriter, r *htt
 		%s:   &%sStore{db},
 `, userTaskName, userTaskName)
 
-	err = insertGeneratedCode(filePathEditStorage, generateCodeStorage, "// GENERATED CODE INTERFACE")
+	err = g.insertGeneratedCode(filePathEditStorage, generateCodeStorage, "// GENERATED CODE INTERFACE")
 	if err != nil {
 		return err
 	}
 
-	err = insertGeneratedCode(filePathEditStorage, generateCodeConstructor, "// GENERATED CODE CONSTRUCTOR")
+	err = g.insertGeneratedCode(filePathEditStorage, generateCodeConstructor, "// GENERATED CODE CONSTRUCTOR")
 	if err != nil {
 		return err
 	}
@@ -843,7 +1093,7 @@ type %sStore struct {
 }
 
 const %sExpTime = time.Hour * 24 * 7
-	
+
 func (s *%sStore) Get(ctx context.Context, modelID int64) (*store.%s, error) {
 	cacheKey := fmt.Sprintf("%s-%s", modelID)
 
@@ -899,7 +1149,7 @@ func (s *%sStore) Delete(ctx context.Context, modelID int64) {
 		"%v",
 	)
 
-	err = os.WriteFile(filePathStoreCache, []byte(modelCacheCode), 0o644)
+	err = g.writeFile(filePathStoreCache, modelCacheCode)
 	if err != nil {
 		return fmt.Errorf("failed to write cache file: %w", err)
 	}
@@ -926,12 +1176,12 @@ func (s *%sStore) Delete(ctx context.Context, modelID int64) {
 		userTaskName,
 	)
 
-	err = insertGeneratedCode(filePathEditCacheStorage, generateCodeCacheStorage, "// GENERATED CACHE CODE INTERFACE")
+	err = g.insertGeneratedCode(filePathEditCacheStorage, generateCodeCacheStorage, "// GENERATED CACHE CODE INTERFACE")
 	if err != nil {
 		return err
 	}
 
-	err = insertGeneratedCode(filePathEditCacheStorage, generateCodeCacheInterface, "// GENERATED CACHE CODE CONSTRUCTOR")
+	err = g.insertGeneratedCode(filePathEditCacheStorage, generateCodeCacheInterface, "// GENERATED CACHE CODE CONSTRUCTOR")
 	if err != nil {
 		return err
 	}
@@ -939,42 +1189,40 @@ func (s *%sStore) Delete(ctx context.Context, modelID int64) {
 	return nil
 }
 
-func generateCrudProcess(processName, resourceName, tableName string, version int32, processDefinitionKey int64) error {
+func (g *Generator) generateCrudProcess(processName, resourceName, tableName string, version int32, processDefinitionKey int64) error {
 	filePathHandler := fmt.Sprintf("./cmd/api/%s_process.go", tableName)
 	filePathStore := fmt.Sprintf("./internal/store/%s_process.go", tableName)
 	filePathStoreCache := fmt.Sprintf("./internal/store/cache/%s_process.go", tableName)
-	filePathScripts := fmt.Sprintf("./scripts/%s_process.sql", tableName)
 	filePathEditCacheStorage := "./internal/store/cache/storage.go"
 	filePathEditStorage := "./internal/store/storage.go"
 	filePathEditRoutes := "./cmd/api/api.go"
+	filePathEditReconciler := "./internal/service/reconciler.go"
 
-	moduleName, errModule := getModuleName()
+	moduleName, errModule := g.moduleName()
 	if errModule != nil {
 		return errModule
 	}
 
-	scriptCode := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
-	id BIGSERIAL PRIMARY KEY,
-	process_definition_key BIGINT NOT NULL,
-	version INT NOT NULL,
-	resource_name VARCHAR(256) NOT NULL,
-	process_instance_key BIGINT,
-	task_definition_id VARCHAR(256),
-	task_state VARCHAR(20) NOT NULL DEFAULT 'CREATED', 
-	created_by BIGINT NOT NULL,
-	updated_by BIGINT,
-	created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
-	updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
-	deleted_at TIMESTAMP(0) WITH TIME ZONE,
-	CONSTRAINT task_state_check CHECK (task_state IN ('CREATED', 'COMPLETED', 'CANCELED', 'FAILED'))
-)
-
-DROP TABLE IF EXISTS %s;
-	`, tableName, tableName)
-
-	err := os.WriteFile(filePathScripts, []byte(scriptCode), 0o644)
+	err := g.generateMigration(migrationTable{
+		Name: tableName,
+		Columns: []migrationColumn{
+			{"id", "BIGSERIAL PRIMARY KEY"},
+			{"process_definition_key", "BIGINT NOT NULL"},
+			{"version", "INT NOT NULL"},
+			{"resource_name", "VARCHAR(256) NOT NULL"},
+			{"process_instance_key", "BIGINT"},
+			{"task_definition_id", "VARCHAR(256)"},
+			{"task_state", "VARCHAR(20) NOT NULL DEFAULT 'CREATED'"},
+			{"created_by", "BIGINT NOT NULL"},
+			{"updated_by", "BIGINT"},
+			{"created_at", "TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()"},
+			{"updated_at", "TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()"},
+			{"deleted_at", "TIMESTAMP(0) WITH TIME ZONE"},
+		},
+		Constraint: "CONSTRAINT task_state_check CHECK (task_state IN ('CREATED', 'COMPLETED', 'CANCELED', 'FAILED'))",
+	})
 	if err != nil {
-		return fmt.Errorf("failed to write script file: %w", err)
+		return fmt.Errorf("failed to generate migration: %w", err)
 	}
 
 	modelCode := fmt.Sprintf(`package store
@@ -982,12 +1230,14 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strconv"
 )
 
 const (
 	%sVersion = %d
 	%sProcessDefinitionKey = %d
 	%sResourceName = "%s"
+	%sTableName = "%s"
 )
 
 // TODO: UPDATE THIS STRUCT AND CODE BELOW
@@ -1038,8 +1288,8 @@ func (s *%sStore) Update(ctx context.Context, model *%s) error {
 }
 	
 func (s *%sStore) create(ctx context.Context, tx *sql.Tx, model *%s) error {
-	//model.Version = %d
-	//model.ProcessDefinitionKey = %d
+	// model.Version = %d
+	// model.ProcessDefinitionKey = %d
 	model.ResourceName = "%s"
 
 	query := %s
@@ -1050,10 +1300,10 @@ func (s *%sStore) create(ctx context.Context, tx *sql.Tx, model *%s) error {
 			$1, 
 			$2, 
 			$3,
-			$4,
+			NULLIF($4, 0),
 			$5
 		) RETURNING 
-		 	id, process_definition_key, version, resource_name, process_instance_key, created_by, updated_by,
+		 	id, process_definition_key, version, resource_name, COALESCE(process_instance_key, 0), created_by, updated_by,
 			created_at, updated_at
 		%s
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -1088,7 +1338,7 @@ func (s *%sStore) create(ctx context.Context, tx *sql.Tx, model *%s) error {
 func (s *%sStore) GetByID(ctx context.Context, id int64) (*%s, error) {
 	query := %s
 		SELECT id, process_definition_key, version, 
-			resource_name, process_instance_key, 
+			resource_name, COALESCE(process_instance_key, 0),
 			task_definition_id, task_state,
 			created_by, updated_by, created_at, updated_at
 		FROM %s
@@ -1152,18 +1402,17 @@ func (s *%sStore) update(ctx context.Context, tx *sql.Tx, model *%s) error {
 		SET process_definition_key = $1, 
 			version = $2, 
 			resource_name = $3, 
-			process_instance_key = $4, 
+			process_instance_key = COALESCE(NULLIF($4, 0), process_instance_key),
 			updated_by = $5, 
 			updated_at = NOW()
 		WHERE id = $6 AND deleted_at IS NULL
 		RETURNING id, process_definition_key, 
 			version, 
 			resource_name, 
-			process_instance_key, 
+			COALESCE(process_instance_key, 0),
 			created_by, 
 			updated_by, 
-			created_at, 
-			updated_at
+			created_at, updated_at
 	%s
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
@@ -1199,61 +1448,108 @@ func (s *%sStore) update(ctx context.Context, tx *sql.Tx, model *%s) error {
 
 	return nil
 }
+`,
+		processName,
+		version,
+		processName,
+		processDefinitionKey,
+		processName,
+		resourceName,
+		processName,
+		tableName,
+		processName,
+		processName,
+
+		processName,
+		processName,
+		processName,
+		processName,
+		processName,
+
+		processName,
+		processName,
+		version,
+		processDefinitionKey,
+		resourceName,
+		"`",
+		tableName,
+		"`",
+		processName,
+		processName,
+		"`",
+		tableName,
+		"`",
+		processName,
+		processName,
+		"`",
+		tableName,
+		"`",
+		processName,
+		processName,
+		"`",
+		tableName,
+		"`",
+	)
 
-func (s *%sStore) Search(ctx context.Context, pq PaginatedQuery) (map[string]interface{}, error) {
+	modelCode += fmt.Sprintf(`
+func (s *%[1]sStore) Search(ctx context.Context, pq PaginatedQuery) (map[string]interface{}, error) {
 	sortOrder := "DESC"
-	if pq.Sort == "desc" || pq.Sort == "DESC" {
-		sortOrder = "DESC"
-	}
 	if pq.Sort == "asc" || pq.Sort == "ASC" {
 		sortOrder = "ASC"
 	}
 
-	// Base Query
-	query := %s
-	 	SELECT p.id, p.process_definition_key, p.version,
-            p.resource_name, p.process_instance_key,
-            p.task_definition_id, p.task_state,
-            p.created_by, p.updated_by, p.created_at, p.updated_at
-        FROM pembuatan_media_berita_technology p
-        LEFT JOIN users u ON p.created_by = u.id
+	// filters are shared by the page and the count query
+	where := %[3]s
         WHERE p.deleted_at IS NULL
-    %s
-
-	var params []interface{}
-	params = append(params, pq.Limit, pq.Offset)
+    %[3]s
+	var args []interface{}
 
 	if pq.Search != "" {
-		query += %s
-		AND (
-				p.process_definition_key::text ILIKE '%s' || $3 || '%s' OR 
-				p.resource_name ILIKE '%s' || $3 || '%s' OR
-				p.process_instance_key::text ILIKE '%s' || $3 || '%s' OR
-				p.task_definition_id::text ILIKE '%s' || $3 || '%s' OR
-				p.task_state ILIKE '%s' || $3 || '%s' OR
-				u.email ILIKE '%s' || $3 || '%s' OR
-				u.username ILIKE '%s' || $3 || '%s'
+		args = append(args, pq.Search)
+		search := "$" + strconv.Itoa(len(args))
+		where += %[3]s
+			AND (
+				p.process_definition_key::text ILIKE '%%' || %[3]s + search + %[3]s || '%%' OR
+				p.resource_name ILIKE '%%' || %[3]s + search + %[3]s || '%%' OR
+				p.process_instance_key::text ILIKE '%%' || %[3]s + search + %[3]s || '%%' OR
+				p.task_definition_id::text ILIKE '%%' || %[3]s + search + %[3]s || '%%' OR
+				p.task_state ILIKE '%%' || %[3]s + search + %[3]s || '%%' OR
+				u.email ILIKE '%%' || %[3]s + search + %[3]s || '%%' OR
+				u.username ILIKE '%%' || %[3]s + search + %[3]s || '%%'
 			)
-		%s
-		params = append(params, pq.Search)
+		%[3]s
+	}
+
+	if pq.State != "" {
+		args = append(args, pq.State)
+		where += %[3]s
+			AND p.task_state = $%[3]s + strconv.Itoa(len(args))
 	}
 
 	if pq.Since != "" && pq.Until != "" {
-		query += %s
-			AND p.created_at BETWEEN $4 AND $5
-        %s
-		params = append(params, pq.Since, pq.Until)
+		args = append(args, pq.Since, pq.Until)
+		where += %[3]s
+			AND p.created_at BETWEEN $%[3]s + strconv.Itoa(len(args)-1) + %[3]s AND $%[3]s + strconv.Itoa(len(args))
 	}
 
-	query += %s
-        ORDER BY p.created_at %s
-        LIMIT $1 OFFSET $2
-    %s
+	query := %[3]s
+        SELECT p.id, p.process_definition_key, p.version,
+            p.resource_name, COALESCE(p.process_instance_key, 0),
+            p.task_definition_id, p.task_state,
+            p.created_by, p.updated_by, p.created_at, p.updated_at
+        FROM %[2]s p
+        LEFT JOIN users u ON p.created_by = u.id
+    %[3]s + where + %[3]s
+        ORDER BY p.created_at %[3]s + sortOrder + %[3]s
+        LIMIT $%[3]s + strconv.Itoa(len(args)+1) + %[3]s OFFSET $%[3]s + strconv.Itoa(len(args)+2)
+
+	params := append([]interface{}{}, args...)
+	params = append(params, pq.Limit, pq.Offset)
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	var results []*%s
+	var results []*%[1]s
 	rows, err := s.db.QueryContext(ctx, query, params...)
 	if err != nil {
 		return nil, err
@@ -1261,7 +1557,7 @@ func (s *%sStore) Search(ctx context.Context, pq PaginatedQuery) (map[string]int
 	defer rows.Close()
 
 	for rows.Next() {
-		var item PembuatanMediaBeritaTechnology
+		var item %[1]s
 		if err := rows.Scan(
 			&item.ID,
 			&item.ProcessDefinitionKey,
@@ -1284,19 +1580,11 @@ func (s *%sStore) Search(ctx context.Context, pq PaginatedQuery) (map[string]int
 		return nil, err
 	}
 
-	countQuery := %s
-		SELECT COUNT(*)
-        FROM %s p
+	countQuery := %[3]s
+        SELECT COUNT(*)
+        FROM %[2]s p
         LEFT JOIN users u ON p.created_by = u.id
-        WHERE p.deleted_at IS NULL
-    %s
-	var args []interface{}
-	if pq.Search != "" {
-		countQuery += %s
-			%s
-		%s
-		args = append(args, pq.Search)
-	}
+    %[3]s + where
 
 	var totalCount int
 	err = s.db.QueryRowContext(ctx, countQuery, args...).Scan(&totalCount)
@@ -1304,131 +1592,153 @@ func (s *%sStore) Search(ctx context.Context, pq PaginatedQuery) (map[string]int
 		return nil, err
 	}
 
-	totalPages := (totalCount + pq.Limit - 1) / pq.Limit
-
-	response := map[string]interface{}{
-		"content":      results,
-		"totalElement": totalCount,
-		"totalPages":   totalPages,
-		"limit":        pq.Limit,
-		"offset":       pq.Offset,
-		"sort":         pq.Sort,
-		"search":       pq.Search,
-		"since":        pq.Since,
-		"until":        pq.Until,
+	totalPages := (totalCount + pq.Limit - 1) / pq.Limit
+
+	response := map[string]interface{}{
+		"content":      results,
+		"totalElement": totalCount,
+		"totalPages":   totalPages,
+		"limit":        pq.Limit,
+		"offset":       pq.Offset,
+		"sort":         pq.Sort,
+		"search":       pq.Search,
+		"state":        pq.State,
+		"since":        pq.Since,
+		"until":        pq.Until,
+	}
+
+	return response, nil
+}
+
+// GetRunning returns the rows whose process instance can still change state,
+// ordered by id so they can be paged through with afterID. FAILED rows are
+// included, they go back to CREATED once the incidents are resolved.
+func (s *%[1]sStore) GetRunning(ctx context.Context, afterID int64, limit int) ([]ProcessInstanceState, error) {
+	query := %[3]s
+		SELECT id, process_instance_key, task_definition_id, task_state
+		FROM %[2]s
+		WHERE deleted_at IS NULL AND process_instance_key IS NOT NULL
+			AND task_state IN ('CREATED', 'FAILED') AND id > $1
+		ORDER BY id ASC
+		LIMIT $2
+	%[3]s
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ProcessInstanceState
+	for rows.Next() {
+		var item ProcessInstanceState
+		if err := rows.Scan(
+			&item.ID,
+			&item.ProcessInstanceKey,
+			&item.TaskDefinitionId,
+			&item.TaskState,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return response, nil
+	return results, nil
 }
-`,
-		processName,
-		version,
-		processName,
-		processDefinitionKey,
-		processName,
-		resourceName,
-		processName,
-		processName,
 
-		processName,
-		processName,
-		processName,
-		processName,
-		processName,
+func (s *%[1]sStore) UpdateState(ctx context.Context, state *ProcessInstanceState) error {
+	query := %[3]s
+		UPDATE %[2]s
+		SET task_definition_id = $1, task_state = $2, updated_at = NOW()
+		WHERE id = $3 AND deleted_at IS NULL
+	%[3]s
 
-		processName,
-		processName,
-		version,
-		processDefinitionKey,
-		resourceName,
-		"`",
-		tableName,
-		"`",
-		processName,
-		processName,
-		"`",
-		tableName,
-		"`",
-		processName,
-		processName,
-		"`",
-		tableName,
-		"`",
-		processName,
-		processName,
-		"`",
-		tableName,
-		"`",
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
 
-		processName,
-		"`", "`", "`",
-		"%", "%", "%", "%", "%", "%", "%", "%", "%", "%", "%", "%", "%", "%",
-		"`", "`", "`", "`", "` + sortOrder + `", "`",
-		processName,
-		"`", tableName, "`", "`",
-		`
-		AND (
-                p.process_definition_key::text ILIKE '%' || $1 || '%' OR 
-                p.resource_name ILIKE '%' || $1 || '%' OR
-                p.process_instance_key::text ILIKE '%' || $1 || '%' OR
-                p.task_definition_id::text ILIKE '%' || $1 || '%' OR
-                p.task_state ILIKE '%' || $1 || '%' OR
-                u.email ILIKE '%' || $1 || '%' OR
-                u.username ILIKE '%' || $1 || '%'
-            )
-		`, "`",
-	)
+	res, err := s.db.ExecContext(ctx, query, state.TaskDefinitionId, state.TaskState, state.ID)
+	if err != nil {
+		return err
+	}
 
-	err = os.WriteFile(filePathStore, []byte(modelCode), 0o644)
+	rows, err := res.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to write model file: %w", err)
+		return err
 	}
 
-	handlerCode := fmt.Sprintf(`package main
+	if rows == 0 {
+		return ErrNotFound
+	}
 
-import (
-	"bytes"
-	"context"
-	"encoding/json"
-	"fmt"
-	"net/http"
-	"strconv"
-	"time"
+	return nil
+}
 
-	"%s/internal/store"
-	"github.com/go-chi/chi/v5"
-)
+// CreateWithOutbox inserts the row and the command starting its process
+// instance in one transaction.
+func (s *%[1]sStore) CreateWithOutbox(ctx context.Context, model *%[1]s, msg *OutboxMessage) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.create(ctx, tx, model); err != nil {
+			return err
+		}
 
-type Create%sPayload struct {
-	Variables   		 *map[string]string  %sjson:"variables,omitempty"%s
-}
-type Update%sPayload struct {
-	Variables            *map[string]string  %sjson:"variables,omitempty"%s
+		msg.TableName = %[1]sTableName
+		msg.RowID = model.ID
+		return createOutboxMessage(ctx, tx, msg)
+	})
 }
-type DataStore%sWrapper struct {
-	Data store.%s `+"`json:\"data\"`"+`
-	Message string    	 `+"`json:\"message\"`"+`
-	Status  int          `+"`json:\"status\"`"+`
+
+// DeleteWithOutbox deletes the row and inserts the command canceling its
+// process instance in one transaction.
+func (s *%[1]sStore) DeleteWithOutbox(ctx context.Context, id int64, msg *OutboxMessage) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.delete(ctx, tx, id); err != nil {
+			return err
+		}
+
+		msg.TableName = %[1]sTableName
+		msg.RowID = id
+		return createOutboxMessage(ctx, tx, msg)
+	})
 }
+`, processName, tableName, "`")
 
-// TODO: U CAN ADD MORE HANDLER LIKE THIS EXAMPLE
+	err = g.writeFile(filePathStore, modelCode)
+	if err != nil {
+		return fmt.Errorf("failed to write model file: %w", err)
+	}
 
-// Create %s godoc
+	// create and cancel go through the outbox, see service.Dispatcher
+	createCancelCode := fmt.Sprintf(`// Create %[1]s godoc
 //
-//	@Summary		Create %s
-//	@Description	Create %s
-//	@Tags			bpmn/%s
+//	@Summary		Create %[1]s
+//	@Description	Create %[1]s, the process instance is started in the background: the response is 202 Accepted instead of 201 Created and process_instance_key is set once zeebe started the instance. A retry with the same Idempotency-Key returns the first row with 200.
+//	@Tags			bpmn/%[1]s
 //	@Accept			json
 //	@produce		json
-//	@Param			payload	body		Create%sPayload		true	"%s Payload"
-//	@Success		201		{object}	DataStore%sWrapper	"%s Created"
-//	@Failure		400		{object}	error
-//	@Failure		500		{object}	error
+//	@Param			Idempotency-Key	header		string											false	"Retries with the same key return the first row"
+//	@Param			payload			body		Create%[1]sPayload		true	"%[1]s Payload"
+//	@Success		200				{object}	DataStore%[1]sWrapper	"%[1]s Already Created"
+//	@Success		202				{object}	DataStore%[1]sWrapper	"%[1]s Created"
+//	@Failure		400				{object}	error
+//	@Failure		409				{object}	error
+//	@Failure		500				{object}	error
+//	@Failure		503				{object}	error	"Outbox dispatcher is disabled"
 //	@Security		ApiKeyAuth
-//	@Router			/bpmn/%s  [post]
-func (app *application) create%sHandler(w http.ResponseWriter, r *http.Request) {
+//	@Router			/bpmn/%[2]s  [post]
+func (app *application) create%[1]sHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.requireOutbox(w, r) {
+		return
+	}
+
 	user := GetUserFromContext(r)
-	var payload Create%sPayload
+	var payload Create%[1]sPayload
 	if err := readJSON(w, r, &payload); err != nil {
 		app.badRequestResponse(w, r, err)
 		return
@@ -1439,9 +1749,15 @@ func (app *application) create%sHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	idempotencyKey, err := getIdempotencyKey(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	// TODO: Change in this code
 	// TODO: ADD to storage interface for use create store
-	
+
 	ctx := r.Context()
 	variables := make(map[string]interface{})
 	variables["created_by"] = map[string]interface{}{
@@ -1459,51 +1775,92 @@ func (app *application) create%sHandler(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	resp, err := app.zeebeClient.StartWorkflow(ctx, store.%sProcessDefinitionKey, variables)
-	if err != nil {
+	// a retried request returns the row of the first one, the keys are
+	// scoped to the user
+	msg, err := app.store.Outbox.GetByIdempotencyKey(ctx, user.ID, idempotencyKey)
+	if err == nil {
+		if msg.Command != store.OutboxStartProcess || msg.TableName != store.%[1]sTableName {
+			app.conflictResponse(w, r, ErrIdempotencyKeyReused)
+			return
+		}
+
+		model, err := app.get%[1]s(ctx, msg.RowID)
+		if err != nil {
+			app.handleRequestError(w, r, err)
+			return
+		}
+
+		if err := app.jsonResponse(w, http.StatusOK, model); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if !errors.Is(err, store.ErrNotFound) {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	model := &store.%s{
-		ProcessDefinitionKey: resp.GetProcessDefinitionKey(),
-		Version:              resp.GetVersion(),
-		ProcessInstanceKey:   resp.GetProcessInstanceKey(),
-		ResourceName:         store.%sResourceName,
-		CreatedBy:            user.ID,
-		TaskState:            StringPtr("CREATED"),
+	startPayload, err := json.Marshal(store.StartProcessPayload{
+		ProcessDefinitionKey: store.%[1]sProcessDefinitionKey,
+		Variables:            variables,
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
 	}
 
-	if err := app.store.%s.Create(ctx, model); err != nil {
-		if err := app.zeebeClient.CancelWorkflow(ctx, model.ProcessInstanceKey); err != nil {
+	model := &store.%[1]s{
+		ProcessDefinitionKey: store.%[1]sProcessDefinitionKey,
+		Version:              store.%[1]sVersion,
+		ResourceName:         store.%[1]sResourceName,
+		CreatedBy:            user.ID,
+		TaskState:            StringPtr(store.ProcessStateCreated),
+	}
+
+	// the row and the start command are committed together, the outbox
+	// dispatcher sets process_instance_key once zeebe started the instance
+	if err := app.store.%[1]s.CreateWithOutbox(ctx, model, &store.OutboxMessage{
+		IdempotencyKey: idempotencyKey,
+		CreatedBy:      user.ID,
+		Command:        store.OutboxStartProcess,
+		Payload:        startPayload,
+	}); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, err)
+		default:
 			app.internalServerError(w, r, err)
-			return
 		}
-		app.internalServerError(w, r, err)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusCreated, model); err != nil {
+	app.triggerOutbox()
+
+	if err := app.jsonResponse(w, http.StatusAccepted, model); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 }
 
-
-// Cancel %s godoc
+// Cancel %[1]s godoc
 //
-//	@Summary		Cancel %s
-//	@Description	Cancel %s
-//	@Tags			bpmn/%s
+//	@Summary		Cancel %[1]s
+//	@Description	Cancel %[1]s, the process instance is canceled in the background
+//	@Tags			bpmn/%[1]s
 //	@Accept			json
 //	@produce		json
 //	@Param			id	path		int		true	"ProcessInstanceKey"
-//	@Success		200		{string}	string	"%s Canceled"
-//	@Failure		400		{object}	error
-//	@Failure		500		{object}	error
+//	@Success		200	{string}	string	"%[1]s Canceled"
+//	@Failure		400	{object}	error
+//	@Failure		500	{object}	error
+//	@Failure		503	{object}	error	"Outbox dispatcher is disabled"
 //	@Security		ApiKeyAuth
-//	@Router			/bpmn/%s/{id}  [delete]
-func (app *application) cancel%sHandler(w http.ResponseWriter, r *http.Request) {
+//	@Router			/bpmn/%[2]s/{id}  [delete]
+func (app *application) cancel%[1]sHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.requireOutbox(w, r) {
+		return
+	}
+
 	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil || id < 1 {
 		app.badRequestResponse(w, r, err)
@@ -1515,26 +1872,25 @@ func (app *application) cancel%sHandler(w http.ResponseWriter, r *http.Request)
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
 
-	model, err := app.get%s(ctx, id)
+	model, err := app.get%[1]s(ctx, id)
 	if err != nil {
 		app.handleRequestError(w, r, err)
 		return
 	}
 
-	// delete model
-	if err := app.store.%s.Delete(ctx, model.ID); err != nil {
-		app.internalServerError(w, r, err)
+	// delete model and cancel the process instance through the outbox
+	if err := app.store.%[1]s.DeleteWithOutbox(ctx, model.ID, &store.OutboxMessage{
+		IdempotencyKey: cancelIdempotencyKey(store.%[1]sTableName, model.ID),
+		Command:        store.OutboxCancelProcess,
+	}); err != nil {
+		app.handleRequestError(w, r, err)
 		return
 	}
 
-	// TODO: add rollback if failed to cancel in zeebe
-	if err := app.zeebeClient.CancelWorkflow(ctx, model.ProcessInstanceKey); err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
+	app.triggerOutbox()
 
 	// delete cache
-	app.cacheStorage.%s.Delete(ctx, model.ID)
+	app.cacheStorage.%[1]s.Delete(ctx, model.ID)
 
 	if err := app.jsonResponse(w, http.StatusOK, "success"); err != nil {
 		app.internalServerError(w, r, err)
@@ -1542,8 +1898,38 @@ func (app *application) cancel%sHandler(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+`, processName, strings.ReplaceAll(tableName, " ", "_"))
+
+	handlerCode := fmt.Sprintf(`package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"%s/internal/camunda"
+	"%s/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type Create%sPayload struct {
+	Variables   		 *map[string]string  %sjson:"variables,omitempty"%s
+}
+type Update%sPayload struct {
+	Variables            *map[string]string  %sjson:"variables,omitempty"%s
+}
+type DataStore%sWrapper struct {
+	Data store.%s `+"`json:\"data\"`"+`
+	Message string    	 `+"`json:\"message\"`"+`
+	Status  int          `+"`json:\"status\"`"+`
+}
+
+// TODO: U CAN ADD MORE HANDLER LIKE THIS EXAMPLE
 
-// GetById %s godoc
+%s// GetById %s godoc
 //
 //	@Summary		GetById %s
 //	@Description	GetById %s
@@ -1551,10 +1937,10 @@ func (app *application) cancel%sHandler(w http.ResponseWriter, r *http.Request)
 //	@Accept			json
 //	@produce		json
 //	@Param			id	path		int		true	"ID from table"
-//	@Success		200					{string}	string	"%s GetById"
-//	@Failure		400					{object}	error
-//	@Failure		404					{object}	error
-//	@Failure		500					{object}	error
+//	@Success		200	{string}	string	"%s GetById"
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/bpmn/%s/{id}  [get]
 func (app *application) getById%sHandler(w http.ResponseWriter, r *http.Request) {
@@ -1575,7 +1961,28 @@ func (app *application) getById%sHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusCreated, model); err != nil {
+	// the process instance is not started by the outbox dispatcher yet
+	if model.ProcessInstanceKey == 0 {
+		if err := app.jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"model":   model,
+			"camunda": nil,
+		}); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// get zeebe client untuk mendapatkan detail task
+	instance, err := app.camundaClient.ProcessInstances.Get(ctx, model.ProcessInstanceKey)
+	if err != nil {
+		app.handleRequestError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"model":   model,
+		"camunda": instance,
+	}); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -1615,16 +2022,17 @@ func (app *application) get%s(ctx context.Context, modelID int64) (*store.%s, er
 //	@Param			size			query		string	false	"Size 50"
 //	@Param			order			query		string	false	"Order DESC ASC"
 //
-// @Param			type			query		string	false	"Type USER_TASK"
-// @Param			state			query		string	false	"State ACTIVE"
+//	@Param			type			query		string	false	"Type USER_TASK"
+//	@Param			state			query		string	false	"State ACTIVE"
 //
 //	@Param			sort			query		string	false	"Sort startDate"
 //	@Param			searchAfter		query		string	false	"SearchAfter 1731486859777,2251799814109407"
 //	@Param			searchBefore	query		string	false	"SearchBefore 1731486859777,2251799814109407"
-//	@Success		200	{string}	string	"%s GetHistoryById"
-//	@Failure		400	{object}	error
-//	@Failure		404	{object}	error
-//	@Failure		500	{object}	error
+//	@Success		200				{string}	string	"%s GetHistoryById"
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		409				{object}	error	"Process instance is not started yet"
+//	@Failure		500				{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/bpmn/%s/{id}/history  [get]
 func (app *application) getHistoryById%sHandler(w http.ResponseWriter, r *http.Request) {
@@ -1654,58 +2062,29 @@ func (app *application) getHistoryById%sHandler(w http.ResponseWriter, r *http.R
 		app.handleRequestError(w, r, err)
 		return
 	}
-	
-	jsonTemplate := %s
-{
-    "filter": {
-        "processInstanceKey": %s
-    }, 
-    "size": %s, 
-    "sort": [{"field": "%s", "order": "%s"}]
-    %s
-}%s
-
-	var searchAfterStr, searchBeforeStr string
-
-	if flowNodeQueryParams.SearchAfter != "" {
-		searchAfterStr = fmt.Sprintf(%s, "searchAfter": %s%s, flowNodeQueryParams.SearchAfter)
-	}
 
-	if flowNodeQueryParams.SearchBefore != "" {
-		searchBeforeStr = fmt.Sprintf(%s, "searchBefore": %s%s, flowNodeQueryParams.SearchBefore)
+	if model.ProcessInstanceKey == 0 {
+		app.conflictResponse(w, r, ErrProcessNotStarted)
+		return
 	}
 
-	searchParams := searchAfterStr + searchBeforeStr
-
-	body := []byte(fmt.Sprintf(
-		jsonTemplate,
-		model.ProcessInstanceKey,
-		flowNodeQueryParams.Size,
-		flowNodeQueryParams.Sort,
-		flowNodeQueryParams.Order,
-		searchParams,
-	))
-
-	// get history from operate api rest api
-	url := fmt.Sprintf("%s%s/search", app.config.camundaRest.camundaOperateBaseUrl, V1FlowNodeUrl)
-	resp, err := app.zeebeClientRest.SendRequest(
-		ctx,
-		http.MethodPost,
-		url,
-		bytes.NewBuffer(body),
-	)
+	results, err := app.camundaClient.FlowNodeInstances.Search(ctx, camunda.Query[camunda.FlowNodeInstanceFilter]{
+		Filter: camunda.FlowNodeInstanceFilter{
+			ProcessInstanceKey: &model.ProcessInstanceKey,
+			Type:               flowNodeQueryParams.Type,
+			State:              flowNodeQueryParams.State,
+		},
+		Size:         flowNodeQueryParams.Size,
+		Sort:         flowNodeQueryParams.SortBy(),
+		SearchAfter:  flowNodeQueryParams.SearchAfter,
+		SearchBefore: flowNodeQueryParams.SearchBefore,
+	})
 	if err != nil {
 		app.handleRequestError(w, r, err)
 		return
 	}
 
-	var jsonData interface{}
-	if err := json.Unmarshal(resp, &jsonData); err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-
-	if err := app.jsonResponse(w, http.StatusOK, jsonData); err != nil {
+	if err := app.jsonResponse(w, http.StatusOK, results); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -1722,6 +2101,7 @@ func (app *application) getHistoryById%sHandler(w http.ResponseWriter, r *http.R
 //	@Param			page	query		string	true	"Page 1"
 //	@Param			search	query		string	false	"Search string"
 //	@Param			sort	query		string	false	"Sort desc"
+//	@Param			state	query		string	false	"State CREATED COMPLETED CANCELED FAILED"
 //	@Param			since	query		string	false	"Since desc"
 //	@Param			until	query		string	false	"Until desc"
 //	@Success		200		{string}	string	"%s Search"
@@ -1805,6 +2185,11 @@ func (app *application) update%sHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if model.ProcessInstanceKey == 0 {
+		app.conflictResponse(w, r, ErrProcessNotStarted)
+		return
+	}
+
 	variables := make(map[string]interface{})
 	variables["updated_by"] = map[string]interface{}{
 		"id":         user.ID,
@@ -1848,18 +2233,19 @@ func (app *application) update%sHandler(w http.ResponseWriter, r *http.Request)
 //	@Param			size			query		string	false	"Size 50"
 //	@Param			order			query		string	false	"Order DESC ASC"
 //
-// @Param			type			query		string	false	"Type USER_TASK"
-// @Param			state			query		string	false	"State ACTIVE"
+//	@Param			type			query		string	false	"Type USER_TASK"
+//	@Param			state			query		string	false	"State ACTIVE"
 //
 //	@Param			sort			query		string	false	"Sort startDate"
 //	@Param			searchAfter		query		string	false	"SearchAfter 1731486859777,2251799814109407"
 //	@Param			searchBefore	query		string	false	"SearchBefore 1731486859777,2251799814109407"
-//	@Success		200	{string}	string	"%s GetProcessIncidents"
-//	@Failure		400	{object}	error
-//	@Failure		404	{object}	error
-//	@Failure		500	{object}	error
+//	@Success		200				{string}	string	"%s GetProcessIncidents"
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		409				{object}	error	"Process instance is not started yet"
+//	@Failure		500				{object}	error
 //	@Security		ApiKeyAuth
-//	@Router			/bpmn/%s/{id}/history  [get]
+//	@Router			/bpmn/%s/{id}/incidents  [get]
 func (app *application) getIncidentsById%sHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil || id < 1 {
@@ -1887,76 +2273,40 @@ func (app *application) getIncidentsById%sHandler(w http.ResponseWriter, r *http
 		app.handleRequestError(w, r, err)
 		return
 	}
-	
-	jsonTemplate := %s
-{
-    "filter": {
-        "processInstanceKey": %s
-    }, 
-    "size": %s, 
-    "sort": [{"field": "%s", "order": "%s"}]
-    %s
-}%s
-
-	var searchAfterStr, searchBeforeStr string
-
-	if flowNodeQueryParams.SearchAfter != "" {
-		searchAfterStr = fmt.Sprintf(%s, "searchAfter": %s%s, flowNodeQueryParams.SearchAfter)
-	}
 
-	if flowNodeQueryParams.SearchBefore != "" {
-		searchBeforeStr = fmt.Sprintf(%s, "searchBefore": %s%s, flowNodeQueryParams.SearchBefore)
+	if model.ProcessInstanceKey == 0 {
+		app.conflictResponse(w, r, ErrProcessNotStarted)
+		return
 	}
 
-	searchParams := searchAfterStr + searchBeforeStr
-
-	body := []byte(fmt.Sprintf(
-		jsonTemplate,
-		model.ProcessInstanceKey,
-		flowNodeQueryParams.Size,
-		flowNodeQueryParams.Sort,
-		flowNodeQueryParams.Order,
-		searchParams,
-	))
-
-	// get incidents from operate api rest api
-	url := fmt.Sprintf("%s%s/search", app.config.camundaRest.camundaOperateBaseUrl, V1IncidentUrl)
-	resp, err := app.zeebeClientRest.SendRequest(
-		ctx,
-		http.MethodPost,
-		url,
-		bytes.NewBuffer(body),
-	)
+	results, err := app.camundaClient.Incidents.Search(ctx, camunda.Query[camunda.IncidentFilter]{
+		Filter: camunda.IncidentFilter{
+			ProcessInstanceKey: &model.ProcessInstanceKey,
+			Type:               flowNodeQueryParams.Type,
+			State:              flowNodeQueryParams.State,
+		},
+		Size:         flowNodeQueryParams.Size,
+		Sort:         flowNodeQueryParams.SortBy(),
+		SearchAfter:  flowNodeQueryParams.SearchAfter,
+		SearchBefore: flowNodeQueryParams.SearchBefore,
+	})
 	if err != nil {
 		app.handleRequestError(w, r, err)
 		return
 	}
 
-	var jsonData interface{}
-	if err := json.Unmarshal(resp, &jsonData); err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-
-	if err := app.jsonResponse(w, http.StatusOK, jsonData); err != nil {
+	if err := app.jsonResponse(w, http.StatusOK, results); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 }
 
 `,
-		moduleName, processName, "`", "`", processName, "`", "`",
-		processName, processName, processName, processName,
+		moduleName, moduleName, processName, "`", "`", processName, "`", "`",
 		processName, processName,
 
-		processName, processName, processName, processName,
-		strings.ReplaceAll(tableName, " ", "_"), processName,
-		processName, processName, processName, processName, processName,
-
-		// cancel
-		processName, processName, processName, processName,
-		processName, strings.ReplaceAll(tableName, " ", "_"),
-		processName, processName, processName, processName,
+		// create and cancel
+		createCancelCode,
 
 		// get by id
 		processName, processName, processName, processName, processName,
@@ -1967,8 +2317,6 @@ func (app *application) getIncidentsById%sHandler(w http.ResponseWriter, r *http
 		// get history
 		processName, processName, processName, processName, processName,
 		strings.ReplaceAll(tableName, " ", "_"), processName, processName,
-		"`", "%d", "%s", "%s", "%s", "%s", "`", "`", "%s", "`",
-		"`", "%s", "`", "%s", "%s",
 
 		// search
 		processName, processName, processName, processName, processName,
@@ -1983,11 +2331,9 @@ func (app *application) getIncidentsById%sHandler(w http.ResponseWriter, r *http
 		// incidents
 		processName, processName, processName, processName, processName,
 		strings.ReplaceAll(tableName, " ", "_"), processName, processName,
-		"`", "%d", "%s", "%s", "%s", "%s", "`", "`", "%s", "`",
-		"`", "%s", "`", "%s", "%s",
 	)
 
-	err = os.WriteFile(filePathHandler, []byte(handlerCode), 0o644)
+	err = g.writeFile(filePathHandler, handlerCode)
 	if err != nil {
 		return fmt.Errorf("failed to write handler file: %w", err)
 	}
@@ -2009,7 +2355,7 @@ type %sStore struct {
 }
 
 const %sExpTime = time.Hour * 24 * 7
-	
+
 func (s *%sStore) Get(ctx context.Context, modelID int64) (*store.%s, error) {
 	cacheKey := fmt.Sprintf("%s-%s", modelID)
 
@@ -2052,7 +2398,7 @@ func (s *%sStore) Delete(ctx context.Context, modelID int64) {
 		processName, processName, processName, "%v",
 	)
 
-	err = os.WriteFile(filePathStoreCache, []byte(modelCacheCode), 0o644)
+	err = g.writeFile(filePathStoreCache, modelCacheCode)
 	if err != nil {
 		return fmt.Errorf("failed to write cache file: %w", err)
 	}
@@ -2065,9 +2411,13 @@ func (s *%sStore) Delete(ctx context.Context, modelID int64) {
 		GetByID(context.Context, int64) (*%s, error)
 		Update(context.Context, *%s) error
 		Search(context.Context, PaginatedQuery) (map[string]interface{}, error)
+		GetRunning(context.Context, int64, int) ([]ProcessInstanceState, error)
+		UpdateState(context.Context, *ProcessInstanceState) error
+		CreateWithOutbox(context.Context, *%s, *OutboxMessage) error
+		DeleteWithOutbox(context.Context, int64, *OutboxMessage) error
 	}
 `,
-		processName, processName, processName, processName,
+		processName, processName, processName, processName, processName,
 	)
 	generateCodeConstructor := fmt.Sprintf(`
 		%s:   &%sStore{db},
@@ -2076,7 +2426,7 @@ func (s *%sStore) Delete(ctx context.Context, modelID int64) {
 	// edit file routes
 	generateCodeRoutes := fmt.Sprintf(`
 			r.Route("/%s", func(r chi.Router) {
-				r.Get("/", app.search%sTechnologyHandler)
+				r.Get("/", app.search%sHandler)
 				r.Post("/", app.create%sHandler)
 				r.Route("/{id}", func(r chi.Router) {
 					r.Get("/", app.getById%sHandler)
@@ -2115,27 +2465,35 @@ func (s *%sStore) Delete(ctx context.Context, modelID int64) {
 		processName,
 	)
 
-	err = insertGeneratedCode(filePathEditStorage, generateCodeStorage, "// GENERATED CODE INTERFACE")
+	err = g.insertGeneratedCode(filePathEditStorage, generateCodeStorage, "// GENERATED CODE INTERFACE")
+	if err != nil {
+		return err
+	}
+
+	err = g.insertGeneratedCode(filePathEditStorage, generateCodeConstructor, "// GENERATED CODE CONSTRUCTOR")
 	if err != nil {
 		return err
 	}
 
-	err = insertGeneratedCode(filePathEditStorage, generateCodeConstructor, "// GENERATED CODE CONSTRUCTOR")
+	err = g.insertGeneratedCode(filePathEditRoutes, generateCodeRoutes, "// GENERATE ROUTES API")
 	if err != nil {
 		return err
 	}
 
-	err = insertGeneratedCode(filePathEditRoutes, generateCodeRoutes, "// GENERATE ROUTES API")
+	err = g.insertGeneratedCode(filePathEditCacheStorage, generateCodeCacheStorage, "// GENERATED CACHE CODE INTERFACE")
 	if err != nil {
 		return err
 	}
 
-	err = insertGeneratedCode(filePathEditCacheStorage, generateCodeCacheStorage, "// GENERATED CACHE CODE INTERFACE")
+	err = g.insertGeneratedCode(filePathEditCacheStorage, generateCodeCacheInterface, "// GENERATED CACHE CODE CONSTRUCTOR")
 	if err != nil {
 		return err
 	}
 
-	err = insertGeneratedCode(filePathEditCacheStorage, generateCodeCacheInterface, "// GENERATED CACHE CODE CONSTRUCTOR")
+	// edit file reconciler
+	generateCodeReconciler := fmt.Sprintf(`		"%s": {r.store.%s, r.cache.%s},`, tableName, processName, processName)
+
+	err = g.insertGeneratedCode(filePathEditReconciler, generateCodeReconciler, "// GENERATED RECONCILER PROCESSES")
 	if err != nil {
 		return err
 	}
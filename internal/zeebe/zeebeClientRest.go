@@ -4,13 +4,67 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/damarteplok/social/internal/metrics"
 	"github.com/damarteplok/social/internal/store"
+	"github.com/damarteplok/social/internal/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func NewZeebeClientRest(clientID, clientSecret, authServerURL, zeebeAddr string) (*ZeebeClientRest, error) {
-	tokenManager := NewTokenManager(clientID, clientSecret, authServerURL)
+type RestConfig struct {
+	Token TokenConfig
+	// the base urls tell which component, and so which token audience,
+	// breaker and timeout, a request is for, requests to other urls count as
+	// zeebe requests
+	ZeebeURL    string
+	OperateURL  string
+	TasklistURL string
+	OptimizeURL string
+
+	Retry   RetryConfig
+	Breaker BreakerConfig
+	// Timeout bounds every attempt of a request, Timeouts overrides it per
+	// component.
+	Timeout  time.Duration
+	Timeouts map[Component]time.Duration
+}
+
+// RetryConfig applies to idempotent requests only: GET, PUT, DELETE and the
+// POST searches. Transport errors, 429, 502, 503 and 504 are retried with an
+// exponential backoff.
+type RetryConfig struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+func NewZeebeClientRest(cfg RestConfig) (*ZeebeClientRest, error) {
+	if cfg.Retry.MaxAttempts <= 0 {
+		cfg.Retry.MaxAttempts = 3
+	}
+	if cfg.Retry.Backoff <= 0 {
+		cfg.Retry.Backoff = 200 * time.Millisecond
+	}
+	if cfg.Retry.MaxBackoff <= 0 {
+		cfg.Retry.MaxBackoff = 2 * time.Second
+	}
+	if cfg.Breaker.FailureThreshold <= 0 {
+		cfg.Breaker.FailureThreshold = 5
+	}
+	if cfg.Breaker.OpenTimeout <= 0 {
+		cfg.Breaker.OpenTimeout = 30 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+
+	tokenManager := NewTokenManager(cfg.Token)
 
 	// Fetch initial token
 	_, err := tokenManager.GetAuthToken(context.Background())
@@ -18,15 +72,39 @@ func NewZeebeClientRest(clientID, clientSecret, authServerURL, zeebeAddr string)
 		return nil, fmt.Errorf("failed to get initial auth token: %w", err)
 	}
 
-	// Create an HTTP client
+	// Create an HTTP client, its transport starts a span per attempt and
+	// injects the w3c traceparent of the span
 	httpClient := &http.Client{
-		Transport: &http.Transport{},
+		Transport: otelhttp.NewTransport(&http.Transport{}, otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			return "camunda " + r.Method
+		})),
+	}
+
+	// on equal base urls the first component wins, zeebe before the others
+	components := []componentURL{}
+	for _, c := range []componentURL{
+		{component: ComponentZeebe, url: cfg.ZeebeURL},
+		{component: ComponentOperate, url: cfg.OperateURL},
+		{component: ComponentTasklist, url: cfg.TasklistURL},
+		{component: ComponentOptimize, url: cfg.OptimizeURL},
+	} {
+		if c.url != "" {
+			c.url = strings.TrimSuffix(c.url, "/")
+			components = append(components, c)
+		}
+	}
+
+	breakers := map[Component]*circuitBreaker{}
+	for _, component := range []Component{ComponentZeebe, ComponentOperate, ComponentTasklist, ComponentOptimize} {
+		breakers[component] = newCircuitBreaker(component, cfg.Breaker)
 	}
 
 	return &ZeebeClientRest{
 		httpClient:   httpClient,
 		tokenManager: tokenManager,
-		zeebeAddr:    zeebeAddr,
+		components:   components,
+		breakers:     breakers,
+		config:       cfg,
 	}, nil
 }
 
@@ -34,21 +112,176 @@ func (z *ZeebeClientRest) Close() error {
 	return nil
 }
 
-func (z *ZeebeClientRest) SendRequest(ctx context.Context, method, endpoint string, body io.Reader) ([]byte, error) {
-	token, err := z.tokenManager.GetAuthToken(ctx)
+// Breakers returns the breaker state of every configured component.
+func (z *ZeebeClientRest) Breakers() map[Component]BreakerStatus {
+	statuses := map[Component]BreakerStatus{}
+	for _, c := range z.components {
+		statuses[c.component] = z.breakers[c.component].status()
+	}
+	if _, ok := statuses[ComponentZeebe]; !ok {
+		statuses[ComponentZeebe] = z.breakers[ComponentZeebe].status()
+	}
+
+	return statuses
+}
+
+// component returns the component of the longest base url matching the
+// request, components may share a host and differ by path only.
+func (z *ZeebeClientRest) component(req *http.Request) Component {
+	target := req.URL.String()
+	component, matched := ComponentZeebe, 0
+
+	for _, c := range z.components {
+		if len(c.url) > matched && (target == c.url || strings.HasPrefix(target, c.url+"/")) {
+			component, matched = c.component, len(c.url)
+		}
+	}
+
+	return component
+}
+
+func (z *ZeebeClientRest) timeout(component Component) time.Duration {
+	if timeout, ok := z.config.Timeouts[component]; ok && timeout > 0 {
+		return timeout
+	}
+	return z.config.Timeout
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		// the searches of operate, tasklist and the v2 api only read
+		return strings.HasSuffix(req.URL.Path, "/search")
+	}
+	return false
+}
+
+func isRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (z *ZeebeClientRest) backoff(attempt int) time.Duration {
+	backoff := z.config.Retry.Backoff << (attempt - 1)
+	if backoff <= 0 || backoff > z.config.Retry.MaxBackoff {
+		backoff = z.config.Retry.MaxBackoff
+	}
+	// jitter between half and the full backoff
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff)/2+1))
+}
+
+// Do sends a request with the bearer token of the client, so typed clients
+// such as camunda.Client can share its token and transport. Requests to a
+// component with an open breaker fail with a *CircuitOpenError, idempotent
+// requests are retried according to the RetryConfig.
+func (z *ZeebeClientRest) Do(req *http.Request) (resp *http.Response, err error) {
+	component := z.component(req)
+	breaker := z.breakers[component]
+
+	ctx, span := tracing.Start(req.Context(), "camunda."+string(component)+" "+req.Method,
+		attribute.String("camunda.component", string(component)),
+		attribute.String("url.path", req.URL.Path),
+	)
+	defer func() { tracing.End(span, err) }()
+	req = req.WithContext(ctx)
+
+	attempts := 1
+	// a request body can only be sent again when it can be recreated
+	if isIdempotent(req) && (req.Body == nil || req.Body == http.NoBody || req.GetBody != nil) {
+		attempts = z.config.Retry.MaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err := breaker.allow(); err != nil {
+			return nil, err
+		}
+
+		resp, err := z.send(req, component, attempt)
+		if err != nil && ctx.Err() != nil {
+			// the caller gave up, which says nothing about the component
+			breaker.release()
+			return nil, err
+		}
+		breaker.record(err == nil && resp.StatusCode < http.StatusInternalServerError)
+
+		if attempt >= attempts || !isRetryable(resp, err) {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(z.backoff(attempt)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// send makes a single attempt within the timeout of the component, the
+// timeout covers reading the body too and ends when it is closed.
+func (z *ZeebeClientRest) send(req *http.Request, component Component, attempt int) (*http.Response, error) {
+	token, err := z.tokenManager.GetComponentToken(req.Context(), component)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get auth token: %w", err)
 	}
 
+	ctx, cancel := context.WithTimeout(req.Context(), z.timeout(component))
+
+	attemptReq := req.Clone(ctx)
+	if attempt > 1 && req.GetBody != nil {
+		attemptReq.Body, err = req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	attemptReq.Header.Set("Authorization", "Bearer "+token)
+
+	start := time.Now()
+	resp, err := z.httpClient.Do(attemptReq)
+	if err != nil {
+		metrics.CamundaRESTDuration.WithLabelValues(string(component), req.Method, "error").Observe(time.Since(start).Seconds())
+		cancel()
+		return nil, err
+	}
+	metrics.CamundaRESTDuration.WithLabelValues(string(component), req.Method, strconv.Itoa(resp.StatusCode)).Observe(time.Since(start).Seconds())
+
+	resp.Body = &cancelReadCloser{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelReadCloser) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+func (z *ZeebeClientRest) SendRequest(ctx context.Context, method, endpoint string, body io.Reader) ([]byte, error) {
 	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := z.httpClient.Do(req)
+	resp, err := z.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -66,6 +299,10 @@ func (z *ZeebeClientRest) SendRequest(ctx context.Context, method, endpoint stri
 		return nil, store.ErrMethodNotAllowed
 	}
 
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return nil, store.ErrServiceUnavailable
+	}
+
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return nil, fmt.Errorf("request failed with status: %d", resp.StatusCode)
 	}
@@ -0,0 +1,107 @@
+package zeebe
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/damarteplok/social/internal/store"
+)
+
+// newFlakyClient returns a client whose api answers 503 to the first
+// failures requests.
+func newFlakyClient(t *testing.T, failures int32, breaker BreakerConfig) (*ZeebeClientRest, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+	mux := http.NewServeMux()
+	mux.Handle("POST /token", &fakeAuthServer{expiresIn: 3600})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client, err := NewZeebeClientRest(RestConfig{
+		Token:      TokenConfig{ClientID: "test", ClientSecret: "test", AuthURL: server.URL + "/token"},
+		ZeebeURL:   server.URL + "/zeebe",
+		OperateURL: server.URL + "/operate",
+		Retry:      RetryConfig{MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond},
+		Breaker:    breaker,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client, &calls
+}
+
+func TestZeebeClientRestRetry(t *testing.T) {
+	ctx := context.Background()
+	breaker := BreakerConfig{FailureThreshold: 10, OpenTimeout: time.Minute}
+
+	t.Run("should retry idempotent requests", func(t *testing.T) {
+		client, calls := newFlakyClient(t, 2, breaker)
+
+		if _, err := client.SendRequest(ctx, http.MethodPost, client.config.OperateURL+"/v1/process-instances/search", strings.NewReader(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+		if got := calls.Load(); got != 3 {
+			t.Errorf("expected 3 attempts got %d", got)
+		}
+	})
+
+	t.Run("should not retry other requests", func(t *testing.T) {
+		client, calls := newFlakyClient(t, 2, breaker)
+
+		_, err := client.SendRequest(ctx, http.MethodPost, client.config.ZeebeURL+"/v2/process-instances", strings.NewReader(`{}`))
+		if !errors.Is(err, store.ErrServiceUnavailable) {
+			t.Errorf("expected %v got %v", store.ErrServiceUnavailable, err)
+		}
+		if got := calls.Load(); got != 1 {
+			t.Errorf("expected 1 attempt got %d", got)
+		}
+	})
+}
+
+func TestZeebeClientRestBreaker(t *testing.T) {
+	ctx := context.Background()
+	client, calls := newFlakyClient(t, 3, BreakerConfig{FailureThreshold: 3, OpenTimeout: 50 * time.Millisecond})
+	operate := client.config.OperateURL + "/v1/process-instances/1"
+
+	// three failed attempts open the breaker of operate only
+	client.SendRequest(ctx, http.MethodGet, operate, nil)
+
+	_, err := client.SendRequest(ctx, http.MethodGet, operate, nil)
+	var circuitOpen *CircuitOpenError
+	if !errors.As(err, &circuitOpen) || !errors.Is(err, store.ErrServiceUnavailable) || circuitOpen.Component != ComponentOperate {
+		t.Fatalf("expected an open operate breaker got %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("expected no request while the breaker is open got %d", got)
+	}
+
+	breakers := client.Breakers()
+	if breakers[ComponentOperate].State != BreakerOpen || breakers[ComponentZeebe].State != BreakerClosed {
+		t.Errorf("expected only the operate breaker open got %v", breakers)
+	}
+
+	// after the open timeout a successful probe closes it again
+	time.Sleep(60 * time.Millisecond)
+	if _, err := client.SendRequest(ctx, http.MethodGet, operate, nil); err != nil {
+		t.Fatal(err)
+	}
+	if state := client.Breakers()[ComponentOperate].State; state != BreakerClosed {
+		t.Errorf("expected the operate breaker closed got %s", state)
+	}
+}
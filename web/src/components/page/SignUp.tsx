@@ -18,6 +18,7 @@ import { useFormik } from 'formik';
 import { useDispatch, useSelector } from 'react-redux';
 import { RootState } from '../../slices/store/rootReducer';
 import { registerUser } from '../../slices/modules/auth/thunk';
+import { toApiError } from '../../utils/apiError';
 
 interface SignUpProps {
 	onSuccess: () => void;
@@ -42,21 +43,22 @@ const SignUp: React.FC<SignUpProps> = ({ onSuccess }) => {
 			email: Yup.string().email('Invalid email address').required('Required'),
 			password: Yup.string().required('Required'),
 		}),
-		onSubmit: async (values, { setSubmitting }) => {
-			try {
-				setSubmitting(true);
-				dispatch(
-					registerUser({
-						username: values.username,
-						email: values.email,
-						password: values.password,
-					})
-				);
-				setSubmitting(false);
-				onSuccess();
-			} catch (err) {
-				setSubmitting(false);
+		onSubmit: async (values, { setSubmitting, setErrors }) => {
+			setSubmitting(true);
+			const result = await dispatch(
+				registerUser({
+					username: values.username,
+					email: values.email,
+					password: values.password,
+				})
+			);
+			setSubmitting(false);
+			if (registerUser.rejected.match(result)) {
+				// the api names the invalid fields as the form does
+				setErrors(toApiError(result.payload).fieldErrors);
+				return;
 			}
+			onSuccess();
 		},
 	});
 
@@ -18,6 +18,7 @@ import DashboardPage from './pages/dashboardPage.tsx';
 import NotFoundPage from './pages/notFoundPage.tsx';
 import UnauthorizedPage from './pages/unAuthorizedPage.tsx';
 import CamundaPage from './pages/camunda/camundaPage.tsx';
+import OidcCallbackPage from './pages/oidcCallbackPage.tsx';
 
 const router = createBrowserRouter([
 	{
@@ -51,6 +52,10 @@ const router = createBrowserRouter([
 				path: '/sign-in',
 				Component: SignInPage,
 			},
+			{
+				path: '/oidc/callback',
+				Component: OidcCallbackPage,
+			},
 			{
 				path: '/sign-up',
 				Component: SignUpPage,
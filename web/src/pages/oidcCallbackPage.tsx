@@ -0,0 +1,71 @@
+import { useEffect, useRef, useState } from 'react';
+import { Link, useNavigate, useSearchParams } from 'react-router-dom';
+import { useDispatch } from 'react-redux';
+import { Box, CircularProgress, Typography } from '@mui/material';
+import axiosInstance, { setAuthToken } from '../utils/axiosInstance';
+import { toApiError } from '../utils/apiError';
+import { setSession } from '../slices/modules/session/sessionSlice';
+
+export default function OidcCallbackPage() {
+	const dispatch = useDispatch();
+	const navigate = useNavigate();
+	const [searchParams] = useSearchParams();
+	const [error, setError] = useState('');
+	// a code is exchanged once, strict mode runs the effect twice
+	const exchanged = useRef(false);
+
+	useEffect(() => {
+		if (exchanged.current) {
+			return;
+		}
+		exchanged.current = true;
+
+		const code = searchParams.get('code');
+		const state = searchParams.get('state');
+		if (!code || !state) {
+			setError(searchParams.get('error_description') || 'Sign in was canceled');
+			return;
+		}
+
+		axiosInstance
+			.post('/authentication/oidc/token', { code, state })
+			.then((response) => {
+				const { token, user } = response.data.data;
+				setAuthToken(token);
+				dispatch(
+					setSession({
+						user: {
+							id: user.id.toString(),
+							email: user.email,
+							name: user.name,
+							image: '',
+						},
+					})
+				);
+				navigate('/', { replace: true });
+			})
+			.catch((err) => setError(toApiError(err).message));
+	}, [dispatch, navigate, searchParams]);
+
+	return (
+		<Box
+			sx={{
+				display: 'flex',
+				flexDirection: 'column',
+				alignItems: 'center',
+				justifyContent: 'center',
+				minHeight: '100vh',
+				gap: 2,
+			}}
+		>
+			{error ? (
+				<>
+					<Typography color='error'>{error}</Typography>
+					<Link to='/sign-in'>Back to sign in</Link>
+				</>
+			) : (
+				<CircularProgress />
+			)}
+		</Box>
+	);
+}
@@ -2,7 +2,7 @@
 import { SignInPage } from '@toolpad/core/SignInPage';
 import { Link, useNavigate } from 'react-router-dom';
 import axiosInstance, { setAuthToken } from '../utils/axiosInstance';
-import { AxiosError } from 'axios';
+import { toApiError } from '../utils/apiError';
 import { useDispatch } from 'react-redux';
 import { setSession } from '../slices/modules/session/sessionSlice';
 import { useNotifications } from '@toolpad/core';
@@ -26,14 +26,53 @@ export default function SignIn() {
 					</Link>
 				),
 			}}
-			providers={[{ id: 'credentials', name: 'Email and Password' }]}
+			providers={[
+				{ id: 'credentials', name: 'Email and Password' },
+				{ id: 'keycloak', name: 'Camunda Identity' },
+			]}
 			signIn={async (provider, formData, callbackUrl) => {
 				try {
-					const response = await axiosInstance.post('/authentication/token', {
+					// keycloak redirects back to /oidc/callback
+					if (provider.id === 'keycloak') {
+						const response = await axiosInstance.get(
+							'/authentication/oidc/authorize'
+						);
+						window.location.href = response.data.data.authorization_url;
+						return {};
+					}
+
+					let response = await axiosInstance.post('/authentication/token', {
 						email: formData.get('email'),
 						password: formData.get('password'),
 					});
 
+					// the account needs a second factor, the api sent a challenge
+					if (response.status === 202) {
+						const { challenge_token, enrollment_required } =
+							response.data.data;
+						// the setup link is emailed, the password alone does not enroll
+						if (enrollment_required) {
+							await axiosInstance.post('/authentication/2fa/enroll', {
+								challenge_token,
+							});
+							return {
+								error:
+									'Your role requires two-factor authentication, we emailed you a link to set it up',
+							};
+						}
+
+						const code = window.prompt(
+							'Enter the code of your authenticator app or a recovery code'
+						);
+						if (!code) {
+							return { error: 'Two-factor code is required' };
+						}
+						response = await axiosInstance.post('/authentication/2fa/verify', {
+							challenge_token,
+							code: code.trim(),
+						});
+					}
+
 					notifications.show('Success Sign In', {
 						severity: 'success',
 						autoHideDuration: 3000,
@@ -54,12 +93,7 @@ export default function SignIn() {
 
 					navigate(callbackUrl || '/');
 				} catch (error) {
-					return {
-						error:
-							error instanceof AxiosError
-								? error.response?.data?.error
-								: 'An error occurred',
-					};
+					return { error: toApiError(error).message };
 				}
 				return {};
 			}}
@@ -1,5 +1,6 @@
 import { createSlice, PayloadAction } from '@reduxjs/toolkit';
 import { registerUser } from './thunk';
+import { errorMessage } from '../../../utils/apiError';
 
 interface RegisterUser {
 	username: string;
@@ -46,7 +47,7 @@ const registerUserSlice = createSlice({
 			.addCase(registerUser.rejected, (state, action) => {
 				state.loading = false;
 				state.success = false;
-				state.errorMessage = action.payload as string;
+				state.errorMessage = errorMessage(action.payload);
 			});
 	},
 });
@@ -1,5 +1,6 @@
 import { createSlice, PayloadAction } from '@reduxjs/toolkit';
 import { fetchBpmnXml, fetchResources, resolveIncident } from './thunk';
+import { errorMessage } from '../../../utils/apiError';
 
 interface ResourceCamundaItem {
 	bpmnProcessId: string;
@@ -67,7 +68,7 @@ export const camundaSlice = createSlice({
 			.addCase(fetchResources.rejected, (state, action) => {
 				state.resources = null;
 				state.loading = false;
-				state.error = action.payload as string;
+				state.error = errorMessage(action.payload);
 			})
 			.addCase(fetchBpmnXml.pending, (state) => {
 				state.loadingViewer = true;
@@ -81,7 +82,7 @@ export const camundaSlice = createSlice({
 			.addCase(fetchBpmnXml.rejected, (state, action) => {
 				state.bpmnXml = null;
 				state.loadingViewer = false;
-				state.errorViewer = action.payload as string;
+				state.errorViewer = errorMessage(action.payload);
 			})
 			.addCase(resolveIncident.pending, (state) => {
 				state.loadingIncident = true;
@@ -95,7 +96,7 @@ export const camundaSlice = createSlice({
 			})
 			.addCase(resolveIncident.rejected, (state, action) => {
 				state.loadingIncident = false;
-				state.errorIncident = action.payload as string;
+				state.errorIncident = errorMessage(action.payload);
 				state.successIncident = false;
 			});
 	},
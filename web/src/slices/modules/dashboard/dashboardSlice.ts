@@ -1,5 +1,6 @@
 import { createSlice, PayloadAction } from '@reduxjs/toolkit';
 import { fetchDashboard } from './thunk';
+import { errorMessage } from '../../../utils/apiError';
 
 interface ProcessType {
 	processId: string;
@@ -83,7 +84,7 @@ const dashboardSlice = createSlice({
 			})
 			.addCase(fetchDashboard.rejected, (state, action) => {
 				state.loading = false;
-				state.error = action.payload as string;
+				state.error = errorMessage(action.payload);
 			});
 	},
 });
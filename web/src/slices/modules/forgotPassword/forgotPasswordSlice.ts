@@ -1,5 +1,6 @@
 import { createSlice, PayloadAction } from '@reduxjs/toolkit';
 import { forgotPassword } from './thunk';
+import { errorMessage } from '../../../utils/apiError';
 
 interface ForgotPassword {
 	email: string;
@@ -48,7 +49,7 @@ const forgotPasswordSlice = createSlice({
 			})
 			.addCase(forgotPassword.rejected, (state, action) => {
 				state.loading = false;
-				state.error = (action.payload as string) || 'Error';
+				state.error = errorMessage(action.payload) || 'Error';
 				state.success = '';
 			});
 	},
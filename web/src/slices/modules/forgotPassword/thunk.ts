@@ -5,17 +5,19 @@ export const forgotPassword = createAsyncThunk(
 	'forgotPassword/forgotPassword',
 	async (email: string, { rejectWithValue }) => {
 		try {
-			const response = await axiosInstance.post('/auth/forgot-password', {
-				email,
-			});
+			const response = await axiosInstance.post(
+				'/authentication/password/forgot',
+				{
+					email,
+				}
+			);
 
-			const data = response.data;
-
-			if (response.status === 200) {
-				return data;
+			// the api answers the same whether the email has an account or not
+			if (response.status === 202) {
+				return { email };
 			}
 
-			return rejectWithValue(data.message);
+			return rejectWithValue(response.data);
 		} catch (error) {
 			return rejectWithValue(error);
 		}
@@ -0,0 +1,90 @@
+import axios from 'axios';
+
+export interface ProblemFieldError {
+	field: string;
+	code: string;
+	message: string;
+}
+
+// RFC 7807 body of every error response of the api
+export interface Problem {
+	type: string;
+	title: string;
+	status: number;
+	code: string;
+	detail?: string;
+	instance?: string;
+	request_id?: string;
+	errors?: ProblemFieldError[];
+}
+
+// plain object so it can travel through redux actions
+export interface ApiError {
+	message: string;
+	status?: number;
+	code?: string;
+	requestId?: string;
+	fieldErrors: Record<string, string>;
+}
+
+const isProblem = (data: unknown): data is Problem =>
+	typeof data === 'object' &&
+	data !== null &&
+	typeof (data as Problem).code === 'string' &&
+	typeof (data as Problem).status === 'number';
+
+export const toApiError = (error: unknown): ApiError => {
+	if (axios.isAxiosError(error)) {
+		if (!error.response) {
+			return {
+				message: error.request
+					? 'No response received from the server'
+					: error.message || 'Request configuration error',
+				fieldErrors: {},
+			};
+		}
+
+		const data = error.response.data;
+		if (isProblem(data)) {
+			const fieldErrors: Record<string, string> = {};
+			data.errors?.forEach(({ field, message }) => {
+				fieldErrors[field] = message;
+			});
+			return {
+				message: data.detail || data.title,
+				status: data.status,
+				code: data.code,
+				requestId: data.request_id,
+				fieldErrors,
+			};
+		}
+
+		return {
+			message: data?.message || 'An error occurred',
+			status: error.response.status,
+			fieldErrors: {},
+		};
+	}
+
+	if (isApiError(error)) {
+		return error;
+	}
+
+	return {
+		message:
+			typeof error === 'string'
+				? error
+				: (error as Error)?.message || 'An error occurred',
+		fieldErrors: {},
+	};
+};
+
+export const isApiError = (error: unknown): error is ApiError =>
+	typeof error === 'object' &&
+	error !== null &&
+	typeof (error as ApiError).message === 'string' &&
+	typeof (error as ApiError).fieldErrors === 'object';
+
+// message of the payload of a rejected thunk
+export const errorMessage = (payload: unknown): string =>
+	toApiError(payload).message;
@@ -1,4 +1,5 @@
 import axios from 'axios';
+import { toApiError } from './apiError';
 import NProgress from 'nprogress';
 import { store } from '../slices/store/store';
 import { setSession } from '../slices/modules/session/sessionSlice';
@@ -25,27 +26,15 @@ axiosInstance.interceptors.response.use(
 		return response;
 	},
 	function (error) {
-		let message;
-		if (axios.isAxiosError(error) && error.response) {
-			const status = error.response.status;
-			if (status === 401) {
-				message = 'Invalid credentials';
-				store.dispatch(setSession(null));
-				setAuthToken(null);
-				window.location.href = '/unauthorized';
-			} else {
-				message =
-					error.response.data?.error ||
-					error.response.data?.message ||
-					'An error occurred';
-			}
-		} else if (error.request) {
-			message = 'No response received from the server';
-		} else {
-			message = error.message || 'Request configuration error';
+		const apiError = toApiError(error);
+		if (apiError.status === 401) {
+			apiError.message = 'Invalid credentials';
+			store.dispatch(setSession(null));
+			setAuthToken(null);
+			window.location.href = '/unauthorized';
 		}
 		NProgress.done();
-		return Promise.reject(message);
+		return Promise.reject(apiError);
 	}
 );
 